@@ -11,12 +11,13 @@ import (
 	"src.elv.sh/pkg/daemon"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/shell"
+	"src.elv.sh/pkg/web"
 )
 
 func main() {
 	os.Exit(prog.Run(
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
 		prog.Composite(
-			buildinfo.Program, daemon.Program,
+			buildinfo.Program, daemon.Program, web.Program,
 			shell.Program{ActivateDaemon: daemon.Activate})))
 }
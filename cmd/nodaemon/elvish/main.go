@@ -9,12 +9,14 @@ import (
 	"src.elv.sh/pkg/buildinfo"
 	"src.elv.sh/pkg/prog"
 	"src.elv.sh/pkg/shell"
+	"src.elv.sh/pkg/web"
 )
 
 func main() {
 	os.Exit(prog.Run(
 		[3]*os.File{os.Stdin, os.Stdout, os.Stderr}, os.Args,
-		prog.Composite(buildinfo.Program, daemonStub{}, shell.Program{})))
+		prog.Composite(
+			buildinfo.Program, daemonStub{}, web.Program, shell.Program{})))
 }
 
 var errNoDaemon = errors.New("daemon is not supported in this build")
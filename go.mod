@@ -1,10 +1,13 @@
 module src.elv.sh
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/creack/pty v1.1.15
 	github.com/mattn/go-isatty v0.0.13
 	go.etcd.io/bbolt v1.3.6
-	golang.org/x/sys v0.0.0-20210820121016-41cdb8703e55
+	golang.org/x/sys v0.0.0-20211007075335-d3039528d8ac
+	gopkg.in/yaml.v3 v3.0.1
+	modernc.org/sqlite v1.14.2
 )
 
 go 1.16
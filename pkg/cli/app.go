@@ -66,9 +66,11 @@ type app struct {
 	TTY               TTY
 	MaxHeight         func() int
 	RPromptPersistent func() bool
+	Mouse             func() bool
 	BeforeReadline    []func()
 	AfterReadline     []func(string)
 	Highlighter       Highlighter
+	Autosuggester     Autosuggester
 	Prompt            Prompt
 	RPrompt           Prompt
 	GlobalBindings    tk.Bindings
@@ -77,6 +79,15 @@ type app struct {
 	State      State
 
 	codeArea tk.CodeArea
+
+	// Whether mouse reporting is on in the current ReadCode session.
+	mouse bool
+	// Mouse events waiting for a cursor position report.
+	pendingMouse []term.MouseEvent
+	// Widgets in the last rendered main buffer and the number of lines each
+	// of them occupies.
+	shownWidgets []tk.Widget
+	shownLines   []int
 }
 
 // State represents mutable state of an App.
@@ -96,9 +107,11 @@ func NewApp(spec AppSpec) App {
 		TTY:               spec.TTY,
 		MaxHeight:         spec.MaxHeight,
 		RPromptPersistent: spec.RPromptPersistent,
+		Mouse:             spec.Mouse,
 		BeforeReadline:    spec.BeforeReadline,
 		AfterReadline:     spec.AfterReadline,
 		Highlighter:       spec.Highlighter,
+		Autosuggester:     spec.Autosuggester,
 		Prompt:            spec.Prompt,
 		RPrompt:           spec.RPrompt,
 		GlobalBindings:    spec.GlobalBindings,
@@ -113,9 +126,15 @@ func NewApp(spec AppSpec) App {
 	if a.RPromptPersistent == nil {
 		a.RPromptPersistent = func() bool { return false }
 	}
+	if a.Mouse == nil {
+		a.Mouse = func() bool { return false }
+	}
 	if a.Highlighter == nil {
 		a.Highlighter = dummyHighlighter{}
 	}
+	if a.Autosuggester == nil {
+		a.Autosuggester = dummyAutosuggester{}
+	}
 	if a.Prompt == nil {
 		a.Prompt = NewConstPrompt(nil)
 	}
@@ -131,10 +150,12 @@ func NewApp(spec AppSpec) App {
 	a.codeArea = tk.NewCodeArea(tk.CodeAreaSpec{
 		Bindings:      spec.CodeAreaBindings,
 		Highlighter:   a.Highlighter.Get,
+		Autosuggester: a.Autosuggester.Get,
 		Prompt:        a.Prompt.Get,
 		RPrompt:       a.RPrompt.Get,
 		Abbreviations: spec.Abbreviations,
 		QuotePaste:    spec.QuotePaste,
+		OnPaste:       spec.OnPaste,
 		OnSubmit:      a.CommitCode,
 		State:         spec.CodeAreaState,
 
@@ -221,11 +242,7 @@ func (a *app) handle(e event) {
 			a.RedrawFull()
 		}
 	case term.Event:
-		target := a.ActiveWidget()
-		handled := target.Handle(e)
-		if !handled {
-			a.GlobalBindings.Handle(target, e)
-		}
+		a.handleTermEvent(e)
 		if !a.loop.HasReturned() {
 			a.triggerPrompts(false)
 			a.reqRead <- struct{}{}
@@ -233,6 +250,53 @@ func (a *app) handle(e event) {
 	}
 }
 
+func (a *app) handleTermEvent(e term.Event) {
+	switch e := e.(type) {
+	case term.MouseEvent:
+		if a.mouse {
+			// Mouse events report positions on the screen. Request the
+			// cursor position to find out where the main buffer starts.
+			a.pendingMouse = append(a.pendingMouse, e)
+			if len(a.pendingMouse) == 1 {
+				a.TTY.RequestCursorPosition()
+			}
+			return
+		}
+	case term.CursorPosition:
+		if len(a.pendingMouse) > 0 {
+			top := e.Line - 1 - a.TTY.Buffer().Dot.Line
+			for _, me := range a.pendingMouse {
+				a.handleMouse(me, top)
+			}
+			a.pendingMouse = nil
+			return
+		}
+	}
+	target := a.ActiveWidget()
+	handled := target.Handle(e)
+	if !handled {
+		a.GlobalBindings.Handle(target, e)
+	}
+}
+
+// Delivers a mouse event to the active widget if the mouse is over it, with
+// the position translated to be relative to the widget. The top argument is
+// the 0-based screen line where the main buffer starts.
+func (a *app) handleMouse(e term.MouseEvent, top int) {
+	line := e.Line - 1 - top
+	target := a.ActiveWidget()
+	for i, w := range a.shownWidgets {
+		if 0 <= line && line < a.shownLines[i] {
+			if w == target {
+				e.Pos = term.Pos{Line: line, Col: e.Col - 1}
+				target.Handle(e)
+			}
+			return
+		}
+		line -= a.shownLines[i]
+	}
+}
+
 func (a *app) triggerPrompts(force bool) {
 	a.Prompt.Trigger(force)
 	a.RPrompt.Trigger(force)
@@ -257,20 +321,24 @@ func (a *app) redraw(flag redrawFlag) {
 	isFinalRedraw := flag&finalRedraw != 0
 	if isFinalRedraw {
 		hideRPrompt := !a.RPromptPersistent()
-		if hideRPrompt {
-			a.codeArea.MutateState(func(s *tk.CodeAreaState) { s.HideRPrompt = true })
-		}
-		bufMain := renderApp([]tk.Widget{a.codeArea /* no addon */}, width, height)
-		if hideRPrompt {
-			a.codeArea.MutateState(func(s *tk.CodeAreaState) { s.HideRPrompt = false })
-		}
+		a.codeArea.MutateState(func(s *tk.CodeAreaState) {
+			s.HideRPrompt = hideRPrompt
+			s.HideSuggestion = true
+		})
+		bufMain, _ := renderApp([]tk.Widget{a.codeArea /* no addon */}, width, height)
+		a.codeArea.MutateState(func(s *tk.CodeAreaState) {
+			s.HideRPrompt = false
+			s.HideSuggestion = false
+		})
 		// Insert a newline after the buffer and position the cursor there.
 		bufMain.Extend(term.NewBuffer(width), true)
 
 		a.TTY.UpdateBuffer(bufNotes, bufMain, flag&fullRedraw != 0)
 		a.TTY.ResetBuffer()
 	} else {
-		bufMain := renderApp(append([]tk.Widget{a.codeArea}, addons...), width, height)
+		widgets := append([]tk.Widget{a.codeArea}, addons...)
+		bufMain, lines := renderApp(widgets, width, height)
+		a.shownWidgets, a.shownLines = widgets, lines
 		a.TTY.UpdateBuffer(bufNotes, bufMain, flag&fullRedraw != 0)
 	}
 }
@@ -291,22 +359,25 @@ func renderNotes(notes []string, width int) *term.Buffer {
 	return bb.Buffer()
 }
 
-// Renders the codearea, and uses the rest of the height for the listing.
-func renderApp(widgets []tk.Widget, width, height int) *term.Buffer {
+// Renders the codearea, and uses the rest of the height for the listing. It
+// also returns the number of lines each widget occupies.
+func renderApp(widgets []tk.Widget, width, height int) (*term.Buffer, []int) {
 	heights, focus := distributeHeight(widgets, width, height)
+	lines := make([]int, len(widgets))
 	var buf *term.Buffer
 	for i, w := range widgets {
 		if heights[i] == 0 {
 			continue
 		}
 		buf2 := w.Render(width, heights[i])
+		lines[i] = len(buf2.Lines)
 		if buf == nil {
 			buf = buf2
 		} else {
 			buf.Extend(buf2, i == focus)
 		}
 	}
-	return buf
+	return buf, lines
 }
 
 // Distributes the height among all the widgets. Returns the height for each
@@ -406,6 +477,12 @@ func (a *app) ReadCode() (string, error) {
 	}
 	defer restore()
 
+	a.mouse, a.pendingMouse = a.Mouse(), nil
+	if a.mouse {
+		a.TTY.SetMouse(true)
+		defer a.TTY.SetMouse(false)
+	}
+
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
@@ -443,7 +520,7 @@ func (a *app) ReadCode() (string, error) {
 		wg.Done()
 	}()
 
-	// Relay late updates from prompt, rprompt and highlighter.
+	// Relay late updates from prompt, rprompt, highlighter and autosuggester.
 	stopRelayLateUpdates := make(chan struct{})
 	defer close(stopRelayLateUpdates)
 	relayLateUpdates := func(ch <-chan struct{}) {
@@ -467,6 +544,7 @@ func (a *app) ReadCode() (string, error) {
 	relayLateUpdates(a.Prompt.LateUpdates())
 	relayLateUpdates(a.RPrompt.LateUpdates())
 	relayLateUpdates(a.Highlighter.LateUpdates())
+	relayLateUpdates(a.Autosuggester.LateUpdates())
 
 	// Trigger an initial prompt update.
 	a.triggerPrompts(true)
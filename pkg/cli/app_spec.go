@@ -10,17 +10,20 @@ type AppSpec struct {
 	TTY               TTY
 	MaxHeight         func() int
 	RPromptPersistent func() bool
+	Mouse             func() bool
 	BeforeReadline    []func()
 	AfterReadline     []func(string)
 
-	Highlighter Highlighter
-	Prompt      Prompt
-	RPrompt     Prompt
+	Highlighter   Highlighter
+	Autosuggester Autosuggester
+	Prompt        Prompt
+	RPrompt       Prompt
 
 	GlobalBindings   tk.Bindings
 	CodeAreaBindings tk.Bindings
 	Abbreviations    func(f func(abbr, full string))
 	QuotePaste       func() bool
+	OnPaste          func(text string) bool
 
 	SmallWordAbbreviations func(f func(abbr, full string))
 
@@ -46,6 +49,23 @@ func (dummyHighlighter) Get(code string) (ui.Text, []error) {
 
 func (dummyHighlighter) LateUpdates() <-chan struct{} { return nil }
 
+// Autosuggester represents a source of suggestions for the rest of the code,
+// whose result can be delivered asynchronously.
+type Autosuggester interface {
+	// Get returns the text suggested to be appended to the code, or an empty
+	// string if there is no suggestion.
+	Get(code string) string
+	// LateUpdates returns a channel for delivering late updates.
+	LateUpdates() <-chan struct{}
+}
+
+// An Autosuggester implementation that never suggests anything.
+type dummyAutosuggester struct{}
+
+func (dummyAutosuggester) Get(code string) string { return "" }
+
+func (dummyAutosuggester) LateUpdates() <-chan struct{} { return nil }
+
 // Prompt represents a prompt whose result can be delivered asynchronously.
 type Prompt interface {
 	// Trigger requests a re-computation of the prompt. The force flag is set
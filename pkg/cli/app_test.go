@@ -241,6 +241,62 @@ func withHighlighter(hl Highlighter) func(*AppSpec, TTYCtrl) {
 	return WithSpec(func(spec *AppSpec) { spec.Highlighter = hl })
 }
 
+func TestReadCode_ShowsSuggestion(t *testing.T) {
+	f := Setup(withAutosuggester(testAutosuggester{
+		get: func(code string) string { return " suggested" },
+	}))
+	defer f.Stop()
+
+	feedInput(f.TTY, "code")
+	wantBuf := bb().Write("code").SetDotHere().Write(" suggested", ui.Dim).Buffer()
+	f.TTY.TestBuffer(t, wantBuf)
+}
+
+func TestReadCode_RedrawsOnLateUpdateFromAutosuggester(t *testing.T) {
+	suggestionCh := make(chan string, 1)
+	suggestion := ""
+	as := testAutosuggester{
+		get: func(code string) string {
+			select {
+			case suggestion = <-suggestionCh:
+			default:
+			}
+			return suggestion
+		},
+		lateUpdates: make(chan struct{}),
+	}
+	f := Setup(withAutosuggester(as))
+	defer f.Stop()
+
+	feedInput(f.TTY, "code")
+	f.TTY.TestBuffer(t, bb().Write("code").SetDotHere().Buffer())
+
+	suggestionCh <- "s"
+	as.lateUpdates <- struct{}{}
+	f.TTY.TestBuffer(t, bb().Write("code").SetDotHere().Write("s", ui.Dim).Buffer())
+}
+
+func TestReadCode_HidesSuggestionInFinalRedraw(t *testing.T) {
+	f := Setup(withAutosuggester(testAutosuggester{
+		get: func(code string) string { return "s" },
+	}), WithSpec(func(spec *AppSpec) {
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "code", Dot: 4}
+	}))
+	defer f.Stop()
+
+	f.TTY.Inject(term.K('\n'))
+
+	wantBuf := bb().
+		Write("code").          // no suggestion
+		Newline().SetDotHere(). // cursor on newline in final redraw
+		Buffer()
+	f.TTY.TestBuffer(t, wantBuf)
+}
+
+func withAutosuggester(as Autosuggester) func(*AppSpec, TTYCtrl) {
+	return WithSpec(func(spec *AppSpec) { spec.Autosuggester = as })
+}
+
 func TestReadCode_ShowsPrompt(t *testing.T) {
 	f := Setup(WithSpec(func(spec *AppSpec) {
 		spec.Prompt = NewConstPrompt(ui.T("> "))
@@ -532,6 +588,44 @@ func TestReadCode_DoesNotCrashWithNilTTY(t *testing.T) {
 	defer f.Stop()
 }
 
+func TestReadCode_TurnsOnMouseIfEnabled(t *testing.T) {
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.Mouse = func() bool { return true }
+	}))
+	f.TestTTY(t /* nothing */)
+	if !f.TTY.Mouse() {
+		t.Errorf("mouse not turned on during ReadCode")
+	}
+	f.Stop()
+	if f.TTY.Mouse() {
+		t.Errorf("mouse not turned off after ReadCode")
+	}
+}
+
+func TestReadCode_DeliversMouseEventsToActiveWidget(t *testing.T) {
+	f := Setup(WithSpec(func(spec *AppSpec) {
+		spec.Mouse = func() bool { return true }
+		spec.CodeAreaState.Buffer = tk.CodeBuffer{Content: "code", Dot: 4}
+		spec.State.Addons = []tk.Widget{
+			tk.NewCodeArea(tk.CodeAreaSpec{
+				Prompt: func() ui.Text { return ui.T("addon> ") },
+				State: tk.CodeAreaState{
+					Buffer: tk.CodeBuffer{Content: "input", Dot: 5}},
+			}),
+		}
+	}))
+	defer f.Stop()
+	f.TestTTY(t, "code\n", "addon> input", term.DotHere)
+
+	// The fake TTY reports positions as if the buffer is at the top of the
+	// screen, and positions reported by the terminal are 1-based.
+	f.TTY.Inject(
+		// Clicking the main code area has no effect.
+		term.MouseEvent{Pos: term.Pos{Line: 1, Col: 2}, Down: true},
+		term.MouseEvent{Pos: term.Pos{Line: 2, Col: 10}, Down: true})
+	f.TestTTY(t, "code\n", "addon> in", term.DotHere, "put")
+}
+
 // Other properties.
 
 func TestReadCode_DoesNotLockWithALotOfInputsWithNewlines(t *testing.T) {
@@ -593,6 +687,20 @@ func (hl testHighlighter) LateUpdates() <-chan struct{} {
 	return hl.lateUpdates
 }
 
+// An Autosuggester implementation useful for testing.
+type testAutosuggester struct {
+	get         func(code string) string
+	lateUpdates chan struct{}
+}
+
+func (as testAutosuggester) Get(code string) string {
+	return as.get(code)
+}
+
+func (as testAutosuggester) LateUpdates() <-chan struct{} {
+	return as.lateUpdates
+}
+
 // A Prompt implementation useful for testing.
 type testPrompt struct {
 	trigger     func(force bool)
@@ -40,6 +40,8 @@ type fakeTTY struct {
 	sigCh chan os.Signal
 	// Argument that SetRawInput got.
 	raw int
+	// Argument that SetMouse got.
+	mouse bool
 	// Number of times the TTY screen has been cleared, incremented in
 	// ClearScreen.
 	cleared int
@@ -94,6 +96,23 @@ func (t *fakeTTY) SetRawInput(n int) {
 	t.raw = n
 }
 
+// Records the argument.
+func (t *fakeTTY) SetMouse(on bool) {
+	t.mouse = on
+}
+
+// Injects a CursorPosition event for the dot of the last recorded buffer, as
+// if the buffer is shown at the top of the screen.
+func (t *fakeTTY) RequestCursorPosition() {
+	t.bufMutex.RLock()
+	var dot term.Pos
+	if len(t.bufs) > 0 && t.bufs[len(t.bufs)-1] != nil {
+		dot = t.bufs[len(t.bufs)-1].Dot
+	}
+	t.bufMutex.RUnlock()
+	TTYCtrl{t}.inject(term.CursorPosition{Line: dot.Line + 1, Col: dot.Col + 1})
+}
+
 // Closes eventCh.
 func (t *fakeTTY) CloseReader() {
 	t.eventChMutex.Lock()
@@ -207,6 +226,11 @@ func (t TTYCtrl) RawInput() int {
 	return t.raw
 }
 
+// Mouse returns the argument in the last call to the SetMouse method of TTY.
+func (t TTYCtrl) Mouse() bool {
+	return t.mouse
+}
+
 // ScreenCleared returns the number of times ClearScreen has been called on the
 // TTY.
 func (t TTYCtrl) ScreenCleared() int {
@@ -10,7 +10,10 @@ import (
 type DB interface {
 	NextCmdSeq() (int, error)
 	AddCmd(cmd string) (int, error)
+	AddCmdWithMeta(cmd storedefs.Cmd) (int, error)
+	SetCmdResult(seq int, duration float64, status string) error
 	CmdsWithSeq(from, upto int) ([]storedefs.Cmd, error)
+	QueryCmds(from, upto int, q storedefs.CmdQuery) ([]storedefs.Cmd, error)
 	PrevCmd(upto int, prefix string) (storedefs.Cmd, error)
 	NextCmd(from int, prefix string) (storedefs.Cmd, error)
 }
@@ -26,12 +29,16 @@ type FaultyInMemoryDB interface {
 
 // NewFaultyInMemoryDB creates a new FaultyInMemoryDB with the given commands.
 func NewFaultyInMemoryDB(cmds ...string) FaultyInMemoryDB {
-	return &testDB{cmds: cmds}
+	db := &testDB{}
+	for _, cmd := range cmds {
+		db.cmds = append(db.cmds, storedefs.Cmd{Text: cmd, Seq: len(db.cmds)})
+	}
+	return db
 }
 
 // Implementation of FaultyInMemoryDB.
 type testDB struct {
-	cmds        []string
+	cmds        []storedefs.Cmd
 	oneOffError error
 }
 
@@ -50,11 +57,28 @@ func (s *testDB) NextCmdSeq() (int, error) {
 }
 
 func (s *testDB) AddCmd(cmd string) (int, error) {
+	return s.AddCmdWithMeta(storedefs.Cmd{Text: cmd})
+}
+
+func (s *testDB) AddCmdWithMeta(cmd storedefs.Cmd) (int, error) {
 	if s.oneOffError != nil {
 		return -1, s.error()
 	}
+	cmd.Seq = len(s.cmds)
 	s.cmds = append(s.cmds, cmd)
-	return len(s.cmds) - 1, nil
+	return cmd.Seq, nil
+}
+
+func (s *testDB) SetCmdResult(seq int, duration float64, status string) error {
+	if err := s.error(); err != nil {
+		return err
+	}
+	if seq < 0 || seq >= len(s.cmds) {
+		return storedefs.ErrNoMatchingCmd
+	}
+	s.cmds[seq].Duration = duration
+	s.cmds[seq].Status = status
+	return nil
 }
 
 func (s *testDB) CmdsWithSeq(from, upto int) ([]storedefs.Cmd, error) {
@@ -69,11 +93,19 @@ func (s *testDB) CmdsWithSeq(from, upto int) ([]storedefs.Cmd, error) {
 	}
 	var cmds []storedefs.Cmd
 	for i := from; i < upto; i++ {
-		cmds = append(cmds, storedefs.Cmd{Text: s.cmds[i], Seq: i})
+		cmds = append(cmds, s.cmds[i])
 	}
 	return cmds, nil
 }
 
+func (s *testDB) QueryCmds(from, upto int, q storedefs.CmdQuery) ([]storedefs.Cmd, error) {
+	cmds, err := s.CmdsWithSeq(from, upto)
+	if err != nil {
+		return nil, err
+	}
+	return queryCmds(cmds, q), nil
+}
+
 func (s *testDB) PrevCmd(upto int, prefix string) (storedefs.Cmd, error) {
 	if s.oneOffError != nil {
 		return storedefs.Cmd{}, s.error()
@@ -82,8 +114,8 @@ func (s *testDB) PrevCmd(upto int, prefix string) (storedefs.Cmd, error) {
 		upto = len(s.cmds)
 	}
 	for i := upto - 1; i >= 0; i-- {
-		if strings.HasPrefix(s.cmds[i], prefix) {
-			return storedefs.Cmd{Text: s.cmds[i], Seq: i}, nil
+		if strings.HasPrefix(s.cmds[i].Text, prefix) {
+			return s.cmds[i], nil
 		}
 	}
 	return storedefs.Cmd{}, storedefs.ErrNoMatchingCmd
@@ -97,8 +129,8 @@ func (s *testDB) NextCmd(from int, prefix string) (storedefs.Cmd, error) {
 		from = 0
 	}
 	for i := from; i < len(s.cmds); i++ {
-		if strings.HasPrefix(s.cmds[i], prefix) {
-			return storedefs.Cmd{Text: s.cmds[i], Seq: i}, nil
+		if strings.HasPrefix(s.cmds[i].Text, prefix) {
+			return s.cmds[i], nil
 		}
 	}
 	return storedefs.Cmd{}, storedefs.ErrNoMatchingCmd
@@ -23,8 +23,16 @@ func (s dbStore) AllCmds() ([]storedefs.Cmd, error) {
 	return s.db.CmdsWithSeq(0, s.upper)
 }
 
+func (s dbStore) QueryCmds(q storedefs.CmdQuery) ([]storedefs.Cmd, error) {
+	return s.db.QueryCmds(0, s.upper, q)
+}
+
 func (s dbStore) AddCmd(cmd storedefs.Cmd) (int, error) {
-	return s.db.AddCmd(cmd.Text)
+	return s.db.AddCmdWithMeta(cmd)
+}
+
+func (s dbStore) SetCmdResult(seq int, duration float64, status string) error {
+	return s.db.SetCmdResult(seq, duration, status)
 }
 
 func (s dbStore) Cursor(prefix string) Cursor {
@@ -21,10 +21,17 @@ type hybridStore struct {
 
 func (s hybridStore) AddCmd(cmd storedefs.Cmd) (int, error) {
 	seq, err := s.shared.AddCmd(cmd)
-	s.session.AddCmd(storedefs.Cmd{Text: cmd.Text, Seq: seq})
+	cmd.Seq = seq
+	s.session.AddCmd(cmd)
 	return seq, err
 }
 
+func (s hybridStore) SetCmdResult(seq int, duration float64, status string) error {
+	err := s.shared.SetCmdResult(seq, duration, status)
+	s.session.SetCmdResult(seq, duration, status)
+	return err
+}
+
 func (s hybridStore) AllCmds() ([]storedefs.Cmd, error) {
 	shared, err := s.shared.AllCmds()
 	session, err2 := s.session.AllCmds()
@@ -37,6 +44,15 @@ func (s hybridStore) AllCmds() ([]storedefs.Cmd, error) {
 	return append(shared, session...), err
 }
 
+func (s hybridStore) QueryCmds(q storedefs.CmdQuery) ([]storedefs.Cmd, error) {
+	shared, err := s.shared.QueryCmds(q)
+	session, err2 := s.session.QueryCmds(q)
+	if err == nil {
+		err = err2
+	}
+	return append(shared, session...), err
+}
+
 func (s hybridStore) Cursor(prefix string) Cursor {
 	return &hybridStoreCursor{
 		s.shared.Cursor(prefix), s.session.Cursor(prefix), false}
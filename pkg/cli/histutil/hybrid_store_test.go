@@ -76,6 +76,28 @@ func TestHybridStore_AddCmd_AddsToSessionEvenIfDBErrors(t *testing.T) {
 	}
 }
 
+func TestHybridStore_SetCmdResult_UpdatesBothDBAndSession(t *testing.T) {
+	db := NewFaultyInMemoryDB("shared 1")
+	f := mustNewHybridStore(db)
+
+	seq, _ := f.AddCmd(storedefs.Cmd{Text: "session 1", Dir: "/tmp"})
+	err := f.SetCmdResult(seq, 1.5, storedefs.StatusOK)
+	if err != nil {
+		t.Errorf("SetCmdResult -> error %v, want nil", err)
+	}
+
+	wantCmd := storedefs.Cmd{
+		Text: "session 1", Seq: 1, Dir: "/tmp", Duration: 1.5, Status: "ok"}
+	wantDBCmds := []storedefs.Cmd{{Text: "shared 1", Seq: 0}, wantCmd}
+	if dbCmds, _ := db.CmdsWithSeq(-1, -1); !reflect.DeepEqual(dbCmds, wantDBCmds) {
+		t.Errorf("DB commands = %v, want %v", dbCmds, wantDBCmds)
+	}
+	wantAllCmds := []storedefs.Cmd{{Text: "shared 1", Seq: 0}, wantCmd}
+	if allCmds, _ := f.AllCmds(); !reflect.DeepEqual(allCmds, wantAllCmds) {
+		t.Errorf("AllCmd -> %v, want %v", allCmds, wantAllCmds)
+	}
+}
+
 func TestHybridStore_AllCmds_IncludesFrozenSharedAndNewlyAdded(t *testing.T) {
 	db := NewFaultyInMemoryDB("shared 1")
 	f := mustNewHybridStore(db)
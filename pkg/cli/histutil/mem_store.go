@@ -21,6 +21,10 @@ func (s *memStore) AllCmds() ([]storedefs.Cmd, error) {
 	return s.cmds, nil
 }
 
+func (s *memStore) QueryCmds(q storedefs.CmdQuery) ([]storedefs.Cmd, error) {
+	return queryCmds(s.cmds, q), nil
+}
+
 func (s *memStore) AddCmd(cmd storedefs.Cmd) (int, error) {
 	if cmd.Seq < 0 {
 		cmd.Seq = len(s.cmds) + 1
@@ -29,6 +33,17 @@ func (s *memStore) AddCmd(cmd storedefs.Cmd) (int, error) {
 	return cmd.Seq, nil
 }
 
+func (s *memStore) SetCmdResult(seq int, duration float64, status string) error {
+	for i := range s.cmds {
+		if s.cmds[i].Seq == seq {
+			s.cmds[i].Duration = duration
+			s.cmds[i].Status = status
+			return nil
+		}
+	}
+	return storedefs.ErrNoMatchingCmd
+}
+
 func (s *memStore) Cursor(prefix string) Cursor {
 	return &memStoreCursor{s.cmds, prefix, len(s.cmds)}
 }
@@ -12,14 +12,30 @@ type Store interface {
 	// Depending on the implementation, the Store might respect cmd.Seq and
 	// return it as is, or allocate another sequence number.
 	AddCmd(cmd storedefs.Cmd) (int, error)
+	// SetCmdResult records the duration and status of the command with the
+	// given sequence number.
+	SetCmdResult(seq int, duration float64, status string) error
 	// AllCmds returns all commands kept in the store.
 	AllCmds() ([]storedefs.Cmd, error)
+	// QueryCmds returns the commands kept in the store that match the query.
+	QueryCmds(q storedefs.CmdQuery) ([]storedefs.Cmd, error)
 	// Cursor returns a cursor that iterating through commands with the given
 	// prefix. The cursor is initially placed just after the last command in the
 	// store.
 	Cursor(prefix string) Cursor
 }
 
+// Returns the commands that match the query.
+func queryCmds(cmds []storedefs.Cmd, q storedefs.CmdQuery) []storedefs.Cmd {
+	var matched []storedefs.Cmd
+	for _, cmd := range cmds {
+		if q.Match(cmd) {
+			matched = append(matched, cmd)
+		}
+	}
+	return matched
+}
+
 // Cursor is used to navigate a Store.
 type Cursor interface {
 	// Prev moves the cursor to the previous command.
@@ -2,6 +2,7 @@ package modes
 
 import (
 	"errors"
+	"sync"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/tk"
@@ -13,6 +14,15 @@ import (
 // candidates. It is based on the ComboBox widget.
 type Completion interface {
 	tk.ComboBox
+	// SetItems replaces all the candidates, keeping the current filter and the
+	// selected candidate if it is still shown. If loading is true, the mode
+	// indicates that more candidates are still being generated. It is safe to
+	// call SetItems from any goroutine.
+	SetItems(items []CompletionItem, loading bool)
+	// SetContext sets the name of the completion context and the range of the
+	// code replaced by candidates. It is safe to call SetContext from any
+	// goroutine.
+	SetContext(name string, replace diag.Ranging)
 }
 
 // CompletionSpec specifies the configuration for the completion mode.
@@ -22,6 +32,12 @@ type CompletionSpec struct {
 	Replace  diag.Ranging
 	Items    []CompletionItem
 	Filter   FilterSpec
+	// If true, the mode starts in the loading state, and Items may be empty.
+	// Name and Replace may also be left empty, and set later with SetContext.
+	Loading bool
+	// Called when the mode is dismissed, either after accepting a candidate or
+	// after being closed.
+	OnDismiss func()
 }
 
 // CompletionItem represents a completion item, also known as a candidate.
@@ -37,6 +53,22 @@ type CompletionItem struct {
 type completion struct {
 	tk.ComboBox
 	attached tk.CodeArea
+	state    *completionState
+}
+
+type completionState struct {
+	filter    FilterSpec
+	onDismiss func()
+
+	mutex   sync.Mutex
+	items   []CompletionItem
+	loading bool
+
+	// Guarded by a separate mutex, since OnSelect may be called while mutex is
+	// held.
+	contextMutex sync.Mutex
+	name         string
+	replace      diag.Ranging
 }
 
 var errNoCandidates = errors.New("no candidates")
@@ -47,22 +79,40 @@ func NewCompletion(app cli.App, cfg CompletionSpec) (Completion, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(cfg.Items) == 0 {
+	if len(cfg.Items) == 0 && !cfg.Loading {
 		return nil, errNoCandidates
 	}
+	state := &completionState{filter: cfg.Filter, onDismiss: cfg.OnDismiss,
+		name: cfg.Name, replace: cfg.Replace, items: cfg.Items, loading: cfg.Loading}
 	w := tk.NewComboBox(tk.ComboBoxSpec{
 		CodeArea: tk.CodeAreaSpec{
-			Prompt:      modePrompt(" COMPLETING "+cfg.Name+" ", true),
+			Prompt: func() ui.Text {
+				state.contextMutex.Lock()
+				text := " COMPLETING "
+				if state.name != "" {
+					text += state.name + " "
+				}
+				state.contextMutex.Unlock()
+				state.mutex.Lock()
+				defer state.mutex.Unlock()
+				if state.loading {
+					text += "(loading) "
+				}
+				return modeLine(text, true)
+			},
 			Highlighter: cfg.Filter.Highlighter,
 		},
 		ListBox: tk.ListBoxSpec{
 			Horizontal: true,
 			Bindings:   cfg.Bindings,
 			OnSelect: func(it tk.Items, i int) {
-				text := it.(completionItems)[i].ToInsert
+				text := it.(completionItems).items[i].ToInsert
+				state.contextMutex.Lock()
+				replace := state.replace
+				state.contextMutex.Unlock()
 				codeArea.MutateState(func(s *tk.CodeAreaState) {
 					s.Pending = tk.PendingCode{
-						From: cfg.Replace.From, To: cfg.Replace.To, Content: text}
+						From: replace.From, To: replace.To, Content: text}
 				})
 			},
 			OnAccept: func(it tk.Items, i int) {
@@ -72,30 +122,77 @@ func NewCompletion(app cli.App, cfg CompletionSpec) (Completion, error) {
 			ExtendStyle: true,
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
-			w.ListBox().Reset(filterCompletionItems(cfg.Items, cfg.Filter.makePredicate(p)), 0)
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			w.ListBox().Reset(filterCompletionItems(state.items, cfg.Filter, p), 0)
 		},
 	})
-	return completion{w, codeArea}, nil
+	return completion{w, codeArea, state}, nil
+}
+
+func (w completion) SetItems(items []CompletionItem, loading bool) {
+	w.state.mutex.Lock()
+	defer w.state.mutex.Unlock()
+	w.state.items, w.state.loading = items, loading
+
+	old := w.ListBox().CopyState()
+	filtered := filterCompletionItems(items,
+		w.state.filter, w.CodeArea().CopyState().Buffer.Content)
+	selected := 0
+	if 0 <= old.Selected && old.Selected < old.Items.Len() {
+		toInsert := old.Items.(completionItems).items[old.Selected].ToInsert
+		for i, item := range filtered.items {
+			if item.ToInsert == toInsert {
+				selected = i
+				break
+			}
+		}
+	}
+	w.ListBox().Reset(filtered, selected)
+}
+
+func (w completion) SetContext(name string, replace diag.Ranging) {
+	w.state.contextMutex.Lock()
+	defer w.state.contextMutex.Unlock()
+	w.state.name, w.state.replace = name, replace
 }
 
 func (w completion) Dismiss() {
 	w.attached.MutateState(func(s *tk.CodeAreaState) { s.Pending = tk.PendingCode{} })
+	if w.state.onDismiss != nil {
+		w.state.onDismiss()
+	}
 }
 
-type completionItems []CompletionItem
+type completionItems struct {
+	items []CompletionItem
+	// Byte indices of the matched runes in ToShow of each item, if the filter
+	// reports them.
+	positions [][]int
+}
 
-func filterCompletionItems(all []CompletionItem, p func(string) bool) completionItems {
-	var filtered []CompletionItem
-	for _, candidate := range all {
-		if p(candidate.ToShow) {
-			filtered = append(filtered, candidate)
+func filterCompletionItems(all []CompletionItem, spec FilterSpec, p string) completionItems {
+	matches := spec.filter(p, len(all), func(i int) string { return all[i].ToShow })
+	filtered := make([]CompletionItem, len(matches))
+	var positions [][]int
+	if spec.Scorer != nil {
+		positions = make([][]int, len(matches))
+	}
+	for i, match := range matches {
+		filtered[i] = all[match.index]
+		if positions != nil {
+			positions[i] = match.positions
 		}
 	}
-	return filtered
+	return completionItems{filtered, positions}
 }
 
 func (it completionItems) Show(i int) ui.Text {
-	return ui.Text{&ui.Segment{Style: it[i].ShowStyle, Text: it[i].ToShow}}
+	t := ui.Text{&ui.Segment{Style: it.items[i].ShowStyle, Text: it.items[i].ToShow}}
+	if it.positions != nil {
+		t = highlightMatched(t, 0, it.positions[i])
+	}
+	return t
 }
 
-func (it completionItems) Len() int { return len(it) }
+func (it completionItems) Len() int { return len(it.items) }
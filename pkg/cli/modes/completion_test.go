@@ -6,6 +6,7 @@ import (
 	"src.elv.sh/pkg/cli"
 	. "src.elv.sh/pkg/cli/clitest"
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/ui"
 )
@@ -25,6 +26,37 @@ func TestCompletion_Filter(t *testing.T) {
 	)
 }
 
+func TestCompletion_Scorer(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+	w, _ := NewCompletion(f.App, CompletionSpec{
+		Name:    "WORD",
+		Replace: diag.Ranging{From: 0, To: 0},
+		Items: []CompletionItem{
+			{ToShow: "fxxb", ToInsert: "fxxb"},
+			{ToShow: "foo bar", ToInsert: "'foo bar'",
+				ShowStyle: ui.Style{Foreground: ui.Blue}},
+		},
+		Filter: FilterSpec{Scorer: fuzzyScorer},
+	})
+	f.App.PushAddon(w)
+	f.TTY.Inject(term.K('f'), term.K('b'))
+	f.TestTTY(t,
+		"'foo bar'\n", Styles,
+		"_________",
+		" COMPLETING WORD  fb", Styles,
+		"*****************   ", term.DotHere, "\n",
+		"foo bar  fxxb", completionScorerStyles,
+		"U###U##  _  _",
+	)
+}
+
+var completionScorerStyles = ui.RuneStylesheet{
+	'_': ui.Underlined,
+	'#': ui.Stylings(ui.Inverse, ui.FgBlue),
+	'U': ui.Stylings(ui.Inverse, ui.FgBlue, ui.Underlined),
+}
+
 func TestCompletion_Accept(t *testing.T) {
 	f := setupStartedCompletion(t)
 	defer f.Stop()
@@ -42,6 +74,58 @@ func TestCompletion_Dismiss(t *testing.T) {
 	f.TestTTY(t /* nothing */)
 }
 
+func TestCompletion_Loading(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+	dismissed := false
+	// The name and the range to replace can be set later.
+	w, err := NewCompletion(f.App, CompletionSpec{
+		Loading:   true,
+		OnDismiss: func() { dismissed = true },
+	})
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+	f.App.PushAddon(w)
+	f.App.Redraw()
+	f.TestTTY(t,
+		"\n",
+		" COMPLETING (loading)  ", Styles,
+		"********************** ", term.DotHere,
+	)
+
+	w.SetContext("WORD", diag.Ranging{From: 0, To: 0})
+	w.SetItems([]CompletionItem{{ToShow: "foo", ToInsert: "foo"}}, true)
+	w.ListBox().Select(tk.Next)
+	f.App.Redraw()
+	f.TestTTY(t,
+		"foo\n", Styles,
+		"___",
+		" COMPLETING WORD (loading)  ", Styles,
+		"*************************** ", term.DotHere, "\n",
+		"foo", Styles,
+		"+++",
+	)
+
+	// The selected candidate stays selected when more candidates come.
+	w.SetItems([]CompletionItem{
+		{ToShow: "bar", ToInsert: "bar"}, {ToShow: "foo", ToInsert: "foo"}}, false)
+	f.App.Redraw()
+	f.TestTTY(t,
+		"foo\n", Styles,
+		"___",
+		" COMPLETING WORD  ", Styles,
+		"***************** ", term.DotHere, "\n",
+		"bar  foo", Styles,
+		"     +++",
+	)
+
+	f.App.PopAddon()
+	if !dismissed {
+		t.Errorf("OnDismiss not called")
+	}
+}
+
 func TestNewCompletion_NoItems(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
@@ -1,7 +1,9 @@
 package modes
 
 import (
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/ui"
 )
@@ -11,13 +13,93 @@ type FilterSpec struct {
 	// Called with the filter text to get the filter predicate. If nil, the
 	// predicate performs substring match.
 	Maker func(string) func(string) bool
+	// Called with the filter text to get a function that scores items. The
+	// function returns whether the item matches, its score, and the byte
+	// indices of the matched runes. If not nil, it is used instead of Maker,
+	// and modes that support ranking sort matching items by their scores and
+	// highlight the matched runes.
+	Scorer func(string) func(string) (score int, positions []int, ok bool)
 	// Highlighter for the filter. If nil, the filter will not be highlighted.
 	Highlighter func(string) (ui.Text, []error)
 }
 
 func (f FilterSpec) makePredicate(p string) func(string) bool {
+	if f.Scorer != nil {
+		score := f.Scorer(p)
+		return func(s string) bool {
+			_, _, ok := score(s)
+			return ok
+		}
+	}
 	if f.Maker == nil {
 		return func(s string) bool { return strings.Contains(s, p) }
 	}
 	return f.Maker(p)
 }
+
+// An item that matches the filter: its index among all the items, and the
+// byte indices of the matched runes in its text.
+type filterMatch struct {
+	index     int
+	positions []int
+}
+
+// Filters n items, whose texts are given by the text function. If the spec has
+// a Scorer, the matches are sorted by decreasing scores, with ties keeping the
+// original order; otherwise they are in the original order.
+func (f FilterSpec) filter(p string, n int, text func(int) string) []filterMatch {
+	var matches []filterMatch
+	if f.Scorer == nil {
+		pred := f.makePredicate(p)
+		for i := 0; i < n; i++ {
+			if pred(text(i)) {
+				matches = append(matches, filterMatch{index: i})
+			}
+		}
+		return matches
+	}
+	score := f.Scorer(p)
+	var scores []int
+	for i := 0; i < n; i++ {
+		if s, positions, ok := score(text(i)); ok {
+			matches = append(matches, filterMatch{i, positions})
+			scores = append(scores, s)
+		}
+	}
+	sort.Stable(byScore{matches, scores})
+	return matches
+}
+
+type byScore struct {
+	matches []filterMatch
+	scores  []int
+}
+
+func (b byScore) Len() int           { return len(b.matches) }
+func (b byScore) Less(i, j int) bool { return b.scores[i] > b.scores[j] }
+func (b byScore) Swap(i, j int) {
+	b.matches[i], b.matches[j] = b.matches[j], b.matches[i]
+	b.scores[i], b.scores[j] = b.scores[j], b.scores[i]
+}
+
+var stylingForMatchedRune = ui.Underlined
+
+// Applies stylingForMatchedRune to the runes of t at the given byte indices,
+// offset by offset.
+func highlightMatched(t ui.Text, offset int, positions []int) ui.Text {
+	if len(positions) == 0 {
+		return t
+	}
+	s := t.String()
+	var indices []int
+	for _, pos := range positions {
+		pos += offset
+		_, size := utf8.DecodeRuneInString(s[pos:])
+		indices = append(indices, pos, pos+size)
+	}
+	parts := t.Partition(indices...)
+	for i := 1; i < len(parts); i += 2 {
+		parts[i] = ui.StyleText(parts[i], stylingForMatchedRune)
+	}
+	return ui.Concat(parts...)
+}
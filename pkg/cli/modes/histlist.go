@@ -2,9 +2,12 @@ package modes
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/fsutil"
 	"src.elv.sh/pkg/store/storedefs"
 	"src.elv.sh/pkg/ui"
 )
@@ -26,6 +29,14 @@ type HistlistSpec struct {
 	Dedup func() bool
 	// Configuration for the filter.
 	Filter FilterSpec
+	// Query is called with the filter text to split it into a query on the
+	// metadata of commands and the rest of the filter text, which is used to
+	// filter the text of commands. If nil, the entire filter text is used to
+	// filter the text of commands.
+	Query func(string) (storedefs.CmdQuery, string)
+	// QueryCmds is called to retrieve commands that match a non-zero query.
+	// Must be non-nil if Query is non-nil.
+	QueryCmds func(storedefs.CmdQuery) ([]storedefs.Cmd, error)
 }
 
 // NewHistlist creates a new histlist mode.
@@ -45,13 +56,16 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 	if err != nil {
 		return nil, fmt.Errorf("db error: %v", err.Error())
 	}
-	last := map[string]int{}
-	for i, cmd := range cmds {
-		last[cmd.Text] = i
-	}
-	cmdItems := histlistItems{cmds, last}
+	allItems := newHistlistItems(cmds)
+	// Commands matching the last non-zero query. They are only retrieved again
+	// when the query changes, instead of on every change to the filter text.
+	var (
+		lastQuery  storedefs.CmdQuery
+		queryItems histlistItems
+	)
 
-	w := tk.NewComboBox(tk.ComboBoxSpec{
+	var w tk.ComboBox
+	w = tk.NewComboBox(tk.ComboBoxSpec{
 		CodeArea: tk.CodeAreaSpec{
 			Prompt: func() ui.Text {
 				content := " HISTORY "
@@ -65,7 +79,13 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 		ListBox: tk.ListBoxSpec{
 			Bindings: spec.Bindings,
 			OnAccept: func(it tk.Items, i int) {
-				text := it.(histlistItems).entries[i].Text
+				// When there are marked entries, insert all of them, each on
+				// its own line.
+				var texts []string
+				for _, i := range acceptedIndices(w.ListBox(), i) {
+					texts = append(texts, it.(histlistItems).entries[i].Text)
+				}
+				text := strings.Join(texts, "\n")
 				codeArea.MutateState(func(s *tk.CodeAreaState) {
 					buf := &s.Buffer
 					if buf.Content == "" {
@@ -78,7 +98,22 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 			},
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
-			it := cmdItems.filter(spec.Filter.makePredicate(p), spec.Dedup())
+			items := allItems
+			if spec.Query != nil {
+				var q storedefs.CmdQuery
+				q, p = spec.Query(p)
+				if q != (storedefs.CmdQuery{}) {
+					if q != lastQuery {
+						cmds, err := spec.QueryCmds(q)
+						if err != nil {
+							app.Notify(fmt.Sprintf("db error: %v", err))
+						}
+						lastQuery, queryItems = q, newHistlistItems(cmds)
+					}
+					items = queryItems
+				}
+			}
+			it := items.filter(spec.Filter, p, spec.Dedup())
 			w.ListBox().Reset(it, it.Len()-1)
 		},
 	})
@@ -88,26 +123,86 @@ func NewHistlist(app cli.App, spec HistlistSpec) (Histlist, error) {
 type histlistItems struct {
 	entries []storedefs.Cmd
 	last    map[string]int
+	// Byte indices of the matched runes in the text of each entry, if the
+	// filter reports them.
+	positions [][]int
+}
+
+func newHistlistItems(cmds []storedefs.Cmd) histlistItems {
+	last := map[string]int{}
+	for i, cmd := range cmds {
+		last[cmd.Text] = i
+	}
+	return histlistItems{cmds, last, nil}
 }
 
-func (it histlistItems) filter(p func(string) bool, dedup bool) histlistItems {
-	var filtered []storedefs.Cmd
+func (it histlistItems) filter(spec FilterSpec, p string, dedup bool) histlistItems {
+	var candidates []storedefs.Cmd
 	for i, entry := range it.entries {
-		text := entry.Text
-		if dedup && it.last[text] != i {
-			continue
+		if !dedup || it.last[entry.Text] == i {
+			candidates = append(candidates, entry)
 		}
-		if p(text) {
-			filtered = append(filtered, entry)
+	}
+	matches := spec.filter(p, len(candidates),
+		func(i int) string { return candidates[i].Text })
+	// The last item is selected initially, so put the best match last.
+	n := len(matches)
+	filtered := make([]storedefs.Cmd, n)
+	var positions [][]int
+	if spec.Scorer != nil {
+		positions = make([][]int, n)
+	}
+	for i, match := range matches {
+		j := i
+		if spec.Scorer != nil {
+			j = n - 1 - i
+			positions[j] = match.positions
 		}
+		filtered[j] = candidates[match.index]
 	}
-	return histlistItems{filtered, nil}
+	return histlistItems{filtered, nil, positions}
 }
 
 func (it histlistItems) Show(i int) ui.Text {
 	entry := it.entries[i]
 	// TODO: The alignment of the index works up to 10000 entries.
-	return ui.T(fmt.Sprintf("%4d %s", entry.Seq, entry.Text))
+	index := fmt.Sprintf("%4d ", entry.Seq)
+	t := ui.T(index + entry.Text)
+	if it.positions != nil {
+		t = highlightMatched(t, len(index), it.positions[i])
+	}
+	return append(t, showCmdMeta(entry)...)
+}
+
+// Layout of the start time of commands.
+const histlistTimeLayout = "2006-01-02 15:04"
+
+// Shows the known metadata of a command, with a status other than "ok" in red.
+func showCmdMeta(cmd storedefs.Cmd) ui.Text {
+	var parts []string
+	if cmd.Dir != "" {
+		parts = append(parts, fsutil.TildeAbbr(cmd.Dir))
+	}
+	if cmd.Start != 0 {
+		parts = append(parts,
+			time.Unix(int64(cmd.Start), 0).Format(histlistTimeLayout))
+	}
+	if cmd.Duration != 0 {
+		d := time.Duration(cmd.Duration * float64(time.Second))
+		parts = append(parts, d.Round(time.Millisecond).String())
+	}
+	var t ui.Text
+	if len(parts) > 0 {
+		t = ui.T("  "+strings.Join(parts, " "), ui.Dim)
+	}
+	if cmd.Status != "" && cmd.Status != storedefs.StatusOK {
+		t = ui.Concat(t, ui.T("  "+cmd.Status, ui.FgRed))
+	}
+	return t
 }
 
 func (it histlistItems) Len() int { return len(it.entries) }
+
+// Entries are identified by their sequence numbers, so that marks are kept when
+// the filter changes.
+func (it histlistItems) Key(i int) interface{} { return it.entries[i].Seq }
@@ -2,13 +2,17 @@ package modes
 
 import (
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/cli"
 	. "src.elv.sh/pkg/cli/clitest"
 	"src.elv.sh/pkg/cli/histutil"
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/strutil"
 	"src.elv.sh/pkg/ui"
 )
 
@@ -86,6 +90,21 @@ func TestHistlist(t *testing.T) {
 		"\n", "baz2", term.DotHere)
 }
 
+func TestHistlist_AcceptMarked(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore("foo", "bar", "baz")
+	w, _ := NewHistlist(f.App, HistlistSpec{AllCmds: st.AllCmds})
+	startMode(f.App, w, nil)
+	w.ListBox().Select(tk.Prev)
+	w.ListBox().Mark(tk.InvertMarks)
+	w.ListBox().Mark(tk.ToggleMark)
+	f.TTY.Inject(term.K(ui.Enter))
+	// Marked entries are inserted in order, each on its own line.
+	f.TestTTY(t, "foo\nbaz", term.DotHere)
+}
+
 func TestHistlist_Dedup(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
@@ -152,6 +171,111 @@ func TestHistlist_CustomFilter(t *testing.T) {
 		"++++++++++++++++++++++++++++++++++++++++++++++++++")
 }
 
+func TestHistlist_Scorer(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore(
+		// 0     1          2
+		"fxxb", "foo bar", "echo")
+
+	startHistlist(f.App, HistlistSpec{
+		AllCmds: st.AllCmds,
+		Filter:  FilterSpec{Scorer: fuzzyScorer},
+	})
+	f.TTY.Inject(term.K('f'), term.K('b'))
+	// The best match is put last, and matched runes are highlighted.
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on)  fb", Styles,
+		"********************   ", term.DotHere, "\n",
+		"   0 fxxb\n", Styles,
+		"     _  _ \n",
+		"   1 foo bar                                      ", scorerStyles,
+		"+++++U+++U++++++++++++++++++++++++++++++++++++++++")
+}
+
+func TestHistlist_ShowsMetadata(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore()
+	st.AddCmd(storedefs.Cmd{Text: "make", Seq: 0, Dir: "/tmp",
+		Start: 1634300000, Duration: 1.5, Status: "exit 2"})
+	st.AddCmd(storedefs.Cmd{Text: "ls", Seq: 1, Status: "ok"})
+	startHistlist(f.App, HistlistSpec{AllCmds: st.AllCmds})
+
+	start := time.Unix(1634300000, 0).Format(histlistTimeLayout)
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on)  ", Styles,
+		"******************** ", term.DotHere, "\n",
+		"   0 make  /tmp "+start+" 1.5s  exit 2\n", metaStyles,
+		"         ----------------------------!!!!!!!!\n",
+		"   1 ls                                           ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++")
+}
+
+func TestHistlist_Query(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	st := histutil.NewMemStore()
+	st.AddCmd(storedefs.Cmd{Text: "foo", Seq: 0, Session: "a"})
+	st.AddCmd(storedefs.Cmd{Text: "bar", Seq: 1, Session: "b"})
+	st.AddCmd(storedefs.Cmd{Text: "foo2", Seq: 2, Session: "b"})
+	queries := 0
+	startHistlist(f.App, HistlistSpec{
+		AllCmds: st.AllCmds,
+		// Treats a leading "@" word as a session query.
+		Query: func(p string) (storedefs.CmdQuery, string) {
+			if strings.HasPrefix(p, "@") {
+				fields := strings.SplitN(p[1:], " ", 2)
+				return storedefs.CmdQuery{Session: fields[0]}, strings.Join(fields[1:], "")
+			}
+			return storedefs.CmdQuery{}, p
+		},
+		QueryCmds: func(q storedefs.CmdQuery) ([]storedefs.Cmd, error) {
+			queries++
+			return st.QueryCmds(q)
+		},
+	})
+
+	f.TTY.Inject(term.K('@'), term.K('b'))
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on)  @b", Styles,
+		"********************   ", term.DotHere, "\n",
+		"   1 bar\n",
+		"   2 foo2                                         ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++")
+
+	// Changing the rest of the filter does not query the store again.
+	f.TTY.Inject(term.K(' '), term.K('f'))
+	f.TestTTY(t,
+		"\n",
+		" HISTORY (dedup on)  @b f", Styles,
+		"********************     ", term.DotHere, "\n",
+		"   2 foo2                                         ", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++")
+	if queries != 1 {
+		t.Errorf("QueryCmds called %d times, want 1", queries)
+	}
+}
+
+var metaStyles = ui.RuneStylesheet{'-': ui.Dim, '!': ui.FgRed}
+
+var scorerStyles = ui.RuneStylesheet{
+	'+': ui.Inverse,
+	'U': ui.Stylings(ui.Inverse, ui.Underlined),
+}
+
+func fuzzyScorer(p string) func(string) (int, []int, bool) {
+	return func(s string) (int, []int, bool) {
+		return strutil.FuzzyMatch(s, p, false)
+	}
+}
+
 func startHistlist(app cli.App, spec HistlistSpec) {
 	w, err := NewHistlist(app, spec)
 	startMode(app, w, err)
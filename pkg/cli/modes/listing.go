@@ -28,6 +28,10 @@ type ListingSpec struct {
 	// If unspecified, the Accept function default to a function that does
 	// nothing other than returning false.
 	Accept func(string)
+	// If not nil, called instead of Accept when the user has accepted items,
+	// with ToAccept of all the marked items, or the selected item if no item
+	// is marked.
+	AcceptMulti func([]string)
 	// Whether to automatically accept when there is only one item.
 	AutoAccept bool
 }
@@ -55,16 +59,30 @@ func NewListing(app cli.App, spec ListingSpec) (Listing, error) {
 	}
 	accept := func(s string) {
 		app.PopAddon()
-		spec.Accept(s)
+		if spec.AcceptMulti != nil {
+			spec.AcceptMulti([]string{s})
+		} else {
+			spec.Accept(s)
+		}
 	}
-	w := tk.NewComboBox(tk.ComboBoxSpec{
+	var w tk.ComboBox
+	w = tk.NewComboBox(tk.ComboBoxSpec{
 		CodeArea: tk.CodeAreaSpec{
 			Prompt: modePrompt(spec.Caption, true),
 		},
 		ListBox: tk.ListBoxSpec{
 			Bindings: spec.Bindings,
 			OnAccept: func(it tk.Items, i int) {
-				accept(it.(listingItems)[i].ToAccept)
+				if spec.AcceptMulti == nil {
+					accept(it.(listingItems)[i].ToAccept)
+					return
+				}
+				var toAccept []string
+				for _, i := range acceptedIndices(w.ListBox(), i) {
+					toAccept = append(toAccept, it.(listingItems)[i].ToAccept)
+				}
+				app.PopAddon()
+				spec.AcceptMulti(toAccept)
 			},
 			ExtendStyle: true,
 		},
@@ -83,3 +101,7 @@ type listingItems []ListingItem
 
 func (it listingItems) Len() int           { return len(it) }
 func (it listingItems) Show(i int) ui.Text { return it[i].ToShow }
+
+// Items are identified by what they accept, so that marks are kept when the
+// filter changes.
+func (it listingItems) Key(i int) interface{} { return it[i].ToAccept }
@@ -1,6 +1,8 @@
 package modes
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"src.elv.sh/pkg/cli"
@@ -50,6 +52,71 @@ func TestListing_Accept_ClosingListing(t *testing.T) {
 	f.TestTTY(t, "foo", term.DotHere)
 }
 
+func TestListing_AcceptMulti(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	var accepted []string
+	spec := ListingSpec{
+		GetItems:    fooAndGreenBar,
+		AcceptMulti: func(s []string) { accepted = s },
+	}
+	w, _ := NewListing(f.App, spec)
+	startMode(f.App, w, nil)
+	// Without marks, the selected item is accepted.
+	w.ListBox().Accept()
+	if want := []string{"foo"}; !reflect.DeepEqual(accepted, want) {
+		t.Errorf("accepted %q, want %q", accepted, want)
+	}
+
+	w, _ = NewListing(f.App, spec)
+	startMode(f.App, w, nil)
+	w.ListBox().Mark(tk.MarkAll)
+	w.ListBox().Accept()
+	if want := []string{"foo", "bar"}; !reflect.DeepEqual(accepted, want) {
+		t.Errorf("accepted %q, want %q", accepted, want)
+	}
+}
+
+func TestListing_MarksKeptWhenFilterChanges(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	var accepted []string
+	w, _ := NewListing(f.App, ListingSpec{
+		GetItems: func(q string) ([]ListingItem, int) {
+			var items []ListingItem
+			for _, s := range []string{"foo", "bar", "baz"} {
+				if strings.HasPrefix(s, q) {
+					items = append(items, ListingItem{ToAccept: s, ToShow: ui.T(s)})
+				}
+			}
+			return items, 0
+		},
+		AcceptMulti: func(s []string) { accepted = s },
+	})
+	startMode(f.App, w, nil)
+	w.ListBox().Mark(tk.ToggleMark)
+	setFilter(w, "b")
+	w.ListBox().Mark(tk.ToggleMark)
+	// Accepting only acts on the marked items that match the filter.
+	if marked := w.ListBox().CopyState().MarkedIndices(); !reflect.DeepEqual(marked, []int{0}) {
+		t.Errorf("marked %v, want [0]", marked)
+	}
+	setFilter(w, "")
+	w.ListBox().Accept()
+	if want := []string{"foo", "bar"}; !reflect.DeepEqual(accepted, want) {
+		t.Errorf("accepted %q, want %q", accepted, want)
+	}
+}
+
+func setFilter(w tk.ComboBox, filter string) {
+	w.CodeArea().MutateState(func(s *tk.CodeAreaState) {
+		s.Buffer = tk.CodeBuffer{Content: filter, Dot: len(filter)}
+	})
+	w.Refilter()
+}
+
 func TestListing_Accept_DefaultNop(t *testing.T) {
 	f := Setup()
 	defer f.Stop()
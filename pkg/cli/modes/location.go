@@ -89,7 +89,7 @@ func NewLocation(app cli.App, cfg LocationSpec) (Location, error) {
 		}
 	}
 
-	l := locationList{dirs}
+	l := locationList{dirs, nil}
 
 	w := tk.NewComboBox(tk.ComboBoxSpec{
 		CodeArea: tk.CodeAreaSpec{
@@ -111,7 +111,7 @@ func NewLocation(app cli.App, cfg LocationSpec) (Location, error) {
 			},
 		},
 		OnFilter: func(w tk.ComboBox, p string) {
-			w.ListBox().Reset(l.filter(cfg.Filter.makePredicate(p)), 0)
+			w.ListBox().Reset(l.filter(cfg.Filter, p), 0)
 		},
 	})
 	return w, nil
@@ -152,21 +152,35 @@ func (ws LocationWSIterator) Parse(path string) (kind, root string) {
 
 type locationList struct {
 	dirs []storedefs.Dir
+	// Byte indices of the matched runes in the abbreviated path of each
+	// directory, if the filter reports them.
+	positions [][]int
 }
 
-func (l locationList) filter(p func(string) bool) locationList {
-	var filteredDirs []storedefs.Dir
-	for _, dir := range l.dirs {
-		if p(fsutil.TildeAbbr(dir.Path)) {
-			filteredDirs = append(filteredDirs, dir)
+func (l locationList) filter(spec FilterSpec, p string) locationList {
+	matches := spec.filter(p, len(l.dirs),
+		func(i int) string { return fsutil.TildeAbbr(l.dirs[i].Path) })
+	filteredDirs := make([]storedefs.Dir, len(matches))
+	var positions [][]int
+	if spec.Scorer != nil {
+		positions = make([][]int, len(matches))
+	}
+	for i, match := range matches {
+		filteredDirs[i] = l.dirs[match.index]
+		if positions != nil {
+			positions[i] = match.positions
 		}
 	}
-	return locationList{filteredDirs}
+	return locationList{filteredDirs, positions}
 }
 
 func (l locationList) Show(i int) ui.Text {
-	return ui.T(fmt.Sprintf("%s %s",
-		showScore(l.dirs[i].Score), fsutil.TildeAbbr(l.dirs[i].Path)))
+	score := showScore(l.dirs[i].Score) + " "
+	t := ui.T(score + fsutil.TildeAbbr(l.dirs[i].Path))
+	if l.positions != nil {
+		t = highlightMatched(t, len(score), l.positions[i])
+	}
+	return t
 }
 
 func (l locationList) Len() int { return len(l.dirs) }
@@ -227,6 +227,38 @@ func TestLocation_Workspace(t *testing.T) {
 	}
 }
 
+func TestLocation_Scorer(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	dirs := []storedefs.Dir{
+		{Path: fixPath("/tmp/xaxb"), Score: 200},
+		{Path: fixPath("/ab"), Score: 100},
+		{Path: fixPath("/usr"), Score: 50},
+	}
+	startLocation(f.App, LocationSpec{
+		Store:  locationStore{storedDirs: dirs},
+		Filter: FilterSpec{Scorer: fuzzyScorer},
+	})
+	f.TTY.Inject(term.K('a'), term.K('b'))
+	// Matches are sorted by how well they match, and matched runes are
+	// highlighted.
+	f.TTY.TestBuffer(t, term.NewBufferBuilder(50).
+		Newline().
+		WriteStyled(modeLine(" LOCATION ", true)).
+		Write("ab").SetDotHere().
+		Newline().
+		Write("100 "+fixPath("/"), ui.Inverse).
+		Write("ab", ui.Inverse, ui.Underlined).
+		Write(strings.Repeat(" ", 50-len("100 "+fixPath("/ab"))), ui.Inverse).
+		Newline().
+		Write("200 "+fixPath("/tmp/x")).
+		Write("a", ui.Underlined).
+		Write("x").
+		Write("b", ui.Underlined).
+		Buffer())
+}
+
 func locationBuf(filter string, lines ...string) *term.Buffer {
 	b := term.NewBufferBuilder(50).
 		Newline(). // empty code area
@@ -32,6 +32,15 @@ func modeLine(content string, space bool) ui.Text {
 	return t
 }
 
+// Returns the indices of the marked items of the ListBox, or the index of the
+// selected item if no item is marked.
+func acceptedIndices(w tk.ListBox, selected int) []int {
+	if marked := w.CopyState().MarkedIndices(); len(marked) > 0 {
+		return marked
+	}
+	return []int{selected}
+}
+
 func modePrompt(content string, space bool) func() ui.Text {
 	p := modeLine(content, space)
 	return func() ui.Text { return p }
@@ -18,8 +18,13 @@ type Navigation interface {
 	// string if there is no selected name, which can happen if the current
 	// directory is empty.
 	SelectedName() string
+	// MarkedNames returns the names of the marked files in the current
+	// directory, in the order they are shown.
+	MarkedNames() []string
 	// Select changes the selection.
 	Select(f func(tk.ListBoxState) int)
+	// Mark changes the marked files in the current directory.
+	Mark(f func(tk.ListBoxState) map[interface{}]bool)
 	// ScrollPreview scrolls the preview.
 	ScrollPreview(delta int)
 	// Ascend ascends to the parent directory.
@@ -58,8 +63,10 @@ type navigation struct {
 	codeArea   tk.CodeArea
 	colView    tk.ColView
 	lastFilter string
-	stateMutex sync.RWMutex
-	state      navigationState
+	// Number of lines of the codearea in the last Render.
+	codeAreaLines int
+	stateMutex    sync.RWMutex
+	state         navigationState
 }
 
 func (w *navigation) MutateState(f func(*navigationState)) {
@@ -75,6 +82,14 @@ func (w *navigation) CopyState() navigationState {
 }
 
 func (w *navigation) Handle(event term.Event) bool {
+	if event, ok := event.(term.MouseEvent); ok {
+		if event.Line < w.codeAreaLines &&
+			event.Button != term.WheelUp && event.Button != term.WheelDown {
+			return w.CopyState().Filtering && w.codeArea.Handle(event)
+		}
+		event.Line -= w.codeAreaLines
+		return w.colView.Handle(event)
+	}
 	if w.colView.Handle(event) {
 		return true
 	}
@@ -94,6 +109,7 @@ func (w *navigation) Handle(event term.Event) bool {
 
 func (w *navigation) Render(width, height int) *term.Buffer {
 	buf := w.codeArea.Render(width, height)
+	w.codeAreaLines = len(buf.Lines)
 	bufColView := w.colView.Render(width, height-len(buf.Lines))
 	buf.Extend(bufColView, false)
 	return buf
@@ -203,6 +219,19 @@ func (w *navigation) SelectedName() string {
 	return ""
 }
 
+func (w *navigation) MarkedNames() []string {
+	col, ok := w.colView.CopyState().Columns[1].(tk.ListBox)
+	if !ok {
+		return nil
+	}
+	state := col.CopyState()
+	var names []string
+	for _, i := range state.MarkedIndices() {
+		names = append(names, state.Items.(fileItems)[i].Name())
+	}
+	return names
+}
+
 func updateState(w *navigation, selectName string) {
 	colView := w.colView
 	cursor := w.Cursor
@@ -237,7 +266,8 @@ func updateState(w *navigation, selectName string) {
 				colView.MutateState(func(s *tk.ColViewState) {
 					s.Columns[2] = previewCol
 				})
-			})
+			},
+			func(tk.Items, int) { w.descend() })
 		tryToSelectName(parentCol, current.Name())
 		if selectName != "" {
 			tryToSelectName(currentCol, selectName)
@@ -285,10 +315,10 @@ func tryToSelectName(w tk.Widget, name string) {
 }
 
 func makeCol(f NavigationFile, showHidden bool) tk.Widget {
-	return makeColInner(f, func(string) bool { return true }, showHidden, nil)
+	return makeColInner(f, func(string) bool { return true }, showHidden, nil, nil)
 }
 
-func makeColInner(f NavigationFile, filter func(string) bool, showHidden bool, onSelect func(tk.Items, int)) tk.Widget {
+func makeColInner(f NavigationFile, filter func(string) bool, showHidden bool, onSelect, onAccept func(tk.Items, int)) tk.Widget {
 	files, content, err := f.Read()
 	if err != nil {
 		return makeErrCol(err)
@@ -308,7 +338,7 @@ func makeColInner(f NavigationFile, filter func(string) bool, showHidden bool, o
 			return files[i].Name() < files[j].Name()
 		})
 		return tk.NewListBox(tk.ListBoxSpec{
-			Padding: 1, ExtendStyle: true, OnSelect: onSelect,
+			Padding: 1, ExtendStyle: true, OnSelect: onSelect, OnAccept: onAccept,
 			State: tk.ListBoxState{Items: fileItems(files)},
 		})
 	}
@@ -351,6 +381,12 @@ func (w *navigation) Select(f func(tk.ListBoxState) int) {
 	}
 }
 
+func (w *navigation) Mark(f func(tk.ListBoxState) map[interface{}]bool) {
+	if listBox, ok := w.colView.CopyState().Columns[1].(tk.ListBox); ok {
+		listBox.Mark(f)
+	}
+}
+
 func (w *navigation) ScrollPreview(delta int) {
 	if textView, ok := w.colView.CopyState().Columns[2].(tk.TextView); ok {
 		textView.ScrollBy(delta)
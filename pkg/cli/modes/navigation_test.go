@@ -2,6 +2,7 @@ package modes
 
 import (
 	"errors"
+	"reflect"
 	"testing"
 
 	"src.elv.sh/pkg/cli"
@@ -147,6 +148,25 @@ func TestGetSelectedName(t *testing.T) {
 	}
 }
 
+func TestMarkedNames(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	w := startNavigation(f.App, NavigationSpec{Cursor: getTestCursor()})
+	if names := w.MarkedNames(); len(names) != 0 {
+		t.Errorf("Got names %q, want none", names)
+	}
+
+	w.Select(tk.Next)
+	w.Mark(tk.ToggleMark)
+	w.Select(tk.Prev)
+	w.Mark(tk.ToggleMark)
+	wantNames := []string{"d1", "d2"}
+	if names := w.MarkedNames(); !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("Got names %q, want %q", names, wantNames)
+	}
+}
+
 func TestNavigation_FakeFS(t *testing.T) {
 	cursor := getTestCursor()
 	testNavigation(t, cursor)
@@ -316,6 +336,55 @@ func TestNewNavigation_FocusedWidgetNotCodeArea(t *testing.T) {
 	})
 }
 
+func TestNavigation_Mouse(t *testing.T) {
+	f := setupNav(t)
+	defer f.Stop()
+
+	startNavigation(f.App, NavigationSpec{Cursor: getTestCursor()})
+	f.TestTTY(t,
+		"", term.DotHere, "\n",
+		" NAVIGATING  \n", Styles,
+		"************ ",
+		" a    d1            content    d1\n", Styles,
+		"     ++++++++++++++",
+		" d    d2            line 2\n", Styles,
+		"#### //////////////",
+		" f    d3           ", Styles,
+		"     //////////////",
+	)
+
+	// Double-clicking a directory in the current column descends into it. The
+	// first line of the widget is the modeline.
+	d2 := term.MouseEvent{Pos: term.Pos{Line: 2, Col: 7}, Down: true}
+	f.TTY.Inject(d2, d2)
+	d21Buf := f.MakeBuffer(
+		"", term.DotHere, "\n",
+		" NAVIGATING  \n", Styles,
+		"************ ",
+		" d1   d21           content d21\n", Styles,
+		"     ++++++++++++++",
+		" d2   d22          \n", Styles,
+		"####",
+		" d3   d23.png      ", Styles,
+		"//// !!!!!!!!!!!!!!",
+	)
+	f.TTY.TestBuffer(t, d21Buf)
+
+	// Clicking the parent column ascends.
+	f.TTY.Inject(term.MouseEvent{Pos: term.Pos{Line: 1, Col: 1}, Down: true})
+	f.TestTTY(t,
+		"", term.DotHere, "\n",
+		" NAVIGATING  \n", Styles,
+		"************ ",
+		" a    d1             d21                \n", Styles,
+		"                    ++++++++++++++++++++",
+		" d    d2             d22                \n", Styles,
+		"#### ##############",
+		" f    d3             d23.png            ", Styles,
+		"     ////////////// !!!!!!!!!!!!!!!!!!!!",
+	)
+}
+
 func setupNav(c testutil.Cleanuper) *Fixture {
 	lscolors.SetTestLsColors(c)
 	// Use a small TTY size to make the test buffer easier to build.
@@ -0,0 +1,176 @@
+package modes
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/ui"
+)
+
+// Paste is a mode for previewing pasted text before inserting it. It shows the
+// text and a list of actions: inserting the text, inserting it as a quoted
+// string, or discarding it.
+type Paste interface {
+	tk.Widget
+}
+
+// PasteSpec specifies the configuration for the paste mode.
+type PasteSpec struct {
+	// Key bindings.
+	Bindings tk.Bindings
+	// The pasted text.
+	Text string
+	// Maximum number of lines of the text to show. Defaults to 10.
+	MaxPreviewLines int
+}
+
+type paste struct {
+	PasteSpec
+	actions tk.ListBox
+	// Number of lines of the preview in the last Render.
+	previewLines int
+}
+
+type pasteAction struct {
+	name string
+	// Transforms the text to insert; nil means discarding the text.
+	transform func(string) string
+}
+
+var pasteActions = []pasteAction{
+	{"insert", func(s string) string { return s }},
+	{"insert as quoted string", parse.Quote},
+	{"discard", nil},
+}
+
+type pasteActionItems []pasteAction
+
+func (it pasteActionItems) Show(i int) ui.Text { return ui.T(it[i].name) }
+func (it pasteActionItems) Len() int           { return len(it) }
+
+// NewPaste creates a new paste mode.
+func NewPaste(app cli.App, spec PasteSpec) (Paste, error) {
+	codeArea, err := FocusedCodeArea(app)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Bindings == nil {
+		spec.Bindings = tk.DummyBindings{}
+	}
+	if spec.MaxPreviewLines <= 0 {
+		spec.MaxPreviewLines = 10
+	}
+	actions := tk.NewListBox(tk.ListBoxSpec{
+		Padding: 1,
+		OnAccept: func(it tk.Items, i int) {
+			app.PopAddon()
+			if transform := it.(pasteActionItems)[i].transform; transform != nil {
+				text := transform(spec.Text)
+				codeArea.MutateState(func(s *tk.CodeAreaState) {
+					s.Buffer.InsertAtDot(text)
+				})
+			}
+		},
+		State: tk.ListBoxState{Items: pasteActionItems(pasteActions)},
+	})
+	return &paste{PasteSpec: spec, actions: actions}, nil
+}
+
+func (w *paste) Render(width, height int) *term.Buffer {
+	buf := w.renderPreview(width)
+	actionsHeight := w.actions.MaxHeight(width, height)
+	if previewHeight := height - actionsHeight; len(buf.Lines) > previewHeight {
+		// Keep at least the modeline.
+		if previewHeight < 1 {
+			previewHeight = 1
+		}
+		buf.TrimToLines(0, previewHeight)
+	}
+	w.previewLines = len(buf.Lines)
+	buf.Extend(w.actions.Render(width, height-len(buf.Lines)), false)
+	return buf
+}
+
+func (w *paste) MaxHeight(width, height int) int {
+	return len(w.renderPreview(width).Lines) + w.actions.MaxHeight(width, height)
+}
+
+// Renders the modeline and the preview of the text.
+func (w *paste) renderPreview(width int) *term.Buffer {
+	// A trailing newline does not start a new line in the preview.
+	lines := strings.Split(strings.TrimSuffix(w.Text, "\n"), "\n")
+	linesWord := "lines"
+	if len(lines) == 1 {
+		linesWord = "line"
+	}
+	bb := term.NewBufferBuilder(width).
+		WriteStyled(modeLine(" PASTE ", true)).
+		Write(fmt.Sprintf("%d %s, %d bytes", len(lines), linesWord, len(w.Text))).
+		SetDotHere()
+	for i, line := range lines {
+		bb.Newline()
+		if i == w.MaxPreviewLines {
+			bb.WriteStyled(ui.T(
+				fmt.Sprintf("(%d more lines)", len(lines)-i), ui.Dim))
+			break
+		}
+		bb.WriteStyled(showControls(line))
+	}
+	return bb.Buffer()
+}
+
+// Returns the text with tabs expanded and other control characters shown in
+// caret notation, like ^[ for ESC, in inverse.
+func showControls(s string) ui.Text {
+	var t ui.Text
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			sb.WriteString("    ")
+		case unicode.IsControl(r):
+			if sb.Len() > 0 {
+				t = append(t, &ui.Segment{Text: sb.String()})
+				sb.Reset()
+			}
+			var caret string
+			if r < 0x20 || r == 0x7f {
+				caret = "^" + string(r^0x40)
+			} else {
+				caret = fmt.Sprintf("<U+%04X>", r)
+			}
+			t = append(t, ui.T(caret, ui.Inverse)...)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	if sb.Len() > 0 {
+		t = append(t, &ui.Segment{Text: sb.String()})
+	}
+	return t
+}
+
+func (w *paste) Handle(event term.Event) bool {
+	if event, ok := event.(term.MouseEvent); ok {
+		event.Line -= w.previewLines
+		return w.actions.Handle(event)
+	}
+	return w.Bindings.Handle(w, event) || w.actions.Handle(event)
+}
+
+// NeedsPastePreview returns whether pasted text should be previewed before
+// inserting, which is the case when it has multiple lines or any control
+// character other than tab.
+func NeedsPastePreview(text string) bool {
+	for _, r := range text {
+		if r != '\t' && unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
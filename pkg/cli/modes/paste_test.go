@@ -0,0 +1,95 @@
+package modes
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli"
+	. "src.elv.sh/pkg/cli/clitest"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/tt"
+	"src.elv.sh/pkg/ui"
+)
+
+var styles = ui.RuneStylesheet{'-': ui.Dim}
+
+func TestNewPaste_FocusedWidgetNotCodeArea(t *testing.T) {
+	testFocusedWidgetNotCodeArea(t, func(app cli.App) error {
+		_, err := NewPaste(app, PasteSpec{Text: "foo\n"})
+		return err
+	})
+}
+
+func TestPaste(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	startPaste(f.App, PasteSpec{Text: "echo foo\n\techo\x1b[m bar\n"})
+	f.TestTTY(t,
+		"\n", // empty code area
+		" PASTE  2 lines, 22 bytes", Styles,
+		"******* ", term.DotHere, "\n",
+		"echo foo\n",
+		"    echo^[[m bar\n", Styles,
+		"        ++      ",
+		" insert                                           \n", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		" insert as quoted string\n",
+		" discard",
+	)
+
+	// Insert.
+	f.TTY.Inject(term.K(ui.Enter))
+	f.TestTTY(t, "echo foo", "\n", "\techo\x1b[m bar", "\n", term.DotHere)
+}
+
+func TestPaste_InsertQuoted(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	startPaste(f.App, PasteSpec{Text: "a\nb"})
+	f.TTY.Inject(term.K(ui.Down), term.K(ui.Enter))
+	f.TestTTY(t, `"a\nb"`, term.DotHere)
+}
+
+func TestPaste_Discard(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	startPaste(f.App, PasteSpec{Text: "a\nb"})
+	f.TTY.Inject(term.K(ui.Down), term.K(ui.Down), term.K(ui.Enter))
+	f.TestTTY(t /* nothing */)
+}
+
+func TestPaste_MaxPreviewLines(t *testing.T) {
+	f := Setup()
+	defer f.Stop()
+
+	startPaste(f.App, PasteSpec{Text: "a\nb\nc\nd", MaxPreviewLines: 2})
+	f.TestTTY(t,
+		"\n", // empty code area
+		" PASTE  4 lines, 7 bytes", Styles,
+		"******* ", term.DotHere, "\n",
+		"a\n",
+		"b\n",
+		"(2 more lines)\n", styles,
+		"--------------",
+		" insert                                           \n", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		" insert as quoted string\n",
+		" discard",
+	)
+}
+
+func TestNeedsPastePreview(t *testing.T) {
+	tt.Test(t, tt.Fn("NeedsPastePreview", NeedsPastePreview), tt.Table{
+		tt.Args("echo foo").Rets(false),
+		tt.Args("echo\tfoo").Rets(false),
+		tt.Args("echo foo\n").Rets(true),
+		tt.Args("echo\x1b[m").Rets(true),
+	})
+}
+
+func startPaste(app cli.App, spec PasteSpec) {
+	w, err := NewPaste(app, spec)
+	startMode(app, w, err)
+}
@@ -19,11 +19,21 @@ func K(r rune, mods ...ui.Mod) KeyEvent {
 type MouseEvent struct {
 	Pos
 	Down bool
-	// Number of the Button, 0-based. -1 for unknown.
+	// Number of the Button, 0-based. -1 for unknown. Scrolling the wheel is
+	// reported as pressing WheelUp or WheelDown.
 	Button int
 	Mod    ui.Mod
 }
 
+// Buttons of mouse events.
+const (
+	LeftButton = iota
+	MiddleButton
+	RightButton
+	WheelUp
+	WheelDown
+)
+
 // CursorPosition represents a report of the current cursor position from the
 // terminal driver, usually as a response from a cursor position request.
 type CursorPosition Pos
@@ -125,8 +125,9 @@ func readEvent(rd byteReaderWithTimeout) (event Event, err error) {
 					return
 				}
 				down := true
-				button := int(cb & 3)
-				if button == 3 {
+				button := mouseButton(int(cb))
+				if cb&64 == 0 && cb&3 == 3 {
+					// Release of an unknown button.
 					down = false
 					button = -1
 				}
@@ -170,7 +171,7 @@ func readEvent(rd byteReaderWithTimeout) (event Event, err error) {
 					return
 				}
 				down := r == 'M'
-				button := nums[0] & 3
+				button := mouseButton(nums[0])
 				mod := mouseModify(nums[0])
 				event = MouseEvent{Pos{nums[2], nums[1]}, down, button, mod}
 			} else if r == '~' && len(nums) == 1 && (nums[0] == 200 || nums[0] == 201) {
@@ -418,6 +419,19 @@ func xtermModify(k ui.Key, mod int, seq string) ui.Key {
 	return k
 }
 
+// Returns the button encoded in the lower bits of n. Wheel events have the bit
+// 64 set; horizontal scrolling is reported as an unknown button.
+func mouseButton(n int) int {
+	button := n & 3
+	if n&64 != 0 {
+		if button >= 2 {
+			return -1
+		}
+		return WheelUp + button
+	}
+	return button
+}
+
 func mouseModify(n int) ui.Mod {
 	var mod ui.Mod
 	if n&4 != 0 {
@@ -109,6 +109,9 @@ var readEventTests = []struct {
 	{"\033[M\x08\x23\x24", MouseEvent{Pos{4, 3}, true, 0, ui.Alt}},
 	{"\033[M\x10\x23\x24", MouseEvent{Pos{4, 3}, true, 0, ui.Ctrl}},
 	{"\033[M\x14\x23\x24", MouseEvent{Pos{4, 3}, true, 0, ui.Shift | ui.Ctrl}},
+	// Wheel.
+	{"\033[M\x60\x23\x24", MouseEvent{Pos{4, 3}, true, WheelUp, 0}},
+	{"\033[M\x61\x23\x24", MouseEvent{Pos{4, 3}, true, WheelDown, 0}},
 
 	// SGR-style mouse event.
 	{"\033[<0;3;4M", MouseEvent{Pos{4, 3}, true, 0, 0}},
@@ -119,6 +122,10 @@ var readEventTests = []struct {
 	// Modified.
 	{"\033[<4;3;4M", MouseEvent{Pos{4, 3}, true, 0, ui.Shift}},
 	{"\033[<16;3;4M", MouseEvent{Pos{4, 3}, true, 0, ui.Ctrl}},
+	// Wheel.
+	{"\033[<64;3;4M", MouseEvent{Pos{4, 3}, true, WheelUp, 0}},
+	{"\033[<65;3;4M", MouseEvent{Pos{4, 3}, true, WheelDown, 0}},
+	{"\033[<66;3;4M", MouseEvent{Pos{4, 3}, true, -1, 0}},
 }
 
 func TestReader_ReadEvent(t *testing.T) {
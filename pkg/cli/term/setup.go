@@ -25,10 +25,28 @@ func Sanitize(in, out *os.File) {
 	sanitize(in, out)
 }
 
+// SetMouse turns SGR-style mouse tracking on or off. When it is on, pressing
+// and releasing mouse buttons and scrolling the wheel are reported as
+// MouseEvent's.
+func SetMouse(out *os.File, on bool) error {
+	s := "\033[?1000;1006l"
+	if on {
+		s = "\033[?1000;1006h"
+	}
+	_, err := out.WriteString(s)
+	return err
+}
+
+// RequestCursorPosition requests the terminal to report the current cursor
+// position, which is read as a CursorPosition event.
+func RequestCursorPosition(out *os.File) error {
+	_, err := out.WriteString("\033[6n")
+	return err
+}
+
 const (
-	lackEOLRune    = '\u23ce'
-	lackEOL        = "\033[7m" + string(lackEOLRune) + "\033[m"
-	enableSGRMouse = false
+	lackEOLRune = '\u23ce'
+	lackEOL     = "\033[7m" + string(lackEOLRune) + "\033[m"
 )
 
 // setupVT performs setup for VT-like terminals.
@@ -70,11 +88,6 @@ func setupVT(out *os.File) error {
 	*/
 	s += "\033[?7l"
 
-	// Turn on SGR-style mouse tracking.
-	if enableSGRMouse {
-		s += "\033[?1000;1006h"
-	}
-
 	// Enable bracketed paste.
 	s += "\033[?2004h"
 
@@ -87,10 +100,6 @@ func restoreVT(out *os.File) error {
 	s := ""
 	// Turn on autowrap.
 	s += "\033[?7h"
-	// Turn off mouse tracking.
-	if enableSGRMouse {
-		s += "\033[?1000;1006l"
-	}
 	// Disable bracketed paste.
 	s += "\033[?2004l"
 	// Move the cursor to the first row, even if we haven't written anything
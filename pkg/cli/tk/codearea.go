@@ -21,6 +21,12 @@ type CodeArea interface {
 	MutateState(f func(*CodeAreaState))
 	// Submit triggers the OnSubmit callback.
 	Submit()
+	// Undo restores the buffer to the state before the last change, and
+	// reports whether there was a change to undo.
+	Undo() bool
+	// Redo restores the buffer to the state before the last undo, and reports
+	// whether there was an undo to redo.
+	Redo() bool
 }
 
 // CodeAreaSpec specifies the configuration and initial state for CodeArea.
@@ -31,6 +37,11 @@ type CodeAreaSpec struct {
 	// found when highlighting. If this function is not given, the Widget does
 	// not highlight the code nor show any errors.
 	Highlighter func(code string) (ui.Text, []error)
+	// A function that returns the text suggested to be appended to the given
+	// code. The suggestion is shown after the code when the dot is at the end
+	// of the buffer and there is no pending code. If this function is not
+	// given, the Widget does not show any suggestions.
+	Autosuggester func(code string) string
 	// Prompt callback.
 	Prompt func() ui.Text
 	// Right-prompt callback.
@@ -44,6 +55,10 @@ type CodeAreaSpec struct {
 	// should be quoted. If this function is not given, the Widget defaults to
 	// not quoting pasted texts.
 	QuotePaste func() bool
+	// A function that is called with the pasted text when a bracketed paste
+	// ends. If it returns true, the Widget does not insert the text itself. If
+	// this function is not given, the Widget always inserts pasted texts.
+	OnPaste func(text string) bool
 	// A function that is called on the submit event.
 	OnSubmit func()
 
@@ -56,6 +71,107 @@ type CodeAreaState struct {
 	Buffer      CodeBuffer
 	Pending     PendingCode
 	HideRPrompt bool
+	// Whether to hide the suggestion from the Autosuggester.
+	HideSuggestion bool
+	// The editing mode.
+	Mode CodeAreaMode
+	// The end of the selection opposite to the dot in VisualMode, as a byte
+	// index into Buffer.Content.
+	Anchor int
+	// Keys of a partially typed command in NormalMode or VisualMode, such as
+	// "2d" when waiting for a motion.
+	PendingKeys string
+}
+
+// CodeAreaMode is the editing mode of a CodeArea, used to implement modal
+// editing.
+type CodeAreaMode int
+
+// Possible values of CodeAreaMode.
+const (
+	// Keys not handled by the bindings insert text. This is the default mode.
+	InsertMode CodeAreaMode = iota
+	// Keys not handled by the bindings are ignored, except Enter.
+	NormalMode
+	// Like NormalMode, but the text between the anchor and the dot (both
+	// inclusive) is shown as selected.
+	VisualMode
+)
+
+// String returns the name of the mode: "insert", "normal" or "visual".
+func (m CodeAreaMode) String() string {
+	switch m {
+	case NormalMode:
+		return "normal"
+	case VisualMode:
+		return "visual"
+	default:
+		return "insert"
+	}
+}
+
+// Selection returns the range of text selected in VisualMode, with the rune
+// under the end position included. It returns 0, 0 in other modes.
+func (s *CodeAreaState) Selection() (from, to int) {
+	if s.Mode != VisualMode {
+		return 0, 0
+	}
+	content := s.Buffer.Content
+	from, to = s.Anchor, s.Buffer.Dot
+	if from > to {
+		from, to = to, from
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > len(content) {
+		to = len(content)
+	}
+	_, w := utf8.DecodeRuneInString(content[to:])
+	return from, to + w
+}
+
+// Keeps snapshots of a CodeBuffer for undoing and redoing changes. The zero
+// value is an empty history. A snapshot is recorded when the content of the
+// buffer changes, except that consecutive insertions at the dot are merged into
+// one change.
+type codeHistory struct {
+	// Snapshots of the buffer before each change, the most recent last.
+	undos []CodeBuffer
+	// Snapshots of the buffer before each undo, the most recent last.
+	redos []CodeBuffer
+	// The buffer when the history was last updated.
+	last CodeBuffer
+	// Whether the last change was an insertion that can be merged with further
+	// insertions.
+	merging bool
+}
+
+// Updates the history with the current buffer, recording a snapshot if the
+// content has changed.
+func (h *codeHistory) update(buf CodeBuffer) {
+	if buf == h.last {
+		return
+	}
+	if buf.Content == h.last.Content {
+		// Moving the dot doesn't change the content, but stops merging.
+		h.last, h.merging = buf, false
+		return
+	}
+	insertion := isInsertion(h.last, buf)
+	if !(h.merging && insertion) {
+		h.undos = append(h.undos, h.last)
+	}
+	h.redos = nil
+	h.last, h.merging = buf, insertion
+}
+
+// Reports whether new is the result of inserting some text at the dot of old.
+func isInsertion(old, new CodeBuffer) bool {
+	n := len(new.Content) - len(old.Content)
+	return n > 0 && new.Dot == old.Dot+n &&
+		strings.HasPrefix(new.Content, old.Content[:old.Dot]) &&
+		strings.HasSuffix(new.Content, old.Content[old.Dot:])
 }
 
 // CodeBuffer represents the buffer of the CodeArea widget.
@@ -79,6 +195,16 @@ type PendingCode struct {
 	Content string
 }
 
+// Returns the suggestion from the Autosuggester that is currently
+// shown, or an empty string if no suggestion is shown.
+func (w *codeArea) suggestion(s *CodeAreaState) string {
+	if s.HideSuggestion || s.Pending != (PendingCode{}) ||
+		s.Buffer.Dot != len(s.Buffer.Content) {
+		return ""
+	}
+	return w.Autosuggester(s.Buffer.Content)
+}
+
 // ApplyPending applies pending code to the code buffer, and resets pending code.
 func (s *CodeAreaState) ApplyPending() {
 	s.Buffer, _, _ = patchPending(s.Buffer, s.Pending)
@@ -107,6 +233,11 @@ type codeArea struct {
 	pasting bool
 	// Buffer for keeping Pasted text during bracketed pasting.
 	pasteBuffer bytes.Buffer
+	// Width and number of lines trimmed from the top in the last Render,
+	// used for mapping positions of mouse events.
+	lastWidth, lastTrimmed int
+	// History of the buffer, used for undoing and redoing changes.
+	history codeHistory
 }
 
 // NewCodeArea creates a new CodeArea from the given spec.
@@ -117,6 +248,9 @@ func NewCodeArea(spec CodeAreaSpec) CodeArea {
 	if spec.Highlighter == nil {
 		spec.Highlighter = func(s string) (ui.Text, []error) { return ui.T(s), nil }
 	}
+	if spec.Autosuggester == nil {
+		spec.Autosuggester = func(string) string { return "" }
+	}
 	if spec.Prompt == nil {
 		spec.Prompt = func() ui.Text { return nil }
 	}
@@ -132,6 +266,9 @@ func NewCodeArea(spec CodeAreaSpec) CodeArea {
 	if spec.QuotePaste == nil {
 		spec.QuotePaste = func() bool { return false }
 	}
+	if spec.OnPaste == nil {
+		spec.OnPaste = func(string) bool { return false }
+	}
 	if spec.OnSubmit == nil {
 		spec.OnSubmit = func() {}
 	}
@@ -147,7 +284,7 @@ func (w *codeArea) Submit() {
 // code, the cursor, and compilation errors in the code content.
 func (w *codeArea) Render(width, height int) *term.Buffer {
 	b := w.render(width)
-	truncateToHeight(b, height)
+	w.lastWidth, w.lastTrimmed = width, truncateToHeight(b, height)
 	return b
 }
 
@@ -162,14 +299,16 @@ func (w *codeArea) render(width int) *term.Buffer {
 	return bb.Buffer()
 }
 
-// Handle handles KeyEvent's of non-function keys, as well as PasteSetting
-// events.
+// Handle handles KeyEvent's of non-function keys, PasteSetting events, and
+// clicks of the left mouse button, which move the dot to the clicked position.
 func (w *codeArea) Handle(event term.Event) bool {
 	switch event := event.(type) {
 	case term.PasteSetting:
 		return w.handlePasteSetting(bool(event))
 	case term.KeyEvent:
 		return w.handleKeyEvent(ui.Key(event))
+	case term.MouseEvent:
+		return w.handleMouseEvent(event)
 	}
 	return false
 }
@@ -178,6 +317,37 @@ func (w *codeArea) MutateState(f func(*CodeAreaState)) {
 	w.StateMutex.Lock()
 	defer w.StateMutex.Unlock()
 	f(&w.State)
+	w.history.update(w.State.Buffer)
+}
+
+func (w *codeArea) Undo() bool {
+	w.StateMutex.Lock()
+	defer w.StateMutex.Unlock()
+	h := &w.history
+	h.update(w.State.Buffer)
+	if len(h.undos) == 0 {
+		return false
+	}
+	h.redos = append(h.redos, w.State.Buffer)
+	w.State.Buffer = h.undos[len(h.undos)-1]
+	h.undos = h.undos[:len(h.undos)-1]
+	h.last, h.merging = w.State.Buffer, false
+	return true
+}
+
+func (w *codeArea) Redo() bool {
+	w.StateMutex.Lock()
+	defer w.StateMutex.Unlock()
+	h := &w.history
+	h.update(w.State.Buffer)
+	if len(h.redos) == 0 {
+		return false
+	}
+	h.undos = append(h.undos, w.State.Buffer)
+	w.State.Buffer = h.redos[len(h.redos)-1]
+	h.redos = h.redos[:len(h.redos)-1]
+	h.last, h.merging = w.State.Buffer, false
+	return true
 }
 
 func (w *codeArea) CopyState() CodeAreaState {
@@ -197,17 +367,103 @@ func (w *codeArea) handlePasteSetting(start bool) bool {
 		w.pasting = true
 	} else {
 		text := w.pasteBuffer.String()
-		if w.QuotePaste() {
-			text = parse.Quote(text)
-		}
-		w.MutateState(func(s *CodeAreaState) { s.Buffer.InsertAtDot(text) })
-
 		w.pasting = false
 		w.pasteBuffer = bytes.Buffer{}
+
+		if !w.OnPaste(text) {
+			text = SanitizePaste(text)
+			if w.QuotePaste() {
+				text = parse.Quote(text)
+			}
+			w.MutateState(func(s *CodeAreaState) { s.Buffer.InsertAtDot(text) })
+		}
 	}
 	return true
 }
 
+// Returns the text of a function key received during bracketed pasting. The
+// terminal reader decodes control characters as Ctrl-modified keys and escape
+// sequences as other function keys. Control characters and Alt-modified keys
+// are restored, while other escape sequences cannot be restored and are
+// dropped.
+func pastedKeyText(key ui.Key) string {
+	switch {
+	case key.Mod == ui.Ctrl && '@' <= key.Rune && key.Rune <= '_':
+		return string(key.Rune - 0x40)
+	case key.Mod == ui.Ctrl && key.Rune == '`':
+		return "\x00"
+	case key.Mod == ui.Ctrl && key.Rune == '6':
+		return "\x1e"
+	case key.Mod == ui.Ctrl && key.Rune == '/':
+		return "\x1f"
+	case key.Mod&ui.Alt != 0 && key.Rune >= 0:
+		// An Alt-modified key is sent as an ESC followed by the key.
+		key.Mod &^= ui.Alt
+		if key.Mod == 0 {
+			return "\x1b" + string(key.Rune)
+		}
+		return "\x1b" + pastedKeyText(key)
+	default:
+		return ""
+	}
+}
+
+// SanitizePaste removes escape sequences and control characters other than
+// newlines and tabs from pasted text, and converts carriage returns to
+// newlines.
+func SanitizePaste(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		switch {
+		case r == '\r':
+			sb.WriteRune('\n')
+		case r == '\x1b':
+			i += escapeSequenceLen(text[i:])
+		case r == '\n' || r == '\t':
+			sb.WriteRune(r)
+		case unicode.IsControl(r):
+			// Drop other C0 and C1 control characters, including DEL.
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// Returns the length of the rest of an escape sequence after the ESC.
+func escapeSequenceLen(s string) int {
+	if s == "" {
+		return 0
+	}
+	switch s[0] {
+	case '[':
+		// CSI: parameter and intermediate bytes, then a final byte.
+		for i := 1; i < len(s); i++ {
+			if 0x40 <= s[i] && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']', 'P', '_', '^':
+		// OSC and other string sequences, terminated by BEL or ESC \.
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	default:
+		_, size := utf8.DecodeRuneInString(s)
+		return size
+	}
+}
+
 // Tries to expand a simple abbreviation. This function assumes that the state
 // mutex is already being held.
 func (w *codeArea) expandSimpleAbbr() {
@@ -282,12 +538,28 @@ func (w *codeArea) expandWordAbbr(trigger rune, categorizer func(rune) int) {
 	}
 }
 
+func (w *codeArea) handleMouseEvent(event term.MouseEvent) bool {
+	if !event.Down || event.Button != term.LeftButton || w.lastWidth == 0 {
+		return false
+	}
+	pos := term.Pos{Line: event.Line + w.lastTrimmed, Col: event.Col}
+	prompt := w.Prompt()
+	w.resetInserts()
+	w.MutateState(func(s *CodeAreaState) {
+		if s.Pending != (PendingCode{}) {
+			// The rendered code is not the same as the buffer.
+			return
+		}
+		s.Buffer.Dot = dotAt(prompt, s.Buffer.Content, w.lastWidth, pos)
+	})
+	return true
+}
+
 func (w *codeArea) handleKeyEvent(key ui.Key) bool {
 	isFuncKey := key.Mod != 0 || key.Rune < 0
 	if w.pasting {
 		if isFuncKey {
-			// TODO: Notify the user of the error, or insert the original
-			// character as is.
+			w.pasteBuffer.WriteString(pastedKeyText(key))
 		} else {
 			w.pasteBuffer.WriteRune(key.Rune)
 		}
@@ -298,6 +570,13 @@ func (w *codeArea) handleKeyEvent(key ui.Key) bool {
 		return true
 	}
 
+	if w.CopyState().Mode != InsertMode && key != ui.K('\n') {
+		// Outside insert mode, keys that are not handled by the bindings
+		// never edit the buffer.
+		w.resetInserts()
+		return !isFuncKey
+	}
+
 	// We only implement essential keybindings here. Other keybindings can be
 	// added via handler overlays.
 	switch key {
@@ -335,6 +614,7 @@ func (w *codeArea) handleKeyEvent(key ui.Key) bool {
 		w.lastCodeBuffer = w.State.Buffer
 		w.expandSimpleAbbr()
 		w.expandWordAbbr(key.Rune, CategorizeSmallWord)
+		w.history.update(w.State.Buffer)
 		return true
 	}
 }
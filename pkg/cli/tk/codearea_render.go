@@ -15,7 +15,11 @@ type view struct {
 	errors  []error
 }
 
-var stylingForPending = ui.Underlined
+var (
+	stylingForPending    = ui.Underlined
+	stylingForSelection  = ui.Inverse
+	stylingForSuggestion = ui.Dim
+)
 
 func getView(w *codeArea) *view {
 	s := w.CopyState()
@@ -26,6 +30,14 @@ func getView(w *codeArea) *view {
 		parts := styledCode.Partition(pFrom, pTo)
 		pending := ui.StyleText(parts[1], stylingForPending)
 		styledCode = ui.Concat(parts[0], pending, parts[2])
+	} else if sFrom, sTo := s.Selection(); sFrom < sTo {
+		// Apply stylingForSelection to [sFrom, sTo)
+		parts := styledCode.Partition(sFrom, sTo)
+		selected := ui.StyleText(parts[1], stylingForSelection)
+		styledCode = ui.Concat(parts[0], selected, parts[2])
+	}
+	if suggestion := w.suggestion(&s); suggestion != "" {
+		styledCode = ui.Concat(styledCode, ui.T(suggestion, stylingForSuggestion))
 	}
 
 	var rprompt ui.Text
@@ -95,18 +107,76 @@ func renderView(v *view, buf *term.BufferBuilder) {
 	}
 }
 
-func truncateToHeight(b *term.Buffer, maxHeight int) {
+// Truncates the buffer to at most maxHeight lines, and returns the number of
+// lines removed from the top.
+func truncateToHeight(b *term.Buffer, maxHeight int) int {
 	switch {
 	case len(b.Lines) <= maxHeight:
 		// We can show all line; do nothing.
+		return 0
 	case b.Dot.Line < maxHeight:
 		// We can show all lines before the cursor, and as many lines after the
 		// cursor as we can, adding up to maxHeight.
 		b.TrimToLines(0, maxHeight)
+		return 0
 	default:
 		// We can show maxHeight lines before and including the cursor line.
-		b.TrimToLines(b.Dot.Line-maxHeight+1, b.Dot.Line+1)
+		low := b.Dot.Line - maxHeight + 1
+		b.TrimToLines(low, b.Dot.Line+1)
+		return low
+	}
+}
+
+// Returns the byte index of the last rune in the code that is rendered at or
+// before pos, or len(code) if pos is after the end of the code. The prompt and
+// code are laid out in the same way as renderView.
+func dotAt(prompt ui.Text, code string, width int, pos term.Pos) int {
+	bb := term.NewBufferBuilder(width)
+	bb.EagerWrap = true
+	bb.WriteStyled(prompt)
+	if len(bb.Lines) == 1 && bb.Col*2 < bb.Width {
+		bb.Indent = bb.Col
+	}
+	dot, lastLine := 0, 0
+	// Returns the position where the next rune starts, treating the first rune
+	// on each line after the first as starting from the first column, so that
+	// clicking the indentation selects it.
+	nextStart := func(r rune) term.Pos {
+		start := bb.Cursor()
+		if r != '\n' && bb.Col+renderedWidth(r) > bb.Width {
+			// The rune will be wrapped to the next line.
+			start.Line++
+		}
+		if start.Line != lastLine {
+			start.Col = 0
+		}
+		return start
 	}
+	for i, r := range code {
+		start := nextStart(r)
+		if posBefore(pos, start) {
+			return dot
+		}
+		dot, lastLine = i, start.Line
+		bb.WriteRuneSGR(r, "")
+	}
+	if !posBefore(pos, nextStart('\n')) {
+		dot = len(code)
+	}
+	return dot
+}
+
+// Returns the width of a rune when written with term.BufferBuilder.
+func renderedWidth(r rune) int {
+	if r < 0x20 || r == 0x7f {
+		// Written in caret notation.
+		return 2
+	}
+	return wcwidth.OfRune(r)
+}
+
+func posBefore(a, b term.Pos) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
 }
 
 func styledWcswidth(t ui.Text) int {
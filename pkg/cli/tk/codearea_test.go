@@ -156,6 +156,66 @@ var codeAreaRenderTests = []renderTest{
 		Width: 10, Height: 24,
 		Want: bb(10).Write("code").SetDotHere(),
 	},
+	{
+		Name: "selection in visual mode",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "code", Dot: 1},
+			Mode:   VisualMode, Anchor: 2,
+		}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("c").SetDotHere().WriteStringSGR("od", "7").Write("e"),
+	},
+	{
+		Name: "no selection in normal mode",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "code", Dot: 1},
+			Mode:   NormalMode, Anchor: 2,
+		}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("c").SetDotHere().Write("ode"),
+	},
+	{
+		Name: "suggestion at end of buffer",
+		Given: NewCodeArea(CodeAreaSpec{
+			Autosuggester: func(code string) string { return "-" + code },
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "code", Dot: 4},
+			}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("code").SetDotHere().WriteStringSGR("-code", "2"),
+	},
+	{
+		Name: "no suggestion when dot is not at end of buffer",
+		Given: NewCodeArea(CodeAreaSpec{
+			Autosuggester: func(code string) string { return "-" + code },
+			State: CodeAreaState{
+				Buffer: CodeBuffer{Content: "code", Dot: 2},
+			}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("co").SetDotHere().Write("de"),
+	},
+	{
+		Name: "no suggestion with pending code",
+		Given: NewCodeArea(CodeAreaSpec{
+			Autosuggester: func(code string) string { return "-" + code },
+			State: CodeAreaState{
+				Buffer:  CodeBuffer{Content: "code", Dot: 4},
+				Pending: PendingCode{From: 4, To: 4, Content: "x"},
+			}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("code").WriteStringSGR("x", "4").SetDotHere(),
+	},
+	{
+		Name: "no suggestion when hidden",
+		Given: NewCodeArea(CodeAreaSpec{
+			Autosuggester: func(code string) string { return "-" + code },
+			State: CodeAreaState{
+				Buffer:         CodeBuffer{Content: "code", Dot: 4},
+				HideSuggestion: true,
+			}}),
+		Width: 10, Height: 24,
+		Want: bb(10).Write("code").SetDotHere(),
+	},
 	{
 		Name: "prioritize lines before the cursor with small height",
 		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
@@ -224,6 +284,27 @@ var codeAreaHandleTests = []handleTest{
 			term.PasteSetting(false)},
 		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "ab", Dot: 2}},
 	},
+	{
+		Name:  "literal paste restoring and sanitizing control characters",
+		Given: NewCodeArea(CodeAreaSpec{}),
+		Events: []term.Event{
+			term.PasteSetting(true),
+			term.K('a'), term.K('M', ui.Ctrl), term.K('b'), term.K('G', ui.Ctrl),
+			term.K('\t'), term.K('c'),
+			term.PasteSetting(false)},
+		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "a\nb\tc", Dot: 5}},
+	},
+	{
+		Name: "paste handled by OnPaste",
+		Given: NewCodeArea(CodeAreaSpec{
+			OnPaste: func(text string) bool { return text == "a\rb\x1bc" }}),
+		Events: []term.Event{
+			term.PasteSetting(true),
+			term.K('a'), term.K('M', ui.Ctrl), term.K('b'), term.K('c', ui.Alt),
+			term.K(ui.F1),
+			term.PasteSetting(false)},
+		WantNewState: CodeAreaState{},
+	},
 	{
 		Name:  "quoted paste",
 		Given: NewCodeArea(CodeAreaSpec{QuotePaste: func() bool { return true }}),
@@ -373,6 +454,14 @@ var codeAreaHandleTests = []handleTest{
 		Events:       []term.Event{term.K('a')},
 		WantNewState: CodeAreaState{Buffer: CodeBuffer{Content: "b", Dot: 1}},
 	},
+	{
+		Name: "keys not inserted in normal mode",
+		Given: NewCodeArea(CodeAreaSpec{State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "code", Dot: 1}, Mode: NormalMode}}),
+		Events: []term.Event{term.K('x'), term.K(ui.Backspace)},
+		WantNewState: CodeAreaState{
+			Buffer: CodeBuffer{Content: "code", Dot: 1}, Mode: NormalMode},
+	},
 	{
 		// Regression test for #890.
 		Name: "key bindings do not apply when pasting",
@@ -417,7 +506,8 @@ func TestCodeArea_Handle_AbbreviationExpansionInterruptedByExternalMutation(t *t
 	w.MutateState(func(s *CodeAreaState) { s.Buffer.InsertAtDot("d") })
 	w.Handle(term.K('n'))
 	wantState := CodeAreaState{Buffer: CodeBuffer{Content: "ddn", Dot: 3}}
-	if state := w.CopyState(); !reflect.DeepEqual(state, wantState) {
+	state := w.CopyState()
+	if !reflect.DeepEqual(state, wantState) {
 		t.Errorf("got state %v, want %v", state, wantState)
 	}
 }
@@ -440,6 +530,86 @@ func TestCodeArea_Handle_DefaultNoopSubmit(t *testing.T) {
 	// No panic, we are good
 }
 
+func TestCodeArea_Handle_EnterEmitsSubmitInNormalMode(t *testing.T) {
+	submitted := false
+	w := NewCodeArea(CodeAreaSpec{
+		OnSubmit: func() { submitted = true },
+		State:    CodeAreaState{Mode: NormalMode}})
+	w.Handle(term.K('\n'))
+	if submitted != true {
+		t.Errorf("OnSubmit not triggered")
+	}
+}
+
+func TestCodeArea_Handle_ClickMovesDot(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{
+		Prompt: p(ui.T("> ")),
+		State:  CodeAreaState{Buffer: CodeBuffer{Content: "echo\nfoobarx", Dot: 0}},
+	})
+	// Lines are "> echo", "  fooba" and "  rx".
+	w.Render(7, 10)
+
+	tt.Test(t, tt.Fn("click", func(line, col int) int {
+		w.Handle(term.MouseEvent{Pos: term.Pos{Line: line, Col: col},
+			Down: true, Button: term.LeftButton})
+		return w.CopyState().Buffer.Dot
+	}), tt.Table{
+		// On the prompt.
+		tt.Args(0, 0).Rets(0),
+		// On the code.
+		tt.Args(0, 3).Rets(1),
+		// After the end of a line.
+		tt.Args(0, 6).Rets(4),
+		// On the indentation.
+		tt.Args(1, 0).Rets(5),
+		// On a wrapped line.
+		tt.Args(2, 3).Rets(11),
+		// After the end of the code.
+		tt.Args(3, 0).Rets(12),
+	})
+}
+
+func TestCodeArea_Handle_ClickMovesDotInTruncatedBuffer(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{
+		State: CodeAreaState{Buffer: CodeBuffer{Content: "a\nb\nc", Dot: 5}},
+	})
+	// Only "b" and "c" are shown.
+	w.Render(10, 2)
+	w.Handle(term.MouseEvent{Down: true, Button: term.LeftButton})
+	if dot := w.CopyState().Buffer.Dot; dot != 2 {
+		t.Errorf("got dot %d, want 2", dot)
+	}
+}
+
+func TestSanitizePaste(t *testing.T) {
+	tt.Test(t, tt.Fn("SanitizePaste", SanitizePaste), tt.Table{
+		tt.Args("echo foo\tbar\n").Rets("echo foo\tbar\n"),
+		tt.Args("a\r\nb\rc").Rets("a\nb\nc"),
+		// CSI and OSC sequences
+		tt.Args("\x1b[31mred\x1b[m").Rets("red"),
+		tt.Args("\x1b]0;title\arest").Rets("rest"),
+		tt.Args("\x1b]0;title\x1b\\rest").Rets("rest"),
+		// Other escape sequences
+		tt.Args("a\x1bxb\x1b").Rets("ab"),
+		// Other control characters, including C1 ones
+		tt.Args("a\x00\x07\x7f\u009bb").Rets("ab"),
+	})
+}
+
+func TestCodeAreaState_Selection(t *testing.T) {
+	selection := func(s CodeAreaState) (int, int) { return s.Selection() }
+	tt.Test(t, tt.Fn("selection", selection), tt.Table{
+		tt.Args(CodeAreaState{Buffer: CodeBuffer{"code", 1}, Anchor: 3}).Rets(0, 0),
+		tt.Args(CodeAreaState{Buffer: CodeBuffer{"code", 1}, Mode: VisualMode, Anchor: 3}).
+			Rets(1, 4),
+		tt.Args(CodeAreaState{Buffer: CodeBuffer{"你好", 3}, Mode: VisualMode, Anchor: 0}).
+			Rets(0, 6),
+		// The end is clamped to the buffer.
+		tt.Args(CodeAreaState{Buffer: CodeBuffer{"code", 4}, Mode: VisualMode, Anchor: 2}).
+			Rets(2, 4),
+	})
+}
+
 func TestCodeArea_State(t *testing.T) {
 	w := NewCodeArea(CodeAreaSpec{})
 	w.MutateState(func(s *CodeAreaState) { s.Buffer.Content = "code" })
@@ -466,3 +636,51 @@ func TestCodeAreaState_ApplyPending(t *testing.T) {
 			Rets(CodeAreaState{Buffer: CodeBuffer{Content: "x", Dot: 1}, HideRPrompt: true}),
 	})
 }
+
+func TestCodeArea_UndoRedo(t *testing.T) {
+	w := NewCodeArea(CodeAreaSpec{})
+	buffer := func() CodeBuffer { return w.CopyState().Buffer }
+	undo, redo := w.Undo, w.Redo
+
+	// Consecutive insertions are undone together.
+	w.Handle(term.K('a'))
+	w.Handle(term.K('b'))
+	w.MutateState(func(s *CodeAreaState) { s.Buffer.InsertAtDot("cd") })
+	// Moving the dot stops merging.
+	w.MutateState(func(s *CodeAreaState) { s.Buffer.Dot = 0 })
+	w.Handle(term.K('x'))
+	w.Handle(term.K(ui.Backspace))
+
+	wantBuffers := []CodeBuffer{{"xabcd", 1}, {"abcd", 0}, {"", 0}}
+	for _, want := range wantBuffers {
+		if !undo() {
+			t.Fatalf("Undo() returned false")
+		}
+		if got := buffer(); got != want {
+			t.Errorf("got buffer %v after undo, want %v", got, want)
+		}
+	}
+	if undo() {
+		t.Errorf("Undo() returned true with nothing to undo")
+	}
+
+	for i := len(wantBuffers) - 2; i >= 0; i-- {
+		if !redo() {
+			t.Fatalf("Redo() returned false")
+		}
+		if got, want := buffer(), wantBuffers[i]; got != want {
+			t.Errorf("got buffer %v after redo, want %v", got, want)
+		}
+	}
+
+	// A new change clears the redo history.
+	undo()
+	w.Handle(term.K('y'))
+	if redo() {
+		t.Errorf("Redo() returned true after a new change")
+	}
+	undo()
+	if got, want := buffer(), (CodeBuffer{"abcd", 0}); got != want {
+		t.Errorf("got buffer %v, want %v", got, want)
+	}
+}
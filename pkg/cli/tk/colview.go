@@ -49,6 +49,8 @@ type colView struct {
 	// Mutex for synchronizing access to State.
 	StateMutex sync.RWMutex
 	ColViewSpec
+	// Width of the last Render, used for finding the column under the mouse.
+	lastWidth int
 }
 
 // NewColView creates a new ColView from the given spec.
@@ -95,6 +97,7 @@ const colViewColGap = 1
 // Render renders all the columns side by side, putting the dot in the focused
 // column.
 func (w *colView) Render(width, height int) *term.Buffer {
+	w.lastWidth = width
 	cols, widths := w.prepareRender(width)
 	if len(cols) == 0 {
 		return &term.Buffer{Width: width}
@@ -140,10 +143,18 @@ func (w *colView) prepareRender(width int) ([]Widget, []int) {
 
 // Handle handles the event first by consulting the overlay handler, and then
 // delegating the event to the currently focused column.
+//
+// Mouse events on the focused column and wheel events are delegated to the
+// focused column, with the position made relative to the column. Clicking a
+// column to the left or right of the focused column triggers OnLeft or
+// OnRight.
 func (w *colView) Handle(event term.Event) bool {
 	if w.Bindings.Handle(w, event) {
 		return true
 	}
+	if event, ok := event.(term.MouseEvent); ok {
+		return w.handleMouseEvent(event)
+	}
 	state := w.CopyState()
 	if 0 <= state.FocusColumn && state.FocusColumn < len(state.Columns) {
 		if state.Columns[state.FocusColumn].Handle(event) {
@@ -163,6 +174,40 @@ func (w *colView) Handle(event term.Event) bool {
 	}
 }
 
+func (w *colView) handleMouseEvent(event term.MouseEvent) bool {
+	focus := w.CopyState().FocusColumn
+	cols, widths := w.prepareRender(w.lastWidth)
+	if focus < 0 || focus >= len(cols) {
+		return false
+	}
+	if event.Button == term.WheelUp || event.Button == term.WheelDown {
+		return cols[focus].Handle(event)
+	}
+	left := 0
+	for i, col := range cols {
+		if event.Col < left {
+			// In the gap between columns.
+			return false
+		}
+		if event.Col < left+widths[i] {
+			switch {
+			case i == focus:
+				event.Col -= left
+				return col.Handle(event)
+			case !event.Down || event.Button != term.LeftButton:
+				return false
+			case i < focus:
+				w.Left()
+			default:
+				w.Right()
+			}
+			return true
+		}
+		left += widths[i] + colViewColGap
+	}
+	return false
+}
+
 func (w *colView) Left() {
 	w.OnLeft(w)
 }
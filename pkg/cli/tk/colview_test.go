@@ -1,6 +1,7 @@
 package tk
 
 import (
+	"reflect"
 	"testing"
 
 	"src.elv.sh/pkg/cli/term"
@@ -122,6 +123,43 @@ func TestColView_Handle(t *testing.T) {
 	expectUnhandled(term.K('b'))
 }
 
+func TestColView_Handle_Mouse(t *testing.T) {
+	var leftRight []string
+	newCol := func() ListBox {
+		return NewListBox(ListBoxSpec{State: ListBoxState{Items: TestItems{NItems: 5}}})
+	}
+	focused := newCol()
+	w := NewColView(ColViewSpec{
+		State: ColViewState{
+			Columns: []Widget{newCol(), focused, newCol()}, FocusColumn: 1},
+		OnLeft:  func(ColView) { leftRight = append(leftRight, "left") },
+		OnRight: func(ColView) { leftRight = append(leftRight, "right") },
+	})
+	// Columns occupy [0, 3), [4, 7) and [8, 11).
+	w.Render(11, 5)
+
+	// Clicking the focused column.
+	w.Handle(click(2, 5))
+	if selected := focused.CopyState().Selected; selected != 2 {
+		t.Errorf("got selected %d, want 2", selected)
+	}
+	// Scrolling the wheel anywhere.
+	w.Handle(term.MouseEvent{Down: true, Button: term.WheelDown})
+	if selected := focused.CopyState().Selected; selected != 3 {
+		t.Errorf("got selected %d, want 3", selected)
+	}
+	// Clicking other columns.
+	w.Handle(click(0, 1))
+	w.Handle(click(0, 9))
+	if !reflect.DeepEqual(leftRight, []string{"left", "right"}) {
+		t.Errorf("got callbacks %v, want [left right]", leftRight)
+	}
+	// Clicking the gap.
+	if w.Handle(click(0, 3)) {
+		t.Errorf("click in the gap handled")
+	}
+}
+
 func TestDistribute(t *testing.T) {
 	tt.Test(t, tt.Fn("distribute", distribute), tt.Table{
 		// Nice integer distributions.
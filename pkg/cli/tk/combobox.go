@@ -29,6 +29,8 @@ type comboBox struct {
 
 	// Last filter value.
 	lastFilter string
+	// Number of lines of the codearea in the last Render.
+	codeAreaLines int
 }
 
 // NewComboBox creates a new ComboBox from the given spec.
@@ -48,6 +50,7 @@ func NewComboBox(spec ComboBoxSpec) ComboBox {
 // Render renders the codearea and the listbox below it.
 func (w *comboBox) Render(width, height int) *term.Buffer {
 	buf := w.codeArea.Render(width, height)
+	w.codeAreaLines = len(buf.Lines)
 	bufListBox := w.listBox.Render(width, height-len(buf.Lines))
 	buf.Extend(bufListBox, false)
 	return buf
@@ -60,7 +63,18 @@ func (w *comboBox) MaxHeight(width, height int) int {
 // Handle first lets the listbox handle the event, and if it is unhandled, lets
 // the codearea handle it. If the codearea has handled the event and the code
 // content has changed, it calls OnFilter with the new content.
+//
+// Mouse events are delivered to the widget under the mouse, except that wheel
+// events are always delivered to the listbox.
 func (w *comboBox) Handle(event term.Event) bool {
+	if event, ok := event.(term.MouseEvent); ok {
+		if event.Line < w.codeAreaLines &&
+			event.Button != term.WheelUp && event.Button != term.WheelDown {
+			return w.codeArea.Handle(event)
+		}
+		event.Line -= w.codeAreaLines
+		return w.listBox.Handle(event)
+	}
 	if w.listBox.Handle(event) {
 		return true
 	}
@@ -90,6 +90,25 @@ func TestComboBox_Handle(t *testing.T) {
 	}
 }
 
+func TestComboBox_Handle_Mouse(t *testing.T) {
+	w := NewComboBox(ComboBoxSpec{
+		CodeArea: CodeAreaSpec{State: CodeAreaState{
+			Buffer: CodeBuffer{Content: "filter", Dot: 6}}},
+		ListBox: ListBoxSpec{
+			State: ListBoxState{Items: TestItems{NItems: 5}}}})
+	// The codearea occupies the first line.
+	w.Render(10, 6)
+
+	w.Handle(click(0, 2))
+	if dot := w.CodeArea().CopyState().Buffer.Dot; dot != 2 {
+		t.Errorf("got dot %d, want 2", dot)
+	}
+	w.Handle(click(3, 2))
+	if selected := w.ListBox().CopyState().Selected; selected != 2 {
+		t.Errorf("got selected %d, want 2", selected)
+	}
+}
+
 func TestRefilter(t *testing.T) {
 	onFilter := make(chan string, 100)
 	w := NewComboBox(ComboBoxSpec{
@@ -3,6 +3,7 @@ package tk
 import (
 	"strings"
 	"sync"
+	"time"
 
 	"src.elv.sh/pkg/cli/term"
 	"src.elv.sh/pkg/ui"
@@ -14,13 +15,17 @@ type ListBox interface {
 	// CopyState returns a copy of the state.
 	CopyState() ListBoxState
 	// Reset resets the state of the widget with the given items and index of
-	// the selected item. It triggers the OnSelect callback if the index is
+	// the selected item. Marks are kept if the items implement KeyedItems, and
+	// cleared otherwise. It triggers the OnSelect callback if the index is
 	// valid.
 	Reset(it Items, selected int)
 	// Select changes the selection by calling f with the current state, and
 	// using the return value as the new selection index. It triggers the
 	// OnSelect callback if the selected index has changed and is valid.
 	Select(f func(ListBoxState) int)
+	// Mark changes the marks by calling f with the current state, and using
+	// the return value as the new set of keys of marked items.
+	Mark(f func(ListBoxState) map[interface{}]bool)
 	// Accept accepts the currently selected item.
 	Accept()
 }
@@ -55,8 +60,27 @@ type listBox struct {
 	StateMutex sync.RWMutex
 	// Configuration and state.
 	ListBoxSpec
+
+	// Columns shown in the last Render, used for finding the item under the
+	// mouse. Guarded by StateMutex.
+	shownCols []shownCol
+	// The item last clicked and when, used for detecting double clicks.
+	lastClicked     int
+	lastClickedTime time.Time
+}
+
+// A column of items shown by the listbox, occupying the cells [left, right)
+// of each line.
+type shownCol struct {
+	left, right int
+	// Index of the item shown on each line.
+	items []int
 }
 
+// Maximum interval between two clicks on the same item for them to be
+// considered a double click.
+const doubleClickInterval = 500 * time.Millisecond
+
 // NewListBox creates a new ListBox from the given spec.
 func NewListBox(spec ListBoxSpec) ListBox {
 	if spec.Bindings == nil {
@@ -76,7 +100,10 @@ func NewListBox(spec ListBoxSpec) ListBox {
 	return &listBox{ListBoxSpec: spec}
 }
 
-var stylingForSelected = ui.Inverse
+var (
+	stylingForSelected = ui.Inverse
+	stylingForMarked   = ui.Bold
+)
 
 func (w *listBox) Render(width, height int) *term.Buffer {
 	if w.Horizontal {
@@ -109,6 +136,7 @@ const listBoxColGap = 2
 func (w *listBox) renderHorizontal(width, height int) *term.Buffer {
 	var state ListBoxState
 	w.mutate(func(s *ListBoxState) {
+		w.shownCols = nil
 		if s.Items == nil || s.Items.Len() == 0 {
 			s.First = 0
 		} else {
@@ -131,10 +159,13 @@ func (w *listBox) renderHorizontal(width, height int) *term.Buffer {
 	remainedWidth := width
 	hasCropped := false
 	last := first
+	var shownCols []shownCol
 	for i := first; i < n; i += height {
 		selectedRow := -1
 		// Render the column starting from i.
 		col := make([]ui.Text, 0, height)
+		var marked []bool
+		var shownItems []int
 		for j := i; j < i+height && j < n; j++ {
 			last = j
 			item := items.Show(j)
@@ -142,6 +173,8 @@ func (w *listBox) renderHorizontal(width, height int) *term.Buffer {
 				selectedRow = j - i
 			}
 			col = append(col, item)
+			marked = append(marked, state.IsMarked(j))
+			shownItems = append(shownItems, j)
 		}
 
 		colWidth := maxWidth(items, w.Padding, i, i+height)
@@ -149,9 +182,11 @@ func (w *listBox) renderHorizontal(width, height int) *term.Buffer {
 			colWidth = remainedWidth
 			hasCropped = true
 		}
+		shownCols = append(shownCols,
+			shownCol{buf.Width, buf.Width + colWidth, shownItems})
 
 		colBuf := croppedLines{
-			lines: col, padding: w.Padding,
+			lines: col, marked: marked, padding: w.Padding,
 			selectFrom: selectedRow, selectTo: selectedRow + 1,
 			extendStyle: w.ExtendStyle}.Render(colWidth, height)
 		buf.ExtendRight(colBuf)
@@ -165,6 +200,7 @@ func (w *listBox) renderHorizontal(width, height int) *term.Buffer {
 	}
 	// We may not have used all the width required; force buffer width.
 	buf.Width = width
+	w.setShownCols(shownCols)
 	if first != 0 || last != n-1 || hasCropped {
 		scrollbar := HScrollbar{Total: n, Low: first, High: last + 1}
 		buf.Extend(scrollbar.Render(width, 1), false)
@@ -176,6 +212,7 @@ func (w *listBox) renderVertical(width, height int) *term.Buffer {
 	var state ListBoxState
 	var firstCrop int
 	w.mutate(func(s *ListBoxState) {
+		w.shownCols = nil
 		if s.Items == nil || s.Items.Len() == 0 {
 			s.First = 0
 		} else {
@@ -192,6 +229,8 @@ func (w *listBox) renderVertical(width, height int) *term.Buffer {
 	items, selected, first := state.Items, state.Selected, state.First
 	n := items.Len()
 	allLines := []ui.Text{}
+	var marked []bool
+	var shownItems []int
 	hasCropped := firstCrop > 0
 
 	var i, selectFrom, selectTo int
@@ -212,10 +251,15 @@ func (w *listBox) renderVertical(width, height int) *term.Buffer {
 			hasCropped = true
 		}
 		allLines = append(allLines, lines...)
+		for range lines {
+			marked = append(marked, state.IsMarked(i))
+			shownItems = append(shownItems, i)
+		}
 	}
+	w.setShownCols([]shownCol{{0, width, shownItems}})
 
 	var rd Renderer = croppedLines{
-		lines: allLines, padding: w.Padding,
+		lines: allLines, marked: marked, padding: w.Padding,
 		selectFrom: selectFrom, selectTo: selectTo, extendStyle: w.ExtendStyle}
 	if first > 0 || i < n || hasCropped {
 		rd = VScrollbarContainer{
@@ -227,7 +271,10 @@ func (w *listBox) renderVertical(width, height int) *term.Buffer {
 }
 
 type croppedLines struct {
-	lines       []ui.Text
+	lines []ui.Text
+	// Whether each line belongs to a marked item. May be nil if no item is
+	// marked.
+	marked      []bool
 	padding     int
 	selectFrom  int
 	selectTo    int
@@ -258,6 +305,9 @@ func (c croppedLines) Render(width, height int) *term.Buffer {
 			}
 			acc = ui.Concat(acc, right).TrimWcwidth(width)
 		}
+		if i < len(c.marked) && c.marked[i] {
+			acc = ui.StyleText(acc, stylingForMarked)
+		}
 		if selected {
 			acc = ui.StyleText(acc, stylingForSelected)
 		}
@@ -267,10 +317,37 @@ func (c croppedLines) Render(width, height int) *term.Buffer {
 	return bb.Buffer()
 }
 
+func (w *listBox) setShownCols(cols []shownCol) {
+	w.StateMutex.Lock()
+	defer w.StateMutex.Unlock()
+	w.shownCols = cols
+}
+
+// Returns the index of the item shown at the given position in the last
+// Render, or -1 if there is no item there.
+func (w *listBox) itemAt(pos term.Pos) int {
+	w.StateMutex.RLock()
+	defer w.StateMutex.RUnlock()
+	for _, col := range w.shownCols {
+		if col.left <= pos.Col && pos.Col < col.right &&
+			0 <= pos.Line && pos.Line < len(col.items) {
+			return col.items[pos.Line]
+		}
+	}
+	return -1
+}
+
+// Handle handles events with the bindings first. Unhandled Up, Down and Enter
+// keys move the selection and accept the selected item. Clicking an item
+// selects it, clicking it again quickly accepts it, and scrolling the mouse
+// wheel moves the selection.
 func (w *listBox) Handle(event term.Event) bool {
 	if w.Bindings.Handle(w, event) {
 		return true
 	}
+	if event, ok := event.(term.MouseEvent); ok {
+		return w.handleMouseEvent(event)
+	}
 
 	switch event {
 	case term.K(ui.Up):
@@ -286,6 +363,37 @@ func (w *listBox) Handle(event term.Event) bool {
 	return false
 }
 
+func (w *listBox) handleMouseEvent(event term.MouseEvent) bool {
+	if !event.Down {
+		return false
+	}
+	switch event.Button {
+	case term.WheelUp:
+		w.Select(Prev)
+		return true
+	case term.WheelDown:
+		w.Select(Next)
+		return true
+	case term.LeftButton:
+		i := w.itemAt(event.Pos)
+		if i < 0 {
+			return false
+		}
+		now := time.Now()
+		doubleClick := i == w.lastClicked &&
+			now.Sub(w.lastClickedTime) < doubleClickInterval
+		w.Select(func(ListBoxState) int { return i })
+		if doubleClick {
+			w.lastClickedTime = time.Time{}
+			w.Accept()
+		} else {
+			w.lastClicked, w.lastClickedTime = i, now
+		}
+		return true
+	}
+	return false
+}
+
 func (w *listBox) CopyState() ListBoxState {
 	w.StateMutex.RLock()
 	defer w.StateMutex.RUnlock()
@@ -293,7 +401,14 @@ func (w *listBox) CopyState() ListBoxState {
 }
 
 func (w *listBox) Reset(it Items, selected int) {
-	w.mutate(func(s *ListBoxState) { *s = ListBoxState{Items: it, Selected: selected} })
+	w.mutate(func(s *ListBoxState) {
+		// Marks are only kept if they can still identify the items.
+		var marked map[interface{}]bool
+		if _, ok := it.(KeyedItems); ok {
+			marked = s.Marked
+		}
+		*s = ListBoxState{Items: it, Selected: selected, Marked: marked}
+	})
 	if 0 <= selected && selected < it.Len() {
 		w.OnSelect(it, selected)
 	}
@@ -312,6 +427,10 @@ func (w *listBox) Select(f func(ListBoxState) int) {
 	}
 }
 
+func (w *listBox) Mark(f func(ListBoxState) map[interface{}]bool) {
+	w.mutate(func(s *ListBoxState) { s.Marked = f(*s) })
+}
+
 // Prev moves the selection to the previous item, or does nothing if the
 // first item is currently selected. It is a suitable as an argument to
 // Widget.Select.
@@ -393,6 +512,56 @@ func horizontal(selected, n, d int) int {
 	return newSelected
 }
 
+// ToggleMark toggles whether the selected item is marked. It is suitable as an
+// argument to Widget.Mark.
+func ToggleMark(s ListBoxState) map[interface{}]bool {
+	if s.Items == nil || s.Selected < 0 || s.Selected >= s.Items.Len() {
+		return s.Marked
+	}
+	marked := copyMarks(s.Marked)
+	key := ItemKey(s.Items, s.Selected)
+	if marked[key] {
+		delete(marked, key)
+	} else {
+		marked[key] = true
+	}
+	return marked
+}
+
+// MarkAll marks all the items. It is suitable as an argument to Widget.Mark.
+func MarkAll(s ListBoxState) map[interface{}]bool {
+	marked := copyMarks(s.Marked)
+	for i := 0; s.Items != nil && i < s.Items.Len(); i++ {
+		marked[ItemKey(s.Items, i)] = true
+	}
+	return marked
+}
+
+// InvertMarks marks all the items that are not marked, and unmarks all the
+// items that are marked. It is suitable as an argument to Widget.Mark.
+func InvertMarks(s ListBoxState) map[interface{}]bool {
+	marked := copyMarks(s.Marked)
+	for i := 0; s.Items != nil && i < s.Items.Len(); i++ {
+		key := ItemKey(s.Items, i)
+		if s.Marked[key] {
+			delete(marked, key)
+		} else {
+			marked[key] = true
+		}
+	}
+	return marked
+}
+
+func copyMarks(m map[interface{}]bool) map[interface{}]bool {
+	copied := make(map[interface{}]bool, len(m)+1)
+	for key, marked := range m {
+		if marked {
+			copied[key] = true
+		}
+	}
+	return copied
+}
+
 func fixIndex(i, n int) int {
 	switch {
 	case i < 0:
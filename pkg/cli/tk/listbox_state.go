@@ -12,6 +12,30 @@ type ListBoxState struct {
 	Selected int
 	First    int
 	Height   int
+	// Keys of marked items, as returned by ItemKey. The map is replaced
+	// instead of modified when the marks change, so it can be shared between
+	// copies of the state.
+	Marked map[interface{}]bool
+}
+
+// IsMarked returns whether the item at the given index is marked.
+func (s ListBoxState) IsMarked(i int) bool {
+	return len(s.Marked) > 0 && s.Marked[ItemKey(s.Items, i)]
+}
+
+// MarkedIndices returns the indices of marked items in increasing order. Marks
+// on items that are not among the current items are ignored.
+func (s ListBoxState) MarkedIndices() []int {
+	if len(s.Marked) == 0 || s.Items == nil {
+		return nil
+	}
+	var indices []int
+	for i := 0; i < s.Items.Len(); i++ {
+		if s.IsMarked(i) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
 }
 
 // Items is an interface for accessing multiple items.
@@ -22,6 +46,26 @@ type Items interface {
 	Len() int
 }
 
+// KeyedItems is an optional interface for Items whose items can be identified
+// across different Items, such as before and after the items are filtered.
+// Marks on such items are kept when a ListBox is reset.
+type KeyedItems interface {
+	Items
+	// Key returns a comparable value that identifies the item at the given
+	// index.
+	Key(i int) interface{}
+}
+
+// ItemKey returns the key of the item at the given index, which is used for
+// marking the item. It is the return value of the Key method if it implements
+// KeyedItems, and the index otherwise.
+func ItemKey(it Items, i int) interface{} {
+	if keyed, ok := it.(KeyedItems); ok {
+		return keyed.Key(i)
+	}
+	return i
+}
+
 // TestItems is an implementation of Items useful for testing.
 type TestItems struct {
 	Prefix string
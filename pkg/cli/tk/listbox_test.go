@@ -1,6 +1,7 @@
 package tk
 
 import (
+	"reflect"
 	"testing"
 
 	"src.elv.sh/pkg/cli/term"
@@ -121,6 +122,17 @@ var listBoxRenderVerticalTests = []renderTest{
 			Write(" x1   ", ui.FgBlue, ui.BgGreen).
 			Buffer(),
 	},
+	{
+		Name: "marked items",
+		Given: NewListBox(ListBoxSpec{State: ListBoxState{
+			Items: TestItems{NItems: 3}, Selected: 0,
+			Marked: map[interface{}]bool{0: true, 2: true}}}),
+		Width: 10, Height: 3,
+		Want: bb(10).
+			Write("item 0    ", ui.Bold, ui.Inverse).
+			Newline().Write("item 1").
+			Newline().Write("item 2", ui.Bold),
+	},
 }
 
 func TestListBox_Render_Vertical(t *testing.T) {
@@ -231,6 +243,19 @@ var listBoxRenderHorizontalTests = []renderTest{
 			Newline().
 			Write("          ", ui.Inverse, ui.FgMagenta),
 	},
+	{
+		Name: "marked items",
+		Given: NewListBox(ListBoxSpec{
+			Horizontal: true,
+			State: ListBoxState{
+				Items: TestItems{NItems: 2}, Selected: 0,
+				Marked: map[interface{}]bool{1: true}}}),
+		Width: 14, Height: 1,
+		Want: bb(14).
+			Write("item 0", ui.Inverse).
+			Write("  ").
+			Write("item 1", ui.Bold),
+	},
 }
 
 func TestListBox_Render_Horizontal(t *testing.T) {
@@ -304,6 +329,27 @@ var listBoxHandleTests = []handleTest{
 
 		WantNewState: ListBoxState{Items: TestItems{NItems: 10}, Selected: 5},
 	},
+	{
+		Name:  "wheel up moves selection up",
+		Given: NewListBox(ListBoxSpec{State: ListBoxState{Items: TestItems{NItems: 10}, Selected: 5}}),
+		Event: term.MouseEvent{Down: true, Button: term.WheelUp},
+
+		WantNewState: ListBoxState{Items: TestItems{NItems: 10}, Selected: 4},
+	},
+	{
+		Name:  "wheel down moves selection down",
+		Given: NewListBox(ListBoxSpec{State: ListBoxState{Items: TestItems{NItems: 10}, Selected: 5}}),
+		Event: term.MouseEvent{Down: true, Button: term.WheelDown},
+
+		WantNewState: ListBoxState{Items: TestItems{NItems: 10}, Selected: 6},
+	},
+	{
+		Name:  "mouse release not handled",
+		Given: NewListBox(ListBoxSpec{State: ListBoxState{Items: TestItems{NItems: 10}, Selected: 5}}),
+		Event: term.MouseEvent{Down: false, Button: term.LeftButton},
+
+		WantUnhandled: true,
+	},
 	{
 		Name:  "other keys not handled",
 		Given: NewListBox(ListBoxSpec{State: ListBoxState{Items: TestItems{NItems: 10}, Selected: 5}}),
@@ -348,6 +394,64 @@ func TestListBox_Handle_EnterEmitsAccept(t *testing.T) {
 	}
 }
 
+func TestListBox_Handle_Click(t *testing.T) {
+	var accepted []int
+	w := NewListBox(ListBoxSpec{
+		OnAccept: func(it Items, i int) { accepted = append(accepted, i) },
+		State:    ListBoxState{Items: TestItems{NItems: 10}},
+	})
+	w.Render(10, 3)
+
+	// Clicking an item selects it.
+	if !w.Handle(click(1, 2)) {
+		t.Errorf("click on item not handled")
+	}
+	if selected := w.CopyState().Selected; selected != 1 {
+		t.Errorf("got selected %d, want 1", selected)
+	}
+	if len(accepted) != 0 {
+		t.Errorf("got accepted %v after one click, want none", accepted)
+	}
+	// Clicking it again accepts it.
+	w.Handle(click(1, 2))
+	if !reflect.DeepEqual(accepted, []int{1}) {
+		t.Errorf("got accepted %v after double click, want [1]", accepted)
+	}
+	// Clicks on different items are not double clicks.
+	w.Handle(click(0, 0))
+	w.Handle(click(2, 0))
+	if !reflect.DeepEqual(accepted, []int{1}) {
+		t.Errorf("got accepted %v after clicking different items, want [1]", accepted)
+	}
+	// Clicking below the items is unhandled.
+	if w.Handle(click(3, 0)) {
+		t.Errorf("click below items handled")
+	}
+}
+
+func TestListBox_Handle_ClickInHorizontalLayout(t *testing.T) {
+	w := NewListBox(ListBoxSpec{
+		Horizontal: true,
+		State:      ListBoxState{Items: TestItems{NItems: 10}},
+	})
+	// Columns are "item 0" and "item 1" in [0, 6), "item 2" and "item 3" in
+	// [8, 14), and so on.
+	w.Render(40, 2)
+
+	w.Handle(click(1, 9))
+	if selected := w.CopyState().Selected; selected != 3 {
+		t.Errorf("got selected %d, want 3", selected)
+	}
+	if w.Handle(click(0, 7)) {
+		t.Errorf("click in the gap between columns handled")
+	}
+}
+
+func click(line, col int) term.MouseEvent {
+	return term.MouseEvent{
+		Pos: term.Pos{Line: line, Col: col}, Down: true, Button: term.LeftButton}
+}
+
 func TestListBox_Select_ChangeState(t *testing.T) {
 	// number of items = 10, height = 3
 	var tests = []struct {
@@ -445,6 +549,74 @@ func TestListBox_Select_CallOnSelect(t *testing.T) {
 	verifyOnSelect(0)
 }
 
+func TestListBox_Mark(t *testing.T) {
+	var tests = []struct {
+		name     string
+		selected int
+		before   map[interface{}]bool
+		f        func(ListBoxState) map[interface{}]bool
+		after    []int
+	}{
+		{"ToggleMark marking", 1, nil, ToggleMark, []int{1}},
+		{"ToggleMark unmarking", 1, map[interface{}]bool{1: true, 2: true}, ToggleMark, []int{2}},
+		{"ToggleMark with invalid selection", -1, map[interface{}]bool{2: true}, ToggleMark, []int{2}},
+		{"MarkAll", 0, map[interface{}]bool{2: true}, MarkAll, []int{0, 1, 2, 3}},
+		{"InvertMarks", 0, map[interface{}]bool{0: true, 2: true}, InvertMarks, []int{1, 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := copyMarks(test.before)
+			w := NewListBox(ListBoxSpec{
+				State: ListBoxState{
+					Items: TestItems{NItems: 4}, Selected: test.selected,
+					Marked: test.before}})
+			w.Mark(test.f)
+			if marked := w.CopyState().MarkedIndices(); !reflect.DeepEqual(marked, test.after) {
+				t.Errorf("marked = %v, want %v", marked, test.after)
+			}
+			if len(test.before) != len(before) {
+				t.Errorf("the old marks were modified")
+			}
+		})
+	}
+}
+
+func TestListBox_Reset_ClearsMarks(t *testing.T) {
+	w := NewListBox(ListBoxSpec{
+		State: ListBoxState{Items: TestItems{NItems: 4}, Marked: map[interface{}]bool{1: true}}})
+	w.Reset(TestItems{NItems: 2}, 0)
+	if marked := w.CopyState().MarkedIndices(); len(marked) != 0 {
+		t.Errorf("marked = %v, want none", marked)
+	}
+}
+
+// Items whose keys are the strings shown.
+type keyedItems []string
+
+func (it keyedItems) Show(i int) ui.Text    { return ui.T(it[i]) }
+func (it keyedItems) Len() int              { return len(it) }
+func (it keyedItems) Key(i int) interface{} { return it[i] }
+
+func TestListBox_Reset_KeepsMarksOfKeyedItems(t *testing.T) {
+	w := NewListBox(ListBoxSpec{
+		State: ListBoxState{Items: keyedItems{"a", "b", "c"}, Selected: 1}})
+	w.Mark(ToggleMark)
+	w.Mark(MarkAll)
+	w.Mark(ToggleMark)
+
+	// Marks on items that are filtered out are kept, but not reported.
+	w.Reset(keyedItems{"c"}, 0)
+	if marked := w.CopyState().MarkedIndices(); !reflect.DeepEqual(marked, []int{0}) {
+		t.Errorf("marked = %v, want [0]", marked)
+	}
+	w.Mark(InvertMarks)
+
+	w.Reset(keyedItems{"a", "b", "c"}, 0)
+	if marked := w.CopyState().MarkedIndices(); !reflect.DeepEqual(marked, []int{0}) {
+		t.Errorf("marked = %v, want [0]", marked)
+	}
+}
+
 func TestListBox_Accept_IndexCheck(t *testing.T) {
 	tests := []struct {
 		name         string
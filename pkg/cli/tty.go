@@ -31,6 +31,11 @@ type TTY interface {
 	SetRawInput(n int)
 	// CloseReader releases resources allocated for reading terminal events.
 	CloseReader()
+	// SetMouse turns reporting of mouse events on or off.
+	SetMouse(on bool)
+	// RequestCursorPosition requests the terminal to report the current
+	// cursor position, which is delivered as a term.CursorPosition event.
+	RequestCursorPosition()
 
 	term.Writer
 
@@ -100,6 +105,14 @@ func (t *aTTY) SetRawInput(n int) {
 	t.raw = n
 }
 
+func (t *aTTY) SetMouse(on bool) {
+	term.SetMouse(t.out, on)
+}
+
+func (t *aTTY) RequestCursorPosition() {
+	term.RequestCursorPosition(t.out)
+}
+
 func (t *aTTY) CloseReader() {
 	if t.r != nil {
 		t.r.Close()
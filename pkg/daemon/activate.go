@@ -11,6 +11,7 @@ import (
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/daemon/internal/api"
 	"src.elv.sh/pkg/fsutil"
+	"src.elv.sh/pkg/store"
 )
 
 var (
@@ -163,8 +164,9 @@ var startProcess = func(name string, argv []string, attr *os.ProcAttr) error {
 
 // Spawns a daemon process in the background by invoking BinPath, passing
 // BinPath, DbPath and SockPath as command-line arguments after resolving them
-// to absolute paths. The daemon log file is created in RunDir, and the stdout
-// and stderr of the daemon is redirected to the log file.
+// to absolute paths (for DbPath, just the path part if it is a store URL). The
+// daemon log file is created in RunDir, and the stdout and stderr of the
+// daemon is redirected to the log file.
 //
 // A suitable ProcAttr is chosen depending on the OS and makes sure that the
 // daemon is detached from the current terminal, so that it is not affected by
@@ -175,10 +177,15 @@ func spawn(cfg *daemondefs.SpawnConfig) error {
 	if err != nil {
 		return errors.New("cannot find elvish: " + err.Error())
 	}
-	dbPath, err := abs("DbPath", cfg.DbPath)
+	// Only the path part of a store URL is resolved.
+	dbScheme, dbPath := store.SplitURL(cfg.DbPath)
+	dbPath, err = abs("DbPath", dbPath)
 	if err != nil {
 		return err
 	}
+	if dbScheme != "" {
+		dbPath = dbScheme + "://" + dbPath
+	}
 	sockPath, err := abs("SockPath", cfg.SockPath)
 	if err != nil {
 		return err
@@ -4,6 +4,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 	"time"
@@ -40,6 +41,29 @@ func TestActivate_SpawnsNewServer(t *testing.T) {
 	}
 }
 
+func TestActivate_SpawnsNewServerWithStoreURL(t *testing.T) {
+	var dbArg string
+	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
+		for i, arg := range argv {
+			if arg == "-db" && i+1 < len(argv) {
+				dbArg = argv[i+1]
+			}
+		}
+		startServer(t, argv)
+		return nil
+	})
+
+	_, err := Activate(io.Discard,
+		&daemondefs.SpawnConfig{DbPath: "jsonl://db", SockPath: "sock", RunDir: "."})
+	if err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+	wd, _ := os.Getwd()
+	if want := "jsonl://" + filepath.Join(wd, "db"); dbArg != want {
+		t.Errorf("got -db %q, want %q", dbArg, want)
+	}
+}
+
 func TestActivate_RemovesHangingSocketAndSpawnsNewServer(t *testing.T) {
 	activated := 0
 	setupForActivate(t, func(name string, argv []string, attr *os.ProcAttr) error {
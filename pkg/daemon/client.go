@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net"
 	"sync"
+	"time"
 
 	"src.elv.sh/pkg/daemon/daemondefs"
 	"src.elv.sh/pkg/daemon/internal/api"
@@ -113,6 +114,26 @@ func (c *client) AddCmd(text string) (int, error) {
 	return res.Seq, err
 }
 
+func (c *client) AddCmdWithMeta(cmd storedefs.Cmd) (int, error) {
+	req := &api.AddCmdWithMetaRequest{Cmd: cmd}
+	res := &api.AddCmdWithMetaResponse{}
+	err := c.call("AddCmdWithMeta", req, res)
+	return res.Seq, err
+}
+
+func (c *client) AddCmds(cmds []storedefs.Cmd) (int, error) {
+	req := &api.AddCmdsRequest{Cmds: cmds}
+	res := &api.AddCmdsResponse{}
+	err := c.call("AddCmds", req, res)
+	return res.Seq, err
+}
+
+func (c *client) SetCmdResult(seq int, duration float64, status string) error {
+	req := &api.SetCmdResultRequest{Seq: seq, Duration: duration, Status: status}
+	res := &api.SetCmdResultResponse{}
+	return c.call("SetCmdResult", req, res)
+}
+
 func (c *client) DelCmd(seq int) error {
 	req := &api.DelCmdRequest{Seq: seq}
 	res := &api.DelCmdResponse{}
@@ -134,18 +155,25 @@ func (c *client) CmdsWithSeq(from, upto int) ([]storedefs.Cmd, error) {
 	return res.Cmds, err
 }
 
+func (c *client) QueryCmds(from, upto int, q storedefs.CmdQuery) ([]storedefs.Cmd, error) {
+	req := &api.QueryCmdsRequest{From: from, Upto: upto, Query: q}
+	res := &api.QueryCmdsResponse{}
+	err := c.call("QueryCmds", req, res)
+	return res.Cmds, err
+}
+
 func (c *client) NextCmd(from int, prefix string) (storedefs.Cmd, error) {
 	req := &api.NextCmdRequest{From: from, Prefix: prefix}
 	res := &api.NextCmdResponse{}
 	err := c.call("NextCmd", req, res)
-	return storedefs.Cmd{Text: res.Text, Seq: res.Seq}, err
+	return res.Cmd, err
 }
 
 func (c *client) PrevCmd(upto int, prefix string) (storedefs.Cmd, error) {
 	req := &api.PrevCmdRequest{Upto: upto, Prefix: prefix}
 	res := &api.PrevCmdResponse{}
 	err := c.call("PrevCmd", req, res)
-	return storedefs.Cmd{Text: res.Text, Seq: res.Seq}, err
+	return res.Cmd, err
 }
 
 func (c *client) AddDir(dir string, incFactor float64) error {
@@ -187,3 +215,23 @@ func (c *client) DelSharedVar(name string) error {
 	res := &api.DelSharedVarResponse{}
 	return c.call("DelSharedVar", req, res)
 }
+
+func (c *client) CompareAndSwapSharedVar(name string, old, new *string) (bool, error) {
+	req := &api.CompareAndSwapSharedVarRequest{Name: name}
+	if old != nil {
+		req.Old, req.HasOldValue = *old, true
+	}
+	if new != nil {
+		req.New, req.HasNewValue = *new, true
+	}
+	res := &api.CompareAndSwapSharedVarResponse{}
+	err := c.call("CompareAndSwapSharedVar", req, res)
+	return res.Swapped, err
+}
+
+func (c *client) WatchSharedVar(name string, version int, timeout time.Duration) (int, error) {
+	req := &api.WatchSharedVarRequest{Name: name, Version: version, Timeout: timeout}
+	res := &api.WatchSharedVarResponse{}
+	err := c.call("WatchSharedVar", req, res)
+	return res.Version, err
+}
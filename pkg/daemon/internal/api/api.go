@@ -3,11 +3,13 @@
 package api
 
 import (
+	"time"
+
 	"src.elv.sh/pkg/store/storedefs"
 )
 
 // Version is the API version. It should be bumped any time the API changes.
-const Version = -93
+const Version = -97
 
 // ServiceName is the name of the RPC service exposed by the daemon.
 const ServiceName = "Daemon"
@@ -42,6 +44,30 @@ type AddCmdResponse struct {
 	Seq int
 }
 
+type AddCmdWithMetaRequest struct {
+	Cmd storedefs.Cmd
+}
+
+type AddCmdWithMetaResponse struct {
+	Seq int
+}
+
+type AddCmdsRequest struct {
+	Cmds []storedefs.Cmd
+}
+
+type AddCmdsResponse struct {
+	Seq int
+}
+
+type SetCmdResultRequest struct {
+	Seq      int
+	Duration float64
+	Status   string
+}
+
+type SetCmdResultResponse struct{}
+
 type DelCmdRequest struct {
 	Seq int
 }
@@ -75,14 +101,23 @@ type CmdsWithSeqResponse struct {
 	Cmds []storedefs.Cmd
 }
 
+type QueryCmdsRequest struct {
+	From  int
+	Upto  int
+	Query storedefs.CmdQuery
+}
+
+type QueryCmdsResponse struct {
+	Cmds []storedefs.Cmd
+}
+
 type NextCmdRequest struct {
 	From   int
 	Prefix string
 }
 
 type NextCmdResponse struct {
-	Seq  int
-	Text string
+	Cmd storedefs.Cmd
 }
 
 type PrevCmdRequest struct {
@@ -91,8 +126,7 @@ type PrevCmdRequest struct {
 }
 
 type PrevCmdResponse struct {
-	Seq  int
-	Text string
+	Cmd storedefs.Cmd
 }
 
 // Dir requests.
@@ -140,3 +174,25 @@ type DelSharedVarRequest struct {
 }
 
 type DelSharedVarResponse struct{}
+
+// Since gob doesn't distinguish a nil pointer and a pointer to an empty
+// string, whether Old and New are nil are sent separately.
+type CompareAndSwapSharedVarRequest struct {
+	Name                     string
+	Old, New                 string
+	HasOldValue, HasNewValue bool
+}
+
+type CompareAndSwapSharedVarResponse struct {
+	Swapped bool
+}
+
+type WatchSharedVarRequest struct {
+	Name    string
+	Version int
+	Timeout time.Duration
+}
+
+type WatchSharedVarResponse struct {
+	Version int
+}
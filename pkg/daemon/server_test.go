@@ -43,8 +43,13 @@ func TestProgram_ServesClientRequests(t *testing.T) {
 
 	// Test store requests.
 	storetest.TestCmd(t, client)
+	storetest.TestCmdMeta(t, client)
+	storetest.TestAddCmds(t, client)
+	storetest.TestCmdQuery(t, client)
 	storetest.TestDir(t, client)
 	storetest.TestSharedVar(t, client)
+	storetest.TestSharedVarCAS(t, client)
+	storetest.TestSharedVarWatch(t, client)
 }
 
 func TestProgram_StillServesIfCannotOpenDB(t *testing.T) {
@@ -46,6 +46,31 @@ func (s *service) AddCmd(req *api.AddCmdRequest, res *api.AddCmdResponse) error
 	return err
 }
 
+func (s *service) AddCmdWithMeta(req *api.AddCmdWithMetaRequest, res *api.AddCmdWithMetaResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	seq, err := s.store.AddCmdWithMeta(req.Cmd)
+	res.Seq = seq
+	return err
+}
+
+func (s *service) AddCmds(req *api.AddCmdsRequest, res *api.AddCmdsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	seq, err := s.store.AddCmds(req.Cmds)
+	res.Seq = seq
+	return err
+}
+
+func (s *service) SetCmdResult(req *api.SetCmdResultRequest, res *api.SetCmdResultResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.store.SetCmdResult(req.Seq, req.Duration, req.Status)
+}
+
 func (s *service) DelCmd(req *api.DelCmdRequest, res *api.DelCmdResponse) error {
 	if s.err != nil {
 		return s.err
@@ -72,12 +97,21 @@ func (s *service) CmdsWithSeq(req *api.CmdsWithSeqRequest, res *api.CmdsWithSeqR
 	return err
 }
 
+func (s *service) QueryCmds(req *api.QueryCmdsRequest, res *api.QueryCmdsResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	cmds, err := s.store.QueryCmds(req.From, req.Upto, req.Query)
+	res.Cmds = cmds
+	return err
+}
+
 func (s *service) NextCmd(req *api.NextCmdRequest, res *api.NextCmdResponse) error {
 	if s.err != nil {
 		return s.err
 	}
 	cmd, err := s.store.NextCmd(req.From, req.Prefix)
-	res.Seq, res.Text = cmd.Seq, cmd.Text
+	res.Cmd = cmd
 	return err
 }
 
@@ -86,7 +120,7 @@ func (s *service) PrevCmd(req *api.PrevCmdRequest, res *api.PrevCmdResponse) err
 		return s.err
 	}
 	cmd, err := s.store.PrevCmd(req.Upto, req.Prefix)
-	res.Seq, res.Text = cmd.Seq, cmd.Text
+	res.Cmd = cmd
 	return err
 }
 
@@ -135,3 +169,28 @@ func (s *service) DelSharedVar(req *api.DelSharedVarRequest, res *api.DelSharedV
 	}
 	return s.store.DelSharedVar(req.Name)
 }
+
+func (s *service) CompareAndSwapSharedVar(req *api.CompareAndSwapSharedVarRequest, res *api.CompareAndSwapSharedVarResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	var old, new *string
+	if req.HasOldValue {
+		old = &req.Old
+	}
+	if req.HasNewValue {
+		new = &req.New
+	}
+	swapped, err := s.store.CompareAndSwapSharedVar(req.Name, old, new)
+	res.Swapped = swapped
+	return err
+}
+
+func (s *service) WatchSharedVar(req *api.WatchSharedVarRequest, res *api.WatchSharedVarResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+	version, err := s.store.WatchSharedVar(req.Name, req.Version, req.Timeout)
+	res.Version = version
+	return err
+}
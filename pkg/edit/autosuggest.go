@@ -0,0 +1,239 @@
+package edit
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/histutil"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+)
+
+//elvdoc:var autosuggest:enabled
+//
+// Whether to show suggestions while typing. Defaults to `$true`.
+//
+// When enabled, a suggestion for the rest of the current command is shown as
+// dimmed text after the cursor, if the cursor is at the end of the command.
+// Suggestions are computed in the background by
+// [`$edit:autosuggest:provider`](#editautosuggestprovider), so a slow provider
+// never blocks typing. The provider is called for at most one command at a
+// time; if the command changes while it is running, it is called again with the
+// latest command when it finishes.
+//
+// When disabled, no suggestion is shown, so the default bindings of
+// [`edit:autosuggest:accept`](#editautosuggestaccept) and
+// [`edit:autosuggest:accept-word`](#editautosuggestaccept-word) then behave
+// exactly like the cursor movement commands they are bound together with.
+
+//elvdoc:var autosuggest:provider
+//
+// A function that is called with the current command and outputs suggested
+// commands. The first output that is a string starting with the current command
+// and longer than it is used as the suggestion; the part after the current
+// command is shown. Defaults to
+// [`$edit:autosuggest:from-history~`](#editautosuggestfrom-history).
+//
+// Example of suggesting commands from a fixed list:
+//
+// ```elvish
+// set edit:autosuggest:provider = {|code|
+//   put 'git status' 'git commit' | each {|s| if (str:has-prefix $s $code) { put $s } }
+// }
+// ```
+
+//elvdoc:fn autosuggest:from-history
+//
+// ```elvish
+// edit:autosuggest:from-history $code
+// ```
+//
+// Outputs the most recent command in history that starts with `$code` and is
+// longer than it. Outputs nothing if there is no such command.
+
+//elvdoc:fn autosuggest:accept
+//
+// Inserts the whole suggestion that is currently shown. Does nothing if no
+// suggestion is shown.
+//
+// The default binding of <span class="key">Right</span> in insert mode calls
+// this function before `edit:move-dot-right`, so it accepts the suggestion when
+// the cursor is at the end of the command, and moves the cursor otherwise.
+//
+// @cf edit:autosuggest:accept-word
+
+//elvdoc:fn autosuggest:accept-word
+//
+// Inserts the next word of the suggestion that is currently shown, along with
+// any whitespace around it. Does nothing if no suggestion is shown.
+//
+// The default bindings of <span class="key">Ctrl-Right</span> and <span
+// class="key">Alt-Right</span> in insert mode call this function before
+// `edit:move-dot-right-word`.
+//
+// @cf edit:autosuggest:accept
+
+func initAutosuggest(appSpec *cli.AppSpec, ed *Editor, ev *eval.Evaler, hs histutil.Store, nb eval.NsBuilder) {
+	fromHistory := eval.NewGoFn("<edit:autosuggest>from-history",
+		func(fm *eval.Frame, code string) error {
+			if s, ok := suggestFromHistory(hs, code); ok {
+				return fm.ValueOutput().Put(s)
+			}
+			return nil
+		})
+	enabledVar := newBoolVar(true)
+	providerVar := newFnVar(fromHistory)
+	as := &autosuggester{
+		enabled: func() bool { return enabledVar.Get().(bool) },
+		provide: func(code string) []interface{} {
+			return callForValues(ed, ev, "autosuggest provider",
+				providerVar.Get().(eval.Callable), code)
+		},
+		lates: make(chan struct{}, 1),
+	}
+	appSpec.Autosuggester = as
+
+	nb.AddNs("autosuggest", eval.NsBuilder{
+		"enabled":  enabledVar,
+		"provider": providerVar,
+	}.AddFn("from-history", fromHistory).AddGoFns("<edit:autosuggest>", map[string]interface{}{
+		"accept":      func() { as.accept(ed.app, nil) },
+		"accept-word": func() { as.accept(ed.app, firstWordEnd) },
+	}).Ns())
+}
+
+func suggestFromHistory(hs histutil.Store, code string) (string, bool) {
+	c := hs.Cursor(code)
+	for {
+		c.Prev()
+		cmd, err := c.Get()
+		if err != nil {
+			return "", false
+		}
+		if len(cmd.Text) > len(code) {
+			return cmd.Text, true
+		}
+	}
+}
+
+// Implements cli.Autosuggester. Suggestions are computed by a single worker
+// goroutine, which runs while there is a code it hasn't computed the suggestion
+// for, and delivered as late updates. Codes requested while the worker is busy
+// are not computed unless they are still the latest when it becomes free.
+type autosuggester struct {
+	enabled func() bool
+	provide func(code string) []interface{}
+	lates   chan struct{}
+
+	mutex sync.Mutex
+	// The code that the last suggestion was requested for.
+	code string
+	// Whether the worker goroutine is running.
+	working bool
+	// The last suggested command, which may be for an older code.
+	suggested string
+}
+
+func (as *autosuggester) Get(code string) string {
+	if code == "" || !as.enabled() {
+		return ""
+	}
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if code != as.code {
+		as.code = code
+		if !as.working {
+			as.working = true
+			go as.work(code)
+		}
+	}
+	// Keep showing the last suggestion while the new one is being computed,
+	// as long as it still applies.
+	if len(as.suggested) > len(code) && strings.HasPrefix(as.suggested, code) {
+		return as.suggested[len(code):]
+	}
+	return ""
+}
+
+func (as *autosuggester) work(code string) {
+	for {
+		suggested := as.compute(code)
+		as.mutex.Lock()
+		if as.code == code {
+			as.suggested = suggested
+			as.working = false
+			as.mutex.Unlock()
+			break
+		}
+		// The code has changed since the computation started; compute the
+		// latest one.
+		code = as.code
+		as.mutex.Unlock()
+	}
+	select {
+	case as.lates <- struct{}{}:
+	default:
+		// A late update is already pending.
+	}
+}
+
+func (as *autosuggester) compute(code string) string {
+	for _, v := range as.provide(code) {
+		if s, ok := v.(string); ok && len(s) > len(code) && strings.HasPrefix(s, code) {
+			return s
+		}
+	}
+	return ""
+}
+
+func (as *autosuggester) LateUpdates() <-chan struct{} { return as.lates }
+
+// Accepts the suggestion shown in the focused code area. If split is not nil,
+// only the part of the suggestion before the index returned by split is
+// accepted.
+func (as *autosuggester) accept(app cli.App, split func(string) int) {
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return
+	}
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		buf := &s.Buffer
+		if s.HideSuggestion || s.Pending != (tk.PendingCode{}) || buf.Dot != len(buf.Content) {
+			return
+		}
+		suggestion := as.Get(buf.Content)
+		if split != nil {
+			suggestion = suggestion[:split(suggestion)]
+		}
+		buf.InsertAtDot(suggestion)
+	})
+}
+
+// Returns the end of the first word in s, including any whitespace around it.
+func firstWordEnd(s string) int {
+	rest := strings.TrimLeftFunc(s, unicode.IsSpace)
+	rest = strings.TrimLeftFunc(rest, func(r rune) bool { return !unicode.IsSpace(r) })
+	rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	return len(s) - len(rest)
+}
+
+// Calls a function and returns its outputs, with byte outputs split into lines.
+// Errors and outputs to stderr are written as notifications.
+func callForValues(nt notifier, ev *eval.Evaler, ctx string, fn eval.Callable, args ...interface{}) []interface{} {
+	port1, collect, err := eval.CapturePort()
+	if err != nil {
+		nt.notifyf("cannot create pipe for %s: %v", ctx, err)
+		return nil
+	}
+	port2, done2 := makeNotifyPort(nt)
+	err = ev.Call(fn,
+		eval.CallCfg{Args: args, From: "[" + ctx + "]"},
+		eval.EvalCfg{Ports: []*eval.Port{nil, port1, port2}})
+	done2()
+	if err != nil {
+		nt.notifyError(ctx, err)
+	}
+	return collect()
+}
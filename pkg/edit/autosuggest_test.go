@@ -0,0 +1,132 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/ui"
+)
+
+var autosuggestStyles = ui.RuneStylesheet{'v': ui.FgGreen, '-': ui.Dim}
+
+// Autosuggestion is disabled by default.
+func TestAutosuggest_FromHistory(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo foo bar")
+		s.AddCmd("echo lorem")
+		s.AddCmd("put x")
+	}))
+
+	feedInput(f.TTYCtrl, "echo l")
+	f.TestTTY(t,
+		"~> echo l", autosuggestStyles,
+		"   vvvv  ", term.DotHere,
+		"orem", autosuggestStyles,
+		"----")
+	// Typing more of the suggestion keeps it.
+	feedInput(f.TTYCtrl, "o")
+	f.TestTTY(t,
+		"~> echo lo", autosuggestStyles,
+		"   vvvv   ", term.DotHere,
+		"rem", autosuggestStyles,
+		"---")
+
+	f.TTYCtrl.Inject(term.K(ui.Right), term.K('\n'))
+	if code, _ := f.Wait(); code != "echo lorem" {
+		t.Errorf("got code %q, want %q", code, "echo lorem")
+	}
+}
+
+func TestAutosuggest_AcceptWord(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo foo bar")
+	}))
+
+	feedInput(f.TTYCtrl, "echo")
+	f.TestTTY(t,
+		"~> echo", autosuggestStyles,
+		"   vvvv", term.DotHere,
+		" foo bar", autosuggestStyles,
+		"--------")
+	f.TTYCtrl.Inject(term.K(ui.Right, ui.Alt), term.K('\n'))
+	if code, _ := f.Wait(); code != "echo foo " {
+		t.Errorf("got code %q, want %q", code, "echo foo ")
+	}
+}
+
+func TestAutosuggest_Provider(t *testing.T) {
+	f := setup(t, rc(
+		`edit:autosuggest:provider = {|code| put bad $code' ok' $code' not used' }`))
+
+	feedInput(f.TTYCtrl, "echo")
+	f.TestTTY(t,
+		"~> echo", autosuggestStyles,
+		"   vvvv", term.DotHere,
+		" ok", autosuggestStyles,
+		"---")
+}
+
+func TestAutosuggest_Disabled(t *testing.T) {
+	f := setup(t, rc(`edit:autosuggest:enabled = $false`),
+		storeOp(func(s storedefs.Store) { s.AddCmd("echo foo") }))
+
+	feedInput(f.TTYCtrl, "echo")
+	f.TTYCtrl.Inject(term.K(ui.Right), term.K('\n'))
+	if code, _ := f.Wait(); code != "echo" {
+		t.Errorf("got code %q, want %q", code, "echo")
+	}
+}
+
+func TestAutosuggest_FromHistoryFn(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddCmd("echo foo")
+		s.AddCmd("echo")
+	}))
+
+	evals(f.Evaler,
+		`a = [(edit:autosuggest:from-history ech)]`,
+		`b = [(edit:autosuggest:from-history echo)]`,
+		`c = [(edit:autosuggest:from-history put)]`)
+	testGlobals(t, f.Evaler, map[string]interface{}{
+		"a": vals.MakeList("echo"),
+		"b": vals.MakeList("echo foo"),
+		"c": vals.EmptyList,
+	})
+}
+
+func TestAutosuggester_ComputesOnlyLatestPendingCode(t *testing.T) {
+	calls := make(chan string, 10)
+	unblock := make(chan struct{})
+	as := &autosuggester{
+		enabled: func() bool { return true },
+		provide: func(code string) []interface{} {
+			calls <- code
+			<-unblock
+			return []interface{}{code + " suggested"}
+		},
+		lates: make(chan struct{}, 1),
+	}
+
+	as.Get("a")
+	if code := <-calls; code != "a" {
+		t.Fatalf("provider called with %q, want %q", code, "a")
+	}
+	// Codes requested while the provider is running are coalesced.
+	as.Get("ab")
+	as.Get("abc")
+	close(unblock)
+	if code := <-calls; code != "abc" {
+		t.Errorf("provider called with %q, want %q", code, "abc")
+	}
+	<-as.lates
+	if got := as.Get("abc"); got != " suggested" {
+		t.Errorf("got suggestion %q, want %q", got, " suggested")
+	}
+	select {
+	case code := <-calls:
+		t.Errorf("provider called again with %q", code)
+	default:
+	}
+}
@@ -10,7 +10,9 @@ import (
 
 // TODO(xiaq): Move this into the ui package.
 
-func bufToHTML(b *term.Buffer) string {
+// BufToHTML converts a Buffer to HTML, using spans with sgr-* classes for
+// styled cells. Each line of the buffer is terminated with a newline.
+func BufToHTML(b *term.Buffer) string {
 	var sb strings.Builder
 	for _, line := range b.Lines {
 		style := ""
@@ -8,7 +8,7 @@ import (
 )
 
 func TestBufToHTML(t *testing.T) {
-	tt.Test(t, tt.Fn("bufToHTML", bufToHTML), tt.Table{
+	tt.Test(t, tt.Fn("BufToHTML", BufToHTML), tt.Table{
 		// Just plain text.
 		tt.Args(
 			bb().Write("abc").Buffer(),
@@ -35,7 +35,7 @@ import (
 // ```
 
 func dumpBuf(tty cli.TTY) string {
-	return bufToHTML(tty.Buffer())
+	return BufToHTML(tty.Buffer())
 }
 
 //elvdoc:fn close-mode
@@ -263,41 +263,89 @@ var bufferBuiltinsData = map[string]func(*tk.CodeBuffer){
 
 	"move-dot-up":   makeMove(moveDotUp),
 	"move-dot-down": makeMove(moveDotDown),
+}
 
-	"kill-rune-left":        makeKill(moveDotLeft),
-	"kill-rune-right":       makeKill(moveDotRight),
-	"kill-word-left":        makeKill(moveDotLeftWord),
-	"kill-word-right":       makeKill(moveDotRightWord),
-	"kill-small-word-left":  makeKill(moveDotLeftSmallWord),
-	"kill-small-word-right": makeKill(moveDotRightSmallWord),
-	"kill-left-alnum-word":  makeKill(moveDotLeftAlnumWord),
-	"kill-right-alnum-word": makeKill(moveDotRightAlnumWord),
-	"kill-line-left":        makeKill(moveDotSOL),
-	"kill-line-right":       makeKill(moveDotEOL),
+var killBuiltinsData = map[string]pureMover{
+	"kill-rune-left":        moveDotLeft,
+	"kill-rune-right":       moveDotRight,
+	"kill-word-left":        moveDotLeftWord,
+	"kill-word-right":       moveDotRightWord,
+	"kill-small-word-left":  moveDotLeftSmallWord,
+	"kill-small-word-right": moveDotRightSmallWord,
+	"kill-left-alnum-word":  moveDotLeftAlnumWord,
+	"kill-right-alnum-word": moveDotRightAlnumWord,
+	"kill-line-left":        moveDotSOL,
+	"kill-line-right":       moveDotEOL,
 }
 
 func initBufferBuiltins(app cli.App, nb eval.NsBuilder) {
-	nb.AddGoFns("<edit>", bufferBuiltins(app))
+	kr := initKillRing(app, nb)
+	nb.AddGoFns("<edit>", bufferBuiltins(app, kr))
+	nb.AddGoFns("<edit>", map[string]interface{}{
+		"undo": func() { undo(app) },
+		"redo": func() { redo(app) },
+	})
 }
 
-func bufferBuiltins(app cli.App) map[string]interface{} {
+func bufferBuiltins(app cli.App, kr *killRing) map[string]interface{} {
 	m := make(map[string]interface{})
+	mutate := func(f func(s *tk.CodeAreaState)) {
+		codeArea, ok := focusedCodeArea(app)
+		if !ok {
+			return
+		}
+		codeArea.MutateState(f)
+	}
 	for name, fn := range bufferBuiltinsData {
 		// Make a lexically scoped copy of fn.
 		fn2 := fn
 		m[name] = func() {
-			codeArea, ok := focusedCodeArea(app)
-			if !ok {
-				return
-			}
-			codeArea.MutateState(func(s *tk.CodeAreaState) {
-				fn2(&s.Buffer)
+			mutate(func(s *tk.CodeAreaState) { fn2(&s.Buffer) })
+		}
+	}
+	for name, mover := range killBuiltinsData {
+		mover2 := mover
+		m[name] = func() {
+			mutate(func(s *tk.CodeAreaState) {
+				before := s.Buffer
+				killed := kill(&s.Buffer, mover2)
+				kr.add(before, s.Buffer, killed)
 			})
 		}
 	}
 	return m
 }
 
+//elvdoc:fn undo
+//
+// Undoes the last change to the current command. Consecutive insertions of
+// text are undone together. Does nothing if there is no change to undo.
+//
+// @cf edit:redo
+
+func undo(app cli.App) {
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return
+	}
+	codeArea.Undo()
+}
+
+//elvdoc:fn redo
+//
+// Redoes the last change undone by `edit:undo`. Does nothing if there is no
+// change to redo, or the current command has been changed since the last undo.
+//
+// @cf edit:undo
+
+func redo(app cli.App) {
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return
+	}
+	codeArea.Redo()
+}
+
 // A pure function that takes the current buffer and dot, and returns a new
 // value for the dot. Used to derive move- and kill- functions that operate on
 // the editor state.
@@ -309,19 +357,23 @@ func makeMove(m pureMover) func(*tk.CodeBuffer) {
 	}
 }
 
-func makeKill(m pureMover) func(*tk.CodeBuffer) {
-	return func(buf *tk.CodeBuffer) {
-		newDot := m(buf.Content, buf.Dot)
-		if newDot < buf.Dot {
-			// Dot moved to the left: remove text between new dot and old dot,
-			// and move the dot itself
-			buf.Content = buf.Content[:newDot] + buf.Content[buf.Dot:]
-			buf.Dot = newDot
-		} else if newDot > buf.Dot {
-			// Dot moved to the right: remove text between old dot and new dot.
-			buf.Content = buf.Content[:buf.Dot] + buf.Content[newDot:]
-		}
+// Removes the text between the dot and the position the mover moves the dot
+// to, and returns the removed text.
+func kill(buf *tk.CodeBuffer, m pureMover) string {
+	newDot := m(buf.Content, buf.Dot)
+	var killed string
+	if newDot < buf.Dot {
+		// Dot moved to the left: remove text between new dot and old dot,
+		// and move the dot itself
+		killed = buf.Content[newDot:buf.Dot]
+		buf.Content = buf.Content[:newDot] + buf.Content[buf.Dot:]
+		buf.Dot = newDot
+	} else if newDot > buf.Dot {
+		// Dot moved to the right: remove text between old dot and new dot.
+		killed = buf.Content[buf.Dot:newDot]
+		buf.Content = buf.Content[:buf.Dot] + buf.Content[newDot:]
 	}
+	return killed
 }
 
 // Implementation of pure movers.
@@ -227,6 +227,25 @@ func TestBufferBuiltins(t *testing.T) {
 	}
 }
 
+func TestUndoRedo(t *testing.T) {
+	f := setup(t)
+
+	feedInput(f.TTYCtrl, "echo")
+	f.TestTTY(t, "~> echo", Styles,
+		"   vvvv", term.DotHere)
+	evals(f.Evaler, "edit:kill-rune-left", "edit:undo")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "echo", Dot: 4})
+	// Consecutively inserted runes are undone together.
+	evals(f.Evaler, "edit:undo")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "", Dot: 0})
+	evals(f.Evaler, "edit:undo", "edit:redo")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "echo", Dot: 4})
+	evals(f.Evaler, "edit:redo")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "ech", Dot: 3})
+	evals(f.Evaler, "edit:redo")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "ech", Dot: 3})
+}
+
 // Builtins that expect the focused widget to be code areas. This
 // includes some builtins defined in files other than builtins.go.
 var focusedWidgetNotCodeAreaTests = []string{
@@ -0,0 +1,132 @@
+package complete
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+)
+
+// BashFrameworks are the paths of the bash-completion framework that
+// GenerateFromBash tries to load, in order. The framework provides helper
+// functions that most completion functions rely on, and loads the completion
+// function of commands on demand.
+var BashFrameworks = []string{
+	"/usr/share/bash-completion/bash_completion",
+	"/usr/local/share/bash-completion/bash_completion",
+	"/opt/homebrew/share/bash-completion/bash_completion",
+	"/etc/bash_completion",
+}
+
+// The script run by bash. It is passed the path of the framework, the path of
+// the completion script, the completion function and the words of the command
+// line as positional parameters.
+const bashCompletionScript = `
+framework=$1 script=$2 fn=$3
+shift 3
+if [ -n "$framework" ]; then
+  . "$framework" >/dev/null 2>&1
+fi
+if [ -n "$script" ]; then
+  . "$script" >/dev/null 2>&1
+fi
+if [ -z "$fn" ]; then
+  spec=$(complete -p -- "$1" 2>/dev/null)
+  if [ -z "$spec" ] && declare -F _completion_loader >/dev/null; then
+    _completion_loader "$1" >/dev/null 2>&1
+    spec=$(complete -p -- "$1" 2>/dev/null)
+  fi
+  case $spec in
+  *" -F "*)
+    fn=${spec#* -F }
+    fn=${fn%% *}
+    ;;
+  esac
+fi
+[ -n "$fn" ] || exit 0
+COMP_WORDS=("$@")
+COMP_CWORD=$(($# - 1))
+COMP_LINE="$*"
+COMP_POINT=${#COMP_LINE}
+COMP_TYPE=9
+COMP_KEY=9
+COMPREPLY=()
+"$fn" "$1" "${COMP_WORDS[COMP_CWORD]}" "${COMP_WORDS[COMP_CWORD-1]}" >/dev/null 2>&1
+for reply in "${COMPREPLY[@]}"; do
+  printf '%s\n' "$reply"
+done
+`
+
+var errNoBash = errors.New("bash not found")
+
+// GenerateFromBash generates candidates for the last argument by calling a
+// bash completion function in a bash subprocess, with COMP_WORDS, COMP_CWORD,
+// COMP_LINE and COMP_POINT set up like bash does.
+//
+// If script is not empty, it is sourced before calling the function. If fn is
+// empty, the function registered for the command with "complete -F" is used.
+// If interrupt is closed before bash exits, bash is killed.
+func GenerateFromBash(args []string, script, fn string, interrupt <-chan struct{}) ([]RawItem, error) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		return nil, errNoBash
+	}
+	framework := ""
+	for _, path := range BashFrameworks {
+		if _, err := os.Stat(path); err == nil {
+			framework = path
+			break
+		}
+	}
+	cmd := exec.Command(bash, append(
+		[]string{"-c", bashCompletionScript, "bash", framework, script, fn},
+		args...)...)
+	// Read the output from a pipe instead of letting cmd copy it, so that
+	// waiting for bash does not wait for its children that are still holding
+	// the pipe after it is killed.
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	cmd.Stdout = w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return nil, err
+	}
+	type outputAndError struct {
+		output []byte
+		err    error
+	}
+	exited := make(chan outputAndError, 1)
+	go func() {
+		output, _ := io.ReadAll(r)
+		exited <- outputAndError{output, cmd.Wait()}
+	}()
+	var result outputAndError
+	select {
+	case result = <-exited:
+	case <-interrupt:
+		cmd.Process.Kill()
+		r.Close()
+		<-exited
+		return nil, eval.ErrInterrupted
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	var items []RawItem
+	for _, line := range strings.Split(string(result.output), "\n") {
+		// Completion functions often add a trailing space to candidates,
+		// which is already taken care of when a candidate is inserted.
+		if line = strings.TrimRight(line, " "); line != "" {
+			items = append(items, PlainItem(line))
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,58 @@
+package complete
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestGenerateFromBash(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+	testutil.InTempDir(t)
+	testutil.ApplyDir(testutil.Dir{
+		"foo.bash": `
+_foo() {
+  COMPREPLY=("cword=$COMP_CWORD" "cur=$2" "prev=$3" "line=$COMP_LINE" "with space ")
+}
+complete -F _foo foo
+`,
+		"slow.bash": `_slow() { sleep 10; COMPREPLY=(slow); }`,
+	})
+
+	tests := []struct {
+		name   string
+		args   []string
+		script string
+		fn     string
+		want   []RawItem
+	}{
+		{"function given", []string{"foo", "a", "b"}, "foo.bash", "_foo",
+			[]RawItem{PlainItem("cword=2"), PlainItem("cur=b"), PlainItem("prev=a"),
+				PlainItem("line=foo a b"), PlainItem("with space")}},
+		{"function found with complete -p", []string{"foo", ""}, "foo.bash", "",
+			[]RawItem{PlainItem("cword=1"), PlainItem("cur="), PlainItem("prev=foo"),
+				PlainItem("line=foo"), PlainItem("with space")}},
+		{"no function", []string{"bar", ""}, "foo.bash", "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items, err := GenerateFromBash(test.args, test.script, test.fn, nil)
+			if err != nil || !reflect.DeepEqual(items, test.want) {
+				t.Errorf("got %v, %v, want %v, nil", items, err, test.want)
+			}
+		})
+	}
+
+	interrupt := make(chan struct{})
+	time.AfterFunc(testutil.Scaled(10*time.Millisecond), func() { close(interrupt) })
+	_, err := GenerateFromBash([]string{"slow", ""}, "slow.bash", "_slow", interrupt)
+	if err != eval.ErrInterrupted {
+		t.Errorf("got error %v, want %v", err, eval.ErrInterrupted)
+	}
+}
@@ -30,16 +30,29 @@ type Config struct {
 	// Used to generate candidates for a command argument. Defaults to
 	// Filenames.
 	ArgGenerator ArgGenerator
+	// If not nil, used instead of ArgGenerator to generate candidates for a
+	// command argument.
+	ArgStreamer ArgStreamer
+	// If not nil, called with the result so far when ArgStreamer is started,
+	// and whenever it passes more candidates.
+	OnPartialResult func(*Result)
 }
 
-// Filterer is the type of functions that filter raw candidates.
-type Filterer func(ctxName, seed string, rawItems []RawItem) []RawItem
+// Filterer is the type of functions that filter raw candidates. If ranked is
+// true, the filtered candidates are in the order of decreasing relevance, and
+// the order is kept; otherwise they are sorted.
+type Filterer func(ctxName, seed string, rawItems []RawItem) (filtered []RawItem, ranked bool)
 
 // ArgGenerator is the type of functions that generate raw candidates for a
 // command argument. It takes all the existing arguments, the last being the
 // argument to complete, and returns raw candidates or an error.
 type ArgGenerator func(args []string) ([]RawItem, error)
 
+// ArgStreamer is like ArgGenerator, but passes raw candidates to put in
+// batches as they are generated, instead of returning all of them at the end.
+// It must not call put concurrently or after it returns.
+type ArgStreamer func(args []string, put func([]RawItem)) error
+
 // Result keeps the result of the completion algorithm.
 type Result struct {
 	Name    string
@@ -93,24 +106,85 @@ func Complete(code CodeBuffer, cfg Config) (*Result, error) {
 		if err == errNoCompletion {
 			continue
 		}
-		rawItems = cfg.Filterer(ctx.name, ctx.seed, rawItems)
-		items := make([]modes.CompletionItem, len(rawItems))
-		for i, rawCand := range rawItems {
-			items[i] = rawCand.Cook(ctx.quote)
+		return makeResult(ctx, rawItems, cfg), nil
+	}
+	return nil, errNoCompletion
+}
+
+// Filters, cooks and sorts raw candidates into a Result.
+func makeResult(ctx *context, rawItems []RawItem, cfg Config) *Result {
+	rawItems, ranked := cfg.Filterer(ctx.name, ctx.seed, rawItems)
+	items := make([]modes.CompletionItem, len(rawItems))
+	for i, rawCand := range rawItems {
+		items[i] = rawCand.Cook(ctx.quote)
+	}
+	if !ranked {
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].ToShow < items[j].ToShow
+		})
+	}
+	items = dedup(items)
+	return &Result{Name: ctx.name, Items: items, Replace: ctx.interval}
+}
+
+// Builds the result from raw candidates that arrive in batches, only filtering
+// and cooking the candidates of each new batch. Items of unranked batches are
+// merged in sorted order, and items of ranked batches are appended, since
+// their relevance can't be compared across batches; the final result is built
+// with makeResult from all the candidates.
+type partialResult struct {
+	ctx   *context
+	cfg   Config
+	items []modes.CompletionItem
+	seen  map[string]bool
+}
+
+func newPartialResult(ctx *context, cfg Config) *partialResult {
+	return &partialResult{ctx: ctx, cfg: cfg, seen: make(map[string]bool)}
+}
+
+// Adds a batch of raw candidates, and returns the result so far.
+func (pr *partialResult) add(batch []RawItem) *Result {
+	filtered, ranked := pr.cfg.Filterer(pr.ctx.name, pr.ctx.seed, batch)
+	var items []modes.CompletionItem
+	for _, rawItem := range filtered {
+		item := rawItem.Cook(pr.ctx.quote)
+		if !pr.seen[item.ToInsert] {
+			pr.seen[item.ToInsert] = true
+			items = append(items, item)
 		}
+	}
+	if ranked {
+		pr.items = append(pr.items, items...)
+	} else {
 		sort.Slice(items, func(i, j int) bool {
 			return items[i].ToShow < items[j].ToShow
 		})
-		items = dedup(items)
-		return &Result{Name: ctx.name, Items: items, Replace: ctx.interval}, nil
+		pr.items = mergeByToShow(pr.items, items)
 	}
-	return nil, errNoCompletion
+	return &Result{Name: pr.ctx.name, Items: pr.items, Replace: pr.ctx.interval}
+}
+
+// Merges two slices of items sorted by ToShow into a new slice.
+func mergeByToShow(a, b []modes.CompletionItem) []modes.CompletionItem {
+	merged := make([]modes.CompletionItem, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if b[0].ToShow < a[0].ToShow {
+			merged, b = append(merged, b[0]), b[1:]
+		} else {
+			merged, a = append(merged, a[0]), a[1:]
+		}
+	}
+	return append(append(merged, a...), b...)
 }
 
+// Removes items with the same ToInsert as an earlier item.
 func dedup(items []modes.CompletionItem) []modes.CompletionItem {
 	var result []modes.CompletionItem
-	for i, item := range items {
-		if i == 0 || item.ToInsert != items[i-1].ToInsert {
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if !seen[item.ToInsert] {
+			seen[item.ToInsert] = true
 			result = append(result, item)
 		}
 	}
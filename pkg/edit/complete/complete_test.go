@@ -3,6 +3,7 @@ package complete
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"runtime"
 	"testing"
 
@@ -86,8 +87,8 @@ func TestComplete(t *testing.T) {
 
 	argGeneratorDebugCfg := Config{
 		PureEvaler: cfg.PureEvaler,
-		Filterer: func(ctxName, seed string, items []RawItem) []RawItem {
-			return items
+		Filterer: func(ctxName, seed string, items []RawItem) ([]RawItem, bool) {
+			return items, false
 		},
 		ArgGenerator: func(args []string) ([]RawItem, error) {
 			item := noQuoteItem(fmt.Sprintf("%#v", args))
@@ -102,6 +103,16 @@ func TestComplete(t *testing.T) {
 		},
 	}
 
+	rankedCfg := Config{
+		PureEvaler: cfg.PureEvaler,
+		Filterer: func(ctxName, seed string, items []RawItem) ([]RawItem, bool) {
+			return items, true
+		},
+		ArgGenerator: func([]string) ([]RawItem, error) {
+			return []RawItem{PlainItem("b"), PlainItem("a"), PlainItem("b")}, nil
+		},
+	}
+
 	allFileNameItems := []modes.CompletionItem{
 		fc("a.exe", " "), fc("d"+string(os.PathSeparator), ""), fc("non-exe", " "),
 	}
@@ -125,6 +136,15 @@ func TestComplete(t *testing.T) {
 				},
 			},
 			nil),
+		// Candidates from a ranking Filterer are deduplicated, but not sorted.
+		Args(cb("ls "), rankedCfg).Rets(
+			&Result{
+				Name: "argument", Replace: r(3, 3),
+				Items: []modes.CompletionItem{
+					c("b"), c("a"),
+				},
+			},
+			nil),
 		// Complete arguments using GenerateFileNames.
 		Args(cb("ls "), cfg).Rets(
 			&Result{
@@ -221,6 +241,13 @@ func TestComplete(t *testing.T) {
 				Name: "variable", Replace: r(3, 4),
 				Items: []modes.CompletionItem{c("fn~"), c("foo")}},
 			nil),
+		// Variables interpolated in double-quoted strings.
+		//       0123456
+		Args(cb(`p "a $f`), cfg).Rets(
+			&Result{
+				Name: "variable", Replace: r(6, 7),
+				Items: []modes.CompletionItem{c("fn~"), c("foo")}},
+			nil),
 		//       0123456
 		Args(cb("p $ns1:"), cfg).Rets(
 			&Result{
@@ -267,6 +294,70 @@ func TestComplete(t *testing.T) {
 	}
 }
 
+func TestComplete_ArgStreamer(t *testing.T) {
+	var partials [][]modes.CompletionItem
+	cfg := Config{
+		PureEvaler: testEvaler{},
+		ArgStreamer: func(args []string, put func([]RawItem)) error {
+			put([]RawItem{PlainItem("b"), PlainItem("c")})
+			put([]RawItem{PlainItem("a")})
+			return nil
+		},
+		OnPartialResult: func(r *Result) { partials = append(partials, r.Items) },
+	}
+
+	result, err := Complete(cb("ls "), cfg)
+	wantItems := []modes.CompletionItem{c("a"), c("b"), c("c")}
+	if err != nil || !reflect.DeepEqual(result.Items, wantItems) {
+		t.Errorf("got result %v, err %v, want items %v, nil", result, err, wantItems)
+	}
+	wantPartials := [][]modes.CompletionItem{
+		nil, {c("b"), c("c")}, {c("a"), c("b"), c("c")}}
+	if !reflect.DeepEqual(partials, wantPartials) {
+		t.Errorf("got partial results %v, want %v", partials, wantPartials)
+	}
+}
+
+func TestComplete_ArgStreamer_FiltersOnlyNewBatches(t *testing.T) {
+	var filtered [][]string
+	var partials [][]modes.CompletionItem
+	cfg := Config{
+		PureEvaler: testEvaler{},
+		Filterer: func(ctxName, seed string, items []RawItem) ([]RawItem, bool) {
+			var texts []string
+			for _, item := range items {
+				texts = append(texts, item.String())
+			}
+			filtered = append(filtered, texts)
+			// Keep the order of candidates, and drop "x".
+			var kept []RawItem
+			for _, item := range items {
+				if item.String() != "x" {
+					kept = append(kept, item)
+				}
+			}
+			return kept, true
+		},
+		ArgStreamer: func(args []string, put func([]RawItem)) error {
+			put([]RawItem{PlainItem("b"), PlainItem("x")})
+			put([]RawItem{PlainItem("a"), PlainItem("b")})
+			return nil
+		},
+		OnPartialResult: func(r *Result) { partials = append(partials, r.Items) },
+	}
+
+	Complete(cb("ls "), cfg)
+	wantFiltered := [][]string{{"b", "x"}, {"a", "b"}, {"b", "x", "a", "b"}}
+	if !reflect.DeepEqual(filtered, wantFiltered) {
+		t.Errorf("filterer called with %v, want %v", filtered, wantFiltered)
+	}
+	// Ranked batches are appended, without duplicates.
+	wantPartials := [][]modes.CompletionItem{nil, {c("b")}, {c("b"), c("a")}}
+	if !reflect.DeepEqual(partials, wantPartials) {
+		t.Errorf("got partial results %v, want %v", partials, wantPartials)
+	}
+}
+
 func cb(s string) CodeBuffer { return CodeBuffer{s, len(s)} }
 
 func c(s string) modes.CompletionItem { return modes.CompletionItem{ToShow: s, ToInsert: s} }
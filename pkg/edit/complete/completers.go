@@ -34,7 +34,7 @@ func completeArg(n parse.Node, cfg Config) (*context, []RawItem, error) {
 			// Case 1: starting a new argument.
 			ctx := &context{"argument", "", parse.Bareword, range0(n.Range().To)}
 			args := purelyEvalForm(form, "", n.Range().To, ev)
-			items, err := cfg.ArgGenerator(args)
+			items, err := generateArgItems(ctx, args, cfg)
 			return ctx, items, err
 		}
 	}
@@ -45,7 +45,7 @@ func completeArg(n parse.Node, cfg Config) (*context, []RawItem, error) {
 					// Case 2: in an incomplete argument.
 					ctx := &context{"argument", seed, primary.Type, compound.Range()}
 					args := purelyEvalForm(form, seed, compound.Range().From, ev)
-					items, err := cfg.ArgGenerator(args)
+					items, err := generateArgItems(ctx, args, cfg)
 					return ctx, items, err
 				}
 			}
@@ -54,6 +54,26 @@ func completeArg(n parse.Node, cfg Config) (*context, []RawItem, error) {
 	return nil, nil, errNoCompletion
 }
 
+// Generates raw candidates for a command argument, using cfg.ArgStreamer if
+// it is not nil.
+func generateArgItems(ctx *context, args []string, cfg Config) ([]RawItem, error) {
+	if cfg.ArgStreamer == nil {
+		return cfg.ArgGenerator(args)
+	}
+	if cfg.OnPartialResult != nil {
+		cfg.OnPartialResult(&Result{Name: ctx.name, Replace: ctx.interval})
+	}
+	var items []RawItem
+	partial := newPartialResult(ctx, cfg)
+	err := cfg.ArgStreamer(args, func(batch []RawItem) {
+		items = append(items, batch...)
+		if cfg.OnPartialResult != nil {
+			cfg.OnPartialResult(partial.add(batch))
+		}
+	})
+	return items, err
+}
+
 func completeCommand(n parse.Node, cfg Config) (*context, []RawItem, error) {
 	ev := cfg.PureEvaler
 	generateForEmpty := func(pos int) (*context, []RawItem, error) {
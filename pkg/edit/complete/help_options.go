@@ -0,0 +1,123 @@
+package complete
+
+import (
+	"regexp"
+	"strings"
+
+	"src.elv.sh/pkg/getopt"
+)
+
+// HelpOption is an option scraped from the output of --help.
+type HelpOption struct {
+	getopt.Option
+	// Name of the argument of the option, like "FILE". Empty if the option
+	// takes no argument.
+	ArgDesc string
+	// Description of the option. May be empty.
+	Desc string
+}
+
+var (
+	// An option line starts with some indentation and a dash.
+	helpOptionLine = regexp.MustCompile(`^\s+-`)
+	// The options are separated from the description by at least two spaces
+	// or a tab.
+	helpDescSep = regexp.MustCompile(`\s{2,}|\t`)
+	// Options on the same line are separated by commas. Commas not followed
+	// by a dash may be part of an argument name, like "{always,never}".
+	helpOptionComma = regexp.MustCompile(`,\s*-`)
+	// A single option with an optional argument, like "-o", "-oFILE",
+	// "-o FILE", "--output", "--output=FILE", "--output FILE" or
+	// "--color[=WHEN]". Lower-case argument names must be separated from the
+	// option, so that "-foo" is not mistaken for "-f" with an argument.
+	helpOptionSyntax = regexp.MustCompile(
+		`^(?:-([[:alnum:]?])|--([[:alnum:]][[:alnum:]_.+-]*))` +
+			`(?:\[=?([^\]]*)\]|[= ](` + helpArg + `|` + helpLowerArg + `)|=?(` + helpArg + `))?$`)
+)
+
+const (
+	helpArg      = `<[^>]+>|[[:upper:]][[:upper:][:digit:]_-]*|\{[^}]*\}`
+	helpLowerArg = `[[:lower:]]+(?:[-_][[:lower:]]+)*`
+)
+
+// ParseHelpOptions scrapes options from the output of --help in the format
+// commonly used by GNU tools, where each option line looks like:
+//
+//	-o, --output=FILE    write output to FILE
+//
+// Lines that do not look like option lines are ignored, as are descriptions
+// spanning multiple lines after the first one.
+func ParseHelpOptions(help string) []*HelpOption {
+	var opts []*HelpOption
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(help, "\n") {
+		if !helpOptionLine.MatchString(line) {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		spec, desc := line, ""
+		if loc := helpDescSep.FindStringIndex(line); loc != nil {
+			spec, desc = line[:loc[0]], strings.TrimSpace(line[loc[1]:])
+		}
+		opt, ok := parseHelpOptionSpec(spec)
+		if !ok {
+			continue
+		}
+		opt.Desc = desc
+		key := string(opt.Short) + " " + opt.Long
+		if !seen[key] {
+			seen[key] = true
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
+
+// Parses the option part of an option line, like "-o, --output=FILE".
+func parseHelpOptionSpec(spec string) (*HelpOption, bool) {
+	opt := &HelpOption{}
+	for _, field := range splitHelpOptionSpec(spec) {
+		m := helpOptionSyntax.FindStringSubmatch(field)
+		if m == nil {
+			return nil, false
+		}
+		if m[1] != "" {
+			opt.Short = []rune(m[1])[0]
+		} else {
+			opt.Long = m[2]
+		}
+		switch {
+		case strings.HasSuffix(field, "]"):
+			opt.setArg(getopt.OptionalArgument, m[3])
+		case m[4] != "":
+			opt.setArg(getopt.RequiredArgument, m[4])
+		case m[5] != "":
+			opt.setArg(getopt.RequiredArgument, m[5])
+		}
+	}
+	return opt, opt.Short != 0 || opt.Long != ""
+}
+
+func (opt *HelpOption) setArg(hasArg getopt.HasArg, desc string) {
+	if opt.HasArg == getopt.NoArgument || hasArg == getopt.RequiredArgument {
+		opt.HasArg = hasArg
+	}
+	if opt.ArgDesc == "" {
+		opt.ArgDesc = desc
+	}
+}
+
+// Splits the option part of an option line into fields for individual options,
+// keeping arguments separated by a space with their options.
+func splitHelpOptionSpec(spec string) []string {
+	var fields []string
+	for _, word := range strings.Fields(helpOptionComma.ReplaceAllString(spec, " -")) {
+		if !strings.HasPrefix(word, "-") && len(fields) > 0 {
+			// An argument separated from its option by a space.
+			fields[len(fields)-1] += " " + word
+			continue
+		}
+		fields = append(fields, word)
+	}
+	return fields
+}
@@ -0,0 +1,60 @@
+package complete
+
+import (
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/getopt"
+)
+
+var helpText = `Usage: ls [OPTION]... [FILE]...
+List information about the FILEs (the current directory by default).
+
+Mandatory arguments to long options are mandatory for short options too.
+  -a, --all                  do not ignore entries starting with .
+      --block-size=SIZE      with -l, scale sizes by SIZE when printing them;
+                               e.g., '--block-size=M'; see SIZE format below
+      --color[=WHEN]         color the output WHEN; more info below
+  -I, --ignore=PATTERN       do not list implied entries matching shell PATTERN
+  -o FILE, --output FILE     write to FILE
+  -T COLS                    assume tab stops at each COLS instead of 8
+  -w<cols>
+  -q,--quiet
+  -foo                       an old-style option, which is ignored
+      --when={always,never}  when to do things
+  -?, --help                 display this help and exit
+  -a, --all                  duplicate lines are ignored
+`
+
+func TestParseHelpOptions(t *testing.T) {
+	opt := func(short rune, long string, hasArg getopt.HasArg, argDesc, desc string) *HelpOption {
+		return &HelpOption{getopt.Option{Short: short, Long: long, HasArg: hasArg}, argDesc, desc}
+	}
+	want := []*HelpOption{
+		opt('a', "all", getopt.NoArgument, "", "do not ignore entries starting with ."),
+		opt(0, "block-size", getopt.RequiredArgument, "SIZE",
+			"with -l, scale sizes by SIZE when printing them;"),
+		opt(0, "color", getopt.OptionalArgument, "WHEN",
+			"color the output WHEN; more info below"),
+		opt('I', "ignore", getopt.RequiredArgument, "PATTERN",
+			"do not list implied entries matching shell PATTERN"),
+		opt('o', "output", getopt.RequiredArgument, "FILE", "write to FILE"),
+		opt('T', "", getopt.RequiredArgument, "COLS",
+			"assume tab stops at each COLS instead of 8"),
+		opt('w', "", getopt.RequiredArgument, "<cols>", ""),
+		opt('q', "quiet", getopt.NoArgument, "", ""),
+		opt(0, "when", getopt.RequiredArgument, "{always,never}", "when to do things"),
+		opt('?', "help", getopt.NoArgument, "", "display this help and exit"),
+	}
+	got := ParseHelpOptions(helpText)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:")
+		for _, o := range got {
+			t.Errorf("  %+v", *o)
+		}
+		t.Errorf("want:")
+		for _, o := range want {
+			t.Errorf("  %+v", *o)
+		}
+	}
+}
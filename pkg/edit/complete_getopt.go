@@ -105,7 +105,10 @@ func completeGetopt(fm *eval.Frame, vArgs, vOpts, vArgHandlers interface{}) erro
 	if err != nil {
 		return err
 	}
+	return completeGetoptParsed(fm, args, opts, argHandlers, variadic)
+}
 
+func completeGetoptParsed(fm *eval.Frame, args []string, opts parsedOptSpecs, argHandlers []eval.Callable, variadic bool) error {
 	// TODO(xiaq): Make the Config field configurable
 	g := getopt.Getopt{Options: opts.opts, Config: getopt.GNUGetoptLong}
 	_, parsedArgs, ctx := g.Parse(args)
@@ -0,0 +1,220 @@
+package edit
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"sync"
+
+	"src.elv.sh/pkg/edit/complete"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/getopt"
+)
+
+//elvdoc:fn complete-bash
+//
+// ```elvish
+// edit:complete-bash &script='' &function='' $args...
+// ```
+//
+// Produces candidates for the last argument by calling a bash completion
+// function in a bash subprocess. The function is called with `COMP_WORDS`,
+// `COMP_CWORD`, `COMP_LINE` and `COMP_POINT` set up for `$args`, and the
+// contents of `COMPREPLY` are output as candidates.
+//
+// The [bash-completion](https://github.com/scop/bash-completion) framework is
+// loaded first if it is installed. If `&script` is not empty, it is also
+// sourced. If `&function` is empty, the function registered for the command
+// with `complete -F` is used; with the bash-completion framework, completion
+// scripts of commands are found and loaded automatically.
+//
+// If the function produces no candidates, file names are produced instead,
+// like the `-o default` option of bash's `complete` builtin.
+//
+// Example:
+//
+// ```elvish
+// edit:completion:arg-completer[git] = [@args]{ edit:complete-bash $@args }
+// ```
+//
+// @cf edit:completion:import-bash
+
+//elvdoc:fn complete-help
+//
+// ```elvish
+// edit:complete-help $args...
+// ```
+//
+// Produces candidates for the last argument using options scraped from the
+// output of running the command `$args[0]` with `--help`. Options are
+// completed when the last argument starts with `-`, and file names are
+// completed otherwise. The output of `--help` is only scraped the first time
+// a command is completed.
+//
+// Option lines are recognized when they follow the format used by GNU tools,
+// like the following:
+//
+// ```
+//   -o, --output=FILE    write output to FILE
+//       --color[=WHEN]   colorize the output
+// ```
+//
+// @cf edit:complete-getopt edit:completion:import-help
+
+//elvdoc:fn completion:import-bash
+//
+// ```elvish
+// edit:completion:import-bash &script='' &function='' $commands...
+// ```
+//
+// Sets the [argument completers](#argument-completer) of `$commands` to use
+// [`edit:complete-bash`](#editcomplete-bash) with the given options. Example:
+//
+// ```elvish
+// edit:completion:import-bash git kubectl systemctl
+// ```
+
+//elvdoc:fn completion:import-help
+//
+// ```elvish
+// edit:completion:import-help $commands...
+// ```
+//
+// Sets the [argument completers](#argument-completer) of `$commands` to use
+// [`edit:complete-help`](#editcomplete-help). Example:
+//
+// ```elvish
+// edit:completion:import-help ls grep sort
+// ```
+
+type completeBashOpts struct {
+	Script   string
+	Function string
+}
+
+func (*completeBashOpts) SetDefaultOptions() {}
+
+func completeBash(fm *eval.Frame, opts completeBashOpts, args ...string) error {
+	if len(args) == 0 {
+		return errNoArgs
+	}
+	rawItems, err := complete.GenerateFromBash(
+		args, opts.Script, opts.Function, fm.Interrupts())
+	if err != nil {
+		return err
+	}
+	if len(rawItems) == 0 {
+		rawItems, err = complete.GenerateFileNames(args)
+		if err != nil {
+			return err
+		}
+	}
+	return putRawItems(fm, rawItems)
+}
+
+var errNoArgs = errors.New("no arguments")
+
+// Caches options scraped from the output of --help, keyed by command name.
+type helpOptionsCache struct {
+	mutex sync.Mutex
+	opts  map[string]parsedOptSpecs
+}
+
+func (c *helpOptionsCache) get(fm *eval.Frame, cmd string) (parsedOptSpecs, error) {
+	c.mutex.Lock()
+	opts, ok := c.opts[cmd]
+	c.mutex.Unlock()
+	if ok {
+		return opts, nil
+	}
+
+	path, err := exec.LookPath(cmd)
+	if err != nil {
+		return parsedOptSpecs{}, err
+	}
+	// Some commands write help to stderr, and many exit with a non-zero
+	// status after writing help; both are fine.
+	var output bytes.Buffer
+	helpCmd := exec.Command(path, "--help")
+	helpCmd.Stdout, helpCmd.Stderr = &output, &output
+	if err := helpCmd.Start(); err != nil {
+		return parsedOptSpecs{}, err
+	}
+	exited := make(chan struct{})
+	go func() {
+		helpCmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-fm.Interrupts():
+		helpCmd.Process.Kill()
+		<-exited
+		return parsedOptSpecs{}, eval.ErrInterrupted
+	}
+
+	opts = parsedOptSpecs{
+		nil, map[*getopt.Option]string{},
+		map[*getopt.Option]string{}, map[*getopt.Option]eval.Callable{}}
+	for _, helpOpt := range complete.ParseHelpOptions(output.String()) {
+		opt := &helpOpt.Option
+		opts.opts = append(opts.opts, opt)
+		if helpOpt.Desc != "" {
+			opts.desc[opt] = helpOpt.Desc
+		}
+		if helpOpt.ArgDesc != "" {
+			opts.argDesc[opt] = helpOpt.ArgDesc
+		}
+	}
+	c.mutex.Lock()
+	c.opts[cmd] = opts
+	c.mutex.Unlock()
+	return opts, nil
+}
+
+var completeFilenameFn = eval.NewGoFn(
+	"edit:complete-filename", wrapArgGenerator(complete.GenerateFileNames))
+
+func (c *helpOptionsCache) complete(fm *eval.Frame, args ...string) error {
+	if len(args) == 0 {
+		return errNoArgs
+	}
+	opts, err := c.get(fm, args[0])
+	if err != nil {
+		return err
+	}
+	return completeGetoptParsed(fm, args[1:], opts,
+		[]eval.Callable{completeFilenameFn}, true)
+}
+
+func initCompletionImport(argCompleterVar vars.PtrVar, nb, completionNb eval.NsBuilder) {
+	helpCache := &helpOptionsCache{opts: make(map[string]parsedOptSpecs)}
+	completeHelp := helpCache.complete
+	nb.AddGoFns("<edit>", map[string]interface{}{
+		"complete-bash": completeBash,
+		"complete-help": completeHelp,
+	})
+
+	importCompleter := func(completer eval.Callable, cmds []string) error {
+		m := argCompleterVar.Get().(vals.Map)
+		for _, cmd := range cmds {
+			m = m.Assoc(cmd, completer)
+		}
+		return argCompleterVar.Set(m)
+	}
+	completionNb.AddGoFns("<edit:completion>", map[string]interface{}{
+		"import-bash": func(opts completeBashOpts, cmds ...string) error {
+			completer := eval.NewGoFn("<edit>:complete-bash",
+				func(fm *eval.Frame, args ...string) error {
+					return completeBash(fm, opts, args...)
+				})
+			return importCompleter(completer, cmds)
+		},
+		"import-help": func(cmds ...string) error {
+			completer := eval.NewGoFn("<edit>:complete-help", completeHelp)
+			return importCompleter(completer, cmds)
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package edit
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestCompletionImportBash(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	f := setup(t)
+	testutil.Setenv(t, "PATH", filepath.Dir(bash))
+	testutil.ApplyDir(testutil.Dir{
+		"foo.bash": `_foo() { [ "$2" = x ] && COMPREPLY=("$2"1 "$2"2); }`,
+		"file":     "",
+	})
+
+	evals(f.Evaler,
+		`edit:completion:import-bash &script=foo.bash &function=_foo foo`,
+		`@cands = ($edit:completion:arg-completer[foo] foo x)`,
+		// No candidates from bash; falls back to file names.
+		`@file-cands = ($edit:completion:arg-completer[foo] foo fi)`)
+	testGlobal(t, f.Evaler, "cands", vals.MakeList("x1", "x2"))
+	testGlobal(t, f.Evaler, "file-cands", vals.MakeList(
+		complexItem{Stem: "file", CodeSuffix: " "},
+		complexItem{Stem: "foo.bash", CodeSuffix: " "}))
+}
+
+var toolHelp = `#!/bin/sh
+echo 'Usage: tool [OPTION]... FILE'
+echo '  -a, --all          show all'
+echo '  -o, --output=FILE  write to FILE'
+`
+
+func TestCompletionImportHelp(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	f := setup(t)
+	testutil.ApplyDir(testutil.Dir{
+		"bin":  testutil.Dir{"tool": testutil.File{Perm: 0755, Content: toolHelp}},
+		"file": "",
+	})
+	wd, _ := os.Getwd()
+	testutil.Setenv(t, "PATH", filepath.Join(wd, "bin"))
+
+	evals(f.Evaler,
+		`edit:completion:import-help tool`,
+		`@opts = ($edit:completion:arg-completer[tool] tool -)`,
+		`@long-opts = ($edit:completion:arg-completer[tool] tool --o)`,
+		`@files = ($edit:completion:arg-completer[tool] tool -a fi)`)
+	testGlobal(t, f.Evaler, "opts", vals.MakeList(
+		complexItem{Stem: "-a", Display: "-a (show all)"},
+		complexItem{Stem: "--all", Display: "--all (show all)"},
+		complexItem{Stem: "-o", Display: "-o FILE (write to FILE)"},
+		complexItem{Stem: "--output", Display: "--output FILE (write to FILE)"}))
+	testGlobal(t, f.Evaler, "long-opts", vals.MakeList(
+		complexItem{Stem: "--output", Display: "--output FILE (write to FILE)"}))
+	testGlobal(t, f.Evaler, "files", vals.MakeList(
+		complexItem{Stem: "bin/"},
+		complexItem{Stem: "file", CodeSuffix: " "}))
+}
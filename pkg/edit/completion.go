@@ -2,10 +2,14 @@ package edit
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 
 	"src.elv.sh/pkg/cli"
@@ -23,6 +27,19 @@ import (
 //elvdoc:var completion:arg-completer
 //
 // A map containing argument completers.
+//
+// Argument completers written in Elvish run in the background. The completion
+// mode is shown in a loading state until the completer finishes, and
+// candidates are added as the completer outputs them. Closing the completion mode, for example by pressing <span
+// class="key">Ctrl-C</span>, interrupts the completer.
+//
+// @cf edit:completion:arg-completer-timeout
+
+//elvdoc:var completion:arg-completer-timeout
+//
+// Number of seconds an argument completer may run before it is interrupted,
+// defaulting to 10. The candidates output before the timeout are kept. If the
+// value is zero or negative, argument completers are never timed out.
 
 //elvdoc:var completion:binding
 //
@@ -150,6 +167,30 @@ func complexCandidate(fm *eval.Frame, opts complexCandidateOpts, stem string) co
 // }
 // ```
 
+//elvdoc:fn match-fuzzy
+//
+// ```elvish
+// edit:match-fuzzy $seed $inputs?
+// ```
+//
+// For each input, outputs a score if the input contains all the runes of $seed
+// in the same order, not necessarily consecutively, and `$false` otherwise.
+// Uses the result of `to-string` for non-string inputs.
+//
+// Scores are integers, and higher scores indicate better matches. Runes that
+// are matched at the start of words or consecutively score higher, and gaps
+// between matched runes are penalized, in a way similar to
+// [fzf](https://github.com/junegunn/fzf).
+//
+// When used as a [matcher](#matcher), candidates are sorted by their scores
+// instead of alphabetically. Example:
+//
+// ```elvish
+// set edit:completion:matcher[''] = {|seed| edit:match-fuzzy &smart-case=$true $seed }
+// ```
+//
+// @cf edit:match-subseq
+
 //elvdoc:fn completion:start
 //
 // Start the completion mode.
@@ -159,79 +200,131 @@ func complexCandidate(fm *eval.Frame, opts complexCandidateOpts, stem string) co
 // Starts the completion mode. However, if all the candidates share a non-empty
 // prefix and that prefix starts with the seed, inserts the prefix instead.
 
-func completionStart(app cli.App, bindings tk.Bindings, cfg complete.Config, smart bool) {
+func completionStart(app cli.App, bindings tk.Bindings, cfg func(cancel <-chan struct{}) complete.Config, filter modes.FilterSpec, smart bool) {
 	codeArea, ok := focusedCodeArea(app)
 	if !ok {
 		return
 	}
 	buf := codeArea.CopyState().Buffer
-	result, err := complete.Complete(
-		complete.CodeBuffer{Content: buf.Content, Dot: buf.Dot}, cfg)
+
+	cancel := make(chan struct{})
+	var cancelOnce sync.Once
+	stop := func() { cancelOnce.Do(func() { close(cancel) }) }
+	// The completion mode is shown in the loading state right away, and
+	// updated by the goroutine running the completion algorithm, so that the
+	// UI is never blocked by a slow completion.
+	w, err := modes.NewCompletion(app, modes.CompletionSpec{
+		Filter: filter, Bindings: bindings, Loading: true, OnDismiss: stop,
+	})
 	if err != nil {
 		app.Notify(err.Error())
 		return
 	}
-	if smart {
-		prefix := ""
-		for i, item := range result.Items {
-			if i == 0 {
-				prefix = item.ToInsert
-				continue
-			}
-			prefix = commonPrefix(prefix, item.ToInsert)
-			if prefix == "" {
-				break
-			}
+	app.PushAddon(w)
+
+	c := cfg(cancel)
+	c.OnPartialResult = func(result *complete.Result) {
+		w.SetContext(result.Name, result.Replace)
+		w.SetItems(result.Items, true)
+		app.Redraw()
+	}
+	go func() {
+		result, err := complete.Complete(
+			complete.CodeBuffer{Content: buf.Content, Dot: buf.Dot}, c)
+		select {
+		case <-cancel:
+			// The completion mode has been closed.
+			return
+		default:
 		}
-		if prefix != "" {
-			insertedPrefix := false
-			codeArea.MutateState(func(s *tk.CodeAreaState) {
-				rep := s.Buffer.Content[result.Replace.From:result.Replace.To]
-				if len(prefix) > len(rep) && strings.HasPrefix(prefix, rep) {
-					s.Pending = tk.PendingCode{
-						Content: prefix,
-						From:    result.Replace.From, To: result.Replace.To}
-					s.ApplyPending()
-					insertedPrefix = true
-				}
-			})
-			if insertedPrefix {
-				return
+		defer app.Redraw()
+		if err != nil || len(result.Items) == 0 {
+			closeCompletion(app, w)
+			if err != nil {
+				app.Notify(err.Error())
+			} else {
+				app.Notify("no candidates")
 			}
+			return
+		}
+		if smart && insertCommonPrefix(codeArea, result) {
+			closeCompletion(app, w)
+			return
+		}
+		w.SetContext(result.Name, result.Replace)
+		w.SetItems(result.Items, false)
+	}()
+}
+
+// Inserts the non-empty prefix shared by all the candidates if it starts with
+// the text being completed and is longer than it, and reports whether it has
+// done so.
+func insertCommonPrefix(codeArea tk.CodeArea, result *complete.Result) bool {
+	prefix := ""
+	for i, item := range result.Items {
+		if i == 0 {
+			prefix = item.ToInsert
+			continue
+		}
+		prefix = commonPrefix(prefix, item.ToInsert)
+		if prefix == "" {
+			break
 		}
 	}
-	w, err := modes.NewCompletion(app, modes.CompletionSpec{
-		Name: result.Name, Replace: result.Replace, Items: result.Items,
-		Filter: filterSpec, Bindings: bindings,
-	})
-	if w != nil {
-		app.PushAddon(w)
-	}
-	if err != nil {
-		app.Notify(err.Error())
+	if prefix == "" {
+		return false
 	}
+	insertedPrefix := false
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		rep := s.Buffer.Content[result.Replace.From:result.Replace.To]
+		if len(prefix) > len(rep) && strings.HasPrefix(prefix, rep) {
+			s.Pending = tk.PendingCode{
+				Content: prefix,
+				From:    result.Replace.From, To: result.Replace.To}
+			s.ApplyPending()
+			insertedPrefix = true
+		}
+	})
+	return insertedPrefix
+}
+
+// Closes the completion mode w if it is the active addon.
+func closeCompletion(app cli.App, w modes.Completion) {
+	app.MutateState(func(s *cli.State) {
+		if n := len(s.Addons); n > 0 && s.Addons[n-1] == w {
+			if d, ok := w.(interface{ Dismiss() }); ok {
+				d.Dismiss()
+			}
+			s.Addons = s.Addons[:n-1]
+		}
+	})
 }
 
 //elvdoc:fn completion:close
 //
 // Closes the completion mode UI.
 
-func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
+func initCompletion(ed *Editor, ev *eval.Evaler, filterSpecFor func(string) modes.FilterSpec, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar)
 	matcherMapVar := newMapVar(vals.EmptyMap)
 	argGeneratorMapVar := newMapVar(vals.EmptyMap)
-	cfg := func() complete.Config {
+	argCompleterTimeoutVar := newFloatVar(10)
+	cfg := func(cancel <-chan struct{}) complete.Config {
+		timeout := time.Duration(
+			argCompleterTimeoutVar.GetRaw().(float64) * float64(time.Second))
+		streamer := adaptArgGeneratorMap(
+			ed, ev, argGeneratorMapVar.Get().(vals.Map), timeout, cancel)
 		return complete.Config{
 			PureEvaler: pureEvaler{ev},
 			Filterer: adaptMatcherMap(
 				ed, ev, matcherMapVar.Get().(vals.Map)),
-			ArgGenerator: adaptArgGeneratorMap(
-				ev, argGeneratorMapVar.Get().(vals.Map)),
+			ArgGenerator: collectArgStreamer(streamer),
+			ArgStreamer:  streamer,
 		}
 	}
 	generateForSudo := func(args []string) ([]complete.RawItem, error) {
-		return complete.GenerateForSudo(cfg(), args)
+		return complete.GenerateForSudo(cfg(nil), args)
 	}
 	nb.AddGoFns("<edit>", map[string]interface{}{
 		"complete-filename": wrapArgGenerator(complete.GenerateFileNames),
@@ -241,23 +334,31 @@ func initCompletion(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 		"match-prefix":      wrapMatcher(strings.HasPrefix),
 		"match-subseq":      wrapMatcher(strutil.HasSubseq),
 		"match-substr":      wrapMatcher(strings.Contains),
+		"match-fuzzy":       matchFuzzy,
 	})
 	app := ed.app
+	completionNb := eval.NsBuilder{
+		"arg-completer":         argGeneratorMapVar,
+		"arg-completer-timeout": argCompleterTimeoutVar,
+		"binding":               bindingVar,
+		"matcher":               matcherMapVar,
+	}
+	initCompletionImport(argGeneratorMapVar, nb, completionNb)
 	nb.AddNs("completion",
-		eval.NsBuilder{
-			"arg-completer": argGeneratorMapVar,
-			"binding":       bindingVar,
-			"matcher":       matcherMapVar,
-		}.AddGoFns("<edit:completion>:", map[string]interface{}{
-			"accept":      func() { listingAccept(app) },
-			"smart-start": func() { completionStart(app, bindings, cfg(), true) },
-			"start":       func() { completionStart(app, bindings, cfg(), false) },
-			"up":          func() { listingUp(app) },
-			"down":        func() { listingDown(app) },
-			"up-cycle":    func() { listingUpCycle(app) },
-			"down-cycle":  func() { listingDownCycle(app) },
-			"left":        func() { listingLeft(app) },
-			"right":       func() { listingRight(app) },
+		completionNb.AddGoFns("<edit:completion>:", map[string]interface{}{
+			"accept": func() { listingAccept(app) },
+			"smart-start": func() {
+				completionStart(app, bindings, cfg, filterSpecFor("completion"), true)
+			},
+			"start": func() {
+				completionStart(app, bindings, cfg, filterSpecFor("completion"), false)
+			},
+			"up":         func() { listingUp(app) },
+			"down":       func() { listingDown(app) },
+			"up-cycle":   func() { listingUpCycle(app) },
+			"down-cycle": func() { listingDownCycle(app) },
+			"left":       func() { listingLeft(app) },
+			"right":      func() { listingRight(app) },
 		}).Ns())
 }
 
@@ -312,24 +413,29 @@ func wrapArgGenerator(gen complete.ArgGenerator) wrappedArgGenerator {
 		if err != nil {
 			return err
 		}
-		out := fm.ValueOutput()
-		for _, rawItem := range rawItems {
-			var v interface{}
-			switch rawItem := rawItem.(type) {
-			case complete.ComplexItem:
-				v = complexItem(rawItem)
-			case complete.PlainItem:
-				v = string(rawItem)
-			default:
-				v = rawItem
-			}
-			err := out.Put(v)
-			if err != nil {
-				return err
-			}
+		return putRawItems(fm, rawItems)
+	}
+}
+
+// Outputs raw candidates as Elvish values.
+func putRawItems(fm *eval.Frame, rawItems []complete.RawItem) error {
+	out := fm.ValueOutput()
+	for _, rawItem := range rawItems {
+		var v interface{}
+		switch rawItem := rawItem.(type) {
+		case complete.ComplexItem:
+			v = complexItem(rawItem)
+		case complete.PlainItem:
+			v = string(rawItem)
+		default:
+			v = rawItem
+		}
+		err := out.Put(v)
+		if err != nil {
+			return err
 		}
-		return nil
 	}
+	return nil
 }
 
 func commonPrefix(s1, s2 string) string {
@@ -393,9 +499,26 @@ func wrapMatcher(m matcher) wrappedMatcher {
 	}
 }
 
+func matchFuzzy(fm *eval.Frame, opts matcherOpts, seed string, inputs eval.Inputs) error {
+	out := fm.ValueOutput()
+	ignoreCase := opts.IgnoreCase || (opts.SmartCase && seed == strings.ToLower(seed))
+	var errOut error
+	inputs(func(v interface{}) {
+		if errOut != nil {
+			return
+		}
+		if score, _, ok := strutil.FuzzyMatch(vals.ToString(v), seed, ignoreCase); ok {
+			errOut = out.Put(score)
+		} else {
+			errOut = out.Put(false)
+		}
+	})
+	return errOut
+}
+
 // Adapts $edit:completion:matcher into a Filterer.
 func adaptMatcherMap(nt notifier, ev *eval.Evaler, m vals.Map) complete.Filterer {
-	return func(ctxName, seed string, rawItems []complete.RawItem) []complete.RawItem {
+	return func(ctxName, seed string, rawItems []complete.RawItem) ([]complete.RawItem, bool) {
 		matcher, ok := lookupFn(m, ctxName)
 		if !ok {
 			nt.notifyf(
@@ -423,7 +546,7 @@ func adaptMatcherMap(nt notifier, ev *eval.Evaler, m vals.Map) complete.Filterer
 		port1, collect, err := eval.CapturePort()
 		if err != nil {
 			nt.notifyf("cannot create pipe to run completion matcher: %v", err)
-			return nil
+			return nil, false
 		}
 
 		err = ev.Call(matcher,
@@ -442,35 +565,72 @@ func adaptMatcherMap(nt notifier, ev *eval.Evaler, m vals.Map) complete.Filterer
 				"matcher has output %v values, not equal to %v inputs",
 				len(outputs), len(rawItems))
 		}
+		// Numeric outputs are scores; when there are any, items are sorted by
+		// decreasing scores, and matching items without scores come last.
 		filtered := []complete.RawItem{}
+		var scores []float64
+		ranked := false
 		for i := 0; i < len(rawItems) && i < len(outputs); i++ {
-			if vals.Bool(outputs[i]) {
+			if vals.Kind(outputs[i]) == "number" {
 				filtered = append(filtered, rawItems[i])
+				scores = append(scores, vals.ConvertToFloat64(outputs[i]))
+				ranked = true
+			} else if vals.Bool(outputs[i]) {
+				filtered = append(filtered, rawItems[i])
+				scores = append(scores, math.Inf(-1))
 			}
 		}
-		return filtered
+		if ranked {
+			sort.Stable(rawItemsByScore{filtered, scores})
+		}
+		return filtered, ranked
 	}
 }
 
-func adaptArgGeneratorMap(ev *eval.Evaler, m vals.Map) complete.ArgGenerator {
-	return func(args []string) ([]complete.RawItem, error) {
+type rawItemsByScore struct {
+	items  []complete.RawItem
+	scores []float64
+}
+
+func (s rawItemsByScore) Len() int           { return len(s.items) }
+func (s rawItemsByScore) Less(i, j int) bool { return s.scores[i] > s.scores[j] }
+func (s rawItemsByScore) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+}
+
+// How often candidates output by an argument completer are passed on while it
+// is still running.
+const argCompleterBatchInterval = 20 * time.Millisecond
+
+// Adapts $edit:completion:arg-completer into an ArgStreamer. Argument
+// completers are interrupted when cancel is closed or after timeout, if it is
+// positive.
+func adaptArgGeneratorMap(nt notifier, ev *eval.Evaler, m vals.Map, timeout time.Duration, cancel <-chan struct{}) complete.ArgStreamer {
+	return func(args []string, put func([]complete.RawItem)) error {
 		gen, ok := lookupFn(m, args[0])
 		if !ok {
-			return nil, fmt.Errorf("arg completer for %s not a function", args[0])
+			return fmt.Errorf("arg completer for %s not a function", args[0])
 		}
 		if gen == nil {
-			return complete.GenerateFileNames(args)
+			items, err := complete.GenerateFileNames(args)
+			put(items)
+			return err
 		}
 		argValues := make([]interface{}, len(args))
 		for i, arg := range args {
 			argValues[i] = arg
 		}
-		var output []complete.RawItem
-		var outputMutex sync.Mutex
+		// Items are collected from the value and byte outputs into a single
+		// channel, and passed to put in batches by this goroutine.
+		items := make(chan complete.RawItem)
+		stopCollect := make(chan struct{})
+		defer close(stopCollect)
 		collect := func(item complete.RawItem) {
-			outputMutex.Lock()
-			defer outputMutex.Unlock()
-			output = append(output, item)
+			select {
+			case items <- item:
+			case <-stopCollect:
+			}
 		}
 		valueCb := func(ch <-chan interface{}) {
 			for v := range ch {
@@ -500,14 +660,75 @@ func adaptArgGeneratorMap(ev *eval.Evaler, m vals.Map) complete.ArgGenerator {
 		if err != nil {
 			panic(err)
 		}
-		err = ev.Call(gen,
-			eval.CallCfg{Args: argValues, From: "[editor arg generator]"},
-			eval.EvalCfg{Ports: []*eval.Port{
-				// TODO: Supply the Chan component of port 2.
-				nil, port1, {File: os.Stderr}}})
-		done()
+		interrupt := make(chan struct{})
+		errCh := make(chan error, 1)
+		go func() {
+			err := ev.Call(gen,
+				eval.CallCfg{Args: argValues, From: "[editor arg generator]"},
+				eval.EvalCfg{
+					Ports: []*eval.Port{
+						// TODO: Supply the Chan component of port 2.
+						nil, port1, {File: os.Stderr}},
+					Interrupt: func() (<-chan struct{}, func()) {
+						return interrupt, func() {}
+					}})
+			done()
+			close(items)
+			errCh <- err
+		}()
 
-		return output, err
+		var timeoutCh <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			timeoutCh = timer.C
+		}
+		ticker := time.NewTicker(argCompleterBatchInterval)
+		defer ticker.Stop()
+		var batch []complete.RawItem
+		flush := func() {
+			if len(batch) > 0 {
+				put(batch)
+				batch = nil
+			}
+		}
+		for {
+			select {
+			case item, ok := <-items:
+				if !ok {
+					flush()
+					return <-errCh
+				}
+				batch = append(batch, item)
+			case <-ticker.C:
+				flush()
+			case <-timeoutCh:
+				close(interrupt)
+				flush()
+				nt.notifyf("arg completer for %s timed out after %v", args[0], timeout)
+				return errArgCompleterTimeout
+			case <-cancel:
+				close(interrupt)
+				return errCompletionCancelled
+			}
+		}
+	}
+}
+
+var (
+	errArgCompleterTimeout = errors.New("arg completer timed out")
+	errCompletionCancelled = errors.New("completion cancelled")
+)
+
+// Converts an ArgStreamer to an ArgGenerator that returns all the candidates at
+// once.
+func collectArgStreamer(s complete.ArgStreamer) complete.ArgGenerator {
+	return func(args []string) ([]complete.RawItem, error) {
+		var items []complete.RawItem
+		err := s(args, func(batch []complete.RawItem) {
+			items = append(items, batch...)
+		})
+		return items, err
 	}
 }
 
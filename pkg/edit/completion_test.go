@@ -8,6 +8,7 @@ import (
 	. "src.elv.sh/pkg/eval/evaltest"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
 )
 
 func TestCompletionAddon(t *testing.T) {
@@ -135,6 +136,58 @@ func TestCompletionArgCompleter_BytesOutput(t *testing.T) {
 	)
 }
 
+func TestCompletionArgCompleter_Loading(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler,
+		`fn foo { }`,
+		`edit:completion:arg-completer[foo] = [@args]{
+		   put 1val
+		   sleep 10
+		   put 2val
+		 }`)
+
+	feedInput(f.TTYCtrl, "foo \t")
+	f.TestTTY(t,
+		"~> foo 1val\n", Styles,
+		"   vvv ____",
+		" COMPLETING argument (loading)  ", Styles,
+		"******************************* ", term.DotHere, "\n",
+		"1val", Styles,
+		"++++",
+	)
+	// Closing the completion mode interrupts the arg completer.
+	f.TTYCtrl.Inject(term.K('C', ui.Ctrl))
+	f.TestTTY(t,
+		"~> foo ", Styles,
+		"   vvv", term.DotHere,
+	)
+}
+
+func TestCompletionArgCompleter_Timeout(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler,
+		`fn foo { }`,
+		`edit:completion:arg-completer-timeout = 0.05`,
+		`edit:completion:arg-completer[foo] = [@args]{
+		   put 1val 2val
+		   sleep 10
+		   put 3val
+		 }`)
+
+	feedInput(f.TTYCtrl, "foo \t")
+	f.TestTTYNotes(t, "arg completer for foo timed out after 50ms")
+	f.TestTTY(t,
+		"~> foo 1val\n", Styles,
+		"   vvv ____",
+		" COMPLETING argument  ", Styles,
+		"********************* ", term.DotHere, "\n",
+		"1val  2val", Styles,
+		"++++      ",
+	)
+}
+
 func TestCompleteSudo(t *testing.T) {
 	f := setup(t)
 
@@ -165,6 +218,24 @@ func TestCompletionMatcher(t *testing.T) {
 	)
 }
 
+func TestCompletionMatcher_Ranked(t *testing.T) {
+	f := setup(t)
+
+	testutil.ApplyDir(testutil.Dir{"axxb": "", "a-b": "", "ab": "", "ba": ""})
+
+	evals(f.Evaler, `edit:completion:matcher[''] = $edit:match-fuzzy~`)
+	feedInput(f.TTYCtrl, "echo ab\t")
+	// Candidates are sorted by their scores.
+	f.TestTTY(t,
+		"~> echo ab \n", Styles,
+		"   vvvv ___",
+		" COMPLETING argument  ", Styles,
+		"********************* ", term.DotHere, "\n",
+		"ab  a-b  axxb", Styles,
+		"++           ",
+	)
+}
+
 func TestBuiltinMatchers(t *testing.T) {
 	f := setup(t)
 
@@ -182,6 +253,25 @@ func TestBuiltinMatchers(t *testing.T) {
 	testThatOutputErrorIsBubbled(t, f, "edit:match-prefix ab [ab]")
 }
 
+func TestMatchFuzzy(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler,
+		`@kinds = (edit:match-fuzzy ab [ab axb ba [a b]] | each $kind-of~)`,
+		`@unmatched = (edit:match-fuzzy ab [ab ba] | each {|x| eq $x $false })`,
+		`better = (> (edit:match-fuzzy ab [a-b]) (edit:match-fuzzy ab [axxb]))`,
+		`@smart = (edit:match-fuzzy &smart-case ab [AxB axb] | each {|x| eq $x $false })`,
+	)
+	testGlobals(t, f.Evaler, map[string]interface{}{
+		"kinds":     vals.MakeList("number", "number", "bool", "number"),
+		"unmatched": vals.MakeList(false, true),
+		"better":    true,
+		"smart":     vals.MakeList(false, false),
+	})
+
+	testThatOutputErrorIsBubbled(t, f, "edit:match-fuzzy ab [ab]")
+}
+
 func TestBuiltinMatchers_Options(t *testing.T) {
 	f := setup(t)
 
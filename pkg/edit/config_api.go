@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/cli/histutil"
@@ -11,6 +12,7 @@ import (
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/store/storedefs"
 )
 
@@ -29,6 +31,29 @@ func initMaxHeight(appSpec *cli.AppSpec, nb eval.NsBuilder) {
 	nb.Add("max-height", maxHeight)
 }
 
+//elvdoc:var mouse
+//
+// Whether to turn on mouse reporting of the terminal while reading code,
+// defaults to `$false`. The value is checked at the start of each readline
+// cycle.
+//
+// When mouse reporting is on, clicking in the code area moves the cursor to the
+// clicked position. In listing modes like completion and location mode,
+// clicking an item selects it, clicking it again quickly (double-clicking)
+// accepts it, and scrolling the mouse wheel moves the selection. In navigation
+// mode, clicking the parent or preview column moves up or down the directory
+// tree.
+//
+// Since the terminal handles mouse events itself when mouse reporting is off,
+// selecting text with the mouse usually requires holding
+// <span class="key">Shift</span> when this is turned on.
+
+func initMouse(appSpec *cli.AppSpec, nb eval.NsBuilder) {
+	mouse := newBoolVar(false)
+	appSpec.Mouse = func() bool { return mouse.Get().(bool) }
+	nb.Add("mouse", mouse)
+}
+
 func initReadlineHooks(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
 	initBeforeReadline(appSpec, ev, nb)
 	initAfterReadline(appSpec, ev, nb)
@@ -69,21 +94,85 @@ func initAfterReadline(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder)
 // command is not saved to history, and the rest of the filters are
 // not run. The default value of this list contains a filter which
 // ignores command starts with space.
+//
+// Along with the text of the command, the history records the working
+// directory, the start time, the hostname and the
+// [session ID](#edithistorysession-id); when the command finishes, its duration
+// and status are recorded too. See [`store:cmds`](store.html#storecmds) for how
+// to access them.
 
-func initAddCmdFilters(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder, s histutil.Store) {
+func initAddCmdFilters(appSpec *cli.AppSpec, ed *Editor, ev *eval.Evaler, nb eval.NsBuilder, s histutil.Store, session string) {
 	ignoreLeadingSpace := eval.NewGoFn("<ignore-cmd-with-leading-space>",
 		func(s string) bool { return !strings.HasPrefix(s, " ") })
 	filters := newListVar(vals.MakeList(ignoreLeadingSpace))
 	nb["add-cmd-filters"] = filters
 
+	// The last command added to the history, whose result is yet to be
+	// recorded.
+	var (
+		pending    bool
+		pendingCmd storedefs.Cmd
+	)
 	appSpec.AfterReadline = append(appSpec.AfterReadline, func(code string) {
+		pending = false
 		if code != "" &&
 			callFilters(ev, "$<edit>:add-cmd-filters",
 				filters.Get().(vals.List), code) {
-			s.AddCmd(storedefs.Cmd{Text: code, Seq: -1})
+			cmd := newHistoryCmd(code, session)
+			seq, err := s.AddCmd(cmd)
+			if err == nil {
+				pending, pendingCmd = true, cmd
+				pendingCmd.Seq = seq
+			}
 		}
 		// TODO(xiaq): Handle the error.
 	})
+	ed.AfterCommand = append(ed.AfterCommand,
+		func(src parse.Source, duration float64, err error) {
+			if !pending || src.Code != pendingCmd.Text {
+				return
+			}
+			pending = false
+			s.SetCmdResult(pendingCmd.Seq, duration, cmdStatus(err))
+		})
+}
+
+// Returns a new ID for an interactive session.
+func newSessionID() string {
+	return fmt.Sprintf("%d-%d", os.Getpid(), time.Now().Unix())
+}
+
+// Returns a new entry of the command history with metadata about the current
+// environment.
+func newHistoryCmd(code, session string) storedefs.Cmd {
+	// The metadata is best-effort; errors just leave it unknown.
+	dir, _ := os.Getwd()
+	host, _ := os.Hostname()
+	return storedefs.Cmd{Text: code, Seq: -1,
+		Dir: dir, Start: int(time.Now().Unix()), Host: host, Session: session}
+}
+
+// Returns the value of storedefs.Cmd.Status for a command that finished with
+// the given error.
+func cmdStatus(err error) string {
+	if err == nil {
+		return storedefs.StatusOK
+	}
+	if exc, ok := err.(eval.Exception); ok {
+		err = exc.Reason()
+	}
+	switch err := err.(type) {
+	case eval.ExternalCmdExit:
+		switch ws := err.WaitStatus; {
+		case ws.Exited():
+			return storedefs.ExitStatus(ws.ExitStatus())
+		case ws.Signaled():
+			return storedefs.SignalStatus(ws.Signal().String())
+		}
+	case eval.FailError:
+		return storedefs.StatusFail
+	}
+	return storedefs.StatusError
 }
 
 //elvdoc:var global-binding
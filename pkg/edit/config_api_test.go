@@ -20,6 +20,35 @@ func TestBeforeReadline(t *testing.T) {
 	testGlobal(t, f.Evaler, "called", 1)
 }
 
+func TestMouse(t *testing.T) {
+	f := setup(t, rc(
+		`set edit:mouse = $true`,
+		`set edit:completion:arg-completer[foo] = {|@args| put a b }`))
+	f.TestTTY(t, "~> ", term.DotHere)
+	if !f.TTYCtrl.Mouse() {
+		t.Errorf("mouse not turned on")
+	}
+
+	feedInput(f.TTYCtrl, "foo ")
+	f.TTYCtrl.Inject(term.K(ui.Tab))
+	f.TestTTY(t,
+		"~> foo a\n", Styles,
+		"   !!! _",
+		" COMPLETING argument  ", Styles,
+		"********************* ", term.DotHere, "\n",
+		"a  b", Styles,
+		"+   ",
+	)
+	// Double-click the second candidate. The fake TTY reports positions as if
+	// the buffer is at the top of the screen, and positions reported by the
+	// terminal are 1-based.
+	b := term.MouseEvent{Pos: term.Pos{Line: 3, Col: 4}, Down: true}
+	f.TTYCtrl.Inject(b, b)
+	f.TestTTY(t,
+		"~> foo b", Styles,
+		"   !!!  ", term.DotHere)
+}
+
 func TestAfterReadline(t *testing.T) {
 	f := setup(t)
 
@@ -53,23 +53,28 @@ func NewEditor(tty cli.TTY, ev *eval.Evaler, st storedefs.Store) *Editor {
 	if err != nil {
 		_ = err // TODO(xiaq): Report the error.
 	}
+	session := newSessionID()
 
-	initHighlighter(&appSpec, ev)
+	initHighlighter(&appSpec, ev, nb)
 	initMaxHeight(&appSpec, nb)
+	initMouse(&appSpec, nb)
 	initReadlineHooks(&appSpec, ev, nb)
-	initAddCmdFilters(&appSpec, ev, nb, hs)
+	initAddCmdFilters(&appSpec, ed, ev, nb, hs, session)
 	initGlobalBindings(&appSpec, ed, ev, nb)
 	initInsertAPI(&appSpec, ed, ev, nb)
+	initPaste(&appSpec, ed, ev, nb)
 	initPrompts(&appSpec, ed, ev, nb)
+	initAutosuggest(&appSpec, ed, ev, hs, nb)
+	vi := initViBindings(&appSpec, ed, ev)
 	ed.app = cli.NewApp(appSpec)
 
 	initExceptionsAPI(ed, nb)
 	initVarsAPI(ed, nb)
 	initCommandAPI(ed, ev, nb)
-	initListings(ed, ev, st, hs, nb)
-	initNavigation(ed, ev, nb)
-	initCompletion(ed, ev, nb)
-	initHistWalk(ed, ev, hs, nb)
+	filterSpecFor := initListings(ed, ev, st, hs, session, nb)
+	initNavigation(ed, ev, filterSpecFor, nb)
+	initCompletion(ed, ev, filterSpecFor, nb)
+	initHistWalk(ed, ev, hs, session, nb)
 	initInstant(ed, ev, nb)
 	initMinibuf(ed, ev, nb)
 
@@ -78,6 +83,7 @@ func NewEditor(tty cli.TTY, ev *eval.Evaler, st storedefs.Store) *Editor {
 	initTTYBuiltins(ed.app, tty, nb)
 	initMiscBuiltins(ed.app, nb)
 	initStateAPI(ed.app, nb)
+	initViAPI(ed.app, vi, nb)
 	initStoreAPI(ed.app, nb, hs)
 
 	ed.ns = nb.Ns()
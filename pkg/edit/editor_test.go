@@ -1,10 +1,16 @@
 package edit
 
 import (
+	"errors"
+	"os"
 	"reflect"
 	"testing"
+	"time"
 
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/tt"
 )
 
 func TestEditor_AddsHistoryAfterAccepting(t *testing.T) {
@@ -25,18 +31,58 @@ func TestEditor_DoesNotAddEmptyCommandToHistory(t *testing.T) {
 	testCommands(t, f.Store /* no commands */)
 }
 
+func TestEditor_RecordsHistoryMetadata(t *testing.T) {
+	f := setup(t)
+	wd, _ := os.Getwd()
+	host, _ := os.Hostname()
+	before := int(time.Now().Unix())
+
+	feedInput(f.TTYCtrl, "echo x\n")
+	f.Wait()
+	f.Editor.RunAfterCommandHooks(parse.Source{Code: "echo x"}, 1.5, nil)
+
+	cmds, _ := f.Store.CmdsWithSeq(0, -1)
+	if len(cmds) != 1 {
+		t.Fatalf("got cmds %v, want 1 command", cmds)
+	}
+	cmd := cmds[0]
+	if cmd.Start < before || cmd.Start > int(time.Now().Unix()) {
+		t.Errorf("got start %v, want between %v and now", cmd.Start, before)
+	}
+	cmd.Start = 0
+	evals(f.Evaler, `var session = $edit:history:session-id`)
+	session := getGlobal(f.Evaler, "session").(string)
+	wantCmd := storedefs.Cmd{Text: "echo x", Seq: 1, Dir: wd, Duration: 1.5,
+		Status: "ok", Host: host, Session: session}
+	if cmd != wantCmd {
+		t.Errorf("got cmd %v, want %v", cmd, wantCmd)
+	}
+}
+
+func TestCmdStatus(t *testing.T) {
+	tt.Test(t, tt.Fn("cmdStatus", cmdStatus), tt.Table{
+		tt.Args(eval.FailError{Content: "x"}).Rets("fail"),
+		tt.Args(errors.New("x")).Rets("error"),
+	})
+}
+
 func TestEditor_Notify(t *testing.T) {
 	f := setup(t)
 	f.Editor.Notify("note")
 	f.TestTTYNotes(t, "note")
 }
 
+// Tests the text and sequence numbers of the commands in the store, ignoring
+// the metadata.
 func testCommands(t *testing.T, store storedefs.Store, wantCmds ...storedefs.Cmd) {
 	t.Helper()
 	cmds, err := store.CmdsWithSeq(0, 1024)
 	if err != nil {
 		panic(err)
 	}
+	for i, cmd := range cmds {
+		cmds[i] = storedefs.Cmd{Text: cmd.Text, Seq: cmd.Seq}
+	}
 	if !reflect.DeepEqual(cmds, wantCmds) {
 		t.Errorf("got cmds %v, want %v", cmds, wantCmds)
 	}
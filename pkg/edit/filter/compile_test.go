@@ -1,6 +1,7 @@
 package filter_test
 
 import (
+	"reflect"
 	"testing"
 
 	"src.elv.sh/pkg/edit/filter"
@@ -85,10 +86,59 @@ func TestCompile(t *testing.T) {
 	)
 }
 
+func TestCompileFuzzy(t *testing.T) {
+	testWith(t, filter.CompileFuzzy,
+		That("bareword matches any string containing its runes in order").
+			Filter("fb").Matches("foobar", "f/b", "fb").DoesNotMatch("", "bf", "foo"),
+		That("bareword is case-insensitive is filter is all lower case").
+			Filter("fb").Matches("FooBar").DoesNotMatch("faa"),
+		That("bareword is case-sensitive is filter is not all lower case").
+			Filter("Fb").Matches("Foobar").DoesNotMatch("foobar"),
+		That("space-separated words work like an AND filter").
+			Filter("fb ls").Matches("foobar ls", "ls foobar").DoesNotMatch("foobar"),
+		That("RE filter is not affected").
+			Filter("[re f..]").Matches("foo").DoesNotMatch("fo", "xfo"),
+	)
+}
+
+var scoreTests = []struct {
+	filter        string
+	better, worse string
+	wantPositions []int
+}{
+	{"fb", "foo bar", "fxxb", []int{0, 4}},
+	{"fb ls", "ls foo bar", "ls fxxb", []int{0, 1, 3, 7}},
+	{"[or fb xy]", "foo bar", "xxy", []int{0, 4}},
+	{"[re f.] b", "f bar", "fx xxxb", []int{2}},
+}
+
+func TestScore(t *testing.T) {
+	for _, test := range scoreTests {
+		q, err := filter.CompileFuzzy(test.filter)
+		if err != nil {
+			t.Fatalf("%q should compile, but got %v", test.filter, err)
+		}
+		better, positions, ok1 := filter.Score(q, test.better)
+		worse, _, ok2 := filter.Score(q, test.worse)
+		if !ok1 || !ok2 || better <= worse {
+			t.Errorf("%q should score %q higher than %q, got %v and %v",
+				test.filter, test.better, test.worse, better, worse)
+		}
+		if !reflect.DeepEqual(positions, test.wantPositions) {
+			t.Errorf("%q should match %v in %q, got %v",
+				test.filter, test.wantPositions, test.better, positions)
+		}
+	}
+}
+
 func test(t *testing.T, tests ...testCase) {
+	testWith(t, filter.Compile, tests...)
+}
+
+func testWith(t *testing.T, compile func(string) (filter.Filter, error), tests ...testCase) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			q, err := filter.Compile(test.filter)
+			q, err := compile(test.filter)
 			if errType := getErrorType(err); errType != test.errorType {
 				t.Errorf("%q should have %s, but has %s",
 					test.filter, test.errorType, errType)
@@ -2,7 +2,10 @@ package filter
 
 import (
 	"regexp"
+	"sort"
 	"strings"
+
+	"src.elv.sh/pkg/strutil"
 )
 
 // Filter represents a compiled filter, which can be used to match text.
@@ -10,6 +13,22 @@ type Filter interface {
 	Match(s string) bool
 }
 
+// Scorer is implemented by filters that can rank the text they match.
+type Scorer interface {
+	// Score returns whether s matches, the score of the match, higher being
+	// better, and the byte indices of the matched runes in s.
+	Score(s string) (score int, positions []int, ok bool)
+}
+
+// Score scores s with f. If f does not implement Scorer, matching text gets a
+// score of 0 and no matched runes.
+func Score(f Filter, s string) (score int, positions []int, ok bool) {
+	if sf, ok := f.(Scorer); ok {
+		return sf.Score(s)
+	}
+	return 0, nil, f.Match(s)
+}
+
 type andFilter struct {
 	queries []Filter
 }
@@ -23,6 +42,20 @@ func (aq andFilter) Match(s string) bool {
 	return true
 }
 
+func (aq andFilter) Score(s string) (int, []int, bool) {
+	total := 0
+	var positions []int
+	for _, q := range aq.queries {
+		score, qPositions, ok := Score(q, s)
+		if !ok {
+			return 0, nil, false
+		}
+		total += score
+		positions = append(positions, qPositions...)
+	}
+	return total, sortedUnique(positions), true
+}
+
 type orFilter struct {
 	queries []Filter
 }
@@ -36,6 +69,18 @@ func (oq orFilter) Match(s string) bool {
 	return false
 }
 
+func (oq orFilter) Score(s string) (int, []int, bool) {
+	best, bestOK := 0, false
+	var positions []int
+	for _, q := range oq.queries {
+		score, qPositions, ok := Score(q, s)
+		if ok && (!bestOK || score > best) {
+			best, positions, bestOK = score, qPositions, true
+		}
+	}
+	return best, positions, bestOK
+}
+
 type substringFilter struct {
 	pattern    string
 	ignoreCase bool
@@ -55,3 +100,28 @@ type regexpFilter struct {
 func (rq regexpFilter) Match(s string) bool {
 	return rq.pattern.MatchString(s)
 }
+
+type fuzzyFilter struct {
+	pattern    string
+	ignoreCase bool
+}
+
+func (fq fuzzyFilter) Match(s string) bool {
+	_, _, ok := fq.Score(s)
+	return ok
+}
+
+func (fq fuzzyFilter) Score(s string) (int, []int, bool) {
+	return strutil.FuzzyMatch(s, fq.pattern, fq.ignoreCase)
+}
+
+func sortedUnique(a []int) []int {
+	sort.Ints(a)
+	var unique []int
+	for i, x := range a {
+		if i == 0 || x != a[i-1] {
+			unique = append(unique, x)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/fsutil"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/parse/cmpd"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/ui"
+)
+
+// SplitHistoryQuery splits a filter for command history into a query on the
+// metadata of commands and the rest of the filter, which filters the text of
+// commands.
+//
+// The query is made up of barewords at the top level of the filter in the form
+// key:value, where the key is one of storedefs.CmdQueryKeys. A value starting
+// with ~ has it expanded to the home directory, and the value "current" for
+// the session key means the given session.
+//
+// If any value is invalid, the returned query is zero and the error is
+// non-nil; the rest of the filter is still returned.
+func SplitHistoryQuery(f, session string) (storedefs.CmdQuery, string, error) {
+	qn, _ := parseFilter(f)
+	var q storedefs.CmdQuery
+	var errs []error
+	var sb strings.Builder
+	last := 0
+	now := time.Now()
+	for _, arg := range qn.Args {
+		key, value, ok := historyPredicate(arg)
+		if !ok {
+			continue
+		}
+		if err := setHistoryPredicate(&q, key, value, session, now); err != nil {
+			errs = append(errs, err)
+		}
+		sb.WriteString(f[last:arg.Range().From])
+		last = arg.Range().To
+	}
+	sb.WriteString(f[last:])
+	if len(errs) > 0 {
+		return storedefs.CmdQuery{}, sb.String(), diag.Errors(errs...)
+	}
+	return q, sb.String(), nil
+}
+
+// HighlightHistory is like Highlight, but also highlights the keys of the
+// predicates recognized by SplitHistoryQuery, and their values if invalid.
+func HighlightHistory(f string) (ui.Text, []error) {
+	qn, _ := parseFilter(f)
+	w := walker{}
+	w.walk(qn)
+	now := time.Now()
+	for _, arg := range qn.Args {
+		key, value, ok := historyPredicate(arg)
+		if !ok {
+			continue
+		}
+		r := arg.Range()
+		keyEnd := r.From + len(key) + 1
+		w.emit(diag.Ranging{From: r.From, To: keyEnd}, ui.FgGreen)
+		if setHistoryPredicate(&storedefs.CmdQuery{}, key, value, "", now) != nil {
+			w.emit(diag.Ranging{From: keyEnd, To: r.To}, ui.FgRed)
+		}
+	}
+	return ui.StyleRegions(f, w.regions), nil
+}
+
+// Returns the key and value of a history predicate.
+func historyPredicate(n *parse.Compound) (key, value string, ok bool) {
+	pn, ok := cmpd.Primary(n)
+	if !ok || pn.Type != parse.Bareword {
+		return "", "", false
+	}
+	i := strings.IndexByte(pn.Value, ':')
+	if i == -1 {
+		return "", "", false
+	}
+	key, value = pn.Value[:i], pn.Value[i+1:]
+	for _, k := range storedefs.CmdQueryKeys {
+		if key == k {
+			return key, value, true
+		}
+	}
+	return "", "", false
+}
+
+func setHistoryPredicate(q *storedefs.CmdQuery, key, value, session string, now time.Time) error {
+	switch {
+	case key == "cwd" && (value == "~" || strings.HasPrefix(value, "~/")):
+		home, err := fsutil.GetHome("")
+		if err != nil {
+			return err
+		}
+		value = home + value[1:]
+	case key == "session" && value == "current":
+		value = session
+	}
+	return q.Set(key, value, now)
+}
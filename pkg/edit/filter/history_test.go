@@ -0,0 +1,64 @@
+package filter_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/edit/filter"
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/testutil"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestSplitHistoryQuery(t *testing.T) {
+	home := testutil.TempHome(t)
+	wd, _ := filepath.Abs(".")
+
+	tests := []struct {
+		f         string
+		wantQuery storedefs.CmdQuery
+		wantRest  string
+		wantErr   bool
+	}{
+		{f: "make test", wantRest: "make test"},
+		{
+			f:         "cwd:~/api make status:failed",
+			wantQuery: storedefs.CmdQuery{Dir: filepath.Join(home, "api"), Status: "failed"},
+			wantRest:  " make ",
+		},
+		{f: "cwd:.", wantQuery: storedefs.CmdQuery{Dir: wd}},
+		{f: "status:2", wantQuery: storedefs.CmdQuery{Status: "exit 2"}},
+		{
+			f:         "since:1634300000 before:1634400000",
+			wantQuery: storedefs.CmdQuery{Since: 1634300000, Before: 1634400000},
+			wantRest:  " ",
+		},
+		{
+			f:         "session:current session:other",
+			wantQuery: storedefs.CmdQuery{Session: "other"},
+			wantRest:  " ",
+		},
+		{f: "session:current", wantQuery: storedefs.CmdQuery{Session: "s1"}},
+		// Only barewords with known keys at the top level are predicates.
+		{f: "'cwd:/' foo:bar [and cwd:/]", wantRest: "'cwd:/' foo:bar [and cwd:/]"},
+		{f: "since:bad x", wantRest: " x", wantErr: true},
+	}
+	for _, test := range tests {
+		q, rest, err := filter.SplitHistoryQuery(test.f, "s1")
+		if q != test.wantQuery || rest != test.wantRest || (err != nil) != test.wantErr {
+			t.Errorf("SplitHistoryQuery(%q) -> (%v, %q, %v), want (%v, %q, error? %v)",
+				test.f, q, rest, err, test.wantQuery, test.wantRest, test.wantErr)
+		}
+	}
+}
+
+func TestHighlightHistory(t *testing.T) {
+	got, _ := filter.HighlightHistory("cwd:/ since:bad foo:x")
+	want := ui.Concat(
+		ui.T("cwd:", ui.FgGreen), ui.T("/ "),
+		ui.T("since:", ui.FgGreen), ui.T("bad", ui.FgRed), ui.T(" foo:x"))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -3,19 +3,129 @@ package edit
 import (
 	"os"
 	"os/exec"
+	"sync"
 
 	"src.elv.sh/pkg/cli"
 	"src.elv.sh/pkg/edit/highlight"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
 	"src.elv.sh/pkg/fsutil"
 	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/ui"
 )
 
-func initHighlighter(appSpec *cli.AppSpec, ev *eval.Evaler) {
-	appSpec.Highlighter = highlight.NewHighlighter(highlight.Config{
-		Check:      func(tree parse.Tree) error { return check(ev, tree) },
-		HasCommand: func(cmd string) bool { return hasCommand(ev, cmd) },
+//elvdoc:var highlight:styles
+//
+// A map from region types to the styling used to highlight them, in the same
+// format as the `$style` argument of [`styled`](builtin.html#styled), like
+// `'red bold'`. An empty string means that the region is not styled.
+//
+// The region types are:
+//
+// -   The types of literals: `bareword`, `single-quoted`, `double-quoted`,
+//     `heredoc`, `variable`, `wildcard`, `tilde` and `number`, the last of
+//     which applies to barewords that are number literals, like `42`.
+//
+// -   `comment`, and punctuation marks like `|`, `>`, `(` and `{`, keyed by
+//     themselves.
+//
+// -   `command`, which applies to the command of a form when it is a bareword.
+//     Commands are further classified as `builtin-command` (special forms, and
+//     functions in the builtin namespace and builtin modules), `user-command`
+//     (other functions), `external-command` (external commands and paths) and
+//     `bad-command` (commands that cannot be found).
+//
+// -   `undefined-variable`, which applies to variables that are neither
+//     defined in the code nor in the global or builtin namespace.
+//
+// -   `option-key`, which applies to the key of an option, like `foo` in
+//     `&foo=bar`.
+//
+// -   `keyword`, which applies to keywords in special forms, like `else`.
+//
+// -   `error`, which applies to parse and compilation errors.
+//
+// If the styling of `number`, `builtin-command`, `user-command`,
+// `external-command`, `undefined-variable` or `option-key` is missing from the
+// map, that of `bareword`, `command`, `command`, `command`, `variable` or
+// `bareword` is used respectively. Other region types missing from the map are
+// not styled.
+//
+// Changes to this variable take effect on the next redraw. Example:
+//
+// ```elvish
+// set edit:highlight:styles[builtin-command] = 'blue bold'
+// set edit:highlight:styles[number] = cyan
+// ```
+
+func initHighlighter(appSpec *cli.AppSpec, ev *eval.Evaler, nb eval.NsBuilder) {
+	hl := highlight.NewHighlighter(highlight.Config{
+		Check: func(tree parse.Tree) error { return check(ev, tree) },
+		ClassifyCommand: func(cmd string) highlight.CommandKind {
+			return classifyCommand(ev, cmd)
+		},
+		HasVariable: func(name string) bool { return hasVariable(ev, name) },
 	})
+	appSpec.Highlighter = hl
+
+	var mutex sync.RWMutex
+	styles := vals.EmptyMap
+	for typ, styling := range highlight.DefaultStyles {
+		styles = styles.Assoc(typ, styling)
+	}
+	stylesVar := vars.FromSetGet(
+		func(v interface{}) error {
+			m, ok := v.(vals.Map)
+			if !ok {
+				return errs.BadValue{
+					What:  "value of $edit:highlight:styles",
+					Valid: "map", Actual: vals.Kind(v)}
+			}
+			stylings, err := parseStylings(m)
+			if err != nil {
+				return err
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			styles = m
+			hl.SetStyles(stylings)
+			return nil
+		},
+		func() interface{} {
+			mutex.RLock()
+			defer mutex.RUnlock()
+			return styles
+		})
+	nb.AddNs("highlight", eval.NsBuilder{"styles": stylesVar}.Ns())
+}
+
+func parseStylings(m vals.Map) (map[string]ui.Styling, error) {
+	stylings := make(map[string]ui.Styling, m.Len())
+	for it := m.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		typ, ok := k.(string)
+		if !ok {
+			return nil, errs.BadValue{
+				What:  "key of $edit:highlight:styles",
+				Valid: "string", Actual: vals.Kind(k)}
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, errs.BadValue{
+				What:  "styling for " + typ,
+				Valid: "string", Actual: vals.Kind(v)}
+		}
+		styling := ui.ParseStyling(s)
+		if styling == nil && s != "" {
+			return nil, errs.BadValue{
+				What:  "styling for " + typ,
+				Valid: "valid styling", Actual: parse.Quote(s)}
+		}
+		stylings[typ] = styling
+	}
+	return stylings, nil
 }
 
 func check(ev *eval.Evaler, tree parse.Tree) error {
@@ -26,67 +136,103 @@ func check(ev *eval.Evaler, tree parse.Tree) error {
 	return err
 }
 
-func hasCommand(ev *eval.Evaler, cmd string) bool {
+func classifyCommand(ev *eval.Evaler, cmd string) highlight.CommandKind {
 	if eval.IsBuiltinSpecial[cmd] {
-		return true
+		return highlight.BuiltinCommand
 	}
 	if fsutil.DontSearch(cmd) {
-		return isDirOrExecutable(cmd) || hasExternalCommand(cmd)
+		return externalIf(isDirOrExecutable(cmd) || hasExternalCommand(cmd))
 	}
 
 	sigil, qname := eval.SplitSigil(cmd)
 	if sigil != "" {
 		// The @ sign is only valid when referring to external commands.
-		return hasExternalCommand(cmd)
+		return externalIf(hasExternalCommand(cmd))
 	}
 
 	first, rest := eval.SplitQName(qname)
 	switch {
 	case rest == "":
-		// Unqualified name; try builtin and global.
-		if hasFn(ev.Builtin(), first) || hasFn(ev.Global(), first) {
-			return true
+		// Unqualified name; try global and builtin.
+		if hasFn(ev.Global(), first) {
+			return highlight.UserCommand
+		}
+		if hasFn(ev.Builtin(), first) {
+			return highlight.BuiltinCommand
 		}
 	case first == "e:":
-		return hasExternalCommand(rest)
+		return externalIf(hasExternalCommand(rest))
 	default:
 		// Qualified name. Find the top-level module first.
-		if hasQualifiedFn(ev, first, rest) {
-			return true
+		if ns, name, builtin := resolveQName(ev, first, rest); ns != nil && hasFn(ns, name) {
+			if builtin {
+				return highlight.BuiltinCommand
+			}
+			return highlight.UserCommand
 		}
 	}
 
 	// If all failed, it can still be an external command.
-	return hasExternalCommand(cmd)
+	return externalIf(hasExternalCommand(cmd))
 }
 
-func hasQualifiedFn(ev *eval.Evaler, firstNs string, rest string) bool {
+func externalIf(found bool) highlight.CommandKind {
+	if found {
+		return highlight.ExternalCommand
+	}
+	return highlight.BadCommand
+}
+
+// Reports whether a variable is defined in the global or builtin namespace, or
+// a module in them. The name does not include the sigil.
+func hasVariable(ev *eval.Evaler, name string) bool {
+	first, rest := eval.SplitQName(name)
+	switch first {
+	case "E:":
+		return true
+	case "local:", "up:":
+		// Code in the REPL is compiled in the global scope.
+		first, rest = eval.SplitQName(rest)
+	}
 	if rest == "" {
-		return false
+		return hasVar(ev.Global(), first) || hasVar(ev.Builtin(), first)
 	}
+	ns, name, _ := resolveQName(ev, first, rest)
+	return ns != nil && hasVar(ns, name)
+}
+
+// Finds the namespace a qualified name refers to, and returns it along with
+// the unqualified name in it, and whether the top-level module is found in the
+// builtin namespace. If the namespace cannot be found, it returns a nil *Ns.
+func resolveQName(ev *eval.Evaler, firstNs string, rest string) (*eval.Ns, string, bool) {
+	if rest == "" {
+		return nil, "", false
+	}
+	builtin := false
 	modVal, ok := ev.Global().Index(firstNs)
 	if !ok {
 		modVal, ok = ev.Builtin().Index(firstNs)
 		if !ok {
-			return false
+			return nil, "", false
 		}
+		builtin = true
 	}
 	mod, ok := modVal.(*eval.Ns)
 	if !ok {
-		return false
+		return nil, "", false
 	}
 	segs := eval.SplitQNameSegs(rest)
 	for _, seg := range segs[:len(segs)-1] {
 		modVal, ok = mod.Index(seg)
 		if !ok {
-			return false
+			return nil, "", false
 		}
 		mod, ok = modVal.(*eval.Ns)
 		if !ok {
-			return false
+			return nil, "", false
 		}
 	}
-	return hasFn(mod, segs[len(segs)-1])
+	return mod, segs[len(segs)-1], builtin
 }
 
 func hasFn(ns *eval.Ns, name string) bool {
@@ -98,6 +244,11 @@ func hasFn(ns *eval.Ns, name string) bool {
 	return ok
 }
 
+func hasVar(ns *eval.Ns, name string) bool {
+	_, ok := ns.Index(name)
+	return ok
+}
+
 func isDirOrExecutable(fname string) bool {
 	stat, err := os.Stat(fname)
 	return err == nil && (stat.IsDir() || stat.Mode()&0111 != 0)
@@ -11,8 +11,35 @@ import (
 
 // Config keeps configuration for highlighting code.
 type Config struct {
-	Check      func(n parse.Tree) error
-	HasCommand func(name string) bool
+	Check func(n parse.Tree) error
+	// Classifies a command. This may be slow, and is called asynchronously.
+	ClassifyCommand func(name string) CommandKind
+	// Reports whether a variable is defined outside the code being
+	// highlighted. The name does not include the sigil.
+	HasVariable func(name string) bool
+}
+
+// CommandKind classifies commands by what they resolve to.
+type CommandKind int
+
+// Possible values for CommandKind.
+const (
+	// The command cannot be resolved.
+	BadCommand CommandKind = iota
+	// A special form, or a function defined in the builtin namespace or a
+	// builtin module.
+	BuiltinCommand
+	// A function defined by the user, or in a module imported by the user.
+	UserCommand
+	// An external command, or a path to an executable or a directory.
+	ExternalCommand
+)
+
+var regionForCommandKind = map[CommandKind]string{
+	BadCommand:      badCommandRegion,
+	BuiltinCommand:  builtinCommandRegion,
+	UserCommand:     userCommandRegion,
+	ExternalCommand: externalCommandRegion,
 }
 
 // Information collected about a command region, used for asynchronous
@@ -26,8 +53,8 @@ type cmdRegion struct {
 // It can be changed for test cases.
 var MaxBlockForLate = 10 * time.Millisecond
 
-// Highlights a piece of Elvish code.
-func highlight(code string, cfg Config, lateCb func(ui.Text)) (ui.Text, []error) {
+// Highlights a piece of Elvish code with the given stylings of region types.
+func highlight(code string, cfg Config, stylings map[string]ui.Styling, lateCb func(ui.Text)) (ui.Text, []error) {
 	var errors []error
 	var errorRegions []region
 
@@ -55,8 +82,13 @@ func highlight(code string, cfg Config, lateCb func(ui.Text)) (ui.Text, []error)
 	}
 
 	var text ui.Text
-	regions := getRegionsInner(tree.Root)
-	regions = append(regions, errorRegions...)
+	// Error regions come first, so that they take precedence over other
+	// semantic regions at the same position.
+	regions := append(errorRegions, getRegionsInner(tree.Root)...)
+	if cfg.HasVariable != nil {
+		regions = append(regions,
+			undefinedVariableRegions(tree.Root, cfg.HasVariable)...)
+	}
 	regions = fixRegions(regions)
 	lastEnd := 0
 	var cmdRegions []cmdRegion
@@ -69,17 +101,12 @@ func highlight(code string, cfg Config, lateCb func(ui.Text)) (ui.Text, []error)
 
 		regionCode := code[r.begin:r.end]
 		var styling ui.Styling
-		if r.typ == commandRegion {
-			if cfg.HasCommand != nil {
-				// Do not highlight now, but collect the index of the region and the
-				// segment.
-				cmdRegions = append(cmdRegions, cmdRegion{len(text), regionCode})
-			} else {
-				// Treat all commands as good commands.
-				styling = stylingForGoodCommand
-			}
+		if r.typ == commandRegion && cfg.ClassifyCommand != nil {
+			// Do not highlight now, but collect the index of the region and the
+			// segment.
+			cmdRegions = append(cmdRegions, cmdRegion{len(text), regionCode})
 		} else {
-			styling = stylingFor[r.typ]
+			styling = stylingFor(stylings, r.typ)
 		}
 		seg := &ui.Segment{Text: regionCode}
 		if styling != nil {
@@ -94,20 +121,18 @@ func highlight(code string, cfg Config, lateCb func(ui.Text)) (ui.Text, []error)
 		text = append(text, &ui.Segment{Text: code[lastEnd:]})
 	}
 
-	if cfg.HasCommand != nil && len(cmdRegions) > 0 {
+	if cfg.ClassifyCommand != nil && len(cmdRegions) > 0 {
 		// Launch a goroutine to style command regions asynchronously.
 		lateCh := make(chan ui.Text)
 		go func() {
 			newText := text.Clone()
 			for _, cmdRegion := range cmdRegions {
-				var styling ui.Styling
-				if cfg.HasCommand(cmdRegion.cmd) {
-					styling = stylingForGoodCommand
-				} else {
-					styling = stylingForBadCommand
+				kind := cfg.ClassifyCommand(cmdRegion.cmd)
+				styling := stylingFor(stylings, regionForCommandKind[kind])
+				if styling != nil {
+					seg := &newText[cmdRegion.seg]
+					*seg = ui.StyleSegment(*seg, styling)
 				}
-				seg := &newText[cmdRegion.seg]
-				*seg = ui.StyleSegment(*seg, styling)
 			}
 			lateCh <- newText
 		}()
@@ -17,13 +17,32 @@ type Highlighter struct {
 
 type state struct {
 	sync.Mutex
+	stylings map[string]ui.Styling
+	// Incremented when the stylings change, so that late results computed with
+	// old stylings can be dropped.
+	generation int
 	code       string
 	styledCode ui.Text
 	errors     []error
 }
 
 func NewHighlighter(cfg Config) *Highlighter {
-	return &Highlighter{cfg, state{}, make(chan struct{}, latesBufferSize)}
+	return &Highlighter{
+		cfg, state{stylings: defaultStylings}, make(chan struct{}, latesBufferSize)}
+}
+
+// SetStyles sets the stylings of region types, replacing DefaultStyles. Region
+// types missing from stylings fall back to more general types, or are not
+// styled. The new stylings take effect on the next call to Get.
+func (hl *Highlighter) SetStyles(stylings map[string]ui.Styling) {
+	hl.state.Lock()
+	defer hl.state.Unlock()
+	hl.state.stylings = stylings
+	hl.state.generation++
+	// Invalidate the cache.
+	hl.state.code = ""
+	hl.state.styledCode = nil
+	hl.state.errors = nil
 }
 
 // Get returns the highlighted code and static errors found in the code.
@@ -34,11 +53,12 @@ func (hl *Highlighter) Get(code string) (ui.Text, []error) {
 		return hl.state.styledCode, hl.state.errors
 	}
 
+	generation := hl.state.generation
 	lateCb := func(styledCode ui.Text) {
 		hl.state.Lock()
-		if hl.state.code != code {
-			// Late result was delivered after code has changed. Unlock and
-			// return.
+		if hl.state.code != code || hl.state.generation != generation {
+			// Late result was delivered after code or stylings have changed.
+			// Unlock and return.
 			hl.state.Unlock()
 			return
 		}
@@ -48,7 +68,7 @@ func (hl *Highlighter) Get(code string) (ui.Text, []error) {
 		hl.lates <- struct{}{}
 	}
 
-	styledCode, errors := highlight(code, hl.cfg, lateCb)
+	styledCode, errors := highlight(code, hl.cfg, hl.state.stylings, lateCb)
 
 	hl.state.code = code
 	hl.state.styledCode = styledCode
@@ -20,13 +20,14 @@ var styles = ui.RuneStylesheet{
 	'$':  ui.FgMagenta,
 	'\'': ui.FgYellow,
 	'v':  ui.FgGreen,
+	'!':  ui.FgRed,
 }
 
 func TestHighlighter_HighlightRegions(t *testing.T) {
 	// Force commands to be delivered synchronously.
 	MaxBlockForLate = testutil.Scaled(100 * time.Millisecond)
 	hl := NewHighlighter(Config{
-		HasCommand: func(name string) bool { return name == "ls" },
+		ClassifyCommand: kindIfLs,
 	})
 
 	tt.Test(t, tt.Fn("hl.Get", hl.Get), tt.Table{
@@ -95,6 +96,90 @@ func TestHighlighter_CheckErrors(t *testing.T) {
 	})
 }
 
+func TestHighlighter_CommandKinds(t *testing.T) {
+	MaxBlockForLate = testutil.Scaled(100 * time.Millisecond)
+	hl := NewHighlighter(Config{
+		ClassifyCommand: func(cmd string) CommandKind {
+			return map[string]CommandKind{
+				"put": BuiltinCommand, "f": UserCommand, "ls": ExternalCommand,
+			}[cmd]
+		}})
+	hl.SetStyles(map[string]ui.Styling{
+		"builtin-command": ui.FgBlue,
+		"command":         ui.FgGreen,
+		"bad-command":     ui.FgRed,
+	})
+
+	tt.Test(t, tt.Fn("hl.Get", hl.Get), tt.Table{
+		Args("put").Rets(ui.T("put", ui.FgBlue), noErrors),
+		// User and external commands fall back to the styling of "command".
+		Args("f").Rets(ui.T("f", ui.FgGreen), noErrors),
+		Args("ls").Rets(ui.T("ls", ui.FgGreen), noErrors),
+		Args("bad").Rets(ui.T("bad", ui.FgRed), noErrors),
+	})
+}
+
+func TestHighlighter_UndefinedVariables(t *testing.T) {
+	hl := NewHighlighter(Config{
+		HasVariable: func(name string) bool { return name == "good" || name == "ns:good" },
+	})
+	hl.SetStyles(map[string]ui.Styling{
+		"variable":           ui.FgMagenta,
+		"undefined-variable": ui.FgRed,
+	})
+
+	// Compare the VT sequences, since unstyled text may be split into
+	// different segments.
+	getVT := func(code string) string {
+		text, _ := hl.Get(code)
+		return text.VTString()
+	}
+	tt.Test(t, tt.Fn("getVT", getVT), tt.Table{
+		Args("echo $good $@ns:good $bad").Rets(
+			ui.MarkLines(
+				"echo $good $@ns:good $bad", styles,
+				"     $$$$$ $$$$$$$$$ !!!!").VTString()),
+		// Variables declared in the code are considered defined, as are
+		// arguments and options of lambdas.
+		Args("var x = $x; { |a &o=$nil| echo $a $o $local:x }").Rets(
+			ui.MarkLines(
+				"var x = $x; { |a &o=$nil| echo $a $o $local:x }", styles,
+				"    $   $$          !!!!       $$ $$ $$$$$$$$  ").VTString()),
+	})
+}
+
+func TestHighlighter_SetStyles(t *testing.T) {
+	hl := NewHighlighter(Config{})
+
+	tt.Test(t, tt.Fn("hl.Get", hl.Get), tt.Table{
+		Args("ls 'x' 42").Rets(
+			ui.Concat(ui.T("ls", ui.FgGreen), ui.T(" "), ui.T("'x'", ui.FgYellow),
+				ui.T(" "), ui.T("42")),
+			noErrors),
+	})
+
+	// New styles take effect immediately, even when the code has not changed;
+	// "number" falls back to "bareword".
+	hl.SetStyles(map[string]ui.Styling{
+		"command":       ui.FgBlue,
+		"single-quoted": ui.Bold,
+		"bareword":      ui.Italic,
+	})
+	tt.Test(t, tt.Fn("hl.Get", hl.Get), tt.Table{
+		Args("ls 'x' 42").Rets(
+			ui.Concat(ui.T("ls", ui.FgBlue), ui.T(" "), ui.T("'x'", ui.Bold),
+				ui.T(" "), ui.T("42", ui.Italic)),
+			noErrors),
+	})
+}
+
+func kindIfLs(cmd string) CommandKind {
+	if cmd == "ls" {
+		return ExternalCommand
+	}
+	return BadCommand
+}
+
 type c struct {
 	given       string
 	wantInitial ui.Text
@@ -124,15 +209,15 @@ func testThat(t *testing.T, hl *Highlighter, c c) {
 	}
 }
 
-func TestHighlighter_HasCommand_LateResult_Async(t *testing.T) {
-	// When the HasCommand callback takes longer than maxBlockForLate, late
+func TestHighlighter_ClassifyCommand_LateResult_Async(t *testing.T) {
+	// When the ClassifyCommand callback takes longer than maxBlockForLate, late
 	// results are delivered asynchronously.
 	MaxBlockForLate = testutil.Scaled(time.Millisecond)
 	hl := NewHighlighter(Config{
-		// HasCommand is slow and only recognizes "ls".
-		HasCommand: func(cmd string) bool {
+		// ClassifyCommand is slow and only recognizes "ls".
+		ClassifyCommand: func(cmd string) CommandKind {
 			time.Sleep(testutil.Scaled(10 * time.Millisecond))
-			return cmd == "ls"
+			return kindIfLs(cmd)
 		}})
 
 	testThat(t, hl, c{
@@ -147,15 +232,15 @@ func TestHighlighter_HasCommand_LateResult_Async(t *testing.T) {
 	})
 }
 
-func TestHighlighter_HasCommand_LateResult_Sync(t *testing.T) {
-	// When the HasCommand callback takes shorter than maxBlockForLate, late
+func TestHighlighter_ClassifyCommand_LateResult_Sync(t *testing.T) {
+	// When the ClassifyCommand callback takes shorter than maxBlockForLate, late
 	// results are delivered asynchronously.
 	MaxBlockForLate = testutil.Scaled(100 * time.Millisecond)
 	hl := NewHighlighter(Config{
-		// HasCommand is fast and only recognizes "ls".
-		HasCommand: func(cmd string) bool {
+		// ClassifyCommand is fast and only recognizes "ls".
+		ClassifyCommand: func(cmd string) CommandKind {
 			time.Sleep(testutil.Scaled(time.Millisecond))
-			return cmd == "ls"
+			return kindIfLs(cmd)
 		}})
 
 	testThat(t, hl, c{
@@ -168,28 +253,29 @@ func TestHighlighter_HasCommand_LateResult_Sync(t *testing.T) {
 	})
 }
 
-func TestHighlighter_HasCommand_LateResultOutOfOrder(t *testing.T) {
+func TestHighlighter_ClassifyCommand_LateResultOutOfOrder(t *testing.T) {
 	// When late results are delivered out of order, the ones that do not match
 	// the current code are dropped. In this test, hl.Get is called with "l"
 	// first and then "ls". The late result for "l" is delivered after that of
 	// "ls" and is dropped.
 
-	// Make sure that the HasCommand callback takes longer than maxBlockForLate.
+	// Make sure that the ClassifyCommand callback takes longer than
+	// maxBlockForLate.
 	MaxBlockForLate = testutil.Scaled(time.Millisecond)
 
 	hlSecond := make(chan struct{})
 	hl := NewHighlighter(Config{
-		HasCommand: func(cmd string) bool {
+		ClassifyCommand: func(cmd string) CommandKind {
 			if cmd == "l" {
 				// Make sure that the second highlight has been requested before
 				// returning.
 				<-hlSecond
 				time.Sleep(testutil.Scaled(10 * time.Millisecond))
-				return false
+				return BadCommand
 			}
 			time.Sleep(testutil.Scaled(10 * time.Millisecond))
 			close(hlSecond)
-			return cmd == "ls"
+			return kindIfLs(cmd)
 		}})
 
 	hl.Get("l")
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/parse"
 )
 
@@ -42,9 +43,12 @@ const (
 	barewordRegion     = "bareword"
 	singleQuotedRegion = "single-quoted"
 	doubleQuotedRegion = "double-quoted"
+	heredocRegion      = "heredoc"
 	variableRegion     = "variable" // Could also be semantic.
 	wildcardRegion     = "wildcard"
 	tildeRegion        = "tilde"
+	// A bareword that is a number literal, like "42" or "0x1f".
+	numberRegion = "number"
 	// A comment region. Note that this is the only type of Sep leaf node that
 	// is not identified by its text.
 	commentRegion = "comment"
@@ -55,6 +59,18 @@ const (
 	// A region when a string literal (bareword, single-quoted or double-quoted)
 	// appears as a command.
 	commandRegion = "command"
+	// Command regions are further classified according to what the command
+	// resolves to when a command classifier is available. These types are
+	// only used in highlight, not emitted by emitRegions.
+	builtinCommandRegion  = "builtin-command"
+	userCommandRegion     = "user-command"
+	externalCommandRegion = "external-command"
+	badCommandRegion      = "bad-command"
+	// A region for a variable that is neither defined in the code nor known to
+	// the variable resolver.
+	undefinedVariableRegion = "undefined-variable"
+	// A region for the key of an option, like "foo" in "&foo=bar".
+	optionKeyRegion = "option-key"
 	// A region for keywords in special forms, like "else" in an "if" form.
 	keywordRegion = "keyword"
 	// A region of parse or compilation error.
@@ -77,8 +93,9 @@ func getRegionsInner(n parse.Node) []region {
 
 func fixRegions(regions []region) []region {
 	// Sort regions by the begin position, putting semantic regions before
-	// lexical regions.
-	sort.Slice(regions, func(i, j int) bool {
+	// lexical regions. The sort is stable, so that among semantic regions at
+	// the same position, the ones that are passed in first are preferred.
+	sort.SliceStable(regions, func(i, j int) bool {
 		if regions[i].begin < regions[j].begin {
 			return true
 		}
@@ -124,6 +141,12 @@ func emitRegionsInForm(n *parse.Form, f func(parse.Node, regionKind, string)) {
 	if n.Head == nil {
 		return
 	}
+	// Keys of options.
+	for _, opt := range n.Opts {
+		if opt.Key != nil {
+			f(opt.Key, semanticRegion, optionKeyRegion)
+		}
+	}
 	// Special forms.
 	// TODO: This only highlights bareword special commands, however currently
 	// quoted special commands are also possible (e.g `"if" $true { }` is
@@ -226,11 +249,21 @@ func emitRegionsInTry(n *parse.Form, f func(parse.Node, regionKind, string)) {
 func emitRegionsInPrimary(n *parse.Primary, f func(parse.Node, regionKind, string)) {
 	switch n.Type {
 	case parse.Bareword:
-		f(n, lexicalRegion, barewordRegion)
+		if isNumberLiteral(n.Value) {
+			f(n, lexicalRegion, numberRegion)
+		} else {
+			f(n, lexicalRegion, barewordRegion)
+		}
 	case parse.SingleQuoted:
 		f(n, lexicalRegion, singleQuotedRegion)
-	case parse.DoubleQuoted:
-		f(n, lexicalRegion, doubleQuotedRegion)
+	case parse.DoubleQuoted, parse.Heredoc:
+		// Interpolated strings are highlighted part by part; see below and
+		// emitRegionsInSep.
+		if len(n.Segments) == 0 {
+			f(n, lexicalRegion, stringRegion(n))
+		}
+	case parse.StringSegment:
+		f(n, lexicalRegion, stringRegion(parse.Parent(n).(*parse.Primary)))
 	case parse.Variable:
 		f(n, lexicalRegion, variableRegion)
 	case parse.Wildcard:
@@ -244,6 +277,9 @@ func emitRegionsInSep(n *parse.Sep, f func(parse.Node, regionKind, string)) {
 	text := sourceText(n)
 	trimmed := strings.TrimLeftFunc(text, parse.IsWhitespace)
 	switch {
+	case isInterpolatedString(parse.Parent(n)):
+		// Delimiters of interpolated strings.
+		f(n, lexicalRegion, stringRegion(parse.Parent(n).(*parse.Primary)))
 	case trimmed == "":
 		// Don't do anything; whitespaces do not get highlighted.
 	case strings.HasPrefix(trimmed, "#"):
@@ -252,3 +288,21 @@ func emitRegionsInSep(n *parse.Sep, f func(parse.Node, regionKind, string)) {
 		f(n, lexicalRegion, text)
 	}
 }
+
+// Reports whether a bareword is a number literal. Barewords like "inf" and
+// "nan" are also parsed as numbers, but are not highlighted as such.
+func isNumberLiteral(s string) bool {
+	return strings.ContainsAny(s, "0123456789") && vals.ParseNum(s) != nil
+}
+
+func isInterpolatedString(n parse.Node) bool {
+	pn, ok := n.(*parse.Primary)
+	return ok && len(pn.Segments) > 0
+}
+
+func stringRegion(n *parse.Primary) string {
+	if n.Type == parse.Heredoc {
+		return heredocRegion
+	}
+	return doubleQuotedRegion
+}
@@ -32,6 +32,24 @@ func TestGetRegions(t *testing.T) {
 			lsCommand,
 			{3, 6, lexicalRegion, doubleQuotedRegion}, // 'a'
 		}),
+		Args("ls '''\n  a\n  '''").Rets([]region{
+			lsCommand,
+			{3, 16, lexicalRegion, heredocRegion},
+		}),
+		Args("ls \"\"\"\n  a $x\n  \"\"\"").Rets([]region{
+			lsCommand,
+			{3, 7, lexicalRegion, heredocRegion},    // """\n
+			{7, 11, lexicalRegion, heredocRegion},   //   a
+			{11, 13, lexicalRegion, variableRegion}, // $x
+			{13, 19, lexicalRegion, heredocRegion},  // \n  """
+		}),
+		Args(`ls "a $x"`).Rets([]region{
+			lsCommand,
+			{3, 4, lexicalRegion, doubleQuotedRegion}, // "
+			{4, 6, lexicalRegion, doubleQuotedRegion}, // a
+			{6, 8, lexicalRegion, variableRegion},     // $x
+			{8, 9, lexicalRegion, doubleQuotedRegion}, // "
+		}),
 		Args("ls $x").Rets([]region{
 			lsCommand,
 			{3, 5, lexicalRegion, variableRegion}, // $x
@@ -47,6 +65,13 @@ func TestGetRegions(t *testing.T) {
 			{3, 4, lexicalRegion, tildeRegion},     // ~
 			{4, 10, lexicalRegion, barewordRegion}, // user/x
 		}),
+		Args("ls 42 0x1f 1/2 inf").Rets([]region{
+			lsCommand,
+			{3, 5, lexicalRegion, numberRegion},     // 42
+			{6, 10, lexicalRegion, numberRegion},    // 0x1f
+			{11, 14, lexicalRegion, numberRegion},   // 1/2
+			{15, 18, lexicalRegion, barewordRegion}, // inf
+		}),
 		Args("ls # comment").Rets([]region{
 			lsCommand,
 			{2, 12, lexicalRegion, commentRegion}, // # comment
@@ -68,6 +93,15 @@ func TestGetRegions(t *testing.T) {
 			{6, 8, semanticRegion, commandRegion}, // ls
 		}),
 
+		// Options
+		Args("ls &a=b").Rets([]region{
+			lsCommand,
+			{3, 4, lexicalRegion, "&"},
+			{4, 5, semanticRegion, optionKeyRegion}, // a
+			{5, 6, lexicalRegion, "="},
+			{6, 7, lexicalRegion, barewordRegion}, // b
+		}),
+
 		// The "var" special command
 		Args("var x = foo").Rets([]region{
 			{0, 3, semanticRegion, commandRegion},  // var
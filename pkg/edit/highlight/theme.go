@@ -2,36 +2,83 @@ package highlight
 
 import "src.elv.sh/pkg/ui"
 
-var stylingFor = map[string]ui.Styling{
-	barewordRegion:     nil,
-	singleQuotedRegion: ui.FgYellow,
-	doubleQuotedRegion: ui.FgYellow,
-	variableRegion:     ui.FgMagenta,
-	wildcardRegion:     nil,
-	tildeRegion:        nil,
-
-	commentRegion: ui.FgCyan,
-
-	">":  ui.FgGreen,
-	">>": ui.FgGreen,
-	"<":  ui.FgGreen,
-	"?>": ui.FgGreen,
-	"|":  ui.FgGreen,
-	"?(": ui.Bold,
-	"(":  ui.Bold,
-	")":  ui.Bold,
-	"[":  ui.Bold,
-	"]":  ui.Bold,
-	"{":  ui.Bold,
-	"}":  ui.Bold,
-	"&":  ui.Bold,
-
-	commandRegion: ui.FgGreen,
-	keywordRegion: ui.FgYellow,
-	errorRegion:   ui.Stylings(ui.FgBrightWhite, ui.BgRed),
+// DefaultStyles maps region types to the styling used for them by default,
+// expressed as strings understood by ui.ParseStyling. An empty string means
+// that the region is not styled.
+//
+// Lexical region types are the types of leaf nodes, like "bareword" and
+// "variable", and the source text of separators, like "|" and "(". Semantic
+// region types cover a part of the code with a special meaning, like
+// "command" and "keyword".
+var DefaultStyles = map[string]string{
+	barewordRegion:     "",
+	singleQuotedRegion: "yellow",
+	doubleQuotedRegion: "yellow",
+	heredocRegion:      "yellow",
+	variableRegion:     "magenta",
+	wildcardRegion:     "",
+	tildeRegion:        "",
+	numberRegion:       "",
+
+	commentRegion: "cyan",
+
+	">":  "green",
+	">>": "green",
+	"<":  "green",
+	"?>": "green",
+	"|":  "green",
+	"?(": "bold",
+	"$(": "bold",
+	"(":  "bold",
+	")":  "bold",
+	"[":  "bold",
+	"]":  "bold",
+	"{":  "bold",
+	"}":  "bold",
+	"&":  "bold",
+
+	commandRegion:           "green",
+	builtinCommandRegion:    "green",
+	userCommandRegion:       "green",
+	externalCommandRegion:   "green",
+	badCommandRegion:        "red",
+	undefinedVariableRegion: "magenta underlined",
+	optionKeyRegion:         "",
+	keywordRegion:           "yellow",
+	errorRegion:             "bright-white bg-red",
+}
+
+// Region types that are refinements of more general region types. When a
+// region type is missing from the styles, the styling of the more general type
+// is used instead.
+var fallbackTypes = map[string]string{
+	numberRegion:            barewordRegion,
+	builtinCommandRegion:    commandRegion,
+	userCommandRegion:       commandRegion,
+	externalCommandRegion:   commandRegion,
+	undefinedVariableRegion: variableRegion,
+	optionKeyRegion:         barewordRegion,
 }
 
-var (
-	stylingForGoodCommand = ui.FgGreen
-	stylingForBadCommand  = ui.FgRed
-)
+var defaultStylings = parseStyles(DefaultStyles)
+
+func parseStyles(styles map[string]string) map[string]ui.Styling {
+	stylings := make(map[string]ui.Styling, len(styles))
+	for typ, s := range styles {
+		stylings[typ] = ui.ParseStyling(s)
+	}
+	return stylings
+}
+
+// Returns the styling for a region type, following fallbacks for region types
+// missing from the stylings.
+func stylingFor(stylings map[string]ui.Styling, typ string) ui.Styling {
+	for {
+		if styling, ok := stylings[typ]; ok {
+			return styling
+		}
+		if typ = fallbackTypes[typ]; typ == "" {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package highlight
+
+import (
+	"strings"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/parse"
+)
+
+// Finds uses of variables that are neither declared in the code nor accepted by
+// hasVariable, and returns them as semantic regions.
+//
+// Variables declared anywhere in the code are considered defined, regardless
+// of scoping; precise analysis is left to the Check callback.
+func undefinedVariableRegions(n parse.Node, hasVariable func(string) bool) []region {
+	declared := declaredVariables(n)
+	var regions []region
+	walk(n, func(n parse.Node) {
+		pn, ok := n.(*parse.Primary)
+		if !ok || pn.Type != parse.Variable {
+			return
+		}
+		_, name := eval.SplitSigil(pn.Value)
+		if declared[trimScope(name)] || hasVariable(name) {
+			return
+		}
+		regions = append(regions, region{
+			pn.Range().From, pn.Range().To, semanticRegion, undefinedVariableRegion})
+	})
+	return regions
+}
+
+// Returns the names of all variables declared in the code, including those
+// assigned to and the arguments and options of lambdas.
+func declaredVariables(n parse.Node) map[string]bool {
+	declared := make(map[string]bool)
+	declare := func(n parse.Node) {
+		_, name := eval.SplitSigil(sourceText(n))
+		declared[trimScope(name)] = true
+	}
+	emitRegions(n, func(n parse.Node, kind regionKind, typ string) {
+		if kind == semanticRegion && typ == variableRegion {
+			declare(n)
+		}
+	})
+	walk(n, func(n parse.Node) {
+		pn, ok := n.(*parse.Primary)
+		if !ok || pn.Type != parse.Lambda {
+			return
+		}
+		for _, arg := range pn.Elements {
+			declare(arg)
+		}
+		for _, opt := range pn.MapPairs {
+			if opt.Key != nil {
+				declare(opt.Key)
+			}
+		}
+	})
+	return declared
+}
+
+func trimScope(name string) string {
+	for _, prefix := range []string{"local:", "up:"} {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+func walk(n parse.Node, f func(parse.Node)) {
+	f(n)
+	for _, child := range parse.Children(n) {
+		walk(child, f)
+	}
+}
@@ -7,12 +7,14 @@ import (
 	"testing"
 
 	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/edit/highlight"
 	"src.elv.sh/pkg/env"
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/vars"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/testutil"
 	"src.elv.sh/pkg/tt"
+	"src.elv.sh/pkg/ui"
 )
 
 // High-level sanity test.
@@ -62,7 +64,7 @@ var (
 
 const colonInFilenameOk = runtime.GOOS != "windows"
 
-func TestMakeHasCommand(t *testing.T) {
+func TestClassifyCommand(t *testing.T) {
 	ev := eval.NewEvaler()
 
 	// Set up global functions and modules in the evaler.
@@ -95,43 +97,107 @@ func TestMakeHasCommand(t *testing.T) {
 	mustMkdirAll("a/b/c")
 	mustMkExecutable("a/b/c/executable")
 
-	tt.Test(t, tt.Fn("hasCommand", hasCommand), tt.Table{
+	var (
+		builtin  = highlight.BuiltinCommand
+		user     = highlight.UserCommand
+		external = highlight.ExternalCommand
+		bad      = highlight.BadCommand
+	)
+	externalIfColonOk := bad
+	if colonInFilenameOk {
+		externalIfColonOk = external
+	}
+	tt.Test(t, tt.Fn("classifyCommand", classifyCommand), tt.Table{
 		// Builtin special form
-		tt.Args(ev, "if").Rets(true),
+		tt.Args(ev, "if").Rets(builtin),
 
 		// Builtin function
-		tt.Args(ev, "put").Rets(true),
+		tt.Args(ev, "put").Rets(builtin),
 
 		// User-defined function
-		tt.Args(ev, "good").Rets(true),
+		tt.Args(ev, "good").Rets(user),
 
 		// Function in modules
-		tt.Args(ev, "a:good").Rets(true),
-		tt.Args(ev, "a:b:good").Rets(true),
-		tt.Args(ev, "a:bad").Rets(false),
-		tt.Args(ev, "a:b:bad").Rets(false),
+		tt.Args(ev, "a:good").Rets(user),
+		tt.Args(ev, "a:b:good").Rets(user),
+		tt.Args(ev, "a:bad").Rets(bad),
+		tt.Args(ev, "a:b:bad").Rets(bad),
 
 		// Non-searching directory and external
-		tt.Args(ev, "./a").Rets(true),
-		tt.Args(ev, "a/b").Rets(true),
-		tt.Args(ev, "a/b/c/executable").Rets(true),
-		tt.Args(ev, "./bad").Rets(false),
-		tt.Args(ev, "a/bad").Rets(false),
-
-		// External in PATH
-		tt.Args(ev, "external").Rets(true),
-		tt.Args(ev, "@external").Rets(true),
-		tt.Args(ev, "ex:tern:al").Rets(colonInFilenameOk),
+		tt.Args(ev, "./a").Rets(external),
+		tt.Args(ev, "a/b").Rets(external),
+		tt.Args(ev, "a/b/c/executable").Rets(external),
+		tt.Args(ev, "./bad").Rets(bad),
+		tt.Args(ev, "a/bad").Rets(bad),
+
+		// External in PATH; the unqualified name "external" resolves to the
+		// builtin function of the same name first.
+		tt.Args(ev, "external").Rets(builtin),
+		tt.Args(ev, "@external").Rets(external),
+		tt.Args(ev, "ex:tern:al").Rets(externalIfColonOk),
 		// With explicit e:
-		tt.Args(ev, "e:external").Rets(true),
-		tt.Args(ev, "e:bad-external").Rets(false),
+		tt.Args(ev, "e:external").Rets(external),
+		tt.Args(ev, "e:bad-external").Rets(bad),
 
 		// Non-existent
+		tt.Args(ev, "bad").Rets(bad),
+		tt.Args(ev, "a:").Rets(bad),
+	})
+}
+
+func TestHasVariable(t *testing.T) {
+	ev := eval.NewEvaler()
+	ev.AddGlobal(eval.NsBuilder{}.
+		Add("good", vars.FromInit(0)).
+		AddNs("a", eval.NsBuilder{}.Add("good", vars.FromInit(0)).Ns()).
+		Ns())
+
+	tt.Test(t, tt.Fn("hasVariable", hasVariable), tt.Table{
+		tt.Args(ev, "good").Rets(true),
+		tt.Args(ev, "local:good").Rets(true),
+		tt.Args(ev, "a:good").Rets(true),
+		// Builtin variable
+		tt.Args(ev, "true").Rets(true),
+		// Environment variables are always defined
+		tt.Args(ev, "E:whatever").Rets(true),
+
 		tt.Args(ev, "bad").Rets(false),
-		tt.Args(ev, "a:").Rets(false),
+		tt.Args(ev, "a:bad").Rets(false),
+		tt.Args(ev, "bad:good").Rets(false),
 	})
 }
 
+func TestHighlightStyles(t *testing.T) {
+	f := setup(t, rc(
+		`set edit:highlight:styles[builtin-command] = 'blue'`,
+		`set edit:highlight:styles[number] = 'red'`))
+
+	styles := ui.RuneStylesheet{'/': ui.FgBlue, '!': ui.FgRed}
+
+	feedInput(f.TTYCtrl, "put 42")
+	f.TestTTY(t,
+		"~> put 42", styles,
+		"   /// !!", term.DotHere,
+	)
+
+	// Changes take effect on the next redraw.
+	evals(f.Evaler, `set edit:highlight:styles[number] = ''`, `edit:redraw`)
+	f.TestTTY(t,
+		"~> put 42", styles,
+		"   ///", term.DotHere,
+	)
+}
+
+func TestHighlightStyles_BadValue(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler,
+		`var ok = (bool ?(set edit:highlight:styles[number] = 'bad-style'))`,
+		`var styling = $edit:highlight:styles[number]`)
+	testGlobal(t, f.Evaler, "ok", false)
+	testGlobal(t, f.Evaler, "styling", "")
+}
+
 func mustParse(src string) parse.Tree {
 	tree, err := parse.Parse(parse.SourceForTest(src), parse.Config{})
 	if err != nil {
@@ -26,6 +26,12 @@ func (s *histStore) AddCmd(cmd storedefs.Cmd) (int, error) {
 	return s.hs.AddCmd(cmd)
 }
 
+func (s *histStore) SetCmdResult(seq int, duration float64, status string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.hs.SetCmdResult(seq, duration, status)
+}
+
 // AllCmds returns a slice of all interactive commands in oldest to newest order.
 func (s *histStore) AllCmds() ([]storedefs.Cmd, error) {
 	s.m.Lock()
@@ -33,6 +39,14 @@ func (s *histStore) AllCmds() ([]storedefs.Cmd, error) {
 	return s.hs.AllCmds()
 }
 
+// QueryCmds returns a slice of interactive commands that match the query, in
+// oldest to newest order.
+func (s *histStore) QueryCmds(q storedefs.CmdQuery) ([]storedefs.Cmd, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.hs.QueryCmds(q)
+}
+
 func (s *histStore) Cursor(prefix string) histutil.Cursor {
 	s.m.Lock()
 	defer s.m.Unlock()
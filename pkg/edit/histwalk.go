@@ -8,12 +8,18 @@ import (
 	"src.elv.sh/pkg/cli/modes"
 	"src.elv.sh/pkg/cli/tk"
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vars"
 )
 
 //elvdoc:var history:binding
 //
 // Binding table for the history mode.
 
+//elvdoc:var history:session-id
+//
+// A read-only string that identifies the current interactive session. It is
+// recorded along with each command added to the history.
+
 //elvdoc:fn history:start
 //
 // Starts the history mode.
@@ -36,13 +42,14 @@ import (
 // Import command history entries that happened after the current session
 // started.
 
-func initHistWalk(ed *Editor, ev *eval.Evaler, hs *histStore, nb eval.NsBuilder) {
+func initHistWalk(ed *Editor, ev *eval.Evaler, hs *histStore, session string, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar)
 	app := ed.app
 	nb.AddNs("history",
 		eval.NsBuilder{
-			"binding": bindingVar,
+			"binding":    bindingVar,
+			"session-id": vars.NewReadOnly(session),
 		}.AddGoFns("<edit:history>", map[string]interface{}{
 			"start": func() { notifyError(app, histwalkStart(app, hs, bindings)) },
 			"up":    func() { notifyError(app, histwalkDo(app, modes.Histwalk.Prev)) },
@@ -0,0 +1,150 @@
+package edit
+
+import (
+	"errors"
+	"sync"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/eval/vars"
+)
+
+//elvdoc:var kill-ring
+//
+// A list of texts removed by the `edit:kill-*` builtins, the most recent
+// first. Texts removed by consecutive kills are joined into one element. Only
+// the most recent 100 elements are kept.
+//
+// Elements that are not strings are ignored by `edit:yank` and `edit:yank-pop`.
+//
+// @cf edit:yank edit:yank-pop
+
+//elvdoc:fn yank
+//
+// Inserts the most recently killed text, the first element of
+// `$edit:kill-ring`, at the dot. Does nothing if the kill ring is empty.
+//
+// @cf edit:yank-pop
+
+//elvdoc:fn yank-pop
+//
+// Replaces the text just inserted by `edit:yank` or `edit:yank-pop` with the
+// next element of `$edit:kill-ring`, going back to the first element after the
+// last one. Throws an exception if the current command has been changed since
+// the last yank.
+//
+// @cf edit:yank
+
+const killRingSize = 100
+
+var errNoYank = errors.New("the last command was not a yank")
+
+type killRing struct {
+	entriesVar vars.PtrVar
+
+	mutex sync.Mutex
+	// The buffer after the last kill, used to detect consecutive kills.
+	lastKill tk.CodeBuffer
+	killing  bool
+	// The buffer after the last yank, the start of the yanked text and the
+	// index of it in the kill ring, used by yank-pop.
+	lastYank  tk.CodeBuffer
+	yanking   bool
+	yankFrom  int
+	yankIndex int
+}
+
+func initKillRing(app cli.App, nb eval.NsBuilder) *killRing {
+	kr := &killRing{entriesVar: newListVar(vals.EmptyList)}
+	nb.Add("kill-ring", kr.entriesVar)
+	nb.AddGoFns("<edit>", map[string]interface{}{
+		"yank":     func() { kr.yank(app) },
+		"yank-pop": func() error { return kr.yankPop(app) },
+	})
+	return kr
+}
+
+// Returns the string elements of the kill ring.
+func (kr *killRing) entries() []string {
+	var entries []string
+	vals.Iterate(kr.entriesVar.Get(), func(v interface{}) bool {
+		if s, ok := v.(string); ok {
+			entries = append(entries, s)
+		}
+		return true
+	})
+	return entries
+}
+
+// Adds the text killed when the buffer changes from before to after. If the
+// last kill resulted in before, the text is joined with the last element.
+func (kr *killRing) add(before, after tk.CodeBuffer, killed string) {
+	if killed == "" {
+		return
+	}
+	kr.mutex.Lock()
+	defer kr.mutex.Unlock()
+	entries := kr.entries()
+	if kr.killing && before == kr.lastKill && len(entries) > 0 {
+		if after.Dot < before.Dot {
+			entries[0] = killed + entries[0]
+		} else {
+			entries[0] += killed
+		}
+	} else {
+		entries = append([]string{killed}, entries...)
+		if len(entries) > killRingSize {
+			entries = entries[:killRingSize]
+		}
+	}
+	list := vals.EmptyList
+	for _, entry := range entries {
+		list = list.Cons(entry)
+	}
+	kr.entriesVar.Set(list)
+	kr.lastKill, kr.killing = after, true
+}
+
+func (kr *killRing) yank(app cli.App) {
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return
+	}
+	kr.mutex.Lock()
+	defer kr.mutex.Unlock()
+	entries := kr.entries()
+	if len(entries) == 0 {
+		return
+	}
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		kr.yankFrom, kr.yankIndex = s.Buffer.Dot, 0
+		s.Buffer.InsertAtDot(entries[0])
+		kr.lastYank, kr.yanking = s.Buffer, true
+	})
+}
+
+func (kr *killRing) yankPop(app cli.App) error {
+	codeArea, ok := focusedCodeArea(app)
+	if !ok {
+		return nil
+	}
+	kr.mutex.Lock()
+	defer kr.mutex.Unlock()
+	entries := kr.entries()
+	var err error
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		buf := &s.Buffer
+		if !kr.yanking || *buf != kr.lastYank || len(entries) == 0 {
+			err = errNoYank
+			return
+		}
+		kr.yankIndex = (kr.yankIndex + 1) % len(entries)
+		text := entries[kr.yankIndex]
+		buf.Content = buf.Content[:kr.yankFrom] + text + buf.Content[buf.Dot:]
+		buf.Dot = kr.yankFrom + len(text)
+		kr.lastYank = *buf
+	})
+	return err
+}
@@ -0,0 +1,60 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+func TestKillRing(t *testing.T) {
+	f := setup(t)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "echo foo bar", Dot: 12})
+	// Consecutive kills are joined.
+	evals(f.Evaler, "edit:kill-word-left", "edit:kill-word-left")
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "ls -l", Dot: 0})
+	evals(f.Evaler, "edit:kill-word-right", "edit:kill-word-right")
+	evals(f.Evaler, "edit:kill-rune-left")
+
+	evals(f.Evaler, "ring = $edit:kill-ring")
+	testGlobal(t, f.Evaler, "ring", vals.MakeList("ls -l", "foo bar"))
+}
+
+func TestKillRing_Size(t *testing.T) {
+	f := setup(t)
+
+	for i := 0; i < killRingSize+1; i++ {
+		f.SetCodeBuffer(tk.CodeBuffer{Content: "x", Dot: 1})
+		evals(f.Evaler, "edit:kill-rune-left")
+	}
+
+	evals(f.Evaler, "n = (count $edit:kill-ring)")
+	testGlobal(t, f.Evaler, "n", killRingSize)
+}
+
+func TestYank(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, "edit:kill-ring = [foo bar baz]")
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "ab", Dot: 1})
+	evals(f.Evaler, "edit:yank")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "afoob", Dot: 4})
+	evals(f.Evaler, "edit:yank-pop")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "abarb", Dot: 4})
+	evals(f.Evaler, "edit:yank-pop", "edit:yank-pop")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "afoob", Dot: 4})
+
+	// yank-pop only works right after a yank.
+	evals(f.Evaler, "edit:move-dot-left")
+	evals(f.Evaler, `e = ?(edit:yank-pop)`, `reason = $e[reason]`)
+	testGlobal(t, f.Evaler, "reason", errNoYank)
+}
+
+func TestYank_EmptyKillRing(t *testing.T) {
+	f := setup(t)
+
+	f.SetCodeBuffer(tk.CodeBuffer{Content: "ab", Dot: 1})
+	evals(f.Evaler, "edit:yank")
+	testCodeBuffer(t, f.Editor, tk.CodeBuffer{Content: "ab", Dot: 1})
+}
@@ -15,28 +15,60 @@ import (
 	"src.elv.sh/pkg/store/storedefs"
 )
 
-func initListings(ed *Editor, ev *eval.Evaler, st storedefs.Store, histStore histutil.Store, nb eval.NsBuilder) {
+//elvdoc:var listing:matcher
+//
+// A map from names of modes to how their filters match items. The names are
+// `completion`, `histlist`, `location` and `navigation`; if a name is missing,
+// the value for `''` is used. The values can be:
+//
+// -   `substr`: Words in the filter match items that contain them. This is the
+//     default.
+//
+// -   `fuzzy`: Words in the filter match items that contain their runes in the
+//     same order, not necessarily consecutively. Items are sorted by how well
+//     they match, in the same way as
+//     [`edit:match-fuzzy`](#editmatch-fuzzy), and the matched runes are
+//     highlighted. The navigation mode only uses the filter to hide items.
+//
+// Example:
+//
+// ```elvish
+// set edit:listing:matcher = [&histlist=fuzzy &location=fuzzy]
+// ```
+//
+// @cf edit:completion:matcher
+
+// Initializes the listing modes, and returns a function that builds the
+// filter spec of a mode according to $edit:listing:matcher.
+func initListings(ed *Editor, ev *eval.Evaler, st storedefs.Store, histStore histutil.Store, session string, nb eval.NsBuilder) func(mode string) modes.FilterSpec {
 	bindingVar := newBindingVar(emptyBindingsMap)
+	matcherVar := newMapVar(vals.EmptyMap)
+	filterSpecFor := makeFilterSpecFor(ed, matcherVar)
 	app := ed.app
 	nb.AddNs("listing",
 		eval.NsBuilder{
 			"binding": bindingVar,
+			"matcher": matcherVar,
 		}.AddGoFns("<edit:listing>:", map[string]interface{}{
-			"accept":     func() { listingAccept(app) },
-			"up":         func() { listingUp(app) },
-			"down":       func() { listingDown(app) },
-			"up-cycle":   func() { listingUpCycle(app) },
-			"down-cycle": func() { listingDownCycle(app) },
-			"page-up":    func() { listingPageUp(app) },
-			"page-down":  func() { listingPageDown(app) },
+			"accept":       func() { listingAccept(app) },
+			"up":           func() { listingUp(app) },
+			"down":         func() { listingDown(app) },
+			"up-cycle":     func() { listingUpCycle(app) },
+			"down-cycle":   func() { listingDownCycle(app) },
+			"page-up":      func() { listingPageUp(app) },
+			"page-down":    func() { listingPageDown(app) },
+			"toggle-mark":  func() { listingMark(app, tk.ToggleMark) },
+			"mark-all":     func() { listingMark(app, tk.MarkAll) },
+			"invert-marks": func() { listingMark(app, tk.InvertMarks) },
 			"start-custom": func(fm *eval.Frame, opts customListingOpts, items interface{}) {
 				listingStartCustom(ed, fm, opts, items)
 			},
 		}).Ns())
 
-	initHistlist(ed, ev, histStore, bindingVar, nb)
+	initHistlist(ed, ev, histStore, session, bindingVar, filterSpecFor, nb)
 	initLastcmd(ed, ev, histStore, bindingVar, nb)
-	initLocation(ed, ev, st, bindingVar, nb)
+	initLocation(ed, ev, st, bindingVar, filterSpecFor, nb)
+	return filterSpecFor
 }
 
 var filterSpec = modes.FilterSpec{
@@ -50,7 +82,41 @@ var filterSpec = modes.FilterSpec{
 	Highlighter: filter.Highlight,
 }
 
-func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+var fuzzyFilterSpec = modes.FilterSpec{
+	Scorer: func(f string) func(string) (int, []int, bool) {
+		q, _ := filter.CompileFuzzy(f)
+		if q == nil {
+			return func(string) (int, []int, bool) { return 0, nil, true }
+		}
+		return func(s string) (int, []int, bool) { return filter.Score(q, s) }
+	},
+	Highlighter: filter.Highlight,
+}
+
+// Returns a function that returns the filter spec for a mode. The function is
+// called when the mode starts, so changes to $edit:listing:matcher take effect
+// the next time the mode starts.
+func makeFilterSpecFor(nt notifier, matcherVar vars.PtrVar) func(mode string) modes.FilterSpec {
+	return func(mode string) modes.FilterSpec {
+		m := matcherVar.Get().(vals.Map)
+		matcher, ok := m.Index(mode)
+		if !ok {
+			matcher, ok = m.Index("")
+		}
+		switch {
+		case !ok || matcher == "substr":
+			return filterSpec
+		case matcher == "fuzzy":
+			return fuzzyFilterSpec
+		default:
+			nt.notifyf("unknown matcher %s for %s, falling back to substr",
+				vals.Repr(matcher, vals.NoPretty), mode)
+			return filterSpec
+		}
+	}
+}
+
+func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, session string, commonBindingVar vars.PtrVar, filterSpecFor func(string) modes.FilterSpec, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar, commonBindingVar)
 	dedup := newBoolVar(true)
@@ -59,6 +125,8 @@ func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonB
 			"binding": bindingVar,
 		}.AddGoFns("<edit:histlist>", map[string]interface{}{
 			"start": func() {
+				filterSpec := filterSpecFor("histlist")
+				filterSpec.Highlighter = filter.HighlightHistory
 				w, err := modes.NewHistlist(ed.app, modes.HistlistSpec{
 					Bindings: bindings,
 					AllCmds:  histStore.AllCmds,
@@ -66,6 +134,11 @@ func initHistlist(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonB
 						return dedup.Get().(bool)
 					},
 					Filter: filterSpec,
+					Query: func(f string) (storedefs.CmdQuery, string) {
+						q, rest, _ := filter.SplitHistoryQuery(f, session)
+						return q, rest
+					},
+					QueryCmds: histStore.QueryCmds,
 				})
 				startMode(ed.app, w, err)
 			},
@@ -91,7 +164,7 @@ func initLastcmd(ed *Editor, ev *eval.Evaler, histStore histutil.Store, commonBi
 		}).Ns())
 }
 
-func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBindingVar vars.PtrVar, nb eval.NsBuilder) {
+func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBindingVar vars.PtrVar, filterSpecFor func(string) modes.FilterSpec, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	pinnedVar := newListVar(vals.EmptyList)
 	hiddenVar := newListVar(vals.EmptyList)
@@ -113,7 +186,7 @@ func initLocation(ed *Editor, ev *eval.Evaler, st storedefs.Store, commonBinding
 				IteratePinned:     adaptToIterateString(pinnedVar),
 				IterateHidden:     adaptToIterateString(hiddenVar),
 				IterateWorkspaces: workspaceIterator,
-				Filter:            filterSpec,
+				Filter:            filterSpecFor("location"),
 			})
 			startMode(ed.app, w, err)
 		}).Ns())
@@ -191,6 +264,35 @@ func listingLeft(app cli.App) { listingSelect(app, tk.Left) }
 
 func listingRight(app cli.App) { listingSelect(app, tk.Right) }
 
+//elvdoc:fn listing:toggle-mark
+//
+// Toggles whether the selected item is marked in listing mode. When some items
+// are marked, accepting acts on all the marked items: the history listing
+// inserts all of them, and custom listings started with `&multi` pass all of
+// them to the `&accept` callback. Marks are kept when the filter changes, and
+// accepting only acts on the marked items that match the current filter.
+//
+// @cf edit:listing:mark-all edit:listing:invert-marks
+
+//elvdoc:fn listing:mark-all
+//
+// Marks all the items in listing mode.
+//
+// @cf edit:listing:toggle-mark edit:listing:invert-marks
+
+//elvdoc:fn listing:invert-marks
+//
+// Marks all the items that are not marked in listing mode, and unmarks all the
+// items that are marked.
+//
+// @cf edit:listing:toggle-mark edit:listing:mark-all
+
+func listingMark(app cli.App, f func(tk.ListBoxState) map[interface{}]bool) {
+	if w, ok := activeComboBox(app); ok {
+		w.ListBox().Mark(f)
+	}
+}
+
 func listingSelect(app cli.App, f func(tk.ListBoxState) int) {
 	if w, ok := activeComboBox(app); ok {
 		w.ListBox().Select(f)
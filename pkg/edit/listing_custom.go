@@ -21,6 +21,7 @@ type customListingOpts struct {
 	KeepBottom bool
 	Accept     eval.Callable
 	AutoAccept bool
+	Multi      bool
 }
 
 func (*customListingOpts) SetDefaultOptions() {}
@@ -28,6 +29,12 @@ func (*customListingOpts) SetDefaultOptions() {}
 //elvdoc:fn listing:start-custom
 //
 // Starts a custom listing addon.
+//
+// If the `&multi` option is true, the `&accept` callback is called with a list
+// of the `to-accept` values of all the marked items, or of only the selected
+// item if no item is marked.
+//
+// @cf edit:listing:toggle-mark
 
 func listingStartCustom(ed *Editor, fm *eval.Frame, opts customListingOpts, items interface{}) {
 	var bindings tk.Bindings
@@ -86,6 +93,19 @@ func listingStartCustom(ed *Editor, fm *eval.Frame, opts customListingOpts, item
 		}
 	}
 
+	var acceptMulti func([]string)
+	if opts.Multi {
+		acceptMulti = func(ss []string) {
+			if opts.Accept != nil {
+				list := vals.EmptyList
+				for _, s := range ss {
+					list = list.Cons(s)
+				}
+				callWithNotifyPorts(ed, fm.Evaler, opts.Accept, list)
+			}
+		}
+	}
+
 	w, err := modes.NewListing(ed.app, modes.ListingSpec{
 		Bindings: bindings,
 		Caption:  opts.Caption,
@@ -102,7 +122,8 @@ func listingStartCustom(ed *Editor, fm *eval.Frame, opts customListingOpts, item
 				callWithNotifyPorts(ed, fm.Evaler, opts.Accept, s)
 			}
 		},
-		AutoAccept: opts.AutoAccept,
+		AcceptMulti: acceptMulti,
+		AutoAccept:  opts.AutoAccept,
 	})
 	startMode(ed.app, w, err)
 }
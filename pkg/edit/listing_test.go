@@ -127,6 +127,62 @@ func TestHistlistAddon(t *testing.T) {
 	)
 }
 
+func TestHistlistAddon_HistoryPredicates(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddCmdWithMeta(storedefs.Cmd{Text: "make", Status: "exit 2"})
+		s.AddCmdWithMeta(storedefs.Cmd{Text: "ls", Status: "ok"})
+		s.AddCmdWithMeta(storedefs.Cmd{Text: "mv", Status: "fail"})
+	}))
+
+	f.TTYCtrl.Inject(term.K('R', ui.Ctrl))
+	feedInput(f.TTYCtrl, "status:failed m")
+	f.TestTTY(t,
+		"~> \n",
+		" HISTORY (dedup on)  status:failed m", Styles,
+		"******************** vvvvvvv        ", term.DotHere, "\n",
+		"   1 make  exit 2\n", Styles,
+		"         !!!!!!!!",
+		"   3 mv  fail                                     ",
+		ui.RuneStylesheet{'+': ui.Inverse, 'R': ui.Stylings(ui.Inverse, ui.FgRed)},
+		"+++++++RRRRRR+++++++++++++++++++++++++++++++++++++",
+	)
+}
+
+func TestHistlistAddon_FuzzyMatcher(t *testing.T) {
+	f := setup(t, storeOp(func(s storedefs.Store) {
+		s.AddCmd("git commit")
+		s.AddCmd("logic")
+		s.AddCmd("echo")
+	}))
+
+	evals(f.Evaler, `edit:listing:matcher[histlist] = fuzzy`)
+	f.TTYCtrl.Inject(term.K('R', ui.Ctrl), term.K('g'), term.K('c'))
+	// The best match is the last one, and matched runes are highlighted.
+	f.TestTTY(t,
+		"~> \n",
+		" HISTORY (dedup on)  gc", Styles,
+		"********************   ", term.DotHere, "\n",
+		"   2 logic\n", Styles,
+		"       _ _ \n",
+		"   1 git commit                                   ", fuzzyStyles,
+		"+++++U+++U++++++++++++++++++++++++++++++++++++++++",
+	)
+}
+
+var fuzzyStyles = ui.RuneStylesheet{
+	'+': ui.Inverse,
+	'U': ui.Stylings(ui.Inverse, ui.Underlined),
+}
+
+func TestListingMatcher_Unknown(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `edit:listing:matcher[''] = foo`)
+	f.TTYCtrl.Inject(term.K('R', ui.Ctrl))
+	f.TestTTYNotes(t,
+		"unknown matcher foo for histlist, falling back to substr")
+}
+
 func TestLastCmdAddon(t *testing.T) {
 	f := setup(t, storeOp(func(s storedefs.Store) {
 		s.AddCmd("echo hello world")
@@ -214,3 +270,71 @@ func TestCustomListing_PassingBytesCallback(t *testing.T) {
 		"~> # x", Styles,
 		"   ccc", term.DotHere)
 }
+
+var markedStyles = ui.RuneStylesheet{
+	'!': ui.Bold, '+': ui.Stylings(ui.Inverse, ui.Bold)}
+
+func TestCustomListing_Multi(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler,
+		`items = [[&to-filter=1 &to-accept=foo &to-show=foo]
+		          [&to-filter=2 &to-accept=bar &to-show=bar]
+		          [&to-filter=3 &to-accept=baz &to-show=baz]]`,
+		`edit:listing:start-custom $items &caption=A &multi=$true `+
+			`&accept={|l| edit:insert-at-dot 'put '(echo $@l) }`)
+	f.TestTTY(t,
+		"~> \n",
+		"A ", Styles,
+		"* ", term.DotHere, "\n",
+		"foo                                               \n", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		"bar                                               \n",
+		"baz                                               ",
+	)
+	// Mark "foo" and "baz".
+	evals(f.Evaler, `edit:listing:toggle-mark`, `edit:listing:down`,
+		`edit:listing:down`, `edit:listing:toggle-mark`, `edit:redraw`)
+	f.TestTTY(t,
+		"~> \n",
+		"A ", Styles,
+		"* ", term.DotHere, "\n",
+		"foo                                               \n", markedStyles,
+		"!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!",
+		"bar                                               \n",
+		"baz                                               ", markedStyles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+	)
+	f.TTYCtrl.Inject(term.K('\n'))
+	f.TestTTY(t,
+		"~> put foo baz", Styles,
+		"   vvv", term.DotHere)
+}
+
+func TestCustomListing_Multi_NoMarks(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler,
+		`items = [[&to-filter=1 &to-accept=foo &to-show=foo]]`,
+		`edit:listing:start-custom $items &caption=A &multi=$true `+
+			`&accept={|l| edit:insert-at-dot 'put '(count $l)' '$l[0] }`)
+	f.TTYCtrl.Inject(term.K('\n'))
+	f.TestTTY(t,
+		"~> put 1 foo", Styles,
+		"   vvv", term.DotHere)
+}
+
+func TestListing_InvertMarks(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler,
+		`items = [[&to-filter=1 &to-accept=foo &to-show=foo]
+		          [&to-filter=2 &to-accept=bar &to-show=bar]]`,
+		`edit:listing:start-custom $items &caption=A &multi=$true `+
+			`&accept={|l| edit:insert-at-dot 'put '(echo $@l) }`)
+	evals(f.Evaler, `edit:listing:toggle-mark`, `edit:listing:invert-marks`)
+	f.TTYCtrl.Inject(term.K('\n'))
+	f.TestTTY(t,
+		"~> put bar", Styles,
+		"   vvv", term.DotHere)
+}
@@ -27,7 +27,8 @@ import (
 
 //elvdoc:fn navigation:insert-selected
 //
-// Inserts the selected filename.
+// Inserts the selected filename. If some files are marked, inserts the names
+// of all the marked files instead, separated by spaces.
 
 func navInsertSelected(app cli.App) {
 	w, ok := activeNavigation(app)
@@ -38,11 +39,15 @@ func navInsertSelected(app cli.App) {
 	if !ok {
 		return
 	}
-	fname := w.SelectedName()
-	if fname == "" {
-		// User pressed Alt-Enter or Enter in an empty directory with nothing
-		// selected; don't do anything.
-		return
+	fnames := w.MarkedNames()
+	if len(fnames) == 0 {
+		fname := w.SelectedName()
+		if fname == "" {
+			// User pressed Alt-Enter or Enter in an empty directory with
+			// nothing selected; don't do anything.
+			return
+		}
+		fnames = []string{fname}
 	}
 
 	codeArea.MutateState(func(s *tk.CodeAreaState) {
@@ -52,8 +57,12 @@ func navInsertSelected(app cli.App) {
 			// character is not a space or newline. Insert a space.
 			s.Buffer.InsertAtDot(" ")
 		}
-		// Insert the selected filename.
-		s.Buffer.InsertAtDot(parse.Quote(fname))
+		// Insert the selected filenames.
+		quoted := make([]string, len(fnames))
+		for i, fname := range fnames {
+			quoted[i] = parse.Quote(fname)
+		}
+		s.Buffer.InsertAtDot(strings.Join(quoted, " "))
 	})
 }
 
@@ -66,6 +75,22 @@ func navInsertSelectedAndQuit(app cli.App) {
 	closeMode(app)
 }
 
+//elvdoc:fn navigation:toggle-mark
+//
+// Toggles whether the selected file is marked. Marks are kept only while the
+// current directory stays the same.
+//
+// @cf edit:navigation:insert-selected
+
+//elvdoc:fn navigation:mark-all
+//
+// Marks all the files in the current directory.
+
+//elvdoc:fn navigation:invert-marks
+//
+// Marks all the files in the current directory that are not marked, and
+// unmarks all the files that are marked.
+
 //elvdoc:fn navigation:trigger-filter
 //
 // Toggles the filtering status of the navigation addon.
@@ -103,7 +128,7 @@ func convertNavWidthRatio(v interface{}) [3]int {
 	return ret
 }
 
-func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
+func initNavigation(ed *Editor, ev *eval.Evaler, filterSpecFor func(string) modes.FilterSpec, nb eval.NsBuilder) {
 	bindingVar := newBindingVar(emptyBindingsMap)
 	bindings := newMapBindings(ed, ev, bindingVar)
 	widthRatioVar := newListVar(vals.MakeList(1.0, 3.0, 4.0))
@@ -128,7 +153,7 @@ func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 					WidthRatio: func() [3]int {
 						return convertNavWidthRatio(widthRatioVar.Get())
 					},
-					Filter: filterSpec,
+					Filter: filterSpecFor("navigation"),
 				})
 				if err != nil {
 					app.Notify(err.Error())
@@ -152,6 +177,13 @@ func initNavigation(ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
 			"file-preview-down": actOnNavigation(app,
 				func(w modes.Navigation) { w.ScrollPreview(1) }),
 
+			"toggle-mark": actOnNavigation(app,
+				func(w modes.Navigation) { w.Mark(tk.ToggleMark) }),
+			"mark-all": actOnNavigation(app,
+				func(w modes.Navigation) { w.Mark(tk.MarkAll) }),
+			"invert-marks": actOnNavigation(app,
+				func(w modes.Navigation) { w.Mark(tk.InvertMarks) }),
+
 			"insert-selected":          func() { navInsertSelected(app) },
 			"insert-selected-and-quit": func() { navInsertSelectedAndQuit(app) },
 
@@ -145,6 +145,35 @@ func TestNavigation_EnterDoesNothingInEmptyDir(t *testing.T) {
 	)
 }
 
+func TestNavigation_EnterInsertsMarkedNames(t *testing.T) {
+	f := setupNav(t)
+
+	feedInput(f.TTYCtrl, "put")
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl)) // begin navigation mode
+	f.TTYCtrl.Inject(term.K('a', ui.Alt))  // mark all files
+	f.TTYCtrl.Inject(term.K(ui.Enter))     // insert all marked file names
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ",
+		"put a e", Styles,
+		"vvv", term.DotHere,
+	)
+}
+
+func TestNavigation_ToggleMark(t *testing.T) {
+	f := setupNav(t)
+
+	feedInput(f.TTYCtrl, "put")
+	f.TTYCtrl.Inject(term.K('N', ui.Ctrl)) // begin navigation mode
+	f.TTYCtrl.Inject(term.K('m', ui.Alt))  // mark "a"
+	f.TTYCtrl.Inject(term.K('i', ui.Alt))  // mark "e" and unmark "a"
+	f.TTYCtrl.Inject(term.K(ui.Enter))     // insert the "e" file name
+	f.TestTTY(t,
+		filepath.Join("~", "d"), "> ",
+		"put e", Styles,
+		"vvv", term.DotHere,
+	)
+}
+
 var testDir = testutil.Dir{
 	"d": testutil.Dir{
 		"a": "",
@@ -0,0 +1,132 @@
+package edit
+
+import (
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/modes"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+)
+
+//elvdoc:var paste-filters
+//
+// A list of functions to run on text pasted with bracketed paste. Each function
+// is called with the pasted text, and should output the text to use instead.
+// If a function outputs nothing, the paste is discarded; if it outputs more
+// than one value or a value that is not a string, an error is shown and the
+// paste is also discarded.
+//
+// The default value of this list contains
+// [`edit:strip-escapes`](#editstrip-escapes). Example of adding a filter that
+// removes trailing newlines:
+//
+// ```elvish
+// use str
+// set edit:paste-filters = [$@edit:paste-filters {|s| str:trim-right $s "\n" }]
+// ```
+//
+// @cf edit:paste:preview
+
+//elvdoc:var paste:preview
+//
+// Whether to preview pasted text that has multiple lines or control characters
+// after running [`$edit:paste-filters`](#editpaste-filters), instead of
+// inserting it immediately. Defaults to `$true`.
+//
+// The preview shows the pasted text, with control characters shown in caret
+// notation like `^[`, and lets you choose to insert the text, insert it as a
+// quoted string, or discard it. Use <span class="key">Up</span> and
+// <span class="key">Down</span> to choose and <span class="key">Enter</span> to
+// confirm; closing the preview discards the text.
+//
+// Pasted text that is not previewed is inserted directly, quoted if
+// [`$edit:insert:quote-paste`](#editinsertquote-paste) is `$true`.
+
+//elvdoc:var paste:binding
+//
+// Keybinding for the paste preview.
+
+//elvdoc:fn strip-escapes
+//
+// ```elvish
+// edit:strip-escapes $text
+// ```
+//
+// Outputs `$text` with terminal escape sequences and control characters other
+// than newlines and tabs removed, and carriage returns converted to newlines.
+//
+// ```elvish-transcript
+// ~> edit:strip-escapes "\e[31mred\e[m\r\n"
+// ▶ "red\n"
+// ```
+
+func initPaste(appSpec *cli.AppSpec, ed *Editor, ev *eval.Evaler, nb eval.NsBuilder) {
+	stripEscapes := eval.NewGoFn("edit:strip-escapes", tk.SanitizePaste)
+	filtersVar := newListVar(vals.MakeList(stripEscapes))
+	previewVar := newBoolVar(true)
+	bindingVar := newBindingVar(emptyBindingsMap)
+	bindings := newMapBindings(ed, ev, bindingVar)
+
+	quotePaste := appSpec.QuotePaste
+	appSpec.OnPaste = func(text string) bool {
+		text, ok := filterPaste(ed, ev, filtersVar.Get().(vals.List), text)
+		if !ok {
+			return true
+		}
+		if previewVar.Get().(bool) && modes.NeedsPastePreview(text) {
+			w, err := modes.NewPaste(ed.app,
+				modes.PasteSpec{Bindings: bindings, Text: text})
+			startMode(ed.app, w, err)
+			return true
+		}
+		if quotePaste() {
+			text = parse.Quote(text)
+		}
+		codeArea, err := modes.FocusedCodeArea(ed.app)
+		if err != nil {
+			ed.app.Notify(err.Error())
+			return true
+		}
+		codeArea.MutateState(func(s *tk.CodeAreaState) { s.Buffer.InsertAtDot(text) })
+		return true
+	}
+
+	nb.Add("paste-filters", filtersVar)
+	nb.AddFn("strip-escapes", stripEscapes)
+	nb.AddNs("paste", eval.NsBuilder{
+		"binding": bindingVar,
+		"preview": previewVar,
+	}.Ns())
+}
+
+// Runs the paste filters on the text, and returns the result and whether the
+// paste should go ahead.
+func filterPaste(nt notifier, ev *eval.Evaler, filters vals.List, text string) (string, bool) {
+	for it := filters.Iterator(); it.HasElem(); it.Next() {
+		fn, ok := it.Elem().(eval.Callable)
+		if !ok {
+			nt.notifyf("paste filter is not a function: %s",
+				vals.Repr(it.Elem(), vals.NoPretty))
+			return "", false
+		}
+		outputs := callForValues(nt, ev, "paste filter", fn, text)
+		switch len(outputs) {
+		case 0:
+			return "", false
+		case 1:
+			s, ok := outputs[0].(string)
+			if !ok {
+				nt.notifyf("paste filter should output a string, got %s",
+					vals.Kind(outputs[0]))
+				return "", false
+			}
+			text = s
+		default:
+			nt.notifyf("paste filter should output one value, got %d",
+				len(outputs))
+			return "", false
+		}
+	}
+	return text, true
+}
@@ -0,0 +1,97 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/ui"
+)
+
+func TestPaste_SingleLineInsertedDirectly(t *testing.T) {
+	f := setup(t)
+
+	feedPaste(f, "x", term.K('G', ui.Ctrl), "y")
+	f.TestTTY(t,
+		"~> xy", Styles,
+		"   !!", term.DotHere)
+}
+
+func TestPaste_MultiLinePreviewed(t *testing.T) {
+	f := setup(t)
+
+	feedPaste(f, "a", term.K(ui.Enter), "b")
+	f.TestTTY(t,
+		"~> \n",
+		" PASTE  2 lines, 3 bytes", Styles,
+		"*******", term.DotHere, "\n",
+		"a\n",
+		"b\n",
+		" insert                                           \n", Styles,
+		"++++++++++++++++++++++++++++++++++++++++++++++++++",
+		" insert as quoted string\n",
+		" discard",
+	)
+
+	f.TTYCtrl.Inject(term.K(ui.Down), term.K(ui.Enter))
+	f.TestTTY(t,
+		`~> "a\nb"`, ui.RuneStylesheet{'\'': ui.FgYellow},
+		`   ''''''`, term.DotHere)
+}
+
+func TestPaste_PreviewDisabled(t *testing.T) {
+	f := setup(t, rc(`set edit:paste:preview = $false`))
+
+	feedPaste(f, "a", term.K(ui.Enter), "b")
+	f.TestTTY(t,
+		"~> a\n", Styles,
+		"   !",
+		"   b", Styles,
+		"   !", term.DotHere)
+}
+
+func TestPaste_Filters(t *testing.T) {
+	f := setup(t, rc(`set edit:paste-filters = [{|s| put x$s } {|s| put $s'' }]`))
+
+	feedPaste(f, "a")
+	f.TestTTY(t,
+		"~> xa", Styles,
+		"   !!", term.DotHere)
+}
+
+func TestPaste_FilterDiscards(t *testing.T) {
+	f := setup(t, rc(`set edit:paste-filters = [{|s| }]`))
+
+	feedPaste(f, "a")
+	f.TestTTY(t, "~> ", term.DotHere)
+}
+
+func TestPaste_FilterOutputsNonString(t *testing.T) {
+	f := setup(t, rc(`set edit:paste-filters = [{|s| num 1 }]`))
+
+	feedPaste(f, "a")
+	f.TestTTYNotes(t, "paste filter should output a string, got number")
+}
+
+func TestStripEscapes(t *testing.T) {
+	f := setup(t)
+
+	evals(f.Evaler, `var s = (edit:strip-escapes "\e[31mred\e[m\r\n")`)
+	testGlobal(t, f.Evaler, "s", "red\n")
+}
+
+// Feeds a bracketed paste. Each argument is either a string of runes or a
+// term.Event.
+func feedPaste(f *fixture, args ...interface{}) {
+	f.TTYCtrl.Inject(term.PasteSetting(true))
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			for _, r := range arg {
+				f.TTYCtrl.Inject(term.K(r))
+			}
+		case term.Event:
+			f.TTYCtrl.Inject(arg)
+		}
+	}
+	f.TTYCtrl.Inject(term.PasteSetting(false))
+}
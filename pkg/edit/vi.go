@@ -0,0 +1,327 @@
+package edit
+
+// Implementation of vi-style modal editing.
+
+import (
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/cli"
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/ui"
+)
+
+//elvdoc:var vi:mode
+//
+// The current editing mode of the main code area, one of `insert`, `normal`
+// and `visual`. This variable is read-only. Prompts are updated whenever the
+// mode changes, so it can be used to show a mode indicator:
+//
+// ```elvish
+// set edit:rprompt = { if (eq $edit:vi:mode normal) { styled NORMAL inverse } }
+// ```
+//
+// @cf edit:vi:normal:start
+
+//elvdoc:var vi:normal:binding
+//
+// Key bindings for vi normal mode. They are consulted before the builtin vi
+// commands, unless a command is partially typed.
+//
+// @cf edit:vi:visual:binding
+
+//elvdoc:var vi:visual:binding
+//
+// Key bindings for vi visual mode. They are consulted before the builtin vi
+// commands, unless a command is partially typed.
+//
+// @cf edit:vi:normal:binding
+
+//elvdoc:fn vi:normal:start
+//
+// Enters vi normal mode. When called in insert mode, the dot moves left one
+// rune, unless it is already at the start of a line.
+//
+// In normal mode, keys are interpreted as vi commands. Supported commands are:
+//
+// -   Motions: `h`, `l`, `j`, `k`, `w`, `b`, `e`, `W`, `B`, `E`, `0`, `^`,
+//     `$`, `f`, `F`, `t`, `T`, `;`, `,`, `gg` and `G`. Words are small words
+//     as used by `edit:move-dot-left-small-word`, and WORDs are words as used
+//     by `edit:move-dot-left-word`.
+//
+// -   Operators `d` (delete), `c` (change) and `y` (yank), followed by a motion,
+//     a text object, or the same operator to operate on whole lines.
+//
+// -   Text objects after an operator or in visual mode: `iw`, `aw`, `iW`,
+//     `aW`, quoted strings (`i"`, `a"`, `i'`, `a'`, ``i` ``, ``a` ``) and
+//     brackets (`i(`, `a(`, `ib`, `i[`, `a[`, `i{`, `a{`, `iB`, `i<`, `a<`).
+//
+// -   Other commands: `x`, `X`, `D`, `C`, `s`, `S`, `Y`, `p`, `P`, `r`, `~`,
+//     `J`, `i`, `a`, `I`, `A`, `o`, `O`, `v`, `u` (undo), <span
+//     class="key">Ctrl-R</span> (redo), and `.` to repeat the last change.
+//
+// Motions and commands can be preceded by a count, and operators can be
+// preceded and followed by one. Deleted and yanked text is stored in the
+// unnamed register, and also in a named register if the command is preceded
+// by `"` and a letter.
+//
+// In visual mode, motions move the dot while the anchor stays, and `d`, `x`,
+// `c`, `s`, `y`, `~`, `p`, `P` and `J` operate on the selected text. The `o`
+// command swaps the dot and the anchor.
+//
+// The `vi-binding` module binds the <span class="key">Esc</span> key in insert
+// mode to this function.
+//
+// @cf edit:vi:insert:start edit:vi:visual:start
+
+//elvdoc:fn vi:insert:start
+//
+// Enters vi insert mode. This is the default mode of the code area.
+//
+// @cf edit:vi:normal:start
+
+//elvdoc:fn vi:visual:start
+//
+// Enters vi visual mode, with the selection anchored at the dot.
+//
+// @cf edit:vi:normal:start
+
+// Sets up the key bindings for vi modes, which must happen before the App is
+// created. Functions that need to access the App are added by initViAPI.
+func initViBindings(appSpec *cli.AppSpec, ed *Editor, ev *eval.Evaler) *vi {
+	v := &vi{
+		prompts:   []cli.Prompt{appSpec.Prompt, appSpec.RPrompt},
+		registers: make(map[rune]viRegister),
+
+		normalBindingVar: newBindingVar(emptyBindingsMap),
+		visualBindingVar: newBindingVar(emptyBindingsMap),
+	}
+	appSpec.CodeAreaBindings = viBindings{
+		v,
+		appSpec.CodeAreaBindings,
+		newMapBindings(ed, ev, v.normalBindingVar),
+		newMapBindings(ed, ev, v.visualBindingVar),
+	}
+	return v
+}
+
+func initViAPI(app cli.App, v *vi, nb eval.NsBuilder) {
+	// Like the state API, the vi API always operates on the root CodeArea
+	// widget.
+	codeArea := app.ActiveWidget().(tk.CodeArea)
+	setMode := func(f func(s *tk.CodeAreaState)) {
+		v.mutate(codeArea, func(s *tk.CodeAreaState) {
+			s.PendingKeys = ""
+			f(s)
+		})
+	}
+
+	nb.AddNs("vi", eval.NsBuilder{
+		"mode": vars.FromGet(func() interface{} {
+			return codeArea.CopyState().Mode.String()
+		}),
+	}.AddNs("normal", eval.NsBuilder{
+		"binding": v.normalBindingVar,
+	}.AddGoFn("<edit:vi:normal>", "start", func() {
+		setMode(v.enterNormal)
+	}).Ns()).AddNs("visual", eval.NsBuilder{
+		"binding": v.visualBindingVar,
+	}.AddGoFn("<edit:vi:visual>", "start", func() {
+		setMode(func(s *tk.CodeAreaState) {
+			v.stopRecording(s)
+			s.Mode = tk.VisualMode
+			s.Anchor = s.Buffer.Dot
+		})
+	}).Ns()).AddNs("insert", eval.NsBuilder{}.AddGoFn("<edit:vi:insert>", "start", func() {
+		setMode(func(s *tk.CodeAreaState) { s.Mode = tk.InsertMode })
+	}).Ns()).Ns())
+}
+
+// Bindings of the main code area, which depend on the mode of the code area.
+type viBindings struct {
+	vi     *vi
+	insert tk.Bindings
+	normal tk.Bindings
+	visual tk.Bindings
+}
+
+func (b viBindings) Handle(w tk.Widget, e term.Event) bool {
+	codeArea, ok := w.(tk.CodeArea)
+	if !ok {
+		return b.insert.Handle(w, e)
+	}
+	s := codeArea.CopyState()
+	switch s.Mode {
+	case tk.NormalMode:
+		if s.PendingKeys == "" && b.normal.Handle(w, e) {
+			return true
+		}
+	case tk.VisualMode:
+		if s.PendingKeys == "" && b.visual.Handle(w, e) {
+			return true
+		}
+	default:
+		return b.insert.Handle(w, e)
+	}
+	k, ok := e.(term.KeyEvent)
+	if !ok {
+		return false
+	}
+	return b.vi.handleKey(codeArea, ui.Key(k))
+}
+
+// State of vi-style editing that is kept across commands, and across
+// different sessions of reading code.
+type vi struct {
+	prompts   []cli.Prompt
+	registers map[rune]viRegister
+	// The last f, F, t or T motion, repeated by ; and ,.
+	lastFind string
+	// The last change, repeated by the . command.
+	lastChange *viChange
+	// The change that is being recorded in insert mode.
+	recording *viChange
+	// Content of the buffer when insert mode was entered while recording.
+	recordingStart string
+	// Number of undos requested by the u command. They are done after the
+	// state of the code area is unlocked.
+	pendingUndos int
+
+	normalBindingVar vars.PtrVar
+	visualBindingVar vars.PtrVar
+}
+
+// The content of a register.
+type viRegister struct {
+	text     string
+	linewise bool
+}
+
+// A recorded change. If the command of the change enters insert mode, inserted
+// is the text inserted before leaving insert mode.
+type viChange struct {
+	cmd      viCommand
+	inserted string
+}
+
+// Mutates the state of the code area, and updates the prompts if the mode
+// has changed.
+func (v *vi) mutate(codeArea tk.CodeArea, f func(s *tk.CodeAreaState)) {
+	var oldMode, newMode tk.CodeAreaMode
+	codeArea.MutateState(func(s *tk.CodeAreaState) {
+		oldMode = s.Mode
+		f(s)
+		newMode = s.Mode
+	})
+	if oldMode != newMode {
+		for _, p := range v.prompts {
+			if p != nil {
+				p.Trigger(true)
+			}
+		}
+	}
+}
+
+var (
+	keyEsc  = ui.K('[', ui.Ctrl)
+	keyRedo = ui.K('R', ui.Ctrl)
+)
+
+// Handles a key in normal or visual mode.
+func (v *vi) handleKey(codeArea tk.CodeArea, key ui.Key) bool {
+	if key == ui.K('\n') || key.Mod != 0 || key.Rune < 0 {
+		handled, redo := false, false
+		v.mutate(codeArea, func(s *tk.CodeAreaState) {
+			switch {
+			case s.PendingKeys != "":
+				s.PendingKeys = ""
+				handled = key == keyEsc
+			case key == keyEsc:
+				if s.Mode == tk.VisualMode {
+					s.Mode = tk.NormalMode
+				}
+				handled = true
+			case key == keyRedo && s.Mode == tk.NormalMode:
+				redo, handled = true, true
+			}
+		})
+		if redo {
+			codeArea.Redo()
+			v.mutate(codeArea, viClampDot)
+		}
+		return handled
+	}
+	v.mutate(codeArea, func(s *tk.CodeAreaState) {
+		keys := s.PendingKeys + string(key.Rune)
+		cmd, status := parseViCommand(keys, s.Mode == tk.VisualMode)
+		s.PendingKeys = ""
+		switch status {
+		case viIncomplete:
+			s.PendingKeys = keys
+		case viComplete:
+			v.execute(s, cmd)
+		}
+	})
+	if v.pendingUndos > 0 {
+		for i := 0; i < v.pendingUndos && codeArea.Undo(); i++ {
+		}
+		v.pendingUndos = 0
+		v.mutate(codeArea, viClampDot)
+	}
+	return true
+}
+
+// Enters normal mode.
+func (v *vi) enterNormal(s *tk.CodeAreaState) {
+	if s.Mode == tk.InsertMode {
+		v.stopRecording(s)
+		buf := &s.Buffer
+		if buf.Dot > viSOL(buf.Content, buf.Dot) {
+			buf.Dot = moveDotLeft(buf.Content, buf.Dot)
+		}
+	}
+	s.Mode = tk.NormalMode
+	viClampDot(s)
+}
+
+// Enters insert mode, recording the inserted text for the change if it is not
+// nil.
+func (v *vi) enterInsert(s *tk.CodeAreaState, change *viChange) {
+	s.Mode = tk.InsertMode
+	v.recording = change
+	v.recordingStart = s.Buffer.Content
+}
+
+// Stops recording the inserted text, saving the change being recorded as the
+// last change.
+func (v *vi) stopRecording(s *tk.CodeAreaState) {
+	if v.recording == nil {
+		return
+	}
+	v.recording.inserted = insertedText(v.recordingStart, s.Buffer.Content)
+	v.lastChange = v.recording
+	v.recording = nil
+}
+
+// Finds the text inserted to old to get new, assuming that it is inserted in
+// one place.
+func insertedText(old, new string) string {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	for prefix > 0 && prefix < len(new) && !utf8.RuneStart(new[prefix]) {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !utf8.RuneStart(new[len(new)-suffix]) {
+		suffix--
+	}
+	return new[prefix : len(new)-suffix]
+}
@@ -0,0 +1,870 @@
+package edit
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"src.elv.sh/pkg/cli/tk"
+	"src.elv.sh/pkg/strutil"
+)
+
+// A parsed vi command, such as `"a2d3w`.
+type viCommand struct {
+	// The register named with ", or 0 for the unnamed register.
+	register rune
+	// The product of all the counts, or 0 if no count is given.
+	count int
+	// The operator, or 0 if there is none.
+	op rune
+	// The motion, text object or command, such as "w", "fx", "iw" or "p". When
+	// the operator is repeated to operate on whole lines, this is the operator.
+	keys string
+}
+
+type viParseStatus int
+
+const (
+	viInvalid viParseStatus = iota
+	viIncomplete
+	viComplete
+)
+
+const (
+	viMotionKeys     = "hjkl0^$wbeWBEG;,"
+	viCommandKeys    = "xXDCsSYpP~JiaIAoOvu."
+	viVisualKeys     = "dxcsyYpP~Jov"
+	viChangeKeys     = "xXDCsSpP~JiaIAoOr"
+	viInsertingKeys  = "CsSiaIAoO"
+	viTextObjectKeys = "wW\"'`()b[]{}B<>"
+)
+
+// Parses the keys typed in normal or visual mode as a vi command.
+func parseViCommand(keys string, visual bool) (viCommand, viParseStatus) {
+	rs := []rune(keys)
+	var cmd viCommand
+	if len(rs) > 0 && rs[0] == '"' {
+		if len(rs) == 1 {
+			return cmd, viIncomplete
+		}
+		if !isViRegisterName(rs[1]) {
+			return cmd, viInvalid
+		}
+		cmd.register = rs[1]
+		rs = rs[2:]
+	}
+	count, rs := parseViCount(rs)
+	if len(rs) > 0 && !visual && strings.ContainsRune("dcy", rs[0]) {
+		cmd.op = rs[0]
+		var opCount int
+		opCount, rs = parseViCount(rs[1:])
+		if count == 0 {
+			count = opCount
+		} else if opCount != 0 {
+			count *= opCount
+		}
+	}
+	cmd.count = count
+	if len(rs) == 0 {
+		return cmd, viIncomplete
+	}
+	// Reports whether the command is complete, given that it takes n runes.
+	need := func(n int) viParseStatus {
+		if len(rs) < n {
+			return viIncomplete
+		}
+		cmd.keys = string(rs)
+		return viComplete
+	}
+	r := rs[0]
+	switch {
+	case cmd.op != 0 && r == cmd.op:
+		return cmd, need(1)
+	case strings.ContainsRune(viMotionKeys, r):
+		return cmd, need(1)
+	case r == 'g':
+		if len(rs) > 1 && rs[1] != 'g' {
+			return cmd, viInvalid
+		}
+		return cmd, need(2)
+	case strings.ContainsRune("fFtT", r):
+		return cmd, need(2)
+	case (cmd.op != 0 || visual) && (r == 'i' || r == 'a'):
+		if len(rs) > 1 && !strings.ContainsRune(viTextObjectKeys, rs[1]) {
+			return cmd, viInvalid
+		}
+		return cmd, need(2)
+	case cmd.op == 0 && r == 'r':
+		return cmd, need(2)
+	case cmd.op == 0 && !visual && strings.ContainsRune(viCommandKeys, r):
+		return cmd, need(1)
+	case visual && strings.ContainsRune(viVisualKeys, r):
+		return cmd, need(1)
+	}
+	return cmd, viInvalid
+}
+
+func isViRegisterName(r rune) bool {
+	return r == '"' || 'a' <= r && r <= 'z'
+}
+
+func parseViCount(rs []rune) (int, []rune) {
+	count := 0
+	for len(rs) > 0 && '0' <= rs[0] && rs[0] <= '9' {
+		if rs[0] == '0' && count == 0 {
+			// A leading 0 is the motion to the start of the line.
+			break
+		}
+		count = count*10 + int(rs[0]-'0')
+		rs = rs[1:]
+	}
+	return count, rs
+}
+
+// Commands that are shorthands for operators.
+var viShorthands = map[string]viCommand{
+	"x": {op: 'd', keys: "l"},
+	"X": {op: 'd', keys: "h"},
+	"D": {op: 'd', keys: "$"},
+	"C": {op: 'c', keys: "$"},
+	"s": {op: 'c', keys: "l"},
+	"S": {op: 'c', keys: "c"},
+	"Y": {op: 'y', keys: "y"},
+}
+
+// Executes a command in normal or visual mode.
+func (v *vi) execute(s *tk.CodeAreaState, cmd viCommand) {
+	if s.Mode == tk.VisualMode {
+		v.executeVisual(s, cmd)
+		viClampDot(s)
+		return
+	}
+	if cmd.keys == "." {
+		if v.lastChange == nil {
+			return
+		}
+		change := *v.lastChange
+		if cmd.count != 0 {
+			change.cmd.count = cmd.count
+		}
+		v.executeNormal(s, change.cmd, &change)
+		return
+	}
+	v.executeNormal(s, cmd, nil)
+}
+
+// Executes a command in normal mode. When repeating a change, replay contains
+// the change being repeated.
+func (v *vi) executeNormal(s *tk.CodeAreaState, cmd viCommand, replay *viChange) {
+	isChange := cmd.op == 'd' || cmd.op == 'c' ||
+		cmd.op == 0 && strings.ContainsRune(viChangeKeys, firstRune(cmd.keys))
+	var change *viChange
+	if isChange {
+		change = &viChange{cmd: cmd}
+	}
+
+	ok := true
+	if short, isShort := viShorthands[cmd.keys]; isShort && cmd.op == 0 {
+		short.register, short.count = cmd.register, cmd.count
+		cmd = short
+	}
+	buf := &s.Buffer
+	n := max1(cmd.count)
+	switch {
+	case cmd.op != 0:
+		ok = v.operate(s, cmd)
+	case strings.ContainsRune(viMotionKeys, firstRune(cmd.keys)) || firstRune(cmd.keys) == 'g' ||
+		strings.ContainsRune("fFtT", firstRune(cmd.keys)):
+		if m, ok := v.motion(buf.Content, buf.Dot, cmd.keys, cmd.count, 0); ok {
+			buf.Dot = m.dot
+		}
+	case cmd.keys == "p" || cmd.keys == "P":
+		v.put(s, v.registers[cmd.register], cmd.keys == "p", n)
+	case firstRune(cmd.keys) == 'r':
+		ok = viReplace(buf, []rune(cmd.keys)[1], n)
+	case cmd.keys == "~":
+		eol := viEOL(buf.Content, buf.Dot)
+		to := buf.Dot
+		for i := 0; i < n && to < eol; i++ {
+			to = moveDotRight(buf.Content, to)
+		}
+		buf.Content = buf.Content[:buf.Dot] + toggleCase(buf.Content[buf.Dot:to]) + buf.Content[to:]
+		buf.Dot = to
+	case cmd.keys == "J":
+		ok = viJoin(buf, max1(n-1))
+	case cmd.keys == "u":
+		v.pendingUndos = n
+	case cmd.keys == "v":
+		s.Mode = tk.VisualMode
+		s.Anchor = buf.Dot
+	case strings.ContainsRune(viInsertingKeys, firstRune(cmd.keys)):
+		content, dot := buf.Content, buf.Dot
+		switch cmd.keys {
+		case "a":
+			if dot < viEOL(content, dot) {
+				buf.Dot = moveDotRight(content, dot)
+			}
+		case "I":
+			buf.Dot = viFirstNonBlank(content, dot)
+		case "A":
+			buf.Dot = viEOL(content, dot)
+		case "o":
+			buf.Dot = viEOL(content, dot)
+			buf.InsertAtDot("\n")
+		case "O":
+			buf.Dot = viSOL(content, dot)
+			buf.InsertAtDot("\n")
+			buf.Dot--
+		}
+		v.enterInsert(s, change)
+	}
+	if !ok {
+		return
+	}
+	if s.Mode == tk.InsertMode {
+		if replay != nil {
+			// Insert the recorded text and leave insert mode, without
+			// recording again.
+			v.recording = nil
+			buf.InsertAtDot(replay.inserted)
+			v.enterNormal(s)
+		}
+		return
+	}
+	if change != nil {
+		v.lastChange = change
+	}
+	viClampDot(s)
+}
+
+// Executes a command in visual mode.
+func (v *vi) executeVisual(s *tk.CodeAreaState, cmd viCommand) {
+	buf := &s.Buffer
+	from, to := s.Selection()
+	key := firstRune(cmd.keys)
+	switch {
+	case key == 'i' || key == 'a':
+		if from, to, ok := viTextObject(buf.Content, buf.Dot, cmd.keys); ok && from < to {
+			s.Anchor = from
+			_, w := utf8.DecodeLastRuneInString(buf.Content[:to])
+			buf.Dot = to - w
+		}
+	case strings.ContainsRune(viMotionKeys, key) || key == 'g' || strings.ContainsRune("fFtT", key):
+		if m, ok := v.motion(buf.Content, buf.Dot, cmd.keys, cmd.count, 0); ok {
+			buf.Dot = m.dot
+		}
+	case key == 'o':
+		s.Anchor, buf.Dot = buf.Dot, s.Anchor
+	case key == 'v':
+		s.Mode = tk.NormalMode
+	case key == 'r':
+		r := []rune(cmd.keys)[1]
+		var sb strings.Builder
+		for _, c := range buf.Content[from:to] {
+			if c == '\n' {
+				sb.WriteRune(c)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+		buf.Content = buf.Content[:from] + sb.String() + buf.Content[to:]
+		buf.Dot = from
+		s.Mode = tk.NormalMode
+	case key == '~':
+		buf.Content = buf.Content[:from] + toggleCase(buf.Content[from:to]) + buf.Content[to:]
+		buf.Dot = from
+		s.Mode = tk.NormalMode
+	case key == 'J':
+		lines := strings.Count(buf.Content[from:to], "\n")
+		buf.Dot = from
+		viJoin(buf, max1(lines))
+		s.Mode = tk.NormalMode
+	case key == 'p' || key == 'P':
+		reg := v.registers[cmd.register]
+		v.setRegister(cmd.register, viRegister{buf.Content[from:to], false})
+		buf.Content = buf.Content[:from] + buf.Content[to:]
+		buf.Dot = from
+		s.Mode = tk.NormalMode
+		v.put(s, reg, false, 1)
+	default:
+		// d, x, c, s, y and Y.
+		v.setRegister(cmd.register, viRegister{buf.Content[from:to], false})
+		switch key {
+		case 'y', 'Y':
+			buf.Dot = from
+			s.Mode = tk.NormalMode
+		case 'c', 's':
+			buf.Content = buf.Content[:from] + buf.Content[to:]
+			buf.Dot = from
+			v.enterInsert(s, nil)
+		default:
+			buf.Content = buf.Content[:from] + buf.Content[to:]
+			buf.Dot = from
+			s.Mode = tk.NormalMode
+		}
+	}
+}
+
+// Applies an operator to the text covered by a motion or text object.
+func (v *vi) operate(s *tk.CodeAreaState, cmd viCommand) bool {
+	buf := &s.Buffer
+	content, dot := buf.Content, buf.Dot
+	var from, to int
+	linewise := false
+	switch firstRune(cmd.keys) {
+	case cmd.op:
+		// Operate on count lines.
+		from = viSOL(content, dot)
+		to = dot
+		for i := 1; i < max1(cmd.count); i++ {
+			next := moveDotDown(content, to)
+			if next == to {
+				break
+			}
+			to = next
+		}
+		to = viEOL(content, to)
+		linewise = true
+	case 'i', 'a':
+		var ok bool
+		from, to, ok = viTextObject(content, dot, cmd.keys)
+		if !ok {
+			return false
+		}
+	default:
+		m, ok := v.motion(content, dot, cmd.keys, cmd.count, cmd.op)
+		if !ok {
+			return false
+		}
+		from, to = dot, m.dot
+		if from > to {
+			from, to = to, from
+		}
+		if m.linewise {
+			from, to = viSOL(content, from), viEOL(content, to)
+			linewise = true
+		} else if m.inclusive {
+			_, w := utf8.DecodeRuneInString(content[to:])
+			to += w
+		}
+	}
+
+	v.setRegister(cmd.register, viRegister{content[from:to], linewise})
+	switch cmd.op {
+	case 'y':
+		// Move the dot to the start of the text if it is before the dot, or
+		// before the current line when operating on lines.
+		if !linewise && from < dot || linewise && from < viSOL(content, dot) {
+			buf.Dot = from
+		}
+	case 'c':
+		buf.Content = content[:from] + content[to:]
+		buf.Dot = from
+		v.enterInsert(s, &viChange{cmd: cmd})
+	case 'd':
+		if linewise {
+			// Also delete the newline after the lines, or before them if they
+			// are the last lines.
+			if to < len(content) {
+				to++
+			} else if from > 0 {
+				from--
+			}
+		}
+		buf.Content = content[:from] + content[to:]
+		buf.Dot = from
+		if linewise {
+			if from > 0 && to == len(content) {
+				buf.Dot = viSOL(buf.Content, from)
+			}
+			buf.Dot = viFirstNonBlank(buf.Content, buf.Dot)
+		}
+	}
+	return true
+}
+
+func (v *vi) setRegister(name rune, reg viRegister) {
+	v.registers[0] = reg
+	if name != 0 && name != '"' {
+		v.registers[name] = reg
+	}
+}
+
+// Puts the content of a register after or before the dot n times.
+func (v *vi) put(s *tk.CodeAreaState, reg viRegister, after bool, n int) {
+	if reg.text == "" {
+		return
+	}
+	buf := &s.Buffer
+	text := strings.Repeat(reg.text, n)
+	if reg.linewise {
+		text = strings.Repeat(reg.text+"\n", n)
+		if after {
+			eol := viEOL(buf.Content, buf.Dot)
+			if eol == len(buf.Content) {
+				buf.Content += "\n" + text[:len(text)-1]
+			} else {
+				buf.Content = buf.Content[:eol+1] + text + buf.Content[eol+1:]
+			}
+			buf.Dot = eol + 1
+		} else {
+			sol := viSOL(buf.Content, buf.Dot)
+			buf.Content = buf.Content[:sol] + text + buf.Content[sol:]
+			buf.Dot = sol
+		}
+		buf.Dot = viFirstNonBlank(buf.Content, buf.Dot)
+		return
+	}
+	if after && buf.Dot < viEOL(buf.Content, buf.Dot) {
+		buf.Dot = moveDotRight(buf.Content, buf.Dot)
+	}
+	buf.InsertAtDot(text)
+	buf.Dot = moveDotLeft(buf.Content, buf.Dot)
+}
+
+// The result of a motion.
+type viMotion struct {
+	dot int
+	// Whether the rune under the new dot is covered by the motion.
+	inclusive bool
+	// Whether the motion covers whole lines.
+	linewise bool
+}
+
+// Computes the result of a motion. The operator is used to implement special
+// cases like cw.
+func (v *vi) motion(content string, dot int, keys string, count int, op rune) (viMotion, bool) {
+	n := max1(count)
+	repeat := func(f pureMover) int {
+		for i := 0; i < n; i++ {
+			dot = f(content, dot)
+		}
+		return dot
+	}
+	key := firstRune(keys)
+	if op == 'c' && (key == 'w' || key == 'W') && dot < len(content) &&
+		!unicode.IsSpace(firstRune(content[dot:])) {
+		// Like vi, cw changes to the end of the word.
+		key = key - 'w' + 'e'
+	}
+	switch key {
+	case 'h':
+		sol := viSOL(content, dot)
+		for i := 0; i < n && dot > sol; i++ {
+			dot = moveDotLeft(content, dot)
+		}
+		return viMotion{dot: dot}, true
+	case 'l':
+		eol := viEOL(content, dot)
+		for i := 0; i < n && dot < eol; i++ {
+			dot = moveDotRight(content, dot)
+		}
+		return viMotion{dot: dot}, true
+	case 'j', 'k':
+		f := moveDotDown
+		if key == 'k' {
+			f = moveDotUp
+		}
+		old := dot
+		repeat(f)
+		return viMotion{dot: dot, linewise: true}, op == 0 || dot != old
+	case '0':
+		return viMotion{dot: viSOL(content, dot)}, true
+	case '^':
+		return viMotion{dot: viFirstNonBlank(content, dot)}, true
+	case '$':
+		for i := 1; i < n; i++ {
+			dot = moveDotDown(content, dot)
+		}
+		return viMotion{dot: viEOL(content, dot)}, true
+	case 'w', 'W':
+		f := moveDotRightSmallWord
+		if key == 'W' {
+			f = moveDotRightWord
+		}
+		for i := 0; i < n; i++ {
+			next := f(content, dot)
+			if op != 0 && i == n-1 {
+				// When used with an operator, the last word does not extend
+				// beyond the end of the line.
+				if eol := viEOL(content, dot); dot < eol && next > eol {
+					next = eol
+				}
+			}
+			dot = next
+		}
+		return viMotion{dot: dot}, true
+	case 'b':
+		return viMotion{dot: repeat(moveDotLeftSmallWord)}, true
+	case 'B':
+		return viMotion{dot: repeat(moveDotLeftWord)}, true
+	case 'e':
+		return viMotion{dot: repeat(moveDotEndSmallWord), inclusive: true}, true
+	case 'E':
+		return viMotion{dot: repeat(moveDotEndWord), inclusive: true}, true
+	case 'G', 'g':
+		line := count
+		if line == 0 && key == 'G' {
+			line = strings.Count(content, "\n") + 1
+		}
+		return viMotion{dot: viFirstNonBlank(content, viLineStart(content, max1(line))),
+			linewise: true}, true
+	case 'f', 'F', 't', 'T':
+		v.lastFind = keys
+		return viFind(content, dot, keys, n, false)
+	case ';', ',':
+		if v.lastFind == "" {
+			return viMotion{}, false
+		}
+		keys := v.lastFind
+		if key == ',' {
+			rs := []rune(keys)
+			rs[0] = map[rune]rune{'f': 'F', 'F': 'f', 't': 'T', 'T': 't'}[rs[0]]
+			keys = string(rs)
+		}
+		return viFind(content, dot, keys, n, true)
+	}
+	return viMotion{}, false
+}
+
+// Finds the n-th occurrence of a rune on the current line. When repeating,
+// t and T skip an occurrence adjacent to the dot.
+func viFind(content string, dot int, keys string, n int, repeating bool) (viMotion, bool) {
+	rs := []rune(keys)
+	kind, target := rs[0], string(rs[1])
+	sol, eol := viSOL(content, dot), viEOL(content, dot)
+	pos := dot
+	for i := 0; i < n; i++ {
+		var j int
+		switch kind {
+		case 'f', 't':
+			start := moveDotRight(content, pos)
+			if kind == 't' && (i > 0 || repeating) && start < eol {
+				start = moveDotRight(content, start)
+			}
+			if start > eol {
+				return viMotion{}, false
+			}
+			j = strings.Index(content[start:eol], target)
+			if j != -1 {
+				j += start
+			}
+		default:
+			end := pos
+			if kind == 'T' && (i > 0 || repeating) && end > sol {
+				end = moveDotLeft(content, end)
+			}
+			j = strings.LastIndex(content[sol:end], target)
+			if j != -1 {
+				j += sol
+			}
+		}
+		if j == -1 {
+			return viMotion{}, false
+		}
+		pos = j
+	}
+	switch kind {
+	case 'f':
+		return viMotion{dot: pos, inclusive: true}, true
+	case 't':
+		return viMotion{dot: moveDotLeft(content, pos), inclusive: true}, true
+	case 'F':
+		return viMotion{dot: pos}, true
+	default:
+		return viMotion{dot: moveDotRight(content, pos)}, true
+	}
+}
+
+// Finds the range of a text object.
+func viTextObject(content string, dot int, keys string) (from, to int, ok bool) {
+	rs := []rune(keys)
+	inner := rs[0] == 'i'
+	switch obj := rs[1]; obj {
+	case 'w', 'W':
+		categorize := tk.CategorizeSmallWord
+		if obj == 'W' {
+			categorize = categorizeWord
+		}
+		return viWordObject(content, dot, categorize, inner)
+	case '"', '\'', '`':
+		return viQuoteObject(content, dot, obj, inner)
+	case '(', ')', 'b':
+		return viBracketObject(content, dot, '(', ')', inner)
+	case '[', ']':
+		return viBracketObject(content, dot, '[', ']', inner)
+	case '{', '}', 'B':
+		return viBracketObject(content, dot, '{', '}', inner)
+	case '<', '>':
+		return viBracketObject(content, dot, '<', '>', inner)
+	}
+	return 0, 0, false
+}
+
+func viWordObject(content string, dot int, categorize categorizer, inner bool) (int, int, bool) {
+	sol, eol := viSOL(content, dot), viEOL(content, dot)
+	if sol == eol {
+		return 0, 0, false
+	}
+	if dot == eol {
+		dot = moveDotLeft(content, dot)
+	}
+	// Finds the end of the run of runes in the same category starting at i.
+	runEnd := func(i int) int {
+		cat := categorize(firstRune(content[i:]))
+		for i < eol && categorize(firstRune(content[i:])) == cat {
+			i = moveDotRight(content, i)
+		}
+		return i
+	}
+	cat := categorize(firstRune(content[dot:]))
+	from := dot
+	for from > sol {
+		r, _ := utf8.DecodeLastRuneInString(content[:from])
+		if categorize(r) != cat {
+			break
+		}
+		from = moveDotLeft(content, from)
+	}
+	to := runEnd(dot)
+	if inner {
+		return from, to, true
+	}
+	if cat == 0 {
+		// Whitespace, followed by a word.
+		if to < eol {
+			to = runEnd(to)
+		}
+		return from, to, true
+	}
+	// A word, followed by whitespace, or preceded by whitespace if there is
+	// no whitespace after it.
+	if to < eol && categorize(firstRune(content[to:])) == 0 {
+		return from, runEnd(to), true
+	}
+	for from > sol {
+		r, _ := utf8.DecodeLastRuneInString(content[:from])
+		if categorize(r) != 0 {
+			break
+		}
+		from = moveDotLeft(content, from)
+	}
+	return from, to, true
+}
+
+func viQuoteObject(content string, dot int, quote rune, inner bool) (int, int, bool) {
+	sol, eol := viSOL(content, dot), viEOL(content, dot)
+	var quotes []int
+	for i, r := range content[sol:eol] {
+		if r == quote {
+			quotes = append(quotes, sol+i)
+		}
+	}
+	for i := 0; i+1 < len(quotes); i += 2 {
+		open, close := quotes[i], quotes[i+1]
+		if dot <= close {
+			if inner {
+				return open + 1, close, true
+			}
+			to := close + 1
+			for to < eol && (content[to] == ' ' || content[to] == '\t') {
+				to++
+			}
+			return open, to, true
+		}
+	}
+	return 0, 0, false
+}
+
+func viBracketObject(content string, dot int, open, close byte, inner bool) (int, int, bool) {
+	// Find the unmatched opening bracket at or before the dot.
+	start := -1
+	depth := 0
+	i := dot
+	if i < len(content) && content[i] == close {
+		i--
+	}
+	for ; i >= 0; i-- {
+		if i >= len(content) {
+			continue
+		}
+		switch content[i] {
+		case close:
+			depth++
+		case open:
+			if depth == 0 {
+				start = i
+			} else {
+				depth--
+			}
+		}
+		if start != -1 {
+			break
+		}
+	}
+	if start == -1 {
+		return 0, 0, false
+	}
+	// Find the matching closing bracket.
+	end := -1
+	depth = 0
+	for j := start + 1; j < len(content) && end == -1; j++ {
+		switch content[j] {
+		case open:
+			depth++
+		case close:
+			if depth == 0 {
+				end = j
+			} else {
+				depth--
+			}
+		}
+	}
+	if end == -1 {
+		return 0, 0, false
+	}
+	if !inner {
+		return start, end + 1, true
+	}
+	from, to := start+1, end
+	if from < to && content[from] == '\n' {
+		from++
+	}
+	if sol := strutil.FindLastSOL(content[:to]); sol > from &&
+		strings.TrimSpace(content[sol:to]) == "" {
+		to = sol
+	}
+	return from, to, true
+}
+
+// Replaces n runes from the dot with r, if the line has enough runes.
+func viReplace(buf *tk.CodeBuffer, r rune, n int) bool {
+	eol := viEOL(buf.Content, buf.Dot)
+	to := buf.Dot
+	for i := 0; i < n; i++ {
+		if to == eol {
+			return false
+		}
+		to = moveDotRight(buf.Content, to)
+	}
+	replacement := strings.Repeat(string(r), n)
+	buf.Content = buf.Content[:buf.Dot] + replacement + buf.Content[to:]
+	buf.Dot += len(replacement) - len(string(r))
+	return true
+}
+
+// Joins the current line with the following n lines, separating them with
+// spaces.
+func viJoin(buf *tk.CodeBuffer, n int) bool {
+	joined := false
+	for i := 0; i < n; i++ {
+		eol := viEOL(buf.Content, buf.Dot)
+		if eol == len(buf.Content) {
+			break
+		}
+		rest := strings.TrimLeft(buf.Content[eol+1:], " \t")
+		sep := " "
+		if eol == viSOL(buf.Content, eol) || strings.HasPrefix(rest, "\n") || rest == "" ||
+			strings.HasSuffix(buf.Content[:eol], " ") {
+			sep = ""
+		}
+		buf.Content = buf.Content[:eol] + sep + rest
+		buf.Dot = eol
+		joined = true
+	}
+	return joined
+}
+
+func toggleCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// Makes sure that the dot is on a rune, unless the line is empty, like in vi.
+// This is only done outside insert mode.
+func viClampDot(s *tk.CodeAreaState) {
+	if s.Mode == tk.InsertMode {
+		return
+	}
+	buf := &s.Buffer
+	if buf.Dot > len(buf.Content) {
+		buf.Dot = len(buf.Content)
+	}
+	if buf.Dot == viEOL(buf.Content, buf.Dot) && buf.Dot > viSOL(buf.Content, buf.Dot) {
+		buf.Dot = moveDotLeft(buf.Content, buf.Dot)
+	}
+}
+
+func moveDotEndSmallWord(buffer string, dot int) int {
+	return moveDotEndGeneralWord(tk.CategorizeSmallWord, buffer, dot)
+}
+
+func moveDotEndWord(buffer string, dot int) int {
+	return moveDotEndGeneralWord(categorizeWord, buffer, dot)
+}
+
+// Moves the dot to the last rune of the first word that ends after the dot.
+func moveDotEndGeneralWord(categorize categorizer, buffer string, dot int) int {
+	i := moveDotRight(buffer, dot)
+	for i < len(buffer) && categorize(firstRune(buffer[i:])) == 0 {
+		i = moveDotRight(buffer, i)
+	}
+	if i == len(buffer) {
+		return dot
+	}
+	cat := categorize(firstRune(buffer[i:]))
+	for {
+		next := moveDotRight(buffer, i)
+		if next == len(buffer) || categorize(firstRune(buffer[next:])) != cat {
+			return i
+		}
+		i = next
+	}
+}
+
+func viSOL(content string, dot int) int {
+	return strutil.FindLastSOL(content[:dot])
+}
+
+func viEOL(content string, dot int) int {
+	return strutil.FindFirstEOL(content[dot:]) + dot
+}
+
+func viFirstNonBlank(content string, dot int) int {
+	i := viSOL(content, dot)
+	eol := viEOL(content, i)
+	for i < eol && (content[i] == ' ' || content[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// Returns the start of the line with the given 1-based number, or the start
+// of the last line if there are fewer lines.
+func viLineStart(content string, line int) int {
+	i := 0
+	for ; line > 1; line-- {
+		j := strings.IndexByte(content[i:], '\n')
+		if j == -1 {
+			break
+		}
+		i += j + 1
+	}
+	return i
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
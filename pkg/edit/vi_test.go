@@ -0,0 +1,128 @@
+package edit
+
+import (
+	"testing"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/ui"
+)
+
+var viTests = []struct {
+	name     string
+	keys     string
+	wantCode string
+}{
+	{"delete word", "foo bar baz\x1b0dw", "bar baz"},
+	{"delete with count", "foo bar baz\x1b02dw", "baz"},
+	{"count before and after operator", "a b c d e f\x1b02d2w", "e f"},
+	{"delete rune with count", "foobar\x1b02x", "obar"},
+	{"delete to end of line", "foo bar\x1b0wD", "foo "},
+	{"change inner word", "foo bar\x1b0ciwxyz\x1b", "xyz bar"},
+	{"repeat change", "foo bar\x1b0ciwxyz\x1bw.", "xyz xyz"},
+	{"change inner quotes", `echo "foo bar"` + "\x1b0fbci\"x\x1b", `echo "x"`},
+	{"delete around brackets", "f (a (b) c) d\x1b0fbda(", "f (a  c) d"},
+	{"yank line and put", "foo\x1byyp", "foo\nfoo"},
+	{"yank word and put", "foo bar\x1b0yeP", "foofoo bar"},
+	{"named register", "foo bar\x1b0\"ayiwwdiw\"ap", "foo foo"},
+	{"find and repeat", "a-b-c-d\x1b0f-;x", "a-bc-d"},
+	{"till and delete", "foo(bar)\x1b0dt(", "(bar)"},
+	{"replace", "foo\x1b02rx", "xxo"},
+	{"toggle case", "foo\x1b0~~", "FOo"},
+	{"join lines", "foo\x1bobar\x1bkJ", "foo bar"},
+	{"open line below", "foo\x1bobar\x1b", "foo\nbar"},
+	{"append at end", "foo\x1b0Abar\x1b", "foobar"},
+	{"insert at first non-blank", "  foo\x1bIx\x1b", "  xfoo"},
+	{"go to first line", "a\x1bob\x1boc\x1bggdd", "b\nc"},
+	{"visual delete", "foo bar\x1b0ved", " bar"},
+	{"visual change", "foo bar\x1b0wvecx\x1b", "foo x"},
+	{"visual swap ends", "foo bar\x1b$vbohd", "foo r"},
+	{"undo", "foo bar\x1b0dwdwu", "bar"},
+	{"undo with count", "foo bar\x1b0dwdw2u", "foo bar"},
+	{"redo", "foo bar\x1b0dwdw2u\x12", "bar"},
+	{"invalid command is ignored", "foo\x1bQx", "fo"},
+	{"pending keys are cleared by Esc", "foo\x1bd\x1bx", "fo"},
+}
+
+func TestVi(t *testing.T) {
+	for _, test := range viTests {
+		t.Run(test.name, func(t *testing.T) {
+			f := setup(t, rc(
+				`edit:insert:binding[Ctrl-'['] = $edit:vi:normal:start~`))
+			injectViKeys(f, test.keys)
+			f.TTYCtrl.Inject(term.K('\n'))
+			if code, _ := f.Wait(); code != test.wantCode {
+				t.Errorf("got code %q, want %q", code, test.wantCode)
+			}
+		})
+	}
+}
+
+func TestVi_Mode(t *testing.T) {
+	f := setup(t, rc(
+		`edit:insert:binding[Ctrl-'['] = $edit:vi:normal:start~`,
+		`edit:prompt = { put $edit:vi:mode'> ' }`))
+	styles := ui.RuneStylesheet{
+		'!': ui.FgRed, '+': ui.Stylings(ui.Inverse, ui.FgRed)}
+
+	f.TestTTY(t, "insert> ", term.DotHere)
+	injectViKeys(f, "ab\x1b")
+	f.TestTTY(t,
+		"normal> a", styles,
+		"        !", term.DotHere,
+		"b", styles,
+		"!")
+	f.TTYCtrl.Inject(term.K('v'))
+	f.TestTTY(t,
+		"visual> a", styles,
+		"        !", term.DotHere,
+		"b", styles,
+		"+")
+	f.TTYCtrl.Inject(term.K('[', ui.Ctrl), term.K('a'))
+	f.TestTTY(t, "insert> ab", styles,
+		"        !!", term.DotHere)
+}
+
+func TestVi_Bindings(t *testing.T) {
+	f := setup(t, rc(
+		`edit:insert:binding[Ctrl-'['] = $edit:vi:normal:start~`,
+		`edit:vi:normal:binding[x] = { edit:insert-at-dot N }`,
+		`edit:vi:visual:binding[x] = { edit:insert-at-dot V }`))
+
+	// Bindings take precedence over builtin commands, but not when a command
+	// is partially typed.
+	injectViKeys(f, "ab\x1bxfxvx")
+	f.TTYCtrl.Inject(term.K('\n'))
+	if code, _ := f.Wait(); code != "aNVb" {
+		t.Errorf("got code %q, want %q", code, "aNVb")
+	}
+}
+
+func TestInsertedText(t *testing.T) {
+	tests := []struct{ old, new, want string }{
+		{"", "foo", "foo"},
+		{"ac", "abc", "b"},
+		{"aa", "aaa", "a"},
+		{"a", "aé", "é"},
+		{"é", "éè", "è"},
+	}
+	for _, test := range tests {
+		if got := insertedText(test.old, test.new); got != test.want {
+			t.Errorf("insertedText(%q, %q) = %q, want %q",
+				test.old, test.new, got, test.want)
+		}
+	}
+}
+
+// Injects keys, with \x1b standing for Esc and \x12 standing for Ctrl-R.
+func injectViKeys(f *fixture, s string) {
+	for _, r := range s {
+		switch r {
+		case '\x1b':
+			f.TTYCtrl.Inject(term.K('[', ui.Ctrl))
+		case '\x12':
+			f.TTYCtrl.Inject(term.K('R', ui.Ctrl))
+		default:
+			f.TTYCtrl.Inject(term.K(r))
+		}
+	}
+}
@@ -9,8 +9,6 @@ import (
 
 // Command and process control.
 
-// TODO(xiaq): Document "fg".
-
 func init() {
 	addBuiltinFns(map[string]interface{}{
 		// Command resolution
@@ -19,9 +17,12 @@ func init() {
 		"search-external": searchExternal,
 
 		// Process control
-		"fg":   fg,
-		"exec": execFn,
-		"exit": exit,
+		"fg":     fg,
+		"bg":     bg,
+		"jobs":   jobs,
+		"disown": disown,
+		"exec":   execFn,
+		"exit":   exit,
 	})
 }
 
@@ -87,6 +88,102 @@ func searchExternal(cmd string) (string, error) {
 	return exec.LookPath(cmd)
 }
 
+//elvdoc:fn jobs
+//
+// ```elvish
+// jobs
+// ```
+//
+// Outputs the jobs in the job table, sorted by their IDs. The job table
+// contains all [background jobs](language.html#background-pipeline) that are
+// still running, and foreground jobs that have been stopped, for example by
+// pressing Ctrl-Z.
+//
+// Each job is output as a map with the following fields:
+//
+// -   `id`: The ID of the job, which can be used to refer to the job as `%id`
+//     in [`fg`](#fg), [`bg`](#bg) and [`disown`](#disown).
+//
+// -   `state`: Either `running` or `stopped`.
+//
+// -   `text`: The source code of the job.
+//
+// -   `pgid`: The process group of the job, or 0 if the processes of the job
+//     are in the same process group as Elvish. Every job has a process group
+//     of its own, except foreground jobs started when Elvish doesn't control
+//     the terminal.
+//
+// -   `pids`: A list of the IDs of the external processes of the job that are
+//     still alive.
+//
+// Example:
+//
+// ```elvish-transcript
+// ~> sleep 100 &
+// ~> jobs
+// ▶ [&id=1 &pgid=12345 &pids=[12345] &state=running &text='sleep 100 &']
+// ```
+//
+// State changes of jobs are reported when they happen.
+//
+// @cf fg bg disown
+
+func jobs(fm *Frame) error {
+	out := fm.ValueOutput()
+	for _, j := range fm.Evaler.jobs.list() {
+		err := out.Put(j.info())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//elvdoc:fn disown
+//
+// ```elvish
+// disown $job-spec...
+// ```
+//
+// Removes the jobs identified by the job specs (in the form `%id`) from the
+// job table, defaulting to the most recently added job. Disowned jobs keep
+// running, but are no longer shown by [`jobs`](#jobs), and changes of their
+// states are no longer reported.
+//
+// @cf jobs
+
+func disown(fm *Frame, specs ...string) error {
+	js, err := findJobs(fm, specs)
+	if err != nil {
+		return err
+	}
+	for _, j := range js {
+		fm.Evaler.jobs.disown(j)
+	}
+	return nil
+}
+
+// Finds the jobs identified by the job specs; if there are no specs, finds the
+// most recently added job.
+func findJobs(fm *Frame, specs []string) ([]*job, error) {
+	if len(specs) == 0 {
+		j, err := fm.Evaler.jobs.find("")
+		if err != nil {
+			return nil, err
+		}
+		return []*job{j}, nil
+	}
+	js := make([]*job, len(specs))
+	for i, spec := range specs {
+		j, err := fm.Evaler.jobs.find(spec)
+		if err != nil {
+			return nil, err
+		}
+		js[i] = j
+	}
+	return js, nil
+}
+
 //elvdoc:fn exit
 //
 // ```elvish
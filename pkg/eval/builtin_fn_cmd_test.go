@@ -3,9 +3,50 @@ package eval_test
 import (
 	"testing"
 
+	. "src.elv.sh/pkg/eval"
 	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/mods/file"
 )
 
 func TestBuiltinFnCmd(t *testing.T) {
 	Test(t /* TODO: Add test cases */)
 }
+
+func TestJobs(t *testing.T) {
+	setup := func(ev *Evaler) {
+		ev.AddGlobal(NsBuilder{}.AddNs("file", file.Ns).Ns())
+	}
+	TestWithSetup(t, setup,
+		That("jobs").DoesNothing(),
+		// Background jobs are added to the job table
+		That(
+			"var p = (file:pipe)",
+			"{ nop (slurp < $p); file:close $p[r] } &",
+			"var j = (jobs)",
+			"put $j[id] $j[state] $j[text]",
+			"file:close $p[w]").
+			Puts("1", "running", "{ nop (slurp < $p); file:close $p[r] } &"),
+		// Job IDs are the smallest unused positive integers
+		That(
+			"var p = (file:pipe)",
+			"{ nop (slurp < $p) } &",
+			"{ nop (slurp < $p) } &",
+			"disown %1",
+			"{ nop (slurp < $p) } &",
+			"put (jobs)[id]",
+			"file:close $p[w]; file:close $p[r]").
+			Puts("1", "2"),
+		// Disowned jobs are removed from the job table
+		That(
+			"var p = (file:pipe)",
+			"{ nop (slurp < $p) } &",
+			"disown",
+			"jobs",
+			"file:close $p[w]; file:close $p[r]").
+			DoesNothing(),
+
+		That("disown").Throws(ErrNoJobs),
+		That("disown %1").Throws(NoSuchJob{"%1"}),
+		That("disown 1").Throws(NoSuchJob{"1"}),
+	)
+}
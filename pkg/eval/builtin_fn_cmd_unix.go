@@ -8,11 +8,12 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"syscall"
 
 	"src.elv.sh/pkg/env"
-	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/sys"
 	"src.elv.sh/pkg/sys/eunix"
 )
 
@@ -69,10 +70,143 @@ func decSHLVL() {
 	os.Setenv(env.SHLVL, strconv.Itoa(i-1))
 }
 
-func fg(pids ...int) error {
-	if len(pids) == 0 {
-		return errs.ArityMismatch{What: "arguments", ValidLow: 1, ValidHigh: -1, Actual: len(pids)}
+//elvdoc:fn fg
+//
+// ```elvish
+// fg $job-spec?
+// fg $pid...
+// ```
+//
+// Continues a job in the foreground and waits for it to finish or be stopped
+// again. The job is identified by a job spec in the form `%id`, defaulting to
+// the most recently added job in the job table. If the job finishes, `fg`
+// throws the exceptions of the job, if any.
+//
+// For compatibility, `fg` also accepts a list of process IDs, which must all be
+// in the same process group.
+//
+// This command always raises an exception on Windows with the message "not
+// supported on Windows".
+//
+// @cf jobs bg
+
+func fg(fm *Frame, args ...interface{}) error {
+	if len(args) == 0 {
+		j, err := fm.Evaler.jobs.find("")
+		if err != nil {
+			return err
+		}
+		return fgJob(fm, j)
+	}
+	if spec, ok := args[0].(string); ok && len(args) == 1 && strings.HasPrefix(spec, "%") {
+		j, err := fm.Evaler.jobs.find(spec)
+		if err != nil {
+			return err
+		}
+		return fgJob(fm, j)
+	}
+
+	pids := make([]int, len(args))
+	for i, arg := range args {
+		err := vals.ScanToGo(arg, &pids[i])
+		if err != nil {
+			return err
+		}
+	}
+	if len(pids) == 1 {
+		if j := fm.Evaler.jobs.findByPid(pids[0]); j != nil {
+			return fgJob(fm, j)
+		}
 	}
+	return fgPids(pids)
+}
+
+func fgJob(fm *Frame, j *job) error {
+	j.mu.Lock()
+	j.fg = true
+	j.markContinued()
+	pgid := j.pgid
+	j.mu.Unlock()
+
+	if pgid != 0 && sys.IsATTY(os.Stdin) {
+		err := eunix.Tcsetpgrp(0, pgid)
+		if err != nil {
+			return err
+		}
+		defer putSelfInFg()
+	}
+	err := signalJob(j, syscall.SIGCONT)
+	if err != nil {
+		return err
+	}
+	state, err := j.wait(fm.intCh)
+	if state != JobDone {
+		j.mu.Lock()
+		j.fg = false
+		j.mu.Unlock()
+	}
+	if state == JobStopped {
+		return nil
+	}
+	return err
+}
+
+//elvdoc:fn bg
+//
+// ```elvish
+// bg $job-spec...
+// ```
+//
+// Continues stopped jobs in the background. The jobs are identified by job
+// specs in the form `%id`, defaulting to the most recently added job in the
+// job table.
+//
+// This command always raises an exception on Windows with the message "not
+// supported on Windows".
+//
+// @cf jobs fg
+
+func bg(fm *Frame, specs ...string) error {
+	js, err := findJobs(fm, specs)
+	if err != nil {
+		return err
+	}
+	for _, j := range js {
+		j.mu.Lock()
+		if j.state != JobStopped {
+			j.mu.Unlock()
+			return ErrJobAlreadyRunning
+		}
+		msg := j.markContinued()
+		j.mu.Unlock()
+
+		err := signalJob(j, syscall.SIGCONT)
+		if err != nil {
+			return err
+		}
+		j.notify(msg)
+	}
+	return nil
+}
+
+// Sends a signal to all processes of a job.
+func signalJob(j *job, sig syscall.Signal) error {
+	j.mu.Lock()
+	pgid := j.pgid
+	j.mu.Unlock()
+	if pgid != 0 {
+		return syscall.Kill(-pgid, sig)
+	}
+	for _, pid := range j.pids() {
+		err := syscall.Kill(pid, sig)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fgPids(pids []int) error {
 	var thepgid int
 	for i, pid := range pids {
 		pgid, err := syscall.Getpgid(pid)
@@ -5,8 +5,12 @@ package eval_test
 
 import (
 	"testing"
+	"time"
 
+	. "src.elv.sh/pkg/eval"
 	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/mods/file"
+	"src.elv.sh/pkg/testutil"
 )
 
 func TestHasExternal(t *testing.T) {
@@ -41,3 +45,59 @@ func TestExternal(t *testing.T) {
 		That(`(external sh) -c 'echo external-sh'`).Prints("external-sh\n"),
 	)
 }
+
+func TestJobControl(t *testing.T) {
+	notes := make(chan string, 10)
+	setup := func(ev *Evaler) {
+		ev.BgJobNotify = func(note string) { notes <- note }
+	}
+	verifyNotes := func(wantNotes ...string) func(t *testing.T) {
+		return func(t *testing.T) {
+			for _, wantNote := range wantNotes {
+				select {
+				case note := <-notes:
+					if note != wantNote {
+						t.Errorf("got note %q, want %q", note, wantNote)
+					}
+				case <-time.After(testutil.Scaled(time.Second)):
+					t.Errorf("timeout waiting for notification %q", wantNote)
+				}
+			}
+		}
+	}
+	// The process is stopped in the foreground, and its exception is caught.
+	// Its standard IO are redirected so that it doesn't hold on to the output
+	// pipes of the test.
+	stopSelf := "try { sh -c 'kill -STOP $$; exit 3' </dev/null >/dev/null 2>&1 } except { }"
+
+	TestWithSetup(t, setup,
+		// Stopped foreground jobs are added to the job table
+		That(stopSelf, "put (jobs)[id] (jobs)[state]").
+			Puts("1", "stopped").
+			Passes(verifyNotes("job %1 stopped: "+stopSelf)),
+		// Continuing a stopped job in the foreground
+		That(stopSelf, "fg %1").
+			Throws(ErrorWithType(ExternalCmdExit{})).
+			Passes(verifyNotes("job %1 stopped: "+stopSelf)),
+		That(stopSelf, "fg").
+			Throws(ErrorWithType(ExternalCmdExit{})).
+			Passes(verifyNotes("job %1 stopped: "+stopSelf)),
+		// Continuing a stopped job in the background
+		That(stopSelf, "bg %1").
+			Passes(verifyNotes(
+				"job %1 stopped: "+stopSelf,
+				"job %1 running: "+stopSelf,
+				"job "+stopSelf+" finished, errors = sh exited with 3")),
+
+		That("fg %1").Throws(NoSuchJob{"%1"}),
+		That("bg").Throws(ErrNoJobs),
+		That("var p = (file:pipe)",
+			"{ nop (slurp < $p) } &",
+			"try { bg %1 } except e { put $e[reason] }",
+			"file:close $p[w]; file:close $p[r]").
+			Puts(ErrJobAlreadyRunning).
+			WithSetup(func(ev *Evaler) {
+				ev.AddGlobal(NsBuilder{}.AddNs("file", file.Ns).Ns())
+			}),
+	)
+}
@@ -8,6 +8,10 @@ func execFn(...interface{}) error {
 	return errNotSupportedOnWindows
 }
 
-func fg(...int) error {
+func fg(*Frame, ...interface{}) error {
+	return errNotSupportedOnWindows
+}
+
+func bg(*Frame, ...string) error {
 	return errNotSupportedOnWindows
 }
@@ -38,6 +38,17 @@ func init() {
 		"drop":  drop,
 		"count": count,
 
+		"map":       mapFn,
+		"filter":    filter,
+		"reduce":    reduce,
+		"fold":      fold,
+		"group-by":  groupBy,
+		"partition": partition,
+		"zip":       zip,
+		"enumerate": enumerate,
+		"flatten":   flatten,
+		"uniq":      uniq,
+
 		"keys": keys,
 
 		"order": order,
@@ -532,6 +543,473 @@ func drop(fm *Frame, n int, inputs Inputs) error {
 	return errOut
 }
 
+//elvdoc:fn map
+//
+// ```elvish
+// map $f $input-list?
+// ```
+//
+// Calls `$f` on each input, and outputs the value it outputs. Each call of
+// `$f` must output exactly one value. Examples:
+//
+// ```elvish-transcript
+// ~> map {|x| * $x $x } [1 2 3]
+// ▶ (num 1)
+// ▶ (num 4)
+// ▶ (num 9)
+// ~> put foo bar | map {|x| put $x$x }
+// ▶ foofoo
+// ▶ barbar
+// ```
+//
+// Like other commands that take `$input-list?`, the inputs are processed as
+// they arrive, and the outputs are written as soon as they are computed.
+//
+// @cf each filter
+//
+// Etymology: Various functional languages.
+
+func mapFn(fm *Frame, f Callable, inputs Inputs) error {
+	out := fm.ValueOutput()
+	return iterateInputsUntilError(fm, inputs, func(v interface{}) error {
+		result, err := callForSingleValue(fm, f, "map callback", v)
+		if err != nil {
+			return err
+		}
+		return out.Put(result)
+	})
+}
+
+//elvdoc:fn filter
+//
+// ```elvish
+// filter $pred $input-list?
+// ```
+//
+// Outputs the inputs for which `$pred` outputs `$true`. Each call of `$pred`
+// must output exactly one boolean. Example:
+//
+// ```elvish-transcript
+// ~> filter {|x| > $x 2 } [1 5 2 4]
+// ▶ 5
+// ▶ 4
+// ```
+//
+// @cf partition
+//
+// Etymology: Various functional languages.
+
+func filter(fm *Frame, pred Callable, inputs Inputs) error {
+	out := fm.ValueOutput()
+	return iterateInputsUntilError(fm, inputs, func(v interface{}) error {
+		ok, err := callPredicate(fm, pred, "filter predicate", v)
+		if err != nil || !ok {
+			return err
+		}
+		return out.Put(v)
+	})
+}
+
+//elvdoc:fn reduce
+//
+// ```elvish
+// reduce $f $input-list?
+// ```
+//
+// Combines the inputs with `$f`, from left to right, and outputs the result.
+// The first input is used as the initial accumulated value; `$f` is then called
+// with the accumulated value and each of the remaining inputs, and must output
+// exactly one value, which becomes the new accumulated value. Throws an
+// exception if there are no inputs. Examples:
+//
+// ```elvish-transcript
+// ~> reduce $'+~' [1 2 3 4]
+// ▶ (num 10)
+// ~> reduce {|a b| put $b$a } [a b c]
+// ▶ cba
+// ```
+//
+// @cf fold
+
+func reduce(fm *Frame, f Callable, inputs Inputs) error {
+	var acc interface{}
+	n := 0
+	err := iterateInputsUntilError(fm, inputs, func(v interface{}) error {
+		n++
+		if n == 1 {
+			acc = v
+			return nil
+		}
+		var err error
+		acc, err = callForSingleValue(fm, f, "reduce callback", acc, v)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errs.ArityMismatch{What: "inputs", ValidLow: 1, ValidHigh: -1, Actual: 0}
+	}
+	return fm.ValueOutput().Put(acc)
+}
+
+//elvdoc:fn fold
+//
+// ```elvish
+// fold $f $init $input-list?
+// ```
+//
+// Like [`reduce`](#reduce), but uses `$init` as the initial accumulated value.
+// If there are no inputs, `$init` is output. Examples:
+//
+// ```elvish-transcript
+// ~> fold $'+~' 10 [1 2 3]
+// ▶ (num 16)
+// ~> fold {|acc x| put $acc,$x } '' [a b]
+// ▶ ',a,b'
+// ~> fold $'+~' 10 []
+// ▶ 10
+// ```
+//
+// @cf reduce
+
+func fold(fm *Frame, f Callable, init interface{}, inputs Inputs) error {
+	acc := init
+	err := iterateInputsUntilError(fm, inputs, func(v interface{}) error {
+		var err error
+		acc, err = callForSingleValue(fm, f, "fold callback", acc, v)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return fm.ValueOutput().Put(acc)
+}
+
+//elvdoc:fn group-by
+//
+// ```elvish
+// group-by $f $input-list?
+// ```
+//
+// Calls `$f` on each input to compute its key, and outputs a map from each key
+// to a list of the inputs with that key, in their original order. Each call of
+// `$f` must output exactly one value. Example:
+//
+// ```elvish-transcript
+// ~> group-by {|s| count $s } [a bc d ef ghi]
+// ▶ [&(num 1)=[a d] &(num 2)=[bc ef] &(num 3)=[ghi]]
+// ```
+//
+// Only the groups are kept in memory; the map is output after all inputs have
+// been consumed.
+//
+// @cf partition
+
+func groupBy(fm *Frame, f Callable, inputs Inputs) error {
+	groups := vals.EmptyMap
+	err := iterateInputsUntilError(fm, inputs, func(v interface{}) error {
+		k, err := callForSingleValue(fm, f, "group-by callback", v)
+		if err != nil {
+			return err
+		}
+		group, ok := groups.Index(k)
+		if !ok {
+			group = vals.EmptyList
+		}
+		groups = groups.Assoc(k, group.(vals.List).Cons(v))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return fm.ValueOutput().Put(groups)
+}
+
+//elvdoc:fn partition
+//
+// ```elvish
+// partition $pred $input-list?
+// ```
+//
+// Outputs two lists: the inputs for which `$pred` outputs `$true`, and the
+// inputs for which it outputs `$false`, in their original order. Each call of
+// `$pred` must output exactly one boolean. Example:
+//
+// ```elvish-transcript
+// ~> partition {|x| > $x 2 } [1 5 2 4]
+// ▶ [5 4]
+// ▶ [1 2]
+// ```
+//
+// @cf filter group-by
+
+func partition(fm *Frame, pred Callable, inputs Inputs) error {
+	yes, no := vals.EmptyList, vals.EmptyList
+	err := iterateInputsUntilError(fm, inputs, func(v interface{}) error {
+		ok, err := callPredicate(fm, pred, "partition predicate", v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			yes = yes.Cons(v)
+		} else {
+			no = no.Cons(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	out := fm.ValueOutput()
+	if err := out.Put(yes); err != nil {
+		return err
+	}
+	return out.Put(no)
+}
+
+//elvdoc:fn zip
+//
+// ```elvish
+// zip $iterable...
+// ```
+//
+// Outputs lists made of the corresponding elements of the iterables, stopping
+// when the shortest iterable is exhausted. If no arguments are given, the
+// iterables are taken from the value input. Examples:
+//
+// ```elvish-transcript
+// ~> zip [a b c] [1 2 3]
+// ▶ [a 1]
+// ▶ [b 2]
+// ▶ [c 3]
+// ~> put [a b] [1 2 3] | zip
+// ▶ [a 1]
+// ▶ [b 2]
+// ```
+//
+// @cf enumerate
+//
+// Etymology: Various functional languages.
+
+func zip(fm *Frame, iterables ...interface{}) error {
+	if len(iterables) == 0 {
+		fm.IterateInputs(func(v interface{}) { iterables = append(iterables, v) })
+	}
+	if len(iterables) == 0 {
+		return nil
+	}
+	// Iterables are values in memory, so collecting their elements does not
+	// change the memory usage by more than a constant factor.
+	elems := make([][]interface{}, len(iterables))
+	shortest := -1
+	for i, iterable := range iterables {
+		err := vals.Iterate(iterable, func(v interface{}) bool {
+			elems[i] = append(elems[i], v)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if shortest == -1 || len(elems[i]) < shortest {
+			shortest = len(elems[i])
+		}
+	}
+	out := fm.ValueOutput()
+	for j := 0; j < shortest; j++ {
+		if fm.IsInterrupted() {
+			return ErrInterrupted
+		}
+		tuple := vals.EmptyList
+		for i := range elems {
+			tuple = tuple.Cons(elems[i][j])
+		}
+		if err := out.Put(tuple); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//elvdoc:fn enumerate
+//
+// ```elvish
+// enumerate &start=(num 0) $input-list?
+// ```
+//
+// Outputs a list of the index and the value of each input. The index starts
+// from `&start`. Examples:
+//
+// ```elvish-transcript
+// ~> enumerate [a b]
+// ▶ [(num 0) a]
+// ▶ [(num 1) b]
+// ~> put a b | enumerate &start=1
+// ▶ [(num 1) a]
+// ▶ [(num 2) b]
+// ```
+//
+// @cf zip
+//
+// Etymology: Python.
+
+type enumerateOpts struct{ Start int }
+
+func (*enumerateOpts) SetDefaultOptions() {}
+
+func enumerate(fm *Frame, opts enumerateOpts, inputs Inputs) error {
+	out := fm.ValueOutput()
+	i := opts.Start
+	return iterateInputsUntilError(fm, inputs, func(v interface{}) error {
+		err := out.Put(vals.MakeList(i, v))
+		i++
+		return err
+	})
+}
+
+//elvdoc:fn flatten
+//
+// ```elvish
+// flatten &depth=(num -1) $input-list?
+// ```
+//
+// Outputs the elements of each input that is a list, recursively, and all
+// other inputs as they are. If `&depth` is non-negative, only that many levels
+// of lists are flattened. Examples:
+//
+// ```elvish-transcript
+// ~> flatten [a [b [c]] d]
+// ▶ a
+// ▶ b
+// ▶ c
+// ▶ d
+// ~> put [a [b [c]]] | flatten &depth=1
+// ▶ a
+// ▶ [b [c]]
+// ```
+
+type flattenOpts struct{ Depth int }
+
+func (o *flattenOpts) SetDefaultOptions() { o.Depth = -1 }
+
+func flatten(fm *Frame, opts flattenOpts, inputs Inputs) error {
+	out := fm.ValueOutput()
+	var flattenValue func(v interface{}, depth int) error
+	flattenValue = func(v interface{}, depth int) error {
+		l, ok := v.(vals.List)
+		if !ok || depth == 0 {
+			return out.Put(v)
+		}
+		for it := l.Iterator(); it.HasElem(); it.Next() {
+			if err := flattenValue(it.Elem(), depth-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// Inputs themselves are not counted as a level; with &depth=1, each input
+	// that is a list is replaced by its elements.
+	return iterateInputsUntilError(fm, inputs, func(v interface{}) error {
+		return flattenValue(v, opts.Depth)
+	})
+}
+
+//elvdoc:fn uniq
+//
+// ```elvish
+// uniq &adjacent=$false $input-list?
+// ```
+//
+// Outputs the inputs with duplicates removed, keeping the first occurrence of
+// each value. Values are compared like with [`eq`](#eq).
+//
+// By default, all values that have been output are remembered. If
+// `&adjacent` is true, only adjacent duplicates are removed, like the Unix
+// `uniq` command; this only needs to remember the last value. Examples:
+//
+// ```elvish-transcript
+// ~> uniq [a b a c b]
+// ▶ a
+// ▶ b
+// ▶ c
+// ~> uniq &adjacent [a a b a]
+// ▶ a
+// ▶ b
+// ▶ a
+// ```
+
+type uniqOpts struct{ Adjacent bool }
+
+func (*uniqOpts) SetDefaultOptions() {}
+
+func uniq(fm *Frame, opts uniqOpts, inputs Inputs) error {
+	out := fm.ValueOutput()
+	seen := vals.EmptyMap
+	var last interface{}
+	first := true
+	return iterateInputsUntilError(fm, inputs, func(v interface{}) error {
+		if opts.Adjacent {
+			if !first && vals.Equal(v, last) {
+				return nil
+			}
+			first, last = false, v
+		} else {
+			if _, ok := seen.Index(v); ok {
+				return nil
+			}
+			seen = seen.Assoc(v, true)
+		}
+		return out.Put(v)
+	})
+}
+
+// Calls f with each input until f returns an error or there is an interrupt,
+// and returns the error. The remaining inputs are consumed but ignored.
+func iterateInputsUntilError(fm *Frame, inputs Inputs, f func(interface{}) error) error {
+	var err error
+	inputs(func(v interface{}) {
+		if err != nil {
+			return
+		}
+		if fm.IsInterrupted() {
+			err = ErrInterrupted
+			return
+		}
+		err = f(v)
+	})
+	return err
+}
+
+// Calls a callback with the given arguments, and returns the single value it
+// outputs. The name argument describes the callback in errors.
+func callForSingleValue(fm *Frame, f Callable, name string, args ...interface{}) (interface{}, error) {
+	outputs, err := fm.CaptureOutput(func(fm *Frame) error {
+		return f.Call(fm, args, NoOpts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) != 1 {
+		return nil, errs.ArityMismatch{What: "outputs of the " + name,
+			ValidLow: 1, ValidHigh: 1, Actual: len(outputs)}
+	}
+	return outputs[0], nil
+}
+
+// Like callForSingleValue, but also requires the output to be a boolean.
+func callPredicate(fm *Frame, f Callable, name string, args ...interface{}) (bool, error) {
+	output, err := callForSingleValue(fm, f, name, args...)
+	if err != nil {
+		return false, err
+	}
+	b, ok := output.(bool)
+	if !ok {
+		return false, errs.BadValue{
+			What: "output of the " + name, Valid: "boolean", Actual: vals.Kind(output)}
+	}
+	return b, nil
+}
+
 //elvdoc:fn has-value
 //
 // ```elvish
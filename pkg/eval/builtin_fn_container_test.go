@@ -150,6 +150,114 @@ func TestDrop(t *testing.T) {
 	)
 }
 
+func TestMap(t *testing.T) {
+	Test(t,
+		That(`map {|x| * $x $x } [1 2 3]`).Puts(1, 4, 9),
+		That(`put a b | map {|x| put $x$x }`).Puts("aa", "bb"),
+		That(`map {|x| put $x $x } [a]`).Throws(errs.ArityMismatch{
+			What:     "outputs of the map callback",
+			ValidLow: 1, ValidHigh: 1, Actual: 2}),
+		That(`map {|x| fail bad } [a]`).Throws(FailError{"bad"}),
+		thatOutputErrorIsBubbled("map {|x| put $x } [a]"),
+	)
+}
+
+func TestFilter(t *testing.T) {
+	Test(t,
+		That(`filter {|x| > $x 2 } [1 5 2 4]`).Puts("5", "4"),
+		That(`put a bb c | filter {|x| == (count $x) 1 }`).Puts("a", "c"),
+		That(`filter {|x| put foo } [a]`).Throws(errs.BadValue{
+			What:  "output of the filter predicate",
+			Valid: "boolean", Actual: "string"}),
+		That(`filter {|x| } [a]`).Throws(errs.ArityMismatch{
+			What:     "outputs of the filter predicate",
+			ValidLow: 1, ValidHigh: 1, Actual: 0}),
+		thatOutputErrorIsBubbled("filter {|x| put $true } [a]"),
+	)
+}
+
+func TestReduceAndFold(t *testing.T) {
+	Test(t,
+		That(`reduce $'+~' [1 2 3 4]`).Puts(10),
+		That(`put a b c | reduce {|a b| put $b$a }`).Puts("cba"),
+		That(`reduce $'+~' [a]`).Puts("a"),
+		That(`reduce $'+~' []`).Throws(errs.ArityMismatch{
+			What: "inputs", ValidLow: 1, ValidHigh: -1, Actual: 0}),
+		That(`reduce {|a b| } [a b]`).Throws(errs.ArityMismatch{
+			What:     "outputs of the reduce callback",
+			ValidLow: 1, ValidHigh: 1, Actual: 0}),
+
+		That(`fold $'+~' 10 [1 2 3]`).Puts(16),
+		That(`put a b | fold {|acc x| put $acc,$x } ''`).Puts(",a,b"),
+		That(`fold $'+~' 10 []`).Puts("10"),
+		thatOutputErrorIsBubbled("fold $'+~' 10 [1]"),
+	)
+}
+
+func TestGroupByAndPartition(t *testing.T) {
+	Test(t,
+		That(`group-by {|s| count $s } [a bc d ef ghi]`).Puts(vals.MakeMap(
+			1, vals.MakeList("a", "d"), 2, vals.MakeList("bc", "ef"),
+			3, vals.MakeList("ghi"))),
+		That(`group-by {|s| } [a]`).Throws(errs.ArityMismatch{
+			What:     "outputs of the group-by callback",
+			ValidLow: 1, ValidHigh: 1, Actual: 0}),
+		That(`group-by {|s| put $s } []`).Puts(vals.EmptyMap),
+
+		That(`partition {|x| > $x 2 } [1 5 2 4]`).Puts(
+			vals.MakeList("5", "4"), vals.MakeList("1", "2")),
+		That(`put a | partition {|x| put $false }`).Puts(
+			vals.EmptyList, vals.MakeList("a")),
+		That(`partition {|x| put x } [a]`).Throws(errs.BadValue{
+			What:  "output of the partition predicate",
+			Valid: "boolean", Actual: "string"}),
+		thatOutputErrorIsBubbled("partition {|x| put $true } [a]"),
+	)
+}
+
+func TestZip(t *testing.T) {
+	Test(t,
+		That(`zip [a b c] [1 2 3]`).Puts(
+			vals.MakeList("a", "1"), vals.MakeList("b", "2"), vals.MakeList("c", "3")),
+		That(`zip [a b] [1 2 3] [x y z]`).Puts(
+			vals.MakeList("a", "1", "x"), vals.MakeList("b", "2", "y")),
+		That(`put [a b] ab | zip`).Puts(
+			vals.MakeList("a", "a"), vals.MakeList("b", "b")),
+		That(`zip [a] []`).DoesNothing(),
+		That(`zip`).DoesNothing(),
+		That(`zip [a] (num 1)`).Throws(ErrorWithMessage("cannot iterate number")),
+		thatOutputErrorIsBubbled("zip [a] [b]"),
+	)
+}
+
+func TestEnumerate(t *testing.T) {
+	Test(t,
+		That(`enumerate [a b]`).Puts(vals.MakeList(0, "a"), vals.MakeList(1, "b")),
+		That(`put a b | enumerate &start=1`).Puts(
+			vals.MakeList(1, "a"), vals.MakeList(2, "b")),
+		thatOutputErrorIsBubbled("enumerate [a]"),
+	)
+}
+
+func TestFlatten(t *testing.T) {
+	Test(t,
+		That(`flatten [a [b [c]] [] d]`).Puts("a", "b", "c", "d"),
+		That(`put [a [b [c]]] | flatten &depth=1`).Puts("a", vals.MakeList("b", vals.MakeList("c"))),
+		That(`put [a [b]] | flatten &depth=0`).Puts(vals.MakeList("a", vals.MakeList("b"))),
+		That(`flatten [[&k=[v]]]`).Puts(vals.MakeMap("k", vals.MakeList("v"))),
+		thatOutputErrorIsBubbled("flatten [a]"),
+	)
+}
+
+func TestUniq(t *testing.T) {
+	Test(t,
+		That(`uniq [a b a c b]`).Puts("a", "b", "c"),
+		That(`put [a] [a] (num 1) 1 (num 1) | uniq`).Puts(vals.MakeList("a"), 1, "1"),
+		That(`uniq &adjacent [a a b a]`).Puts("a", "b", "a"),
+		thatOutputErrorIsBubbled("uniq [a]"),
+	)
+}
+
 func TestHasKey(t *testing.T) {
 	Test(t,
 		That(`has-key [foo bar] 0`).Puts(true),
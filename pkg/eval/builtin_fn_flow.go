@@ -21,6 +21,7 @@ func init() {
 		"return":      returnFn,
 		"break":       breakFn,
 		"continue":    continueFn,
+		"defer":       deferFn,
 		// Iterations.
 		"each":  each,
 		"peach": peach,
@@ -381,3 +382,42 @@ func breakFn() error {
 func continueFn() error {
 	return Continue
 }
+
+//elvdoc:fn defer
+//
+// ```elvish
+// defer $callable
+// ```
+//
+// Schedules `$callable` to be called with no arguments when the innermost
+// enclosing function or lambda finishes. When used outside any function, for
+// example at the top level of a module or a piece of code passed to
+// [`eval`](#eval), `$callable` is called when that code finishes.
+//
+// Deferred callables are called in the reverse order of their registration,
+// and are always called, whether the function finishes normally, returns
+// early with [`return`](#return), or throws an exception. If any deferred
+// callables throw exceptions, they are combined with the exception that the
+// function finished with (if any) in the same way as exceptions from a
+// pipeline; the remaining deferred callables are still called.
+//
+// Note that the bodies of special commands like `if` and `for` are lambdas,
+// so a `defer` inside them is run when the body finishes.
+//
+// Example:
+//
+// ```elvish-transcript
+// ~> fn f {
+//      defer { echo cleanup 1 }
+//      defer { echo cleanup 2 }
+//      echo body
+//    }
+// ~> f
+// body
+// cleanup 2
+// cleanup 1
+// ```
+
+func deferFn(fm *Frame, f Callable) error {
+	return fm.addDefer(f)
+}
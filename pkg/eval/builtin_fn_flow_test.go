@@ -95,3 +95,46 @@ func TestReturn(t *testing.T) {
 		// Use of return inside fn is tested in TestFn
 	)
 }
+
+func TestDefer(t *testing.T) {
+	Test(t,
+		// Deferred functions are called in LIFO order
+		That("fn f { defer { put 1 }; defer { put 2 }; put 0 }", "f").
+			Puts("0", "2", "1"),
+		// Deferred functions are called on exceptions
+		That("fn f { defer { put d }; fail x; put bad }", "f").
+			Puts("d").Throws(FailError{"x"}, "fail x", "f"),
+		// Deferred functions are called on return
+		That("fn f { defer { put d }; return; put bad }", "f").Puts("d"),
+		// Lambdas and chunks
+		That("{ defer { put d }; put body }").Puts("body", "d"),
+		That("defer { put d }; put body").Puts("body", "d"),
+		// Bodies of special forms are lambdas
+		That("fn f { if $true { defer { put d } }; put after }", "f").
+			Puts("d", "after"),
+		// Deferred functions can access the local scope
+		That("fn f { var x = foo; defer { put $x }; set x = bar }", "f").
+			Puts("bar"),
+
+		// Exceptions from deferred functions
+		That("fn f { defer { fail d } }", "f").Throws(FailError{"d"}),
+		That("fn f { defer { put a }; defer { fail d } }", "f").
+			Puts("a").Throws(FailError{"d"}),
+		That("fn f { defer { fail d }; fail x }", "f").
+			Throws(ErrorWithType(PipelineError{})),
+		That("fn f { defer { fail d }; fail x }",
+			"put ?(f)[reason][exceptions][(num 0)][reason][content]").
+			Puts("x"),
+		That("fn f { defer { fail d }; fail x }",
+			"put ?(f)[reason][exceptions][(num 1)][reason][content]").
+			Puts("d"),
+		// Flow control exceptions are dropped when deferred functions throw
+		That("fn f { defer { fail d }; return }", "f").Throws(FailError{"d"}),
+		That("for x [a] { defer { fail d }; break }").Throws(FailError{"d"}),
+		That("for x [a] { defer { fail d }; continue }").Throws(FailError{"d"}),
+		// Non-exception errors from deferred functions
+		That("fn f { defer $e:false~ }", "f").Throws(AnyError),
+
+		That("defer foo").Throws(AnyError),
+	)
+}
@@ -2,11 +2,14 @@ package eval
 
 import (
 	"bufio"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/diag"
 	"src.elv.sh/pkg/eval/errs"
@@ -47,11 +50,13 @@ func init() {
 		"slurp":           slurp,
 		"from-lines":      fromLines,
 		"from-json":       fromJSON,
+		"from-csv":        fromCSV,
 		"from-terminated": fromTerminated,
 
 		// Value to bytes
 		"to-lines":      toLines,
 		"to-json":       toJSON,
+		"to-csv":        toCSV,
 		"to-terminated": toTerminated,
 	})
 }
@@ -889,3 +894,281 @@ func toJSON(fm *Frame, inputs Inputs) error {
 	})
 	return errEncode
 }
+
+//elvdoc:fn from-csv
+//
+// ```elvish
+// from-csv &delimiter=',' &header=$true &comment='' &lazy-quotes=$false &trim-leading-space=$false
+// ```
+//
+// Takes bytes stdin, parses it as CSV, and puts one value on structured stdout
+// for each row. Rows are output as soon as they are read, so large inputs can
+// be processed in a streaming fashion.
+//
+// If `&header` is true (the default), the first row is used as the header, and
+// each subsequent row is output as a map from the header fields to the fields
+// of the row. Otherwise, each row is output as a list of fields. Fields are
+// always strings.
+//
+// The `&delimiter` option specifies the field delimiter, which must be a
+// single character other than a double quote or newline; use `"\t"` to parse
+// TSV. If `&comment` is not empty, lines beginning with it are ignored. If
+// `&lazy-quotes` is true, a quote may appear in an unquoted field and a
+// non-doubled quote may appear in a quoted field. If `&trim-leading-space` is
+// true, leading whitespace in a field is ignored.
+//
+// Examples:
+//
+// ```elvish-transcript
+// ~> echo "name,age\nalice,20\nbob,30" | from-csv
+// ▶ [&name=alice &age=20]
+// ▶ [&name=bob &age=30]
+// ~> echo "alice\t20\nbob\t30" | from-csv &header=$false &delimiter="\t"
+// ▶ [alice 20]
+// ▶ [bob 30]
+// ~> echo "name,age\nalice,20\nbob,30" | from-csv | each {|r| put $r[name] }
+// ▶ alice
+// ▶ bob
+// ```
+//
+// @cf to-csv from-json
+
+type fromCSVOpts struct {
+	Delimiter        string
+	Header           bool
+	Comment          string
+	LazyQuotes       bool
+	TrimLeadingSpace bool
+}
+
+func (o *fromCSVOpts) SetDefaultOptions() {
+	o.Delimiter = ","
+	o.Header = true
+}
+
+func fromCSV(fm *Frame, opts fromCSVOpts) error {
+	delimiter, err := csvRune("delimiter", opts.Delimiter)
+	if err != nil {
+		return err
+	}
+	var comment rune
+	if opts.Comment != "" {
+		comment, err = csvRune("comment", opts.Comment)
+		if err != nil {
+			return err
+		}
+	}
+
+	r := csv.NewReader(fm.InputFile())
+	r.Comma = delimiter
+	r.Comment = comment
+	r.LazyQuotes = opts.LazyQuotes
+	r.TrimLeadingSpace = opts.TrimLeadingSpace
+	if !opts.Header {
+		r.FieldsPerRecord = -1
+	}
+
+	out := fm.ValueOutput()
+	var header []string
+	for {
+		record, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var v interface{}
+		switch {
+		case !opts.Header:
+			list := vals.EmptyList
+			for _, field := range record {
+				list = list.Cons(field)
+			}
+			v = list
+		case header == nil:
+			header = record
+			continue
+		default:
+			m := vals.EmptyMap
+			for i, field := range record {
+				m = m.Assoc(header[i], field)
+			}
+			v = m
+		}
+		err = out.Put(v)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//elvdoc:fn to-csv
+//
+// ```elvish
+// to-csv &delimiter=',' &header=$true &columns=$nil &quote-all=$false &use-crlf=$false $input?
+// ```
+//
+// Takes structured stdin, and writes each value as a CSV row to bytes stdout.
+// Each value must be either a list, whose elements are written as the fields
+// of the row, or a map, whose values are written in the order of the columns.
+// Fields are converted to strings as with [to-string](#to-string).
+//
+// The columns are specified by the `&columns` option. If it is not given, the
+// columns are the sorted keys of the first map input. Fields that are missing
+// from a map are written as empty strings.
+//
+// If `&header` is true (the default), the columns are written as the first
+// row, either before the first row if `&columns` is given, or before the
+// first map input otherwise.
+//
+// The `&delimiter` option specifies the field delimiter, and has the same
+// constraints as in [from-csv](#from-csv). Fields are quoted when necessary,
+// or always if `&quote-all` is true. Rows are terminated by `"\r\n"` if
+// `&use-crlf` is true, and `"\n"` otherwise.
+//
+// Examples:
+//
+// ```elvish-transcript
+// ~> put [&name=alice &age=20] [&name=bob] | to-csv
+// age,name
+// 20,alice
+// ,bob
+// ~> put [&name=alice &age=20] | to-csv &columns=[name age] &quote-all
+// "name","age"
+// "alice","20"
+// ~> to-csv &delimiter="\t" [[a 'b c'] [d e]]
+// a	b c
+// d	e
+// ```
+//
+// @cf from-csv to-json
+
+type toCSVOpts struct {
+	Delimiter string
+	Header    bool
+	Columns   vals.List
+	QuoteAll  bool
+	UseCRLF   bool `name:"use-crlf"`
+}
+
+func (o *toCSVOpts) SetDefaultOptions() {
+	o.Delimiter = ","
+	o.Header = true
+}
+
+func toCSV(fm *Frame, opts toCSVOpts, inputs Inputs) error {
+	delimiter, err := csvRune("delimiter", opts.Delimiter)
+	if err != nil {
+		return err
+	}
+	w := &csvWriter{bufio.NewWriter(fm.ByteOutput()), delimiter, opts.QuoteAll, "\n"}
+	if opts.UseCRLF {
+		w.lineEnding = "\r\n"
+	}
+
+	var columns []string
+	if opts.Columns != nil {
+		for it := opts.Columns.Iterator(); it.HasElem(); it.Next() {
+			columns = append(columns, vals.ToString(it.Elem()))
+		}
+	}
+	wroteHeader := false
+	writeHeader := func() error {
+		if !opts.Header || wroteHeader {
+			return nil
+		}
+		wroteHeader = true
+		return w.write(columns)
+	}
+	if columns != nil {
+		err = writeHeader()
+	}
+
+	inputs(func(v interface{}) {
+		if err != nil {
+			return
+		}
+		var fields []string
+		switch v := v.(type) {
+		case vals.List:
+			for it := v.Iterator(); it.HasElem(); it.Next() {
+				fields = append(fields, vals.ToString(it.Elem()))
+			}
+		case vals.Map:
+			if columns == nil {
+				for it := v.Iterator(); it.HasElem(); it.Next() {
+					k, _ := it.Elem()
+					columns = append(columns, vals.ToString(k))
+				}
+				sort.Strings(columns)
+			}
+			if err = writeHeader(); err != nil {
+				return
+			}
+			for _, column := range columns {
+				if field, ok := v.Index(column); ok {
+					fields = append(fields, vals.ToString(field))
+				} else {
+					fields = append(fields, "")
+				}
+			}
+		default:
+			err = errs.BadValue{What: "input",
+				Valid: "list or map", Actual: vals.Kind(v)}
+			return
+		}
+		err = w.write(fields)
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Checks that s is a single character that can be used as the delimiter or
+// comment character of CSV, and returns it.
+func csvRune(what, s string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == utf8.RuneError ||
+		r == '"' || r == '\r' || r == '\n' {
+		return 0, errs.BadValue{What: what,
+			Valid:  "a single character other than quote or newline",
+			Actual: parse.Quote(s)}
+	}
+	return r, nil
+}
+
+// Like csv.Writer, but supports quoting all fields.
+type csvWriter struct {
+	*bufio.Writer
+	delimiter  rune
+	quoteAll   bool
+	lineEnding string
+}
+
+func (w *csvWriter) write(fields []string) error {
+	for i, field := range fields {
+		if i > 0 {
+			w.WriteRune(w.delimiter)
+		}
+		if !w.quoteAll && !w.needsQuotes(field) {
+			w.WriteString(field)
+			continue
+		}
+		w.WriteByte('"')
+		w.WriteString(strings.ReplaceAll(field, `"`, `""`))
+		w.WriteByte('"')
+	}
+	_, err := w.WriteString(w.lineEnding)
+	return err
+}
+
+func (w *csvWriter) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	return strings.ContainsRune(field, w.delimiter) ||
+		strings.ContainsAny(field, "\"\r\n") ||
+		field[0] == ' ' || field[0] == '\t'
+}
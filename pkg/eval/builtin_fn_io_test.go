@@ -137,6 +137,57 @@ func TestFromJson(t *testing.T) {
 	)
 }
 
+func TestFromCSV(t *testing.T) {
+	Test(t,
+		That(`echo "name,age\nalice,20\nbob,30" | from-csv`).Puts(
+			vals.MakeMap("name", "alice", "age", "20"),
+			vals.MakeMap("name", "bob", "age", "30")),
+		That(`echo "alice\t20\nbob\t30" | from-csv &header=$false &delimiter="\t"`).
+			Puts(vals.MakeList("alice", "20"), vals.MakeList("bob", "30")),
+		// Quoted fields
+		That(`echo "a,b\n\"x,\"\"y\"\"\",\"l1\nl2\"" | from-csv`).Puts(
+			vals.MakeMap("a", `x,"y"`, "b", "l1\nl2")),
+		// Rows may have different lengths without a header
+		That(`echo "a\nb,c" | from-csv &header=$false`).
+			Puts(vals.MakeList("a"), vals.MakeList("b", "c")),
+		That(`echo "a,b\nc" | from-csv`).Throws(AnyError),
+		That(`echo "# comment\na, b" | from-csv &header=$false &comment='#' &trim-leading-space`).
+			Puts(vals.MakeList("a", "b")),
+		That(`echo 'a"b' | from-csv &header=$false`).Throws(AnyError),
+		That(`echo 'a"b' | from-csv &header=$false &lazy-quotes`).
+			Puts(vals.MakeList(`a"b`)),
+		That(`echo a | from-csv &delimiter=ab`).Throws(
+			errs.BadValue{What: "delimiter",
+				Valid:  "a single character other than quote or newline",
+				Actual: "ab"}),
+		That(`echo a | from-csv &comment="\n"`).Throws(ErrorWithType(errs.BadValue{})),
+		thatOutputErrorIsBubbled(`echo "a\nb" | from-csv`),
+	)
+}
+
+func TestToCSV(t *testing.T) {
+	Test(t,
+		That(`put [&name=alice &age=(num 20)] [&name=bob] | to-csv`).
+			Prints("age,name\n20,alice\n,bob\n"),
+		That(`put [&name=alice &age=20 &x=y] | to-csv &columns=[name age] &quote-all`).
+			Prints(`"name","age"`+"\n"+`"alice","20"`+"\n"),
+		That(`to-csv &delimiter="\t" [[a 'b c'] [d e]]`).Prints("a\tb c\nd\te\n"),
+		That(`to-csv &columns=[x y] [[a b]]`).Prints("x,y\na,b\n"),
+		That(`to-csv &header=$false [[&x=a] [b c]]`).Prints("a\nb,c\n"),
+		That(`to-csv &use-crlf [[a b]]`).Prints("a,b\r\n"),
+		// Quoting
+		That(`to-csv [['a,b' 'x"y' "l1\nl2" ' s' '']]`).
+			Prints(`"a,b","x""y","l1`+"\n"+`l2"," s",`+"\n"),
+		// Round trip
+		That(`put [a 'b,"c'] | to-csv | from-csv &header=$false`).
+			Puts(vals.MakeList("a", `b,"c`)),
+		That(`to-csv [foo]`).Throws(
+			errs.BadValue{What: "input", Valid: "list or map", Actual: "string"}),
+		That(`to-csv &delimiter='"' [[a]]`).Throws(ErrorWithType(errs.BadValue{})),
+		thatOutputErrorIsBubbled("to-csv [[foo]]"),
+	)
+}
+
 func TestToLines(t *testing.T) {
 	Test(t,
 		That(`put "l\norem" ipsum | to-lines`).Prints("l\norem\nipsum\n"),
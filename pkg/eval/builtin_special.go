@@ -22,8 +22,10 @@ import (
 	"strings"
 
 	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/eval/vars"
+	"src.elv.sh/pkg/glob"
 	"src.elv.sh/pkg/parse"
 	"src.elv.sh/pkg/parse/cmpd"
 )
@@ -54,10 +56,11 @@ func init() {
 		"or":       compileOr,
 		"coalesce": compileCoalesce,
 
-		"if":    compileIf,
-		"while": compileWhile,
-		"for":   compileFor,
-		"try":   compileTry,
+		"if":     compileIf,
+		"switch": compileSwitch,
+		"while":  compileWhile,
+		"for":    compileFor,
+		"try":    compileTry,
 
 		"pragma": compilePragma,
 	}
@@ -528,6 +531,182 @@ func (op *ifOp) exec(fm *Frame) Exception {
 	return nil
 }
 
+// SwitchForm = 'switch' { MapPair } Compound { SwitchClause } [ 'else' Lambda ]
+// SwitchClause = ( 'case' | 'glob' | 'pred' ) Compound Lambda
+func compileSwitch(cp *compiler, fn *parse.Form) effectOp {
+	var exhaustiveOp valuesOp
+	for _, opt := range fn.Opts {
+		name := stringLiteralOrError(cp, opt.Key, "option name")
+		if name != "exhaustive" {
+			cp.errorpf(opt.Key, "unknown option %s", parse.Quote(name))
+			continue
+		}
+		if opt.Value == nil {
+			exhaustiveOp = literalValues(opt, true)
+		} else {
+			exhaustiveOp = cp.compoundOp(opt.Value)
+		}
+	}
+
+	args := cp.walkArgs(fn)
+	valueNode := args.next()
+	var clauses []switchClause
+clauses:
+	for {
+		var kind switchClauseKind
+		switch {
+		case args.nextIs("case"):
+			kind = switchCase
+		case args.nextIs("glob"):
+			kind = switchGlob
+		case args.nextIs("pred"):
+			kind = switchPred
+		default:
+			break clauses
+		}
+		patternNode := args.next()
+		bodyNode := args.nextMustLambda("switch clause body")
+		clauses = append(clauses, switchClause{
+			kind, cp.compoundOp(patternNode), cp.primaryOp(bodyNode)})
+	}
+	elseNode := args.nextMustLambdaIfAfter("else")
+	args.mustEnd()
+
+	var elseOp valuesOp
+	if elseNode != nil {
+		elseOp = cp.primaryOp(elseNode)
+	}
+	return &switchOp{fn.Range(), cp.compoundOp(valueNode), clauses, elseOp, exhaustiveOp}
+}
+
+type switchClauseKind int
+
+const (
+	switchCase switchClauseKind = iota
+	switchGlob
+	switchPred
+)
+
+type switchClause struct {
+	kind      switchClauseKind
+	patternOp valuesOp
+	bodyOp    valuesOp
+}
+
+type switchOp struct {
+	diag.Ranging
+	valueOp      valuesOp
+	clauses      []switchClause
+	elseOp       valuesOp
+	exhaustiveOp valuesOp
+}
+
+// NoMatchingClause is thrown by the "switch" special form when it is
+// exhaustive and none of its clauses match the value.
+type NoMatchingClause struct {
+	Value interface{}
+}
+
+// Error implements the error interface.
+func (e NoMatchingClause) Error() string {
+	return "no clause matches value " + vals.Repr(e.Value, vals.NoPretty)
+}
+
+func (op *switchOp) exec(fm *Frame) Exception {
+	value, exc := evalForValue(fm, op.valueOp, "value being switched on")
+	if exc != nil {
+		return exc
+	}
+	exhaustive := false
+	if op.exhaustiveOp != nil {
+		v, exc := evalForValue(fm, op.exhaustiveOp, "value of &exhaustive")
+		if exc != nil {
+			return exc
+		}
+		exhaustive = vals.Bool(v)
+	}
+
+	for _, clause := range op.clauses {
+		matched, exc := clause.match(fm, value)
+		if exc != nil {
+			return exc
+		}
+		if matched {
+			body := execLambdaOp(fm, clause.bodyOp)
+			return fm.errorp(op, body.Call(fm.fork("switch body"), NoArgs, NoOpts))
+		}
+	}
+	if op.elseOp != nil {
+		elseFn := execLambdaOp(fm, op.elseOp)
+		return fm.errorp(op, elseFn.Call(fm.fork("switch else"), NoArgs, NoOpts))
+	}
+	if exhaustive {
+		return fm.errorp(op.valueOp, NoMatchingClause{value})
+	}
+	return nil
+}
+
+// Returns whether the clause matches the value. A "case" clause matches if any
+// of the values its pattern evaluates to is equal to the value; a "glob" clause
+// matches if the value is a string and any of the patterns matches it; a
+// "pred" clause matches if calling the predicate with the value outputs only
+// booleanly true values.
+func (clause switchClause) match(fm *Frame, value interface{}) (bool, Exception) {
+	switch clause.kind {
+	case switchCase:
+		patterns, exc := clause.patternOp.exec(fm.fork("switch case"))
+		if exc != nil {
+			return false, exc
+		}
+		for _, pattern := range patterns {
+			if vals.Equal(pattern, value) {
+				return true, nil
+			}
+		}
+	case switchGlob:
+		patterns, exc := clause.patternOp.exec(fm.fork("switch glob"))
+		if exc != nil {
+			return false, exc
+		}
+		s, ok := value.(string)
+		if !ok {
+			return false, nil
+		}
+		for _, pattern := range patterns {
+			p, ok := pattern.(string)
+			if !ok {
+				return false, fm.errorp(clause.patternOp, errs.BadValue{
+					What:   "glob pattern",
+					Valid:  "string",
+					Actual: vals.Kind(pattern)})
+			}
+			if glob.Parse(p).Match(s) {
+				return true, nil
+			}
+		}
+	case switchPred:
+		pred, exc := evalForValue(fm.fork("switch pred"), clause.patternOp, "predicate")
+		if exc != nil {
+			return false, exc
+		}
+		predFn, ok := pred.(Callable)
+		if !ok {
+			return false, fm.errorp(clause.patternOp, errs.BadValue{
+				What:   "predicate",
+				Valid:  "callable",
+				Actual: vals.Kind(pred)})
+		}
+		outputs, err := fm.CaptureOutput(func(fm *Frame) error {
+			return predFn.Call(fm, []interface{}{value}, NoOpts)
+		})
+		if err != nil {
+			return false, fm.errorp(clause.patternOp, err)
+		}
+		return allTrue(outputs), nil
+	}
+	return false, nil
+}
+
 func compileWhile(cp *compiler, fn *parse.Form) effectOp {
 	args := cp.walkArgs(fn)
 	condNode := args.next()
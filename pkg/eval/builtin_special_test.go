@@ -197,6 +197,72 @@ func TestIf(t *testing.T) {
 	)
 }
 
+func TestSwitch(t *testing.T) {
+	Test(t,
+		That("switch foo case foo { put matched }").Puts("matched"),
+		That("switch foo case bar { put bad } case foo { put good }").
+			Puts("good"),
+		// No fallthrough
+		That("switch foo case foo { put 1 } case foo { put 2 }").Puts("1"),
+		// Case clause matching multiple values
+		That("switch b case (put a b) { put matched }").Puts("matched"),
+		// Case clause matching by equality, not string conversion
+		That("switch (num 1) case 1 { put bad } case (num 1) { put good }").
+			Puts("good"),
+		That("switch [a b] case [a b] { put matched }").Puts("matched"),
+
+		// Glob clause
+		That("switch foobar glob 'foo*' { put matched }").Puts("matched"),
+		That("switch foobar glob 'b*' { put bad } else { put good }").
+			Puts("good"),
+		That("switch a/b glob 'a*' { put matched }").Puts("matched"),
+		That("switch [a] glob '*' { put bad } else { put good }").
+			Puts("good"),
+		That("switch foo glob [a] { }").Throws(
+			errs.BadValue{What: "glob pattern", Valid: "string", Actual: "list"},
+			"[a]"),
+
+		// Pred clause
+		That("switch (num 10) pred {|x| < $x 5 } { put small } pred {|x| < $x 50 } { put medium }").
+			Puts("medium"),
+		That("switch foo pred {|x| put $true $false } { put bad } else { put good }").
+			Puts("good"),
+		That("switch foo pred foo { }").Throws(
+			errs.BadValue{What: "predicate", Valid: "callable", Actual: "string"},
+			"foo"),
+		That("switch foo pred {|x| fail bad } { }").Throws(FailError{"bad"}),
+
+		// Else clause
+		That("switch foo case bar { put bad } else { put good }").Puts("good"),
+		That("switch foo else { put good }").Puts("good"),
+		// No clause matching and no &exhaustive
+		That("switch foo case bar { put bad }").DoesNothing(),
+		// &exhaustive
+		That("switch &exhaustive foo case bar { }").
+			Throws(NoMatchingClause{"foo"}, "foo"),
+		That("switch &exhaustive=$false foo case bar { }").DoesNothing(),
+		That("switch &exhaustive foo case bar { } else { put good }").
+			Puts("good"),
+
+		// Body is evaluated in the enclosing scope
+		That("var x = 0; switch foo case foo { set x = 1 }; put $x").Puts("1"),
+		// Exception in value or pattern
+		That("switch (fail x) case foo { }").Throws(FailError{"x"}, "fail x"),
+		That("switch foo case (fail x) { }").Throws(FailError{"x"}, "fail x"),
+		That("switch (put) case foo { }").Throws(
+			errs.ArityMismatch{What: "value being switched on",
+				ValidLow: 1, ValidHigh: 1, Actual: 0},
+			"(put)"),
+
+		// Wrong syntax
+		That("switch").DoesNotCompile(),
+		That("switch foo case foo").DoesNotCompile(),
+		That("switch foo case foo bar").DoesNotCompile(),
+		That("switch foo bad foo { }").DoesNotCompile(),
+		That("switch &bad foo case foo { }").DoesNotCompile(),
+	)
+}
+
 func TestTry(t *testing.T) {
 	Test(t,
 		That("try { nop } except { put bad } else { put good }").Puts("good"),
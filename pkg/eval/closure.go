@@ -22,11 +22,16 @@ type closure struct {
 	RestArg     int
 	OptNames    []string
 	OptDefaults []interface{}
-	Op          effectOp
-	NewLocal    []staticVarInfo
-	Captured    *Ns
-	SrcMeta     parse.Source
-	DefRange    diag.Ranging
+	// Type annotations of arguments and options, in the same order as
+	// ArgNames and OptNames. Either slice is nil if none of the arguments or
+	// options are annotated, and elements are nil for those not annotated.
+	ArgTypes []*typeAnnotation
+	OptTypes []*typeAnnotation
+	Op       effectOp
+	NewLocal []staticVarInfo
+	Captured *Ns
+	SrcMeta  parse.Source
+	DefRange diag.Ranging
 }
 
 var _ Callable = &closure{}
@@ -86,6 +91,11 @@ func (c *closure) Call(fm *Frame, args []interface{}, opts map[string]interface{
 		sort.Strings(unsupported)
 		return UnsupportedOptionsError{unsupported}
 	}
+	// Check and convert arguments and options with type annotations.
+	args, opts, err := c.checkTypes(fm, args, opts)
+	if err != nil {
+		return err
+	}
 
 	// This Frame is dedicated to the current form, so we can modify it in place.
 
@@ -137,7 +147,52 @@ func (c *closure) Call(fm *Frame, args []interface{}, opts map[string]interface{
 
 	fm.local = local
 	fm.srcMeta = c.SrcMeta
-	return c.Op.exec(fm)
+	fm.defers = &deferList{}
+	return fm.runDefers(c.Op.exec(fm), c.Op.(diag.Ranger))
+}
+
+func (c *closure) checkTypes(fm *Frame, args []interface{}, opts map[string]interface{}) ([]interface{}, map[string]interface{}, error) {
+	if c.ArgTypes != nil {
+		checked := make([]interface{}, len(args))
+		restOff := len(args) - len(c.ArgNames)
+		for i, arg := range args {
+			// Index of the argument in c.ArgNames.
+			j := i
+			if c.RestArg != -1 && i >= c.RestArg {
+				j = i - restOff
+				if j < c.RestArg {
+					j = c.RestArg
+				}
+			}
+			if t := c.ArgTypes[j]; t != nil {
+				v, err := t.check(fm, "argument "+c.ArgNames[j], arg)
+				if err != nil {
+					return nil, nil, err
+				}
+				arg = v
+			}
+			checked[i] = arg
+		}
+		args = checked
+	}
+	if c.OptTypes != nil && len(opts) > 0 {
+		checked := make(map[string]interface{}, len(opts))
+		for name, v := range opts {
+			checked[name] = v
+		}
+		for i, name := range c.OptNames {
+			v, ok := opts[name]
+			if t := c.OptTypes[i]; ok && t != nil {
+				converted, err := t.check(fm, "option "+name, v)
+				if err != nil {
+					return nil, nil, err
+				}
+				checked[name] = converted
+			}
+		}
+		opts = checked
+	}
+	return args, opts, nil
 }
 
 // MakeVarFromName creates a Var with a suitable type constraint inferred from
@@ -183,6 +238,14 @@ func (cf closureFields) OptDefaults() vals.List {
 	return vals.MakeList(cf.c.OptDefaults...)
 }
 
+func (cf closureFields) ArgTypes() vals.List {
+	return listOfTypes(cf.c.ArgTypes, len(cf.c.ArgNames))
+}
+
+func (cf closureFields) OptTypes() vals.List {
+	return listOfTypes(cf.c.OptTypes, len(cf.c.OptNames))
+}
+
 func (cf closureFields) Body() string {
 	r := cf.c.Op.(diag.Ranger).Range()
 	return cf.c.SrcMeta.Code[r.From:r.To]
@@ -192,6 +255,18 @@ func (cf closureFields) Def() string {
 	return cf.c.SrcMeta.Code[cf.c.DefRange.From:cf.c.DefRange.To]
 }
 
+func listOfTypes(ts []*typeAnnotation, n int) vals.List {
+	list := vals.EmptyList
+	for i := 0; i < n; i++ {
+		if ts == nil {
+			list = list.Cons(nil)
+		} else {
+			list = list.Cons(ts[i].value())
+		}
+	}
+	return list
+}
+
 func listOfStrings(ss []string) vals.List {
 	list := vals.EmptyList
 	for _, s := range ss {
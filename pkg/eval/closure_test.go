@@ -1,10 +1,12 @@
 package eval_test
 
 import (
+	"math/big"
 	"testing"
 
 	"src.elv.sh/pkg/eval"
 	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/tt"
 
 	. "src.elv.sh/pkg/eval/evaltest"
@@ -48,6 +50,59 @@ func TestClosureAsValue(t *testing.T) {
 	)
 }
 
+func TestClosureTypeAnnotations(t *testing.T) {
+	Test(t,
+		// Kinds
+		That("fn f {|x:string l:list| put $x $l }", "f foo [bar]").
+			Puts("foo", vals.MakeList("bar")),
+		That("fn f {|x:string| }", "f [foo]").Throws(
+			errs.BadValue{What: "argument x", Valid: "string", Actual: "list"},
+			"f [foo]"),
+		That("fn f {|x:any| put $x }", "f [foo]").Puts(vals.MakeList("foo")),
+		// Number types convert strings
+		That("fn f {|x:number y:int z:float64| put $x $y $z }", "f 1/2 10 1.5").
+			Puts(big.NewRat(1, 2), 10, 1.5),
+		That("fn f {|x:rat| put $x }", "f 3").Puts(3),
+		That("fn f {|x:int| }", "f 1.5").Throws(
+			errs.BadValue{What: "argument x", Valid: "int", Actual: "float64"}),
+		That("fn f {|x:number| }", "f foo").Throws(
+			errs.BadValue{What: "argument x", Valid: "number", Actual: "string"}),
+		// Rest argument
+		That("fn f {|a @r:int b| put $a $r $b }", "f x 1 2 y").
+			Puts("x", vals.MakeList(1, 2), "y"),
+		That("fn f {|a @r:int b:int| put $a $r $b }", "f x 1").
+			Puts("x", vals.EmptyList, 1),
+		That("fn f {|@r:int| }", "f 1 x").Throws(
+			errs.BadValue{What: "argument r", Valid: "int", Actual: "string"}),
+		// Options; default values are not checked
+		That("fn f {|&n:int=$nil| put $n }", "f", "f &n=2").Puts(nil, 2),
+		That("fn f {|&n:int=$nil| }", "f &n=x").Throws(
+			errs.BadValue{What: "option n", Valid: "int", Actual: "string"},
+			"f &n=x"),
+		// Predicates
+		That("fn pos {|x| > $x 0 }", "fn f {|x:$pos~ &y:$pos~=1| put $x $y }",
+			"f 2 &y=3").Puts("2", "3"),
+		That("fn pos {|x| > $x 0 }", "fn f {|x:$pos~| }", "f -1").Throws(
+			errs.BadValue{What: "argument x", Valid: "value satisfying $pos~", Actual: "-1"},
+			"f -1"),
+		That("fn f {|x:$put~| }", "f foo").Throws(
+			errs.BadValue{What: "output of the type predicate $put~",
+				Valid: "boolean", Actual: "string"}),
+		That("var p = foo", "fn f {|x:$p| }").Throws(
+			errs.BadValue{What: "type predicate", Valid: "callable", Actual: "string"}),
+		// Compilation errors
+		That("fn f {|x:foo| }").DoesNotCompile(),
+		That("fn f {|x:| }").DoesNotCompile(),
+		// Introspection
+		That("var f = {|a:int b @c:$put~ &d:string=x &e=y| }",
+			"put $f[arg-types][0 1]", "eq $f[arg-types][2] $put~",
+			"put $f[opt-types]").
+			Puts("int", nil, true, vals.MakeList("string", nil)),
+		That("put {|a &b=c| }[arg-types opt-types]").
+			Puts(vals.MakeList(nil), vals.MakeList(nil)),
+	)
+}
+
 func TestUnsupportedOptionsError(t *testing.T) {
 	tt.Test(t, tt.Fn("Error", error.Error), tt.Table{
 		tt.Args(eval.UnsupportedOptionsError{[]string{"sole-opt"}}).Rets(
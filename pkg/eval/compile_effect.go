@@ -71,11 +71,16 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 		return fm.errorp(op, ErrInterrupted)
 	}
 
+	j := fm.job
+	ownJob := false
 	if op.bg {
 		fm = fm.fork("background job" + op.source)
 		fm.intCh = nil
 		fm.background = true
 		fm.Evaler.addNumBgJobs(1)
+		j, ownJob = newJob(fm.Evaler, op.source, true), true
+	} else if j == nil {
+		j, ownJob = newJob(fm.Evaler, op.source, false), true
 	}
 
 	nforms := len(op.subops)
@@ -89,6 +94,7 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 	// For each form, create a dedicated evalCtx and run asynchronously
 	for i, formOp := range op.subops {
 		newFm := fm.fork("[form op]")
+		newFm.job = j
 		inputIsPipe := i > 0
 		outputIsPipe := i < nforms-1
 		if inputIsPipe {
@@ -139,21 +145,16 @@ func (op *pipelineOp) exec(fm *Frame) Exception {
 		go func() {
 			wg.Wait()
 			fm.Evaler.addNumBgJobs(-1)
-			if notify := fm.Evaler.BgJobNotify; notify != nil {
-				msg := "job " + op.source + " finished"
-				err := MakePipelineError(excs)
-				if err != nil {
-					msg += ", errors = " + err.Error()
-				}
-				if fm.Evaler.getNotifyBgJobSuccess() || err != nil {
-					notify(msg)
-				}
-			}
+			j.release(MakePipelineError(excs))
 		}()
 		return nil
 	}
 	wg.Wait()
-	return fm.errorp(op, MakePipelineError(excs))
+	err := MakePipelineError(excs)
+	if ownJob {
+		j.release(err)
+	}
+	return fm.errorp(op, err)
 }
 
 func isReaderGone(exc Exception) bool {
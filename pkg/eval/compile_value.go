@@ -253,7 +253,12 @@ func (op *indexingOp) exec(fm *Frame) ([]interface{}, Exception) {
 
 func (cp *compiler) primaryOp(n *parse.Primary) valuesOp {
 	switch n.Type {
-	case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted:
+	case parse.Bareword, parse.SingleQuoted, parse.StringSegment:
+		return literalValues(n, n.Value)
+	case parse.DoubleQuoted, parse.Heredoc:
+		if len(n.Segments) > 0 {
+			return interpolationOp{n.Range(), cp.primaryOps(n.Segments)}
+		}
 		return literalValues(n, n.Value)
 	case parse.Variable:
 		sigil, qname := SplitSigil(n.Value)
@@ -320,6 +325,27 @@ func (op variableOp) exec(fm *Frame) ([]interface{}, Exception) {
 	return []interface{}{value}, nil
 }
 
+type interpolationOp struct {
+	diag.Ranging
+	subops []valuesOp
+}
+
+func (op interpolationOp) exec(fm *Frame) ([]interface{}, Exception) {
+	var sb strings.Builder
+	for _, subop := range op.subops {
+		vs, exc := subop.exec(fm)
+		if exc != nil {
+			return nil, exc
+		}
+		if len(vs) != 1 {
+			return nil, fm.errorp(subop, errs.ArityMismatch{
+				What: "interpolated value", ValidLow: 1, ValidHigh: 1, Actual: len(vs)})
+		}
+		sb.WriteString(vals.ToString(vs[0]))
+	}
+	return []interface{}{sb.String()}, nil
+}
+
 type listOp struct {
 	diag.Ranging
 	subops []valuesOp
@@ -376,12 +402,14 @@ func (cp *compiler) lambda(n *parse.Primary) valuesOp {
 		restArg       int = -1
 		optNames      []string
 		optDefaultOps []valuesOp
+		argTypeOps    []*typeAnnotationOp
+		optTypeOps    []*typeAnnotationOp
 	)
 	if len(n.Elements) > 0 {
 		// Argument list.
 		argNames = make([]string, len(n.Elements))
 		for i, arg := range n.Elements {
-			ref := stringLiteralOrError(cp, arg, "argument name")
+			ref, typeOp := cp.signatureElem(arg, "argument name")
 			sigil, qname := SplitSigil(ref)
 			name, rest := SplitQName(qname)
 			if rest != "" {
@@ -397,13 +425,19 @@ func (cp *compiler) lambda(n *parse.Primary) valuesOp {
 				restArg = i
 			}
 			argNames[i] = name
+			if typeOp != nil {
+				if argTypeOps == nil {
+					argTypeOps = make([]*typeAnnotationOp, len(n.Elements))
+				}
+				argTypeOps[i] = typeOp
+			}
 		}
 	}
 	if len(n.MapPairs) > 0 {
 		optNames = make([]string, len(n.MapPairs))
 		optDefaultOps = make([]valuesOp, len(n.MapPairs))
 		for i, opt := range n.MapPairs {
-			qname := stringLiteralOrError(cp, opt.Key, "option name")
+			qname, typeOp := cp.signatureElem(opt.Key, "option name")
 			name, rest := SplitQName(qname)
 			if rest != "" {
 				cp.errorpf(opt.Key, "option name must be unqualified")
@@ -412,6 +446,12 @@ func (cp *compiler) lambda(n *parse.Primary) valuesOp {
 				cp.errorpf(opt.Key, "option name must not be empty")
 			}
 			optNames[i] = name
+			if typeOp != nil {
+				if optTypeOps == nil {
+					optTypeOps = make([]*typeAnnotationOp, len(n.MapPairs))
+				}
+				optTypeOps[i] = typeOp
+			}
 			if opt.Value == nil {
 				cp.errorpf(opt.Key, "option must have default value")
 			} else {
@@ -432,7 +472,7 @@ func (cp *compiler) lambda(n *parse.Primary) valuesOp {
 	newLocal := local.infos[scopeSizeInit:]
 	cp.popScope()
 
-	return &lambdaOp{n.Range(), argNames, restArg, optNames, optDefaultOps, newLocal, capture, chunkOp, cp.srcMeta}
+	return &lambdaOp{n.Range(), argNames, restArg, optNames, optDefaultOps, argTypeOps, optTypeOps, newLocal, capture, chunkOp, cp.srcMeta}
 }
 
 type lambdaOp struct {
@@ -441,6 +481,8 @@ type lambdaOp struct {
 	restArg       int
 	optNames      []string
 	optDefaultOps []valuesOp
+	argTypeOps    []*typeAnnotationOp
+	optTypeOps    []*typeAnnotationOp
 	newLocal      []staticVarInfo
 	capture       *staticUpNs
 	subop         effectOp
@@ -468,7 +510,15 @@ func (op *lambdaOp) exec(fm *Frame) ([]interface{}, Exception) {
 		}
 		optDefaults[i] = defaultValue
 	}
-	return []interface{}{&closure{op.argNames, op.restArg, op.optNames, optDefaults, op.subop, op.newLocal, capture, op.srcMeta, op.Range()}}, nil
+	argTypes, exc := execTypeAnnotationOps(fm, op.argTypeOps)
+	if exc != nil {
+		return nil, exc
+	}
+	optTypes, exc := execTypeAnnotationOps(fm, op.optTypeOps)
+	if exc != nil {
+		return nil, exc
+	}
+	return []interface{}{&closure{op.argNames, op.restArg, op.optNames, optDefaults, argTypes, optTypes, op.subop, op.newLocal, capture, op.srcMeta, op.Range()}}, nil
 }
 
 type mapOp struct {
@@ -73,11 +73,52 @@ func TestMapLiteral(t *testing.T) {
 func TestStringLiteral(t *testing.T) {
 	Test(t,
 		That(`put 'such \"''literal'`).Puts(`such \"'literal`),
-		That(`put "much \n\033[31;1m$cool\033[m"`).
+		That(`put "much \n\033[31;1m\$cool\033[m"`).
 			Puts("much \n\033[31;1m$cool\033[m"),
 	)
 }
 
+func TestStringInterpolation(t *testing.T) {
+	Test(t,
+		That(`var x = foo; put "[$x] [$(put bar)]"`).Puts("[foo] [bar]"),
+		That(`var x-y = foo; put "$x-y.txt"`).Puts("foo.txt"),
+		// Interpolated values are converted to strings
+		That(`put "$(num 10)"`).Puts("10"),
+		// A $ that doesn't start a variable or an output capture is literal
+		That(`put "a$ $"`).Puts("a$ $"),
+		// \$ is a literal $
+		That(`var x = foo; put "\$x"`).Puts("$x"),
+		// Interpolated strings are part of compound expressions as usual
+		That(`var x = foo; put "$x"-{a,b}`).Puts("foo-a", "foo-b"),
+		// Each interpolated expression must evaluate to exactly one value
+		That(`put "$(put a b)"`).Throws(
+			errs.ArityMismatch{What: "interpolated value",
+				ValidLow: 1, ValidHigh: 1, Actual: 2}, "$(put a b)"),
+		That(`put "$bad"`).DoesNotCompile(),
+		// Quoted variable names can't be interpolated
+		That(`var "$x" = foo`).DoesNotCompile(),
+	)
+}
+
+func TestHeredoc(t *testing.T) {
+	Test(t,
+		That("put '''\n  such \"'literal'\n    $cool\n  '''").
+			Puts("such \"'literal'\n  $cool"),
+		// Interpolated heredoc
+		That("var x = foo; fn f { put bar }",
+			"put \"\"\"\n  $x \\t $(f)\n  \\$x $\n  \"\"\"").
+			Puts("foo \t bar\n$x $"),
+		// Interpolated values are converted to strings
+		That("put \"\"\"\n  $(num 1)$(put [a b])\n  \"\"\"").
+			Puts("1[a b]"),
+		// Each interpolated expression must evaluate to exactly one value
+		That("put \"\"\"\n  $(put a b)\n  \"\"\"").
+			Throws(errs.ArityMismatch{What: "interpolated value",
+				ValidLow: 1, ValidHigh: 1, Actual: 2}, "$(put a b)"),
+		That("put \"\"\"\n  $bad\n  \"\"\"").DoesNotCompile(),
+	)
+}
+
 func TestTilde(t *testing.T) {
 	home := InTempHome(t)
 	ApplyDir(Dir{"file1": "", "file2": ""})
@@ -78,7 +78,11 @@ func (op nsOp) prepare(fm *Frame) (*Ns, func() Exception) {
 		// existing variables deleted.
 		fm.local = &Ns{fm.local.slots, op.template.infos}
 	}
-	return fm.local, func() Exception { return op.inner.exec(fm) }
+	return fm.local, func() Exception {
+		fm.defers = &deferList{}
+		exc := op.inner.exec(fm)
+		return fm.errorp(op.inner.(diag.Ranger), fm.runDefers(exc, op.inner.(diag.Ranger)))
+	}
 }
 
 const compilationErrorType = "compilation error"
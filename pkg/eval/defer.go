@@ -0,0 +1,65 @@
+package eval
+
+import (
+	"errors"
+	"sync"
+
+	"src.elv.sh/pkg/diag"
+)
+
+// A list of functions deferred to run when a closure or chunk finishes.
+type deferList struct {
+	mu  sync.Mutex
+	fns []Callable
+}
+
+// ErrDeferOutsideClosure is thrown when "defer" is called from a context where
+// there is no enclosing closure or chunk, for example directly from Go code.
+var ErrDeferOutsideClosure = errors.New("defer must be called inside a closure or chunk")
+
+func (fm *Frame) addDefer(f Callable) error {
+	if fm.defers == nil {
+		return ErrDeferOutsideClosure
+	}
+	fm.defers.mu.Lock()
+	defer fm.defers.mu.Unlock()
+	fm.defers.fns = append(fm.defers.fns, f)
+	return nil
+}
+
+// Runs all deferred functions registered on the Frame in LIFO order, and
+// combines the exceptions they throw with exc, the exception that the closure
+// or chunk finished with. Deferred functions are run even if some of them
+// throw exceptions. Exceptions from deferred functions that are not Exception
+// values are attributed to r.
+//
+// If no deferred function throws an exception, exc is returned as is. Otherwise
+// exc and the exceptions from the deferred functions are combined with the
+// same rules as exceptions in a pipeline, except that exc is dropped if it is a
+// flow control exception (from return, break or continue).
+func (fm *Frame) runDefers(exc Exception, r diag.Ranger) error {
+	if fm.defers == nil {
+		return exc
+	}
+	fm.defers.mu.Lock()
+	fns := fm.defers.fns
+	fm.defers.fns = nil
+	fm.defers.mu.Unlock()
+
+	var excs []Exception
+	for i := len(fns) - 1; i >= 0; i-- {
+		err := fns[i].Call(fm.fork("deferred"), NoArgs, NoOpts)
+		if err != nil {
+			excs = append(excs, fm.errorp(r, err))
+		}
+	}
+	if len(excs) == 0 {
+		return exc
+	}
+	if exc != nil {
+		if _, isFlow := exc.Reason().(Flow); !isFlow {
+			excs = append([]Exception{exc}, excs...)
+		}
+	}
+	return MakePipelineError(excs)
+}
@@ -87,6 +87,9 @@ type Evaler struct {
 	notifyBgJobSuccess bool
 	// The current number of background jobs, exposed as $num-bg-jobs.
 	numBgJobs int
+
+	// Background jobs and stopped foreground jobs.
+	jobs jobTable
 }
 
 //elvdoc:var after-chdir
@@ -449,7 +452,7 @@ func (ev *Evaler) prepareFrame(src parse.Source, cfg EvalCfg) (*Frame, func()) {
 
 	ports := fillDefaultDummyPorts(cfg.Ports)
 
-	fm := &Frame{ev, src, cfg.Global, new(Ns), intCh, ports, nil, false}
+	fm := &Frame{ev, src, cfg.Global, new(Ns), intCh, ports, nil, false, nil, nil}
 	return fm, func() {
 		if intChCleanup != nil {
 			intChCleanup()
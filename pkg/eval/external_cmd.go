@@ -4,8 +4,8 @@ import (
 	"errors"
 	"os"
 	"os/exec"
+	"strings"
 	"sync/atomic"
-	"syscall"
 
 	"src.elv.sh/pkg/eval/errs"
 	"src.elv.sh/pkg/eval/vals"
@@ -90,26 +90,31 @@ func (e externalCmd) Call(fm *Frame, argVals []interface{}, opts map[string]inte
 
 	args[0] = path
 
-	sys := makeSysProcAttr(fm.background)
-	proc, err := os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	j := fm.job
+	if j == nil {
+		// Not called from a pipeline, for example when called with
+		// (*Evaler).Call; use a job of its own.
+		text := strings.Join(append([]string{e.Name}, args[1:]...), " ")
+		j = newJob(fm.Evaler, text, false)
+		defer j.release(nil)
+	}
+	proc, err := j.startProcess(func(pgid int, fg bool) (*os.Process, error) {
+		sys := makeSysProcAttr(fm.background, fg, pgid)
+		return os.StartProcess(path, args, &os.ProcAttr{Files: files, Sys: sys})
+	})
 	if err != nil {
 		return err
 	}
 
-	state, err := proc.Wait()
+	ws, err := waitProcess(fm, j, e.Name, proc)
 	if err != nil {
-		// This should be a can't happen situation. Nonetheless, treat it as a
-		// soft error rather than panicking since the Go documentation is not
-		// explicit that this can only happen if we make a mistake. Such as
-		// calling `Wait` twice on a particular process object.
 		return err
 	}
-	ws := state.Sys().(syscall.WaitStatus)
 	if ws.Signaled() && isSIGPIPE(ws.Signal()) {
 		readerGone := fm.ports[1].readerGone
 		if readerGone != nil && atomic.LoadInt32(readerGone) == 1 {
 			return errs.ReaderGone{}
 		}
 	}
-	return NewExternalCmdExit(e.Name, state.Sys().(syscall.WaitStatus), proc.Pid)
+	return NewExternalCmdExit(e.Name, ws, proc.Pid)
 }
@@ -30,6 +30,14 @@ type Frame struct {
 	traceback *StackTrace
 
 	background bool
+
+	// Functions registered with the defer builtin, to be run when the
+	// innermost enclosing closure or chunk finishes. Shared among forks of the
+	// same Frame; nil if there is no enclosing closure or chunk.
+	defers *deferList
+
+	// The job of the innermost enclosing pipeline; nil if there is none.
+	job *job
 }
 
 // PrepareEval prepares a piece of code for evaluation in a copy of the current
@@ -55,7 +63,7 @@ func (fm *Frame) PrepareEval(src parse.Source, r diag.Ranger, ns *Ns) (*Ns, func
 		traceback = fm.addTraceback(r)
 	}
 	newFm := &Frame{
-		fm.Evaler, src, local, new(Ns), fm.intCh, fm.ports, traceback, fm.background}
+		fm.Evaler, src, local, new(Ns), fm.intCh, fm.ports, traceback, fm.background, nil, fm.job}
 	op, err := compile(newFm.Evaler.Builtin().static(), local.static(), tree, fm.ErrorFile())
 	if err != nil {
 		return nil, nil, err
@@ -166,7 +174,7 @@ func (fm *Frame) fork(name string) *Frame {
 		fm.Evaler, fm.srcMeta,
 		fm.local, fm.up,
 		fm.intCh, newPorts,
-		fm.traceback, fm.background,
+		fm.traceback, fm.background, fm.defers, fm.job,
 	}
 }
 
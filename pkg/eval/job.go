@@ -0,0 +1,412 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// Job control.
+//
+// Every pipeline is associated with a job, which keeps track of the external
+// processes started by the pipeline. Background pipelines are added to the job
+// table of the Evaler when they start; foreground pipelines are only added
+// when one of their processes gets stopped (for example, by Ctrl-Z). A job is
+// removed from the job table when it finishes, or when it is disowned.
+//
+// Background jobs are put in process groups of their own. When Elvish controls
+// the terminal, so are foreground jobs, and the terminal is given to the
+// process group of a foreground job while the job runs and taken back when the
+// pipeline finishes.
+
+// JobState is the state of a job.
+type JobState int
+
+// Possible values of JobState.
+const (
+	JobRunning JobState = iota
+	JobStopped
+	JobDone
+)
+
+var jobStateNames = [...]string{"running", "stopped", "done"}
+
+func (s JobState) String() string { return jobStateNames[s] }
+
+var (
+	// ErrNoJobs is thrown by job control commands when called without a job
+	// spec and the job table is empty.
+	ErrNoJobs = errors.New("no jobs")
+	// ErrJobAlreadyRunning is thrown by bg when the job is not stopped.
+	ErrJobAlreadyRunning = errors.New("job is already running")
+)
+
+// NoSuchJob is thrown when a job spec does not refer to any job in the job
+// table.
+type NoSuchJob struct {
+	Spec string
+}
+
+func (e NoSuchJob) Error() string { return "no such job: " + e.Spec }
+
+type job struct {
+	ev   *Evaler
+	text string
+	bg   bool
+	// Whether the job is in the foreground and has the terminal while it runs.
+	tty bool
+
+	mu sync.Mutex
+	// ID in the job table; 0 if the job is not in the job table.
+	id int
+	// Process group of the job; 0 if the processes are in the same process
+	// group as Elvish.
+	pgid int
+	// Maps the pid of each live process to whether it is stopped.
+	procs map[int]bool
+	// Number of things keeping the job alive: the pipeline that owns the job
+	// and each live process.
+	refs  int
+	state JobState
+	err   error
+	// Whether the job is being waited for by fg.
+	fg bool
+	// Whether the job has been disowned.
+	disowned bool
+	// Closed and replaced whenever the state changes.
+	changed chan struct{}
+}
+
+// Creates a new job owned by a pipeline. The pipeline must call release when
+// it finishes.
+func newJob(ev *Evaler, text string, bg bool) *job {
+	j := &job{ev: ev, text: text, bg: bg,
+		procs: make(map[int]bool), refs: 1, changed: make(chan struct{})}
+	if bg {
+		ev.jobs.add(j)
+	} else {
+		j.tty = controlsTTY()
+	}
+	return j
+}
+
+// Starts a process with the given function, which is passed the process group
+// the process should join and whether the process group should be given the
+// terminal, and adds the process to the job. If the job has a process group of
+// its own, the first process becomes the leader of a new process group, and
+// subsequent processes join it.
+func (j *job) startProcess(start func(pgid int, fg bool) (*os.Process, error)) (*os.Process, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	proc, err := start(j.pgid, j.tty)
+	if err != nil && j.pgid != 0 {
+		// The process group may have disappeared when all its processes have
+		// exited; start a new one.
+		proc, err = start(0, j.tty)
+		if err == nil {
+			j.pgid = 0
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if (j.bg || j.tty) && j.pgid == 0 {
+		j.pgid = proc.Pid
+	}
+	j.procs[proc.Pid] = false
+	j.refs++
+	return proc, nil
+}
+
+// Records that a process of the job has been stopped. This adds the job to the
+// job table if it is not there.
+func (j *job) processStopped(pid int) {
+	j.mu.Lock()
+	j.procs[pid] = true
+	if j.state != JobRunning {
+		j.mu.Unlock()
+		return
+	}
+	j.state = JobStopped
+	j.fg = false
+	if j.id == 0 && !j.disowned {
+		j.ev.jobs.add(j)
+	}
+	msg := j.stateMessage()
+	j.signalChange()
+	j.mu.Unlock()
+	j.notify(msg)
+}
+
+// Records that a process of the job has been continued.
+func (j *job) processContinued(pid int) {
+	j.mu.Lock()
+	j.procs[pid] = false
+	stillStopped := false
+	for _, stopped := range j.procs {
+		stillStopped = stillStopped || stopped
+	}
+	var msg string
+	if !stillStopped {
+		msg = j.markContinued()
+	}
+	j.mu.Unlock()
+	j.notify(msg)
+}
+
+// Records that a process of the job has exited. If err is not nil, it is
+// recorded as the error of the job.
+func (j *job) processExited(pid int, err error) {
+	j.mu.Lock()
+	delete(j.procs, pid)
+	if err != nil {
+		j.err = err
+	}
+	msg := j.unref()
+	j.mu.Unlock()
+	j.notify(msg)
+}
+
+// Called by the pipeline owning the job when it finishes. For background
+// jobs, err is recorded as the error of the job. For foreground jobs that have
+// been given the terminal, Elvish takes it back.
+func (j *job) release(err error) {
+	j.mu.Lock()
+	if j.bg {
+		j.err = err
+	}
+	tookTTY := j.tty && j.pgid != 0
+	msg := j.unref()
+	j.mu.Unlock()
+	if tookTTY {
+		err := putSelfInFg()
+		if err != nil {
+			logger.Println("failed to put myself in foreground:", err)
+		}
+	}
+	j.notify(msg)
+}
+
+// Marks all processes as continued, and returns the message to notify. Must
+// be called with j.mu held.
+func (j *job) markContinued() string {
+	for pid := range j.procs {
+		j.procs[pid] = false
+	}
+	if j.state != JobStopped {
+		return ""
+	}
+	j.state = JobRunning
+	j.signalChange()
+	return j.stateMessage()
+}
+
+// Decrements the reference count, and finishes the job if it drops to 0.
+// Returns the message to notify. Must be called with j.mu held.
+func (j *job) unref() string {
+	j.refs--
+	if j.refs > 0 {
+		return ""
+	}
+	j.state = JobDone
+	j.signalChange()
+	inTable := j.id != 0
+	if inTable {
+		j.ev.jobs.remove(j)
+	}
+	if j.fg || j.disowned || (!j.bg && !inTable) {
+		return ""
+	}
+	msg := "job " + j.text + " finished"
+	if j.err != nil {
+		msg += ", errors = " + j.err.Error()
+	} else if !j.ev.getNotifyBgJobSuccess() {
+		return ""
+	}
+	return msg
+}
+
+// Must be called with j.mu held.
+func (j *job) stateMessage() string {
+	if j.fg || j.disowned {
+		return ""
+	}
+	return fmt.Sprintf("job %%%d %s: %s", j.id, j.state, j.text)
+}
+
+// Must be called with j.mu held.
+func (j *job) signalChange() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *job) notify(msg string) {
+	if msg == "" {
+		return
+	}
+	if notify := j.ev.BgJobNotify; notify != nil {
+		notify(msg)
+	}
+}
+
+// Waits until the job is no longer running, or until the interrupt channel
+// fires. Returns the state of the job and its error.
+func (j *job) wait(intCh <-chan struct{}) (JobState, error) {
+	for {
+		j.mu.Lock()
+		state, err, changed := j.state, j.err, j.changed
+		j.mu.Unlock()
+		if state != JobRunning {
+			return state, err
+		}
+		select {
+		case <-changed:
+		case <-intCh:
+			return JobRunning, ErrInterrupted
+		}
+	}
+}
+
+// Returns the pids of all live processes, sorted.
+func (j *job) pids() []int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	pids := make([]int, 0, len(j.procs))
+	for pid := range j.procs {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+func (j *job) info() jobInfo {
+	pids := j.pids()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	pidList := vals.EmptyList
+	for _, pid := range pids {
+		pidList = pidList.Cons(strconv.Itoa(pid))
+	}
+	return jobInfo{
+		Id: strconv.Itoa(j.id), State: j.state.String(), Text: j.text,
+		Pgid: strconv.Itoa(j.pgid), Pids: pidList}
+}
+
+// Information about a job, as output by the "jobs" builtin.
+type jobInfo struct {
+	Id    string
+	State string
+	Text  string
+	Pgid  string
+	Pids  vals.List
+}
+
+func (jobInfo) IsStructMap() {}
+
+// The job table of an Evaler. The zero value is ready to use.
+type jobTable struct {
+	mu sync.Mutex
+	// Jobs in the order they were added.
+	jobs []*job
+}
+
+// Adds a job to the table, assigning it the smallest unused ID. Must be called
+// with j.mu held or before j is shared.
+func (t *jobTable) add(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	used := make(map[int]bool, len(t.jobs))
+	for _, j2 := range t.jobs {
+		used[j2.id] = true
+	}
+	id := 1
+	for used[id] {
+		id++
+	}
+	j.id = id
+	t.jobs = append(t.jobs, j)
+}
+
+// Removes a job from the table. Must be called with j.mu held.
+func (t *jobTable) remove(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, j2 := range t.jobs {
+		if j2 == j {
+			t.jobs = append(t.jobs[:i:i], t.jobs[i+1:]...)
+			break
+		}
+	}
+	j.id = 0
+}
+
+// Returns all jobs, sorted by ID.
+func (t *jobTable) list() []*job {
+	t.mu.Lock()
+	jobs := append([]*job(nil), t.jobs...)
+	t.mu.Unlock()
+	sort.Slice(jobs, func(i, k int) bool { return jobID(jobs[i]) < jobID(jobs[k]) })
+	return jobs
+}
+
+func jobID(j *job) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.id
+}
+
+// Finds a job by a job spec, which is "%" followed by the job ID. An empty
+// spec refers to the most recently added job.
+func (t *jobTable) find(spec string) (*job, error) {
+	t.mu.Lock()
+	jobs := append([]*job(nil), t.jobs...)
+	t.mu.Unlock()
+	if spec == "" {
+		if len(jobs) == 0 {
+			return nil, ErrNoJobs
+		}
+		return jobs[len(jobs)-1], nil
+	}
+	if !strings.HasPrefix(spec, "%") {
+		return nil, NoSuchJob{spec}
+	}
+	id, err := strconv.Atoi(spec[1:])
+	if err != nil {
+		return nil, NoSuchJob{spec}
+	}
+	for _, j := range jobs {
+		if jobID(j) == id {
+			return j, nil
+		}
+	}
+	return nil, NoSuchJob{spec}
+}
+
+// Finds a job containing the given process.
+func (t *jobTable) findByPid(pid int) *job {
+	for _, j := range t.list() {
+		j.mu.Lock()
+		_, ok := j.procs[pid]
+		j.mu.Unlock()
+		if ok {
+			return j
+		}
+	}
+	return nil
+}
+
+// Removes a job from the table without affecting its processes.
+func (t *jobTable) disown(j *job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.disowned = true
+	if j.id != 0 {
+		t.remove(j)
+	}
+}
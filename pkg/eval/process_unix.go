@@ -26,6 +26,86 @@ func putSelfInFg() error {
 	return eunix.Tcsetpgrp(0, syscall.Getpgrp())
 }
 
-func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
-	return &syscall.SysProcAttr{Setpgid: bg}
+// Reports whether Elvish controls the terminal, in which case foreground jobs
+// are put in process groups of their own and given the terminal while they run.
+func controlsTTY() bool {
+	if !sys.IsATTY(os.Stdin) {
+		return false
+	}
+	pgid, err := eunix.Tcgetpgrp(0)
+	return err == nil && pgid == syscall.Getpgrp()
+}
+
+// Returns the attributes for starting a process in the process group pgid, or
+// a new process group if pgid is 0. If fg is true, the process group is made
+// the foreground process group of the terminal before the process starts
+// running. If neither bg nor fg is true, the process stays in the process group
+// of Elvish.
+func makeSysProcAttr(bg, fg bool, pgid int) *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setpgid: bg || fg, Pgid: pgid, Foreground: fg, Ctty: 0}
+}
+
+// Waits for a process of an external command to exit, keeping the job
+// informed of its status changes. If the process is stopped while in the
+// foreground, it is left in the job table and the stopped status is returned
+// immediately, so that the user can regain control; the process continues to
+// be waited for asynchronously.
+func waitProcess(fm *Frame, j *job, name string, proc *os.Process) (syscall.WaitStatus, error) {
+	for {
+		ws, err := wait4(proc.Pid)
+		if err != nil {
+			j.processExited(proc.Pid, nil)
+			return ws, err
+		}
+		switch {
+		case ws.Stopped():
+			j.processStopped(proc.Pid)
+			if !fm.background {
+				go func() {
+					ws, err := waitStoppedProcess(j, proc)
+					if err == nil {
+						err = NewExternalCmdExit(name, ws, proc.Pid)
+					}
+					j.processExited(proc.Pid, err)
+				}()
+				return ws, nil
+			}
+		case ws.Continued():
+			j.processContinued(proc.Pid)
+		default:
+			proc.Release()
+			j.processExited(proc.Pid, nil)
+			return ws, nil
+		}
+	}
+}
+
+// Continues waiting for a process that has been stopped, until it exits.
+func waitStoppedProcess(j *job, proc *os.Process) (syscall.WaitStatus, error) {
+	defer proc.Release()
+	for {
+		ws, err := wait4(proc.Pid)
+		if err != nil {
+			return ws, err
+		}
+		switch {
+		case ws.Stopped():
+			j.processStopped(proc.Pid)
+		case ws.Continued():
+			j.processContinued(proc.Pid)
+		default:
+			return ws, nil
+		}
+	}
+}
+
+func wait4(pid int) (syscall.WaitStatus, error) {
+	for {
+		var ws syscall.WaitStatus
+		_, err := syscall.Wait4(pid, &ws, syscall.WUNTRACED|syscall.WCONTINUED, nil)
+		if err != syscall.EINTR {
+			return ws, err
+		}
+	}
 }
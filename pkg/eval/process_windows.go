@@ -1,17 +1,37 @@
 package eval
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+)
 
 // Nop on Windows.
 func putSelfInFg() error { return nil }
 
+// Job control is not supported on Windows.
+func controlsTTY() bool { return false }
+
 // The bitmask for CreationFlags in SysProcAttr to start a process in background.
 const detachedProcess = 0x00000008
 
-func makeSysProcAttr(bg bool) *syscall.SysProcAttr {
+// Process groups are not supported on Windows, so fg and pgid are ignored.
+func makeSysProcAttr(bg, fg bool, pgid int) *syscall.SysProcAttr {
 	flags := uint32(0)
 	if bg {
 		flags |= detachedProcess
 	}
 	return &syscall.SysProcAttr{CreationFlags: flags}
 }
+
+func waitProcess(fm *Frame, j *job, name string, proc *os.Process) (syscall.WaitStatus, error) {
+	state, err := proc.Wait()
+	j.processExited(proc.Pid, nil)
+	if err != nil {
+		// This should be a can't happen situation. Nonetheless, treat it as a
+		// soft error rather than panicking since the Go documentation is not
+		// explicit that this can only happen if we make a mistake. Such as
+		// calling `Wait` twice on a particular process object.
+		return syscall.WaitStatus{}, err
+	}
+	return state.Sys().(syscall.WaitStatus), nil
+}
@@ -24,7 +24,10 @@ func (ev *Evaler) PurelyEvalPartialCompound(cn *parse.Compound, upto int) (strin
 		switch in.Head.Type {
 		case parse.Tilde:
 			tilde = true
-		case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted:
+		case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted, parse.Heredoc:
+			if len(in.Head.Segments) > 0 {
+				return "", false
+			}
 			head += in.Head.Value
 		case parse.Variable:
 			if ev == nil {
@@ -61,7 +64,10 @@ func (ev *Evaler) PurelyEvalPartialCompound(cn *parse.Compound, upto int) (strin
 // Currently, only string literals and variables with no @ can be evaluated.
 func (ev *Evaler) PurelyEvalPrimary(pn *parse.Primary) interface{} {
 	switch pn.Type {
-	case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted:
+	case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted, parse.Heredoc:
+		if len(pn.Segments) > 0 {
+			return nil
+		}
 		return pn.Value
 	case parse.Variable:
 		sigil, qname := SplitSigil(pn.Value)
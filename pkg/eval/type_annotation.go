@@ -0,0 +1,204 @@
+package eval
+
+import (
+	"math/big"
+	"strings"
+
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+)
+
+// A type annotation of an argument or option of a closure. It is either a
+// named type or a predicate callable.
+type typeAnnotation struct {
+	// The name of the type, or the source text of the predicate variable (like
+	// "$positive~").
+	name string
+	// Nil when the annotation is a predicate.
+	convert typeConverter
+	// The predicate. Nil when the annotation is a named type.
+	pred Callable
+}
+
+// Converts a value to a named type, reporting whether the conversion is
+// possible. When it is not, the returned value is the value that failed the
+// check, which is used to describe the actual type in errors.
+type typeConverter func(v interface{}) (interface{}, bool)
+
+// Named types that can be used in type annotations. Number types also accept
+// strings that can be parsed as such numbers, and convert them, like arguments
+// of builtin functions.
+var typeConverters = map[string]typeConverter{
+	"any":    func(v interface{}) (interface{}, bool) { return v, true },
+	"number": numberConverter(func(vals.Num) bool { return true }),
+	"int": numberConverter(func(n vals.Num) bool {
+		switch n.(type) {
+		case int, *big.Int:
+			return true
+		}
+		return false
+	}),
+	"rat": numberConverter(func(n vals.Num) bool {
+		switch n.(type) {
+		case int, *big.Int, *big.Rat:
+			return true
+		}
+		return false
+	}),
+	"float64": numberConverter(func(n vals.Num) bool {
+		_, ok := n.(float64)
+		return ok
+	}),
+}
+
+func init() {
+	for _, kind := range []string{
+		"nil", "bool", "string", "list", "map", "structmap",
+		"fn", "ns", "exception", "file", "pipe"} {
+		typeConverters[kind] = kindConverter(kind)
+	}
+}
+
+func kindConverter(kind string) typeConverter {
+	return func(v interface{}) (interface{}, bool) {
+		return v, vals.Kind(v) == kind
+	}
+}
+
+func numberConverter(accepts func(vals.Num) bool) typeConverter {
+	return func(v interface{}) (interface{}, bool) {
+		if s, ok := v.(string); ok {
+			if n := vals.ParseNum(s); n != nil {
+				v = n
+			}
+		}
+		return v, vals.Kind(v) == "number" && accepts(v)
+	}
+}
+
+// Checks a value against the annotation, and returns the value converted to
+// the annotated type.
+func (t *typeAnnotation) check(fm *Frame, what string, v interface{}) (interface{}, error) {
+	if t.pred != nil {
+		ok, err := callPredicate(fm, t.pred, "type predicate "+t.name, v)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errs.BadValue{
+				What: what, Valid: "value satisfying " + t.name,
+				Actual: vals.Repr(v, vals.NoPretty)}
+		}
+		return v, nil
+	}
+	converted, ok := t.convert(v)
+	if !ok {
+		return nil, errs.BadValue{What: what, Valid: t.name, Actual: typeOf(converted)}
+	}
+	return converted, nil
+}
+
+// Returns the name of the most specific named type of a value.
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case int, *big.Int:
+		return "int"
+	case *big.Rat:
+		return "rat"
+	case float64:
+		return "float64"
+	}
+	return vals.Kind(v)
+}
+
+// Returns the value of a type annotation as seen from Elvish code: nil if
+// there is no annotation, the name of the type, or the predicate.
+func (t *typeAnnotation) value() interface{} {
+	switch {
+	case t == nil:
+		return nil
+	case t.pred != nil:
+		return t.pred
+	default:
+		return t.name
+	}
+}
+
+// The compiled form of a type annotation. The predicate variable, if any, is
+// evaluated when the closure is created.
+type typeAnnotationOp struct {
+	diag.Ranging
+	name    string
+	convert typeConverter
+	predOp  valuesOp
+}
+
+func (op *typeAnnotationOp) exec(fm *Frame) (*typeAnnotation, Exception) {
+	if op == nil {
+		return nil, nil
+	}
+	if op.predOp == nil {
+		return &typeAnnotation{op.name, op.convert, nil}, nil
+	}
+	v, exc := evalForValue(fm, op.predOp, "type predicate")
+	if exc != nil {
+		return nil, exc
+	}
+	pred, ok := v.(Callable)
+	if !ok {
+		return nil, fm.errorp(op, errs.BadValue{
+			What: "type predicate", Valid: "callable", Actual: vals.Kind(v)})
+	}
+	return &typeAnnotation{op.name, nil, pred}, nil
+}
+
+func execTypeAnnotationOps(fm *Frame, ops []*typeAnnotationOp) ([]*typeAnnotation, Exception) {
+	if ops == nil {
+		return nil, nil
+	}
+	types := make([]*typeAnnotation, len(ops))
+	for i, op := range ops {
+		t, exc := op.exec(fm)
+		if exc != nil {
+			return nil, exc
+		}
+		types[i] = t
+	}
+	return types, nil
+}
+
+// Parses an element of a closure signature, which is either a string literal
+// of the form "name" or "name:type", or a bareword "name:" followed by a
+// variable holding a predicate. It returns the name and the compiled type
+// annotation, which is nil when there is no annotation.
+func (cp *compiler) signatureElem(n *parse.Compound, what string) (string, *typeAnnotationOp) {
+	if len(n.Indexings) == 2 {
+		head, pred := n.Indexings[0], n.Indexings[1]
+		if len(head.Indices) == 0 && head.Head.Type == parse.Bareword &&
+			strings.HasSuffix(head.Head.Value, ":") &&
+			len(pred.Indices) == 0 && pred.Head.Type == parse.Variable {
+			name := strings.TrimSuffix(head.Head.Value, ":")
+			return name, &typeAnnotationOp{
+				n.Range(), "$" + pred.Head.Value, nil, cp.indexingOp(pred)}
+		}
+	}
+	s := stringLiteralOrError(cp, n, what)
+	// The first colon after the sigil separates the name and the type; names
+	// of arguments and options can never be qualified.
+	sigil, qname := SplitSigil(s)
+	i := strings.IndexByte(qname, ':')
+	if i == -1 {
+		return s, nil
+	}
+	typeName := qname[i+1:]
+	if typeName == "" {
+		cp.errorpf(n, "type annotation must not be empty")
+	}
+	convert, ok := typeConverters[typeName]
+	if !ok {
+		cp.errorpf(n, "unknown type %s", parse.Quote(typeName))
+	}
+	return sigil + qname[:i], &typeAnnotationOp{n.Range(), typeName, convert, nil}
+}
@@ -181,6 +181,26 @@ func readDir(dir string) ([]os.DirEntry, error) {
 	return os.ReadDir(dir)
 }
 
+// Match returns whether a string matches the pattern. Unlike when globbing the
+// filesystem, the string is not treated as a path: "/" is matched like any
+// other character, "**" is equivalent to "*", and a leading "." does not need
+// to be matched explicitly.
+func (p Pattern) Match(s string) bool {
+	segs := make([]Segment, len(p.Segments))
+	for i, seg := range p.Segments {
+		switch seg := seg.(type) {
+		case Slash:
+			segs[i] = Literal{"/"}
+		case Wild:
+			seg.MatchHidden = true
+			segs[i] = seg
+		default:
+			segs[i] = seg
+		}
+	}
+	return matchElement(segs, s)
+}
+
 // matchElement matches a path element against segments, which may not contain
 // any Slash segments. It treats StarStar segments as they are Star segments.
 func matchElement(segs []Segment, name string) bool {
@@ -103,6 +103,36 @@ func testGlob(t *testing.T, abs bool) {
 	}
 }
 
+var matchCases = []struct {
+	pattern string
+	s       string
+	want    bool
+}{
+	{"foo", "foo", true},
+	{"foo", "foobar", false},
+	{"foo*", "foobar", true},
+	{"*bar", "foobar", true},
+	{"f?o", "foo", true},
+	{"f?o", "fo", false},
+	{"*", "", true},
+	{"*", ".hidden", true},
+	{"a*c", "a/b/c", true},
+	{"a**c", "a/b/c", true},
+	{"a/b", "a/b", true},
+	{`\*`, "*", true},
+	{`\*`, "x", false},
+}
+
+func TestPattern_Match(t *testing.T) {
+	for _, tc := range matchCases {
+		got := Parse(tc.pattern).Match(tc.s)
+		if got != tc.want {
+			t.Errorf("Parse(%q).Match(%q) => %v, want %v",
+				tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
+
 // Regression test for b.elv.sh/1220
 func TestGlob_InvalidUTF8InFilename(t *testing.T) {
 	if runtime.GOOS == "windows" {
@@ -0,0 +1,375 @@
+// Package encoding implements the encoding: module, which provides codecs for
+// data serialization formats.
+package encoding
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+var Ns = eval.NsBuilder{}.AddGoFns("encoding:", fns).Ns()
+
+var fns = map[string]interface{}{
+	"from-yaml": fromYAML,
+	"to-yaml":   toYAML,
+	"from-toml": fromTOML,
+	"to-toml":   toTOML,
+}
+
+type decodeOpts struct{ Ordered bool }
+
+func (*decodeOpts) SetDefaultOptions() {}
+
+//elvdoc:fn from-yaml
+//
+// ```elvish
+// encoding:from-yaml &ordered=$false
+// ```
+//
+// Takes bytes stdin, parses it as a stream of YAML documents, and writes each
+// document to the value output. Documents are output as soon as they are
+// parsed, so a long stream can be processed in a streaming fashion. Examples:
+//
+// ```elvish-transcript
+// ~> echo "name: elvish\ntags: [shell, language]" | encoding:from-yaml
+// ▶ [&name=elvish &tags=[shell language]]
+// ~> echo "--- 1\n--- [2, 3]" | encoding:from-yaml
+// ▶ (num 1)
+// ▶ [(num 2) (num 3)]
+// ```
+//
+// Mappings are converted to maps and sequences to lists. Scalars are resolved
+// using the core schema of YAML 1.2: `null` and `~` become `$nil`, `true` and
+// `false` become booleans, integers and floating-point numbers become
+// [typed numbers](language.html#number) (integers of any size are preserved
+// exactly), and everything else becomes strings. Keys of mappings are always
+// converted to strings, and merge keys (`<<`) are supported.
+//
+// If `&ordered` is true, mappings are output as maps that remember the order
+// of their keys. Such maps behave like normal maps, but their keys are
+// iterated and printed in the original order, and the order is preserved by
+// `encoding:to-yaml`, `encoding:to-toml` and `to-json`.
+//
+// Mappings whose keys are sequences or mappings are not supported.
+//
+// @cf encoding:to-yaml
+
+func fromYAML(fm *eval.Frame, opts decodeOpts) error {
+	out := fm.ValueOutput()
+	dec := yaml.NewDecoder(fm.InputFile())
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		v, err := newYAMLConverter().convert(&doc)
+		if err != nil {
+			return err
+		}
+		err = out.Put(toValue(v, opts.Ordered))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//elvdoc:fn to-yaml
+//
+// ```elvish
+// encoding:to-yaml $input?
+// ```
+//
+// Takes structured stdin, converts each value to a YAML document, and writes
+// the documents to byte output, separated by `---` lines. Examples:
+//
+// ```elvish-transcript
+// ~> put [&name=elvish &tags=[shell language]] | encoding:to-yaml
+// name: elvish
+// tags:
+//   - shell
+//   - language
+// ~> encoding:to-yaml [foo (num 1) $true]
+// - foo
+// - 1
+// - true
+// ```
+//
+// Strings are quoted when they would otherwise be read back as a different
+// value, including strings like `yes` and `on` that are booleans in YAML 1.1,
+// and multi-line strings are written as literal block scalars. Note
+// that Elvish number literals like `1` are strings, so they are written as
+// quoted strings unless converted with `num` first.
+//
+// Keys of maps output by `encoding:from-yaml &ordered` or
+// `encoding:from-toml &ordered` are written in their original order; keys of
+// other maps are sorted.
+//
+// @cf encoding:from-yaml
+
+func toYAML(fm *eval.Frame, inputs eval.Inputs) error {
+	enc := yaml.NewEncoder(fm.ByteOutput())
+	enc.SetIndent(2)
+	var errEncode error
+	inputs(func(v interface{}) {
+		if errEncode != nil {
+			return
+		}
+		n, err := yamlNode(v)
+		if err != nil {
+			errEncode = err
+			return
+		}
+		errEncode = enc.Encode(n)
+	})
+	if errEncode != nil {
+		return errEncode
+	}
+	return enc.Close()
+}
+
+//elvdoc:fn from-toml
+//
+// ```elvish
+// encoding:from-toml &ordered=$false
+// ```
+//
+// Takes bytes stdin, parses it as a TOML document, and writes it to the value
+// output as a map. Example:
+//
+// ```elvish-transcript
+// ~> echo "title = 'elvish'\n[owner]\nid = 0xff" | encoding:from-toml
+// ▶ [&owner=[&id=(num 255)] &title=elvish]
+// ```
+//
+// Tables are converted to maps and arrays to lists. Integers and
+// floating-point numbers become [typed numbers](language.html#number); as
+// required by TOML, integers that don't fit in 64 bits are rejected. Since
+// Elvish has no date-time type, date-times, dates and times are output as
+// strings in the format of RFC 3339.
+//
+// The `&ordered` option works like in `encoding:from-yaml`.
+//
+// @cf encoding:to-toml
+
+func fromTOML(fm *eval.Frame, opts decodeOpts) error {
+	src, err := io.ReadAll(fm.InputFile())
+	if err != nil {
+		return err
+	}
+	v, err := decodeTOML(string(src))
+	if err != nil {
+		return err
+	}
+	return fm.ValueOutput().Put(toValue(v, opts.Ordered))
+}
+
+//elvdoc:fn to-toml
+//
+// ```elvish
+// encoding:to-toml $input?
+// ```
+//
+// Takes exactly one map from structured stdin, and writes it to byte output as
+// a TOML document. Example:
+//
+// ```elvish-transcript
+// ~> encoding:to-toml [[&title=elvish &owner=[&id=(num 255)]]]
+// title = "elvish"
+//
+// [owner]
+// id = 255
+// ```
+//
+// Nested maps are written as tables, and non-empty lists of maps are written
+// as arrays of tables. Since TOML has no null value, `$nil` cannot be encoded.
+// Keys are ordered like in `encoding:to-yaml`.
+//
+// @cf encoding:from-toml
+
+func toTOML(fm *eval.Frame, inputs eval.Inputs) error {
+	var values []interface{}
+	inputs(func(v interface{}) { values = append(values, v) })
+	if len(values) != 1 {
+		return errs.ArityMismatch{
+			What: "inputs", ValidLow: 1, ValidHigh: 1, Actual: len(values)}
+	}
+	doc, err := encodeTOML(values[0])
+	if err != nil {
+		return err
+	}
+	_, err = fm.ByteOutput().WriteString(doc)
+	return err
+}
+
+// A map under construction, which remembers the order of keys. The decoders
+// produce these maps, which are converted to Elvish values by toValue.
+type mapping struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newMapping() *mapping {
+	return &mapping{values: make(map[string]interface{})}
+}
+
+func (m *mapping) has(k string) bool {
+	_, ok := m.values[k]
+	return ok
+}
+
+func (m *mapping) get(k string) (interface{}, bool) {
+	v, ok := m.values[k]
+	return v, ok
+}
+
+func (m *mapping) set(k string, v interface{}) {
+	if !m.has(k) {
+		m.keys = append(m.keys, k)
+	}
+	m.values[k] = v
+}
+
+// Converts a value output by the decoders to an Elvish value.
+func toValue(v interface{}, ordered bool) interface{} {
+	switch v := v.(type) {
+	case *mapping:
+		if ordered {
+			m := emptyOrderedMap
+			for _, k := range v.keys {
+				m = m.assoc(k, toValue(v.values[k], ordered))
+			}
+			return m
+		}
+		m := vals.EmptyMap
+		for _, k := range v.keys {
+			m = m.Assoc(k, toValue(v.values[k], ordered))
+		}
+		return m
+	case []interface{}:
+		l := vals.EmptyList
+		for _, elem := range v {
+			l = l.Cons(toValue(elem, ordered))
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// Parses an integer with big.Int.SetString, and normalizes it to an int if it
+// is small enough.
+func parseBigInt(s string, base int) (interface{}, bool) {
+	z, ok := new(big.Int).SetString(s, base)
+	if !ok {
+		return nil, false
+	}
+	return vals.NormalizeBigInt(z), true
+}
+
+func kindOf(v interface{}) string { return vals.Kind(v) }
+
+func cannotEncode(v interface{}, format string) error {
+	return fmt.Errorf("cannot encode value of kind %s in %s", vals.Kind(v), format)
+}
+
+// Calls f with each element of a list.
+func iterateList(l interface{}, f func(interface{}) error) error {
+	var errCb error
+	err := vals.Iterate(l, func(v interface{}) bool {
+		errCb = f(v)
+		return errCb == nil
+	})
+	if err != nil {
+		return err
+	}
+	return errCb
+}
+
+// Calls f with each pair of a map. The order of keys in an orderedMap is
+// preserved; keys of other maps are sorted, so that the output is
+// deterministic.
+func iterateMap(m interface{}, f func(k string, v interface{}) error) error {
+	var keys []interface{}
+	if om, ok := m.(orderedMap); ok {
+		keys = om.keys
+	} else {
+		err := vals.IterateKeys(m, func(k interface{}) bool {
+			keys = append(keys, k)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return vals.ToString(keys[i]) < vals.ToString(keys[j])
+		})
+	}
+	for _, k := range keys {
+		v, err := vals.Index(m, k)
+		if err != nil {
+			return err
+		}
+		err = f(vals.ToString(k), v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// How special scalar values are written in a format. An empty string means
+// that the value cannot be written.
+type scalarStyle struct {
+	null, inf, negInf, nan string
+}
+
+// Encodes a nil, boolean or number. Rational numbers are written as
+// floating-point numbers.
+func encodeScalar(v interface{}, style scalarStyle) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return style.null, style.null != ""
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case *big.Int:
+		return v.String(), true
+	case *big.Rat:
+		f, _ := v.Float64()
+		return encodeFloat(f, style), true
+	case float64:
+		return encodeFloat(v, style), true
+	}
+	return "", false
+}
+
+func encodeFloat(f float64, style scalarStyle) string {
+	switch {
+	case math.IsInf(f, 1):
+		return style.inf
+	case math.IsInf(f, -1):
+		return style.negInf
+	case math.IsNaN(f):
+		return style.nan
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		// Make sure that the number is read back as a float.
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,308 @@
+package encoding
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/errs"
+	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+func TestFromYAML(t *testing.T) {
+	TestWithSetup(t, setup,
+		// Block collections
+		That(`echo 'a: x
+b:
+  - 1
+  - c: d
+    e: [f, "g"]
+h:
+- i
+- - j
+  - k
+l:
+  m: n' | encoding:from-yaml`).Puts(vals.MakeMap(
+			"a", "x",
+			"b", vals.MakeList(1, vals.MakeMap(
+				"c", "d", "e", vals.MakeList("f", "g"))),
+			"h", vals.MakeList("i", vals.MakeList("j", "k")),
+			"l", vals.MakeMap("m", "n"))),
+		// Flow collections
+		That(`echo '{a: [1, 2,
+  3], "b":c, d, e: {}, f: []}' | encoding:from-yaml`).Puts(vals.MakeMap(
+			"a", vals.MakeList(1, 2, 3), "b", "c", "d", nil,
+			"e", vals.EmptyMap, "f", vals.EmptyList)),
+		That(`echo '[a: b, c]' | encoding:from-yaml`).Puts(
+			vals.MakeList(vals.MakeMap("a", "b"), "c")),
+		// Scalar resolution
+		That(`echo '[~, null, "", true, False, 10, -0x1f, 0o17, 1.5, .5, 1e3, .inf, -.Inf, 123456789012345678901234567890, 1.2.3, yes]' | encoding:from-yaml`).Puts(
+			vals.MakeList(nil, nil, "", true, false, 10, -31, 15, 1.5, 0.5,
+				1000.0, math.Inf(1), math.Inf(-1),
+				bigInt("123456789012345678901234567890"), "1.2.3", "yes")),
+		// Keys are always strings
+		That(`echo '1: a' | encoding:from-yaml`).Puts(vals.MakeMap("1", "a")),
+		// Quoted scalars
+		That(`echo '- "a\tb\u00e9\x41\
+    c"
+- "it''s"
+- "multi
+  line
+
+  text"' | encoding:from-yaml`).Puts(
+			vals.MakeList("a\tbéAc", "it's", "multi line\ntext")),
+		// Plain multi-line scalars and comments
+		That(`echo 'a: b
+  c#d # comment
+# comment
+e: f' | encoding:from-yaml`).Puts(vals.MakeMap("a", "b c#d", "e", "f")),
+		// Block scalars
+		That(`echo 'a: |
+  x
+   y
+
+b: >
+  x
+  y
+
+  z
+c: |-
+  x
+d: |+
+  x
+
+e: >2-
+   x
+' | encoding:from-yaml`).Puts(vals.MakeMap(
+			"a", "x\n y\n", "b", "x y\nz\n", "c", "x", "d", "x\n\n", "e", " x")),
+		// Anchors, aliases and tags
+		That(`echo 'a: &x [1, 2]
+b: *x
+c: !!str 1
+d: !!float 1
+e: !!int "2"' | encoding:from-yaml`).Puts(vals.MakeMap(
+			"a", vals.MakeList(1, 2), "b", vals.MakeList(1, 2),
+			"c", "1", "d", 1.0, "e", 2)),
+		// Merge keys
+		That(`echo 'a: &x {b: 1, c: 2}
+d:
+  <<: *x
+  c: 3' | encoding:from-yaml &ordered | put (one)[d] | repr (one)`).Prints(
+			"[&c=(num 3) &b=(num 1)]\n"),
+		// Multiple documents
+		That(`echo '--- a
+---
+b: c
+...
+---
+...
+# comment only
+--- |
+  x' | encoding:from-yaml`).Puts("a", vals.MakeMap("b", "c"), nil, "x\n"),
+		That(`echo '' | encoding:from-yaml`).DoesNothing(),
+		// Errors
+		That(`echo 'a: [1' | encoding:from-yaml`).Throws(
+			ErrorWithMessage("yaml: line 1: did not find expected ',' or ']'")),
+		That(`echo 'a:
+  b: 1
+ c: 2' | encoding:from-yaml`).Throws(
+			ErrorWithMessage("yaml: line 2: did not find expected key")),
+		That(`echo 'a: 1
+ b: 2' | encoding:from-yaml`).Throws(
+			ErrorWithMessage("yaml: line 2: mapping values are not allowed in this context")),
+		That(`echo 'a: 1
+a: 2' | encoding:from-yaml`).Throws(
+			ErrorWithMessage(`yaml: line 2: duplicate key "a"`)),
+		That(`echo 'ok
+---
+a: [1' | encoding:from-yaml`).Puts("ok").Throws(
+			ErrorWithMessage("yaml: line 2: did not find expected ',' or ']'")),
+		That(`echo 'a: *b' | encoding:from-yaml`).Throws(
+			ErrorWithMessage("yaml: unknown anchor 'b' referenced")),
+		That(`echo '[a]: b' | encoding:from-yaml`).Throws(
+			ErrorWithMessage("yaml: line 1: mapping keys must be scalars")),
+	)
+}
+
+func TestToYAML(t *testing.T) {
+	TestWithSetup(t, setup,
+		That(`encoding:to-yaml [[&b=[x [&c=d &e=[]] [x z]] &a=[&]]]`).Prints(
+			`a: {}
+b:
+  - x
+  - c: d
+    e: []
+  - - x
+    - z
+`),
+		That(`encoding:to-yaml [$nil $true (num 1) (num 1.0) (num 1/2) (num 100000000000000000000) (num +inf)]`).Prints(
+			"null\n---\ntrue\n---\n1\n---\n1.0\n---\n0.5\n---\n100000000000000000000\n---\n.inf\n"),
+		// Quoting of strings
+		That(`encoding:to-yaml [[foo '' 1 true null yes on '- a' 'a: b' 'a #b' "it's" "\t"]]`).Prints(
+			`- foo
+- ""
+- "1"
+- "true"
+- "null"
+- "yes"
+- "on"
+- '- a'
+- 'a: b'
+- 'a #b'
+- it's
+- "\t"
+`),
+		// Multi-line strings
+		That(`encoding:to-yaml [[&a="x\ny" &b="x\n" &c="x\n\n" &d=" x\ny"]]`).Prints(
+			`a: |-
+  x
+  y
+b: |
+  x
+c: |+
+  x
+
+d: |2-
+   x
+  y
+`),
+		That(`encoding:to-yaml ["x\n"]`).Prints("|\n  x\n"),
+		That(`encoding:to-yaml [{ }]`).Throws(
+			ErrorWithMessage("cannot encode value of kind fn in YAML")),
+		// Round trip
+		That(`var v = [&a=[x (num 1) $nil [&'key: x'="multi\nline"]]]`,
+			`eq $v (encoding:to-yaml [$v] | encoding:from-yaml)`).Puts(true),
+	)
+}
+
+func TestFromTOML(t *testing.T) {
+	TestWithSetup(t, setup,
+		That(`echo '''
+a = "x\ty\u00e9"
+b = 'C:\\dir'
+c = """
+x \
+   y"""
+d = '''\n'''
+e = [1, 2.5,
+  "x", # comment
+]
+f = {g = true, h.i = false}
+j.k = 1_000
+l = [0xff, 0o17, 0b11, -0, +inf]
+m = 1979-05-27 07:32:00Z
+"n o" = 07:32:00
+
+[p]
+q = 1
+[p.r]
+s = 2
+
+[[t]]
+u = 1
+[[t]]
+u = 2
+[t.v]
+w = 3
+''' | encoding:from-toml`).Puts(vals.MakeMap(
+			"a", "x\tyé",
+			"b", `C:\\dir`,
+			"c", "x y",
+			"d", `\n`,
+			"e", vals.MakeList(1, 2.5, "x"),
+			"f", vals.MakeMap("g", true, "h", vals.MakeMap("i", false)),
+			"j", vals.MakeMap("k", 1000),
+			"l", vals.MakeList(255, 15, 3, 0, math.Inf(1)),
+			"m", "1979-05-27T07:32:00Z",
+			"n o", "07:32:00",
+			"p", vals.MakeMap("q", 1, "r", vals.MakeMap("s", 2)),
+			"t", vals.MakeList(
+				vals.MakeMap("u", 1),
+				vals.MakeMap("u", 2, "v", vals.MakeMap("w", 3))))),
+		That(`echo '' | encoding:from-toml`).Puts(vals.EmptyMap),
+		// Errors
+		That(`echo 'a = 1
+a = 2' | encoding:from-toml`).Throws(
+			ErrorWithMessage("Near line 2 (last key parsed 'a'): Key 'a' has already been defined.")),
+		That(`echo 'a = {b = 1}
+[a]' | encoding:from-toml`).Throws(
+			ErrorWithMessage("Near line 2 (last key parsed ''): Key 'a' has already been defined.")),
+		That(`echo 'a = 01' | encoding:from-toml`).Throws(
+			ErrorWithMessage(`Near line 1 (last key parsed 'a'): Invalid integer "01": cannot have leading zeroes`)),
+		// Integers must fit in 64 bits
+		That(`echo 'a = 9223372036854775808' | encoding:from-toml`).Throws(
+			ErrorWithMessage("Near line 1 (last key parsed 'a'): Integer '9223372036854775808' is out of the range of 64-bit signed integers.")),
+	)
+}
+
+func TestToTOML(t *testing.T) {
+	TestWithSetup(t, setup,
+		That(`encoding:to-toml [[&z="x\"y\n" &'a b'=(num 1) &c=[(num 1.5) [&d=$true]] &e=[&f=[&g=x]] &h=[[&i=(num 1)] [&i=(num 2)]] &j=[&]]]`).Prints(
+			`"a b" = 1
+c = [1.5, {d = true}]
+z = "x\"y\n"
+
+[e.f]
+g = "x"
+
+[j]
+
+[[h]]
+i = 1
+
+[[h]]
+i = 2
+`),
+		That(`encoding:to-toml [[&a=$nil]]`).Throws(
+			ErrorWithMessage("cannot encode value of kind nil in TOML")),
+		That(`encoding:to-toml [[a]]`).Throws(errTOMLTopLevel),
+		That(`encoding:to-toml [[&] [&]]`).Throws(errs.ArityMismatch{
+			What: "inputs", ValidLow: 1, ValidHigh: 1, Actual: 2}),
+		// Round trip
+		That(`var v = [&a=[&b=[x (num 1)] &c=[[&d=(num 1.5)]]]]`,
+			`eq $v (encoding:to-toml [$v] | encoding:from-toml)`).Puts(true),
+	)
+}
+
+func TestOrdered(t *testing.T) {
+	TestWithSetup(t, setup,
+		That(`var m = (echo 'z: 1
+a: {y: 2, b: 3}' | encoding:from-yaml &ordered)`,
+			`keys $m`, `keys $m[a]`, `put $m[z] (count $m) (has-key $m a)`,
+			`kind-of $m`).
+			Puts("z", "a", "y", "b", 1, 2, true, "map"),
+		That(`var m = (echo 'z = 1
+a = 2' | encoding:from-toml &ordered)`,
+			`repr $m`, `repr (assoc $m b 3)`, `repr (assoc $m z 3)`,
+			`repr (dissoc $m z)`).
+			Prints("[&z=(num 1) &a=(num 2)]\n[&z=(num 1) &a=(num 2) &b=3]\n"+
+				"[&z=3 &a=(num 2)]\n[&a=(num 2)]\n"),
+		// Equality takes the order into account
+		That(`var a b = (echo 'x: 1
+y: 2
+--- {y: 2, x: 1}' | encoding:from-yaml &ordered)`,
+			`eq $a $a`, `eq $a $b`, `eq $a (dissoc (assoc $b x (num 1)) y | assoc (one) y (num 2))`).
+			Puts(true, false, true),
+		// Order is preserved by the encoders
+		That(`echo 'z: 1
+a: 2' | encoding:from-yaml &ordered | encoding:to-yaml`).Prints("z: 1\na: 2\n"),
+		That(`echo 'z = 1
+a = 2' | encoding:from-toml &ordered | encoding:to-toml`).Prints("z = 1\na = 2\n"),
+		That(`echo 'z: 1
+a: [2]' | encoding:from-yaml &ordered | to-json`).Prints(`{"z":1,"a":[2]}`+"\n"),
+	)
+}
+
+func setup(ev *eval.Evaler) {
+	ev.AddGlobal(eval.NsBuilder{}.AddNs("encoding", Ns).Ns())
+}
+
+func bigInt(s string) *big.Int {
+	z, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("cannot parse as big int: " + s)
+	}
+	return z
+}
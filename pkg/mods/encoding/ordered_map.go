@@ -0,0 +1,135 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/persistent/hash"
+)
+
+// An immutable map that remembers the order in which keys were added. It is
+// output by the decoders when the &ordered option is set, and its key order is
+// preserved by the encoders.
+//
+// Apart from the order of keys, it behaves like a normal map: it can be
+// indexed, and it supports the builtins that work on maps, like keys, has-key,
+// assoc and dissoc.
+type orderedMap struct {
+	m    vals.Map
+	keys []interface{}
+}
+
+var emptyOrderedMap = orderedMap{vals.EmptyMap, nil}
+
+func (om orderedMap) Kind() string { return "map" }
+
+func (om orderedMap) Len() int { return len(om.keys) }
+
+func (om orderedMap) Index(k interface{}) (interface{}, bool) {
+	return om.m.Index(k)
+}
+
+func (om orderedMap) HasKey(k interface{}) bool {
+	_, ok := om.m.Index(k)
+	return ok
+}
+
+func (om orderedMap) IterateKeys(f func(interface{}) bool) {
+	for _, k := range om.keys {
+		if !f(k) {
+			return
+		}
+	}
+}
+
+func (om orderedMap) Assoc(k, v interface{}) (interface{}, error) {
+	return om.assoc(k, v), nil
+}
+
+func (om orderedMap) assoc(k, v interface{}) orderedMap {
+	keys := om.keys
+	if _, ok := om.m.Index(k); !ok {
+		// Force a copy, since the backing array may be shared with other maps.
+		keys = append(keys[:len(keys):len(keys)], k)
+	}
+	return orderedMap{om.m.Assoc(k, v), keys}
+}
+
+func (om orderedMap) Dissoc(k interface{}) interface{} {
+	if _, ok := om.m.Index(k); !ok {
+		return om
+	}
+	keys := make([]interface{}, 0, len(om.keys)-1)
+	for _, k2 := range om.keys {
+		if !vals.Equal(k, k2) {
+			keys = append(keys, k2)
+		}
+	}
+	return orderedMap{om.m.Dissoc(k), keys}
+}
+
+// Equal returns whether the argument is an orderedMap with the same pairs in
+// the same order.
+func (om orderedMap) Equal(other interface{}) bool {
+	om2, ok := other.(orderedMap)
+	if !ok || len(om.keys) != len(om2.keys) {
+		return false
+	}
+	for i, k := range om.keys {
+		if !vals.Equal(k, om2.keys[i]) {
+			return false
+		}
+		v, _ := om.m.Index(k)
+		v2, _ := om2.m.Index(k)
+		if !vals.Equal(v, v2) {
+			return false
+		}
+	}
+	return true
+}
+
+func (om orderedMap) Hash() uint32 {
+	h := hash.DJBInit
+	for _, k := range om.keys {
+		v, _ := om.m.Index(k)
+		h = hash.DJBCombine(h, vals.Hash(k))
+		h = hash.DJBCombine(h, vals.Hash(v))
+	}
+	return h
+}
+
+func (om orderedMap) Repr(indent int) string {
+	builder := vals.NewMapReprBuilder(indent)
+	for _, k := range om.keys {
+		v, _ := om.m.Index(k)
+		builder.WritePair(vals.Repr(k, indent+1), indent+2, vals.Repr(v, indent+2))
+	}
+	return builder.String()
+}
+
+// MarshalJSON encodes the map as a JSON object, with keys in order. This makes
+// to-json preserve the order of keys too.
+func (om orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range om.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kBytes, err := json.Marshal(vals.ToString(k))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kBytes)
+		buf.WriteByte(':')
+		v, _ := om.m.Index(k)
+		vBytes, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vBytes)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,102 @@
+package encoding
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"src.elv.sh/pkg/eval/vals"
+)
+
+// Decodes a TOML document into a value that can be passed to toValue.
+func decodeTOML(src string) (interface{}, error) {
+	var m map[string]interface{}
+	md, err := toml.Decode(src, &m)
+	if err != nil {
+		return nil, err
+	}
+	return tomlValue(m, nil, tomlKeyOrder(md.Keys())), nil
+}
+
+// Returns the keys of each table in the order they appear in the document,
+// indexed by the path of the table as built by tomlPath.
+func tomlKeyOrder(keys []toml.Key) map[string][]string {
+	order := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		for i := range key {
+			path := tomlPath(key[:i+1])
+			if !seen[path] {
+				seen[path] = true
+				parent := tomlPath(key[:i])
+				order[parent] = append(order[parent], key[i])
+			}
+		}
+	}
+	return order
+}
+
+// Elements of arrays of tables share the path of the array.
+func tomlPath(key []string) string { return strings.Join(key, "\x00") }
+
+func tomlValue(v interface{}, path []string, order map[string][]string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := newMapping()
+		sub := func(k string) interface{} {
+			return tomlValue(v[k], append(path[:len(path):len(path)], k), order)
+		}
+		for _, k := range order[tomlPath(path)] {
+			if _, ok := v[k]; ok {
+				m.set(k, sub(k))
+			}
+		}
+		// The keys of inline tables in arrays are not recorded by the decoder.
+		var rest []string
+		for k := range v {
+			if !m.has(k) {
+				rest = append(rest, k)
+			}
+		}
+		sort.Strings(rest)
+		for _, k := range rest {
+			m.set(k, sub(k))
+		}
+		return m
+	case []map[string]interface{}:
+		l := make([]interface{}, len(v))
+		for i, t := range v {
+			l[i] = tomlValue(t, path, order)
+		}
+		return l
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, elem := range v {
+			l[i] = tomlValue(elem, path, order)
+		}
+		return l
+	case int64:
+		return vals.NormalizeBigInt(big.NewInt(v))
+	case time.Time:
+		return formatTOMLTime(v)
+	default:
+		return v
+	}
+}
+
+// Formats a date-time, date or time. The decoder marks local ones with
+// special locations.
+func formatTOMLTime(t time.Time) string {
+	switch t.Location().String() {
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
@@ -0,0 +1,190 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errTOMLTopLevel = errors.New("the top-level value of a TOML document must be a map")
+
+// Encodes a map as a TOML document. In each table, pairs with non-table values
+// are written first, followed by sub-tables and arrays of tables.
+func encodeTOML(v interface{}) (string, error) {
+	if kindOf(v) != "map" {
+		return "", errTOMLTopLevel
+	}
+	var b strings.Builder
+	err := writeTOMLTable(&b, nil, v, "")
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Writes a table with the given header, which is empty for the root table. The
+// header is omitted for tables that only contain other tables, since it is
+// implied by the headers of the latter.
+func writeTOMLTable(b *strings.Builder, path []string, t interface{}, header string) error {
+	type pair struct {
+		k string
+		v interface{}
+	}
+	var lines []string
+	var tables, arrays []pair
+	err := iterateMap(t, func(k string, v interface{}) error {
+		switch {
+		case kindOf(v) == "map":
+			tables = append(tables, pair{k, v})
+		case isArrayOfTables(v):
+			arrays = append(arrays, pair{k, v})
+		default:
+			s, err := tomlInline(v)
+			if err != nil {
+				return err
+			}
+			lines = append(lines, tomlKey(k)+" = "+s)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(header, "[[") ||
+		header != "" && (len(lines) > 0 || len(tables)+len(arrays) == 0) {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(header + "\n")
+	}
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	for _, p := range tables {
+		subpath := append(path[:len(path):len(path)], p.k)
+		err := writeTOMLTable(b, subpath, p.v, "["+tomlKeyPath(subpath)+"]")
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range arrays {
+		subpath := append(path[:len(path):len(path)], p.k)
+		err := iterateList(p.v, func(t interface{}) error {
+			return writeTOMLTable(b, subpath, t, "[["+tomlKeyPath(subpath)+"]]")
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reports whether a value is a non-empty list of maps, which is written as an
+// array of tables.
+func isArrayOfTables(v interface{}) bool {
+	if kindOf(v) != "list" {
+		return false
+	}
+	n := 0
+	allMaps := true
+	iterateList(v, func(elem interface{}) error {
+		n++
+		allMaps = allMaps && kindOf(elem) == "map"
+		return nil
+	})
+	return n > 0 && allMaps
+}
+
+var tomlScalarStyle = scalarStyle{inf: "inf", negInf: "-inf", nan: "nan"}
+
+// Encodes a value in its inline form.
+func tomlInline(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return quoteTOML(s), nil
+	}
+	if s, ok := encodeScalar(v, tomlScalarStyle); ok {
+		return s, nil
+	}
+	var elems []string
+	switch kindOf(v) {
+	case "list":
+		err := iterateList(v, func(elem interface{}) error {
+			s, err := tomlInline(elem)
+			elems = append(elems, s)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case "map":
+		err := iterateMap(v, func(k string, v interface{}) error {
+			s, err := tomlInline(v)
+			elems = append(elems, tomlKey(k)+" = "+s)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		return "{" + strings.Join(elems, ", ") + "}", nil
+	default:
+		return "", cannotEncode(v, "TOML")
+	}
+}
+
+func tomlKeyPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+func tomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for i := 0; i < len(k); i++ {
+		if !isTOMLBareKeyChar(k[i]) {
+			return quoteTOML(k)
+		}
+	}
+	return k
+}
+
+// Quotes a string as a TOML basic string.
+func quoteTOML(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func isTOMLBareKeyChar(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' ||
+		b == '_' || b == '-'
+}
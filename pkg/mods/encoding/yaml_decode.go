@@ -0,0 +1,128 @@
+package encoding
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Converts YAML nodes to values that can be passed to toValue. Anchored nodes
+// are only converted once, so that all the aliases of an anchor share the same
+// value.
+type yamlConverter struct {
+	anchored map[*yaml.Node]interface{}
+	// Anchored nodes that are being converted, used to detect aliases that
+	// refer to an enclosing node.
+	pending map[*yaml.Node]bool
+}
+
+func newYAMLConverter() *yamlConverter {
+	return &yamlConverter{
+		make(map[*yaml.Node]interface{}), make(map[*yaml.Node]bool)}
+}
+
+func (c *yamlConverter) convert(n *yaml.Node) (interface{}, error) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Anchor == "" {
+		return c.convertNode(n)
+	}
+	if c.pending[n] {
+		return nil, yamlError(n, "anchor %q contains itself", n.Anchor)
+	}
+	if v, ok := c.anchored[n]; ok {
+		return v, nil
+	}
+	c.pending[n] = true
+	v, err := c.convertNode(n)
+	delete(c.pending, n)
+	c.anchored[n] = v
+	return v, err
+}
+
+func (c *yamlConverter) convertNode(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return c.convert(n.Content[0])
+	case yaml.SequenceNode:
+		l := make([]interface{}, len(n.Content))
+		for i, elem := range n.Content {
+			v, err := c.convert(elem)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = v
+		}
+		return l, nil
+	case yaml.MappingNode:
+		return c.convertMapping(n)
+	default:
+		// Integers of any size are preserved exactly. The library resolves
+		// plain integers that don't fit in 64 bits as floats.
+		tag := n.ShortTag()
+		if tag == "!!int" || tag == "!!float" && n.Style&yaml.TaggedStyle == 0 {
+			if v, ok := parseBigInt(n.Value, 0); ok {
+				return v, nil
+			}
+		}
+		var v interface{}
+		err := n.Decode(&v)
+		return v, err
+	}
+}
+
+// Converts a mapping. Keys are always converted to strings. The keys of
+// mappings merged with the "<<" key come after the other keys, and are only
+// used when they are not defined by the mapping itself.
+func (c *yamlConverter) convertMapping(n *yaml.Node) (interface{}, error) {
+	m := newMapping()
+	var merges []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		kn, vn := n.Content[i], n.Content[i+1]
+		if kn.Kind == yaml.AliasNode {
+			kn = kn.Alias
+		}
+		if kn.Kind != yaml.ScalarNode {
+			return nil, yamlError(kn, "mapping keys must be scalars")
+		}
+		if kn.ShortTag() == "!!merge" {
+			merges = append(merges, vn)
+			continue
+		}
+		if m.has(kn.Value) {
+			return nil, yamlError(kn, "duplicate key %q", kn.Value)
+		}
+		v, err := c.convert(vn)
+		if err != nil {
+			return nil, err
+		}
+		m.set(kn.Value, v)
+	}
+	for _, vn := range merges {
+		v, err := c.convert(vn)
+		if err != nil {
+			return nil, err
+		}
+		sources, ok := v.([]interface{})
+		if !ok {
+			sources = []interface{}{v}
+		}
+		for _, source := range sources {
+			source, ok := source.(*mapping)
+			if !ok {
+				return nil, yamlError(vn, "merge requires a mapping or a sequence of mappings")
+			}
+			for _, k := range source.keys {
+				if !m.has(k) {
+					m.set(k, source.values[k])
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+func yamlError(n *yaml.Node, format string, args ...interface{}) error {
+	return fmt.Errorf("yaml: line %d: %s", n.Line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,59 @@
+package encoding
+
+import "gopkg.in/yaml.v3"
+
+// Converts a value to a YAML node. Maps and lists are written in block style,
+// except empty ones, which are written as "{}" and "[]".
+func yamlNode(v interface{}) (*yaml.Node, error) {
+	if s, ok := v.(string); ok {
+		return yamlString(s)
+	}
+	if s, ok := encodeScalar(v, yamlScalarStyle); ok {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: s}, nil
+	}
+	var n *yaml.Node
+	switch kindOf(v) {
+	case "list":
+		n = &yaml.Node{Kind: yaml.SequenceNode}
+		err := iterateList(v, func(elem interface{}) error {
+			elemNode, err := yamlNode(elem)
+			n.Content = append(n.Content, elemNode)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	case "map":
+		n = &yaml.Node{Kind: yaml.MappingNode}
+		err := iterateMap(v, func(k string, v interface{}) error {
+			kNode, err := yamlString(k)
+			if err != nil {
+				return err
+			}
+			vNode, err := yamlNode(v)
+			n.Content = append(n.Content, kNode, vNode)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, cannotEncode(v, "YAML")
+	}
+	if len(n.Content) == 0 {
+		n.Style = yaml.FlowStyle
+	}
+	return n, nil
+}
+
+var yamlScalarStyle = scalarStyle{
+	null: "null", inf: ".inf", negInf: "-.inf", nan: ".nan"}
+
+// Converts a string to a YAML node. The library decides how to quote it, and
+// quotes strings that would be read back as other values, including the
+// booleans of YAML 1.1 like yes and on.
+func yamlString(s string) (*yaml.Node, error) {
+	var n yaml.Node
+	err := n.Encode(s)
+	return &n, err
+}
@@ -3,6 +3,7 @@ package mods
 
 import (
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/mods/encoding"
 	"src.elv.sh/pkg/mods/epm"
 	"src.elv.sh/pkg/mods/file"
 	"src.elv.sh/pkg/mods/math"
@@ -11,6 +12,7 @@ import (
 	"src.elv.sh/pkg/mods/re"
 	"src.elv.sh/pkg/mods/readlinebinding"
 	"src.elv.sh/pkg/mods/str"
+	"src.elv.sh/pkg/mods/vibinding"
 )
 
 // AddTo adds all standard library modules to the Evaler.
@@ -21,6 +23,8 @@ func AddTo(ev *eval.Evaler) {
 	ev.AddModule("re", re.Ns)
 	ev.AddModule("str", str.Ns)
 	ev.AddModule("file", file.Ns)
+	ev.AddModule("encoding", encoding.Ns)
 	ev.BundledModules["epm"] = epm.Code
 	ev.BundledModules["readline-binding"] = readlinebinding.Code
+	ev.BundledModules["vi-binding"] = vibinding.Code
 }
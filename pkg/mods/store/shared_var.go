@@ -0,0 +1,355 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// Values of shared variables are stored as strings. A string is stored as is,
+// so that the values of shared variables set before they could hold other
+// types can still be read. Any other value, as well as a string that starts
+// with typedPrefix, is stored as typedPrefix followed by a JSON encoding of the
+// value, where numbers are encoded as {"num": "..."} and maps as
+// {"map": [[k, v]...]}, with entries sorted so that equal values have the same
+// encoding.
+const typedPrefix = "\x00"
+
+func encodeValue(v interface{}) (string, error) {
+	if s, ok := v.(string); ok && !strings.HasPrefix(s, typedPrefix) {
+		return s, nil
+	}
+	j, err := toJSONValue(v)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(j)
+	if err != nil {
+		return "", err
+	}
+	return typedPrefix + string(b), nil
+}
+
+func toJSONValue(v interface{}) (interface{}, error) {
+	switch kind := vals.Kind(v); kind {
+	case "nil", "bool", "string":
+		return v, nil
+	case "number":
+		return map[string]string{"num": vals.ToString(v)}, nil
+	case "list":
+		l := []interface{}{}
+		var errElem error
+		err := vals.Iterate(v, func(elem interface{}) bool {
+			var j interface{}
+			j, errElem = toJSONValue(elem)
+			l = append(l, j)
+			return errElem == nil
+		})
+		if err == nil {
+			err = errElem
+		}
+		return l, err
+	case "map", "structmap":
+		type entry struct {
+			encodedKey string
+			pair       [2]interface{}
+		}
+		var entries []entry
+		var errEntry error
+		err := vals.IterateKeys(v, func(k interface{}) bool {
+			var e entry
+			e.pair[0], errEntry = toJSONValue(k)
+			if errEntry != nil {
+				return false
+			}
+			value, err := vals.Index(v, k)
+			if err != nil {
+				errEntry = err
+				return false
+			}
+			e.pair[1], errEntry = toJSONValue(value)
+			if errEntry != nil {
+				return false
+			}
+			b, _ := json.Marshal(e.pair[0])
+			e.encodedKey = string(b)
+			entries = append(entries, e)
+			return true
+		})
+		if err == nil {
+			err = errEntry
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].encodedKey < entries[j].encodedKey
+		})
+		pairs := make([][2]interface{}, len(entries))
+		for i, e := range entries {
+			pairs[i] = e.pair
+		}
+		return map[string]interface{}{"map": pairs}, err
+	default:
+		return nil, fmt.Errorf("cannot store value of kind %s in shared variable", kind)
+	}
+}
+
+var errMalformedValue = errors.New("malformed value of shared variable")
+
+func decodeValue(s string) (interface{}, error) {
+	if !strings.HasPrefix(s, typedPrefix) {
+		return s, nil
+	}
+	var j interface{}
+	err := json.Unmarshal([]byte(s[len(typedPrefix):]), &j)
+	if err != nil {
+		return nil, errMalformedValue
+	}
+	return fromJSONValue(j)
+}
+
+func fromJSONValue(j interface{}) (interface{}, error) {
+	switch j := j.(type) {
+	case nil, bool, string:
+		return j, nil
+	case []interface{}:
+		elems := make([]interface{}, len(j))
+		for i, elem := range j {
+			var err error
+			elems[i], err = fromJSONValue(elem)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return vals.MakeList(elems...), nil
+	case map[string]interface{}:
+		if num, ok := j["num"].(string); ok {
+			if n := vals.ParseNum(num); n != nil {
+				return n, nil
+			}
+		} else if pairs, ok := j["map"].([]interface{}); ok {
+			m := vals.EmptyMap
+			for _, pair := range pairs {
+				pair, ok := pair.([]interface{})
+				if !ok || len(pair) != 2 {
+					return nil, errMalformedValue
+				}
+				k, err := fromJSONValue(pair[0])
+				if err != nil {
+					return nil, err
+				}
+				v, err := fromJSONValue(pair[1])
+				if err != nil {
+					return nil, err
+				}
+				m = m.Assoc(k, v)
+			}
+			return m, nil
+		}
+	}
+	return nil, errMalformedValue
+}
+
+// Operations on shared variables with values of any type.
+type sharedVars struct{ s storedefs.Store }
+
+func (sv sharedVars) get(name string) (interface{}, error) {
+	value, err := sv.s.SharedVar(name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeValue(value)
+}
+
+func (sv sharedVars) set(name string, value interface{}) error {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	return sv.s.SetSharedVar(name, encoded)
+}
+
+func (sv sharedVars) compareAndSwap(name string, old, new interface{}) (bool, error) {
+	encodedOld, err := encodeValue(old)
+	if err != nil {
+		return false, err
+	}
+	encodedNew, err := encodeValue(new)
+	if err != nil {
+		return false, err
+	}
+	return sv.s.CompareAndSwapSharedVar(name, &encodedOld, &encodedNew)
+}
+
+func (sv sharedVars) init(name string, value interface{}) (bool, error) {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return false, err
+	}
+	return sv.s.CompareAndSwapSharedVar(name, nil, &encoded)
+}
+
+// Atomically replaces the value of a shared variable with the result of f,
+// which is called with the old value, or nil and false if the variable doesn't
+// exist. If the variable is changed concurrently, f is called again with the
+// new value.
+func (sv sharedVars) update(name string, f func(old interface{}, exists bool) (interface{}, error)) (interface{}, error) {
+	for {
+		var encodedOld *string
+		var old interface{}
+		value, err := sv.s.SharedVar(name)
+		if err == nil {
+			encodedOld = &value
+			old, err = decodeValue(value)
+			if err != nil {
+				return nil, err
+			}
+		} else if !isNoSharedVar(err) {
+			return nil, err
+		}
+		new, err := f(old, encodedOld != nil)
+		if err != nil {
+			return nil, err
+		}
+		encodedNew, err := encodeValue(new)
+		if err != nil {
+			return nil, err
+		}
+		swapped, err := sv.s.CompareAndSwapSharedVar(name, encodedOld, &encodedNew)
+		if err != nil {
+			return nil, err
+		}
+		if swapped {
+			return new, nil
+		}
+	}
+}
+
+// Errors from the daemon only keep the message.
+func isNoSharedVar(err error) bool {
+	return err.Error() == storedefs.ErrNoSharedVar.Error()
+}
+
+type incOpts struct{ By vals.Num }
+
+func (o *incOpts) SetDefaultOptions() { o.By = 1 }
+
+func (sv sharedVars) inc(opts incOpts, name string) (vals.Num, error) {
+	var by vals.Num
+	err := vals.ScanToGo(opts.By, &by)
+	if err != nil {
+		return nil, err
+	}
+	new, err := sv.update(name, func(old interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			return by, nil
+		}
+		var n vals.Num
+		err := vals.ScanToGo(old, &n)
+		if err != nil {
+			return nil, fmt.Errorf("shared variable %s is not a number", name)
+		}
+		return addNums(n, by), nil
+	})
+	return new, err
+}
+
+func addNums(a, b vals.Num) vals.Num {
+	switch nums := vals.UnifyNums([]vals.Num{a, b}, vals.BigInt).(type) {
+	case []*big.Int:
+		return vals.NormalizeBigInt(new(big.Int).Add(nums[0], nums[1]))
+	case []*big.Rat:
+		return vals.NormalizeBigRat(new(big.Rat).Add(nums[0], nums[1]))
+	case []float64:
+		return nums[0] + nums[1]
+	default:
+		panic("unreachable")
+	}
+}
+
+func (sv sharedVars) append(name string, values ...interface{}) error {
+	_, err := sv.update(name, func(old interface{}, exists bool) (interface{}, error) {
+		l := vals.EmptyList
+		if exists {
+			var ok bool
+			l, ok = old.(vals.List)
+			if !ok {
+				return nil, fmt.Errorf("shared variable %s is not a list", name)
+			}
+		}
+		for _, v := range values {
+			l = l.Cons(v)
+		}
+		return l, nil
+	})
+	return err
+}
+
+// A change to a shared variable, passed to the callback of
+// store:watch-shared-var.
+type sharedVarChange struct {
+	Name    string
+	Value   interface{}
+	Deleted bool
+}
+
+func (sharedVarChange) IsStructMap() {}
+
+// How long each request to watch a shared variable waits for a change. This
+// bounds how long an abandoned request keeps running after the watch is
+// interrupted.
+var watchTimeout = time.Second
+
+func (sv sharedVars) watch(fm *eval.Frame, name string, f eval.Callable) error {
+	version, err := sv.s.WatchSharedVar(name, -1, 0)
+	if err != nil {
+		return err
+	}
+	type result struct {
+		version int
+		err     error
+	}
+	for {
+		resultCh := make(chan result, 1)
+		go func() {
+			v, err := sv.s.WatchSharedVar(name, version, watchTimeout)
+			resultCh <- result{v, err}
+		}()
+		var r result
+		select {
+		case <-fm.Interrupts():
+			return eval.ErrInterrupted
+		case r = <-resultCh:
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if r.version == version {
+			continue
+		}
+		version = r.version
+
+		change := sharedVarChange{Name: name}
+		change.Value, err = sv.get(name)
+		if err != nil {
+			if !isNoSharedVar(err) {
+				return err
+			}
+			change.Deleted = true
+		}
+		err = f.Call(fm, []interface{}{change}, eval.NoOpts)
+		switch eval.Reason(err) {
+		case nil, eval.Continue:
+		case eval.Break:
+			return nil
+		default:
+			return err
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package store
 
 import (
+	"time"
+
 	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/store/histio"
 	"src.elv.sh/pkg/store/storedefs"
 )
 
@@ -48,14 +51,123 @@ import (
 //elvdoc:fn cmds
 //
 // ```elvish
-// store:cmds $from $upto
+// store:cmds &cwd='' &status='' &since='' &before='' &session='' $from $upto
 // ```
 //
 // Outputs all command history entries with sequence numbers between `$from`
 // (inclusive) and `$upto` (exclusive). Use -1 for `$upto` to not set an upper
 // bound.
 //
-// Each entry is represented by a pseudo-map with fields `text` and `seq`.
+// The options, when not empty, only keep entries whose metadata match them, in
+// the same way as the [history predicates](edit.html#history-predicates) in the
+// history listing mode, except that `&session=current` is not supported. The
+// matching is done by the storage backend, so only the matching entries are
+// retrieved. Example:
+//
+// ```elvish
+// store:cmds &cwd=~/work/api &since=yesterday &status=failed 0 -1
+// ```
+//
+// Each entry is represented by a pseudo-map with the following fields:
+//
+// -   `text`: the content of the command.
+//
+// -   `seq`: the sequence number.
+//
+// -   `dir`: the working directory when the command was run.
+//
+// -   `start`: the time when the command was run, in seconds since the Unix
+//     epoch.
+//
+// -   `duration`: how long the command ran, in seconds.
+//
+// -   `status`: the outcome of the command: `ok` if it finished normally,
+//     `exit $n` if an external command exited with status `$n`, `signal $name`
+//     if an external command was killed by a signal, `fail` if it threw an
+//     exception with [`fail`](builtin.html#fail), and `error` for any other
+//     exception.
+//
+// -   `host`: the hostname of the machine the command was run on.
+//
+// -   `session`: the ID of the interactive session the command was run in; see
+//     [`$edit:history:session-id`](edit.html#edithistorysession-id).
+//
+// The metadata fields other than `text` and `seq` are empty strings or 0 when
+// unknown, for example for commands added before Elvish recorded them, or
+// added with [`store:add-cmd`](#storeadd-cmd).
+//
+// Example:
+//
+// ```elvish-transcript
+// ~> store:cmds 1 2
+// ▶ [&text='echo foo' &seq=(num 1) &dir=/home/elf &start=(num 1634300001) &duration=(num 0.00123) &status=ok &host=elf-box &session=1234-1634300000]
+// ```
+
+//elvdoc:fn export-history
+//
+// ```elvish
+// store:export-history &format=json
+// ```
+//
+// Writes the entire command history to the byte output, in one of the
+// following formats:
+//
+// -   `bash`: The format of `~/.bash_history`, with timestamps in the form
+//     written when `HISTTIMEFORMAT` is set.
+//
+// -   `zsh`: The format of `~/.zsh_history` with the `EXTENDED_HISTORY` option.
+//
+// -   `fish`: The format of `~/.local/share/fish/fish_history`.
+//
+// -   `json`: One JSON object per line, with the same fields as the entries
+//     output by [`store:cmds`](#storecmds). This is the only format that keeps
+//     all the metadata of commands.
+//
+// Timestamps are only written for entries whose start times are known. Since
+// the `bash` format relies on timestamps to delimit entries, entries without
+// start times that span multiple lines can't be read back as one entry.
+//
+// Example:
+//
+// ```elvish
+// store:export-history &format=zsh > ~/.zsh_history
+// ```
+//
+// The same can be done without starting an interactive shell with `elvish
+// -export-history ~/.zsh_history`; the format is guessed from the file name
+// unless specified with `-history-format`.
+//
+// @cf store:import-history
+
+//elvdoc:fn import-history
+//
+// ```elvish
+// store:import-history &format=json
+// ```
+//
+// Reads command history from the byte input, in one of the formats supported
+// by [`store:export-history`](#storeexport-history), adds it to the command
+// history, and outputs the number of entries added.
+//
+// The `zsh` format also accepts history written without the `EXTENDED_HISTORY`
+// option, and the `bash` format also accepts history without timestamps.
+//
+// Imported entries are added after all existing entries, in the order of their
+// start times, with entries whose start times are unknown first. Entries with
+// a known start time are skipped if they have the same content and start time
+// as an existing entry or another imported entry, so importing the same
+// timestamped history twice has no effect. Entries whose start times are
+// unknown are always added, keeping their order.
+//
+// Example:
+//
+// ```elvish-transcript
+// ~> store:import-history &format=bash < ~/.bash_history
+// ▶ (num 1024)
+// ```
+//
+// The same can be done without starting an interactive shell with `elvish
+// -import-history ~/.bash_history`.
 
 //elvdoc:fn add-dir
 //
@@ -93,6 +205,9 @@ import (
 //
 // Outputs the value of the shared variable with the given name. Throws an error
 // if the shared variable doesn't exist.
+//
+// Shared variables are kept in the database, so they can be used to share
+// values between Elvish sessions, including those running concurrently.
 
 //elvdoc:fn set-shared-var
 //
@@ -101,7 +216,10 @@ import (
 // ```
 //
 // Sets the value of the shared variable with the given name, creating it if it
-// doesn't exist. The value must be a string.
+// doesn't exist. The value can be a string, number, boolean, `$nil`, or a list
+// or map of such values; the value read back has the same type.
+//
+// @cf store:cas-shared-var
 
 //elvdoc:fn del-shared-var
 //
@@ -111,22 +229,179 @@ import (
 //
 // Deletes the shared variable with the given name.
 
+//elvdoc:fn cas-shared-var
+//
+// ```elvish
+// store:cas-shared-var $name $old $new
+// ```
+//
+// Atomically compares the value of the shared variable with the given name
+// against `$old`, and if they are equal, sets it to `$new`. Outputs whether the
+// value was set. Outputs `$false` if the shared variable doesn't exist.
+//
+// Example:
+//
+// ```elvish-transcript
+// ~> store:set-shared-var mode light
+// ~> store:cas-shared-var mode light dark
+// ▶ $true
+// ~> store:cas-shared-var mode light dark
+// ▶ $false
+// ```
+//
+// @cf store:init-shared-var
+
+//elvdoc:fn init-shared-var
+//
+// ```elvish
+// store:init-shared-var $name $value
+// ```
+//
+// Atomically creates the shared variable with the given name with the given
+// value, if it doesn't exist yet. Outputs whether the variable was created.
+//
+// This can be used to make sure that only one session does something:
+//
+// ```elvish
+// if (store:init-shared-var lock $pid) {
+//   try { do-something } finally { store:del-shared-var lock }
+// }
+// ```
+
+//elvdoc:fn inc-shared-var
+//
+// ```elvish
+// store:inc-shared-var &by=(num 1) $name
+// ```
+//
+// Atomically adds `&by` to the value of the shared variable with the given
+// name, and outputs the new value. The variable is created with the value
+// `&by` if it doesn't exist. Throws an exception if the current value is not a
+// number.
+//
+// Example:
+//
+// ```elvish-transcript
+// ~> store:inc-shared-var counter
+// ▶ (num 1)
+// ~> store:inc-shared-var &by=10 counter
+// ▶ (num 11)
+// ```
+
+//elvdoc:fn append-shared-var
+//
+// ```elvish
+// store:append-shared-var $name $value...
+// ```
+//
+// Atomically appends the values to the list in the shared variable with the
+// given name. The variable is created with a list of the values if it doesn't
+// exist. Throws an exception if the current value is not a list.
+
+//elvdoc:fn watch-shared-var
+//
+// ```elvish
+// store:watch-shared-var $name $callback
+// ```
+//
+// Waits for changes to the shared variable with the given name, made from any
+// session, and calls `$callback` with a pseudo-map describing each change. The
+// pseudo-map has the following fields:
+//
+// -   `name`: The name of the variable.
+//
+// -   `value`: The new value of the variable, or `$nil` if it was deleted.
+//
+// -   `deleted`: Whether the variable was deleted.
+//
+// This command runs until interrupted or `$callback` calls `break`. Several
+// changes made in quick succession may be reported as one change.
+//
+// Example of one session waiting for a signal from another:
+//
+// ```elvish-transcript
+// ~> store:watch-shared-var build-done {|c| echo 'build finished: '$c[value]; break }
+// build finished: ok
+// ```
+//
+// While in another session:
+//
+// ```elvish-transcript
+// ~> store:set-shared-var build-done ok
+// ```
+
 func Ns(s storedefs.Store) *eval.Ns {
+	cmds := func(opts cmdsOpts, from, upto int) ([]storedefs.Cmd, error) {
+		q, err := opts.query()
+		if err != nil {
+			return nil, err
+		}
+		if q == (storedefs.CmdQuery{}) {
+			return s.CmdsWithSeq(from, upto)
+		}
+		return s.QueryCmds(from, upto, q)
+	}
+	sv := sharedVars{s}
 	return eval.NsBuilder{}.AddGoFns("store:", map[string]interface{}{
 		"next-cmd-seq": s.NextCmdSeq,
 		"add-cmd":      s.AddCmd,
 		"del-cmd":      s.DelCmd,
 		"cmd":          s.Cmd,
-		"cmds":         s.CmdsWithSeq,
+		"cmds":         cmds,
 		"next-cmd":     s.NextCmd,
 		"prev-cmd":     s.PrevCmd,
 
+		"export-history": func(fm *eval.Frame, opts historyOpts) error {
+			return histio.Export(s, fm.ByteOutput(), opts.Format)
+		},
+		"import-history": func(fm *eval.Frame, opts historyOpts) (int, error) {
+			imported, err := histio.Read(fm.InputFile(), opts.Format)
+			if err != nil {
+				return 0, err
+			}
+			return histio.Import(s, imported)
+		},
+
 		"add-dir": func(dir string) error { return s.AddDir(dir, 1) },
 		"del-dir": s.DelDir,
 		"dirs":    func() ([]storedefs.Dir, error) { return s.Dirs(storedefs.NoBlacklist) },
 
-		"shared-var":     s.SharedVar,
-		"set-shared-var": s.SetSharedVar,
-		"del-shared-var": s.DelSharedVar,
+		"shared-var":        sv.get,
+		"set-shared-var":    sv.set,
+		"del-shared-var":    s.DelSharedVar,
+		"cas-shared-var":    sv.compareAndSwap,
+		"init-shared-var":   sv.init,
+		"inc-shared-var":    sv.inc,
+		"append-shared-var": sv.append,
+		"watch-shared-var":  sv.watch,
 	}).Ns()
 }
+
+type historyOpts struct{ Format string }
+
+func (o *historyOpts) SetDefaultOptions() { o.Format = "json" }
+
+type cmdsOpts struct {
+	Cwd     string
+	Status  string
+	Since   string
+	Before  string
+	Session string
+}
+
+func (*cmdsOpts) SetDefaultOptions() {}
+
+func (opts cmdsOpts) query() (storedefs.CmdQuery, error) {
+	var q storedefs.CmdQuery
+	now := time.Now()
+	values := []string{opts.Cwd, opts.Status, opts.Since, opts.Before, opts.Session}
+	for i, key := range storedefs.CmdQueryKeys {
+		if values[i] == "" {
+			continue
+		}
+		if err := q.Set(key, values[i], now); err != nil {
+			return storedefs.CmdQuery{}, err
+		}
+	}
+	return q, nil
+}
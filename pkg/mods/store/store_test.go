@@ -1,10 +1,14 @@
 package store
 
 import (
+	"math/big"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"src.elv.sh/pkg/eval"
 	. "src.elv.sh/pkg/eval/evaltest"
+	"src.elv.sh/pkg/eval/vals"
 	"src.elv.sh/pkg/store"
 	"src.elv.sh/pkg/store/storedefs"
 	"src.elv.sh/pkg/testutil"
@@ -62,5 +66,189 @@ func TestStore(t *testing.T) {
 	)
 }
 
+func TestStore_CmdsWithQuery(t *testing.T) {
+	dir := testutil.InTempDir(t)
+	s, err := store.NewStore("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddCmdWithMeta(storedefs.Cmd{Text: "make", Dir: dir, Status: "exit 2", Start: 100})
+	s.AddCmdWithMeta(storedefs.Cmd{Text: "ls", Dir: filepath.Join(dir, "a"), Status: "ok", Start: 200})
+	s.AddCmdWithMeta(storedefs.Cmd{Text: "ls", Dir: filepath.Dir(dir), Status: "ok", Start: 300})
+	ns := Ns(s)
+
+	setup := func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("store", ns).Ns())
+	}
+	TestWithSetup(t, setup,
+		That("store:cmds &cwd=. 0 -1 | each {|c| put $c[text] }").
+			Puts("make", "ls"),
+		That("store:cmds &status=failed 0 -1 | each {|c| put $c[text] }").
+			Puts("make"),
+		That("store:cmds &status=2 0 -1 | each {|c| put $c[text] }").
+			Puts("make"),
+		That("store:cmds &since=200 &before=300 0 -1 | each {|c| put $c[seq] }").
+			Puts(2),
+		That("store:cmds &cwd=. 2 -1 | each {|c| put $c[seq] }").
+			Puts(2),
+		That("store:cmds &since=bad 0 -1").Throws(AnyError),
+	)
+}
+
+func TestStore_ImportExportHistory(t *testing.T) {
+	testutil.InTempDir(t)
+	s, err := store.NewStore("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns := Ns(s)
+
+	setup := func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("store", ns).Ns())
+	}
+	TestWithSetup(t, setup,
+		That("print ': 200:1;make\n: 100:0;ls\n' | store:import-history &format=zsh").
+			Puts(2),
+		That("print ': 100:0;ls\n' | store:import-history &format=zsh").
+			Puts(0),
+		That("store:export-history &format=bash").
+			Prints("#100\nls\n#200\nmake\n"),
+		That("store:export-history").
+			Prints(`{"text":"ls","seq":1,"start":100}`+"\n"+
+				`{"text":"make","seq":2,"start":200,"duration":1}`+"\n"),
+		That("store:export-history &format=csh").Throws(AnyError),
+		That("print '' | store:import-history &format=csh").Throws(AnyError),
+	)
+}
+
+func TestStore_TypedSharedVars(t *testing.T) {
+	setup := storeSetup(t)
+	TestWithSetup(t, setup,
+		// Values of any storable type round-trip.
+		That("store:set-shared-var v lorem; store:shared-var v").Puts("lorem"),
+		That("store:set-shared-var v (num 1); store:shared-var v").Puts(1),
+		That("store:set-shared-var v (num 1.5); store:shared-var v").Puts(1.5),
+		That("store:set-shared-var v (num 1/3); store:shared-var v").Puts(big.NewRat(1, 3)),
+		That("store:set-shared-var v (num 100000000000000000000); store:shared-var v").
+			Puts(bigInt("100000000000000000000")),
+		That("store:set-shared-var v $true; store:shared-var v").Puts(true),
+		That("store:set-shared-var v $nil; store:shared-var v").Puts(nil),
+		That("store:set-shared-var v [a (num 2) [&k=[v]]]; store:shared-var v").
+			Puts(vals.MakeList("a", 2, vals.MakeMap("k", vals.MakeList("v")))),
+		That("store:set-shared-var v [&(num 1)=a &[x]=b]; store:shared-var v").
+			Puts(vals.MakeMap(1, "a", vals.MakeList("x"), "b")),
+		// Strings that look like encoded values are still strings.
+		That("store:set-shared-var v \"\\x00[]\"; store:shared-var v").Puts("\x00[]"),
+		// Values that can't be stored.
+		That("store:set-shared-var v $put~").Throws(AnyError),
+		That("store:set-shared-var v [$put~]").Throws(AnyError),
+	)
+}
+
+func TestStore_SharedVarsSetAsStrings(t *testing.T) {
+	testutil.InTempDir(t)
+	s, err := store.NewStore("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetSharedVar("old", "[lorem]")
+	s.SetSharedVar("bad", "\x00[")
+	setup := func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("store", Ns(s)).Ns())
+	}
+
+	TestWithSetup(t, setup,
+		That("store:shared-var old").Puts("[lorem]"),
+		That("store:shared-var bad").Throws(errMalformedValue),
+	)
+}
+
+func TestStore_AtomicSharedVarOps(t *testing.T) {
+	setup := storeSetup(t)
+	TestWithSetup(t, setup,
+		// cas-shared-var
+		That("store:cas-shared-var v a b").Puts(false),
+		That("store:set-shared-var v [a]; store:cas-shared-var v [a] [b]").Puts(true),
+		That("store:cas-shared-var v [a] [c]").Puts(false),
+		That("store:shared-var v").Puts(vals.MakeList("b")),
+		That("store:cas-shared-var v [&] $put~").Throws(AnyError),
+		// Maps with the same entries are equal regardless of how they were
+		// built.
+		That("store:set-shared-var m [&a=1 &b=2]; store:cas-shared-var m (assoc [&b=2] a 1) x").
+			Puts(true),
+
+		// init-shared-var
+		That("store:init-shared-var i (num 1)").Puts(true),
+		That("store:init-shared-var i (num 2)").Puts(false),
+		That("store:shared-var i").Puts(1),
+
+		// inc-shared-var
+		That("store:inc-shared-var n").Puts(1),
+		That("store:inc-shared-var n").Puts(2),
+		That("store:inc-shared-var &by=(num 0.5) n").Puts(2.5),
+		That("store:inc-shared-var &by=10 n2").Puts(10),
+		That("store:set-shared-var s 5; store:inc-shared-var s").Puts(6),
+		That("store:set-shared-var s x; store:inc-shared-var s").Throws(AnyError),
+		That("store:inc-shared-var &by=x n").Throws(AnyError),
+
+		// append-shared-var
+		That("store:append-shared-var l a b; store:append-shared-var l (num 1); store:shared-var l").
+			Puts(vals.MakeList("a", "b", 1)),
+		That("store:set-shared-var s x; store:append-shared-var s a").Throws(AnyError),
+	)
+}
+
+func TestStore_WatchSharedVar(t *testing.T) {
+	testutil.InTempDir(t)
+	s, err := store.NewStore("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup := func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("store", Ns(s)).Ns())
+	}
+	// Make changes from another goroutine, as another session would.
+	change := func(f func()) func(ev *eval.Evaler) {
+		return func(ev *eval.Evaler) {
+			setup(ev)
+			go func() {
+				time.Sleep(testutil.Scaled(10 * time.Millisecond))
+				f()
+			}()
+		}
+	}
+
+	TestWithSetup(t, setup,
+		That("store:watch-shared-var v {|c| put $c; break }").
+			WithSetup(change(func() { s.SetSharedVar("v", "\x00[\"a\"]") })).
+			Puts(sharedVarChange{Name: "v", Value: vals.MakeList("a")}),
+		That("store:watch-shared-var v {|c| put $c; break }").
+			WithSetup(change(func() { s.DelSharedVar("v") })).
+			Puts(sharedVarChange{Name: "v", Deleted: true}),
+		That("store:watch-shared-var v {|c| fail foo }").
+			WithSetup(change(func() { s.SetSharedVar("v", "a") })).
+			Throws(eval.FailError{Content: "foo"}),
+	)
+}
+
+func storeSetup(t *testing.T) func(*eval.Evaler) {
+	testutil.InTempDir(t)
+	s, err := store.NewStore("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func(ev *eval.Evaler) {
+		ev.AddGlobal(eval.NsBuilder{}.AddNs("store", Ns(s)).Ns())
+	}
+}
+
+func bigInt(s string) *big.Int {
+	z, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("bad big int " + s)
+	}
+	return z
+}
+
 func cmd(s string, i int) storedefs.Cmd     { return storedefs.Cmd{Text: s, Seq: i} }
 func dir(s string, f float64) storedefs.Dir { return storedefs.Dir{Path: s, Score: f} }
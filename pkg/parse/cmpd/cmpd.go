@@ -21,8 +21,10 @@ func Primary(n *parse.Compound) (*parse.Primary, bool) {
 func StringLiteral(n *parse.Compound) (string, bool) {
 	if pn, ok := Primary(n); ok {
 		switch pn.Type {
-		case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted:
-			return pn.Value, true
+		case parse.Bareword, parse.SingleQuoted, parse.DoubleQuoted, parse.Heredoc:
+			if len(pn.Segments) == 0 {
+				return pn.Value, true
+			}
 		}
 	}
 	return "", false
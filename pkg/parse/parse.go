@@ -14,7 +14,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"src.elv.sh/pkg/diag"
 )
@@ -71,6 +73,7 @@ var (
 	errShouldBePipe               = newError("", "'|'")
 	errBothElementsAndPairs       = newError("cannot contain both list elements and map pairs")
 	errShouldBeNewline            = newError("", "newline")
+	errHeredocUnterminated        = newError("heredoc not terminated")
 )
 
 // Chunk = { PipelineSep | Space } { Pipeline { PipelineSep | Space } }
@@ -252,9 +255,13 @@ func ValidLHSVariable(p *Primary, allowSigil bool) bool {
 	case Braced:
 		// TODO(xiaq): check further inside braced expression
 		return true
-	case SingleQuoted, DoubleQuoted:
+	case SingleQuoted:
 		// Quoted variable names may contain anything
 		return true
+	case DoubleQuoted:
+		// Quoted variable names may contain anything, but may not be
+		// interpolated
+		return len(p.Segments) == 0
 	case Bareword:
 		// Bareword variable names may only contain runes that are valid in raw
 		// variable names
@@ -480,12 +487,19 @@ type Primary struct {
 	// Legacy lambda uses [args]{ body } instead of { |args| body }
 	LegacyLambda bool
 	// The unquoted string value. Valid for Bareword, SingleQuoted,
-	// DoubleQuoted, Variable, Wildcard and Tilde.
+	// DoubleQuoted, Heredoc, StringSegment, Variable, Wildcard and Tilde.
+	// For interpolated strings, this is empty and the value is determined by
+	// Segments instead.
 	Value    string
 	Elements []*Compound // Valid for List and Lambda
 	Chunk    *Chunk      // Valid for OutputCapture, ExitusCapture and Lambda
 	MapPairs []*MapPair  // Valid for Map and Lambda
 	Braced   []*Compound // Valid for Braced
+	// Literal text (as StringSegment) and embedded expressions (as Variable or
+	// OutputCapture) of an interpolated string, in order. Valid for
+	// DoubleQuoted and Heredoc, and only non-empty when the string contains any
+	// interpolation.
+	Segments []*Primary
 }
 
 // PrimaryType is the type of a Primary.
@@ -506,6 +520,8 @@ const (
 	Lambda
 	Map
 	Braced
+	Heredoc
+	StringSegment
 )
 
 func (pn *Primary) parse(ps *parser) {
@@ -524,9 +540,17 @@ func (pn *Primary) parse(ps *parser) {
 
 	switch r {
 	case '\'':
-		pn.singleQuoted(ps)
+		if startsHeredoc(ps, rawHeredocDelim) {
+			pn.heredoc(ps, rawHeredocDelim)
+		} else {
+			pn.singleQuoted(ps)
+		}
 	case '"':
-		pn.doubleQuoted(ps)
+		if startsHeredoc(ps, interpolatedHeredocDelim) {
+			pn.heredoc(ps, interpolatedHeredocDelim)
+		} else {
+			pn.doubleQuoted(ps)
+		}
 	case '$':
 		pn.variable(ps)
 	case '*':
@@ -580,14 +604,41 @@ func (pn *Primary) singleQuotedInner(ps *parser) {
 	}
 }
 
+// Parses a double-quoted string, which may contain interpolations like an
+// interpolated heredoc.
 func (pn *Primary) doubleQuoted(ps *parser) {
 	pn.Type = DoubleQuoted
 	ps.next()
-	pn.doubleQuotedInner(ps)
+	it := newInterpolator(pn, ps)
+	for {
+		if it.tryInterpolation() {
+			continue
+		}
+		switch r := ps.peek(); r {
+		case eof:
+			it.finish()
+			ps.error(errStringUnterminated)
+			return
+		case '"':
+			it.finish()
+			ps.next()
+			if len(pn.Segments) > 0 {
+				addSep(pn, ps)
+			}
+			return
+		case '\\':
+			ps.next()
+			parseEscape(ps, &it.buf)
+		default:
+			ps.next()
+			it.buf.WriteRune(r)
+		}
+	}
 }
 
-// Parses a double-quoted string after the opening quote. Sets pn.Value but not
-// pn.Type.
+// Parses a double-quoted string after the opening quote, without support for
+// interpolation. Sets pn.Value but not pn.Type. Used for quoted variable
+// names.
 func (pn *Primary) doubleQuotedInner(ps *parser) {
 	var buf bytes.Buffer
 	defer func() { pn.Value = buf.String() }()
@@ -599,74 +650,80 @@ func (pn *Primary) doubleQuotedInner(ps *parser) {
 		case '"':
 			return
 		case '\\':
-			switch r := ps.next(); r {
-			case 'c', '^': // control sequence
-				r := ps.next()
-				if r < 0x3F || r > 0x5F {
-					ps.backup()
-					ps.error(errInvalidEscapeControl)
-					ps.next()
-				}
-				if byte(r) == '?' { // special-case: \c? => del
-					buf.WriteByte(byte(0x7F))
-				} else {
-					buf.WriteByte(byte(r - 0x40))
-				}
-			case 'x', 'u', 'U': // two, four, or eight hex digits
-				var n int
-				switch r {
-				case 'x':
-					n = 2
-				case 'u':
-					n = 4
-				case 'U':
-					n = 8
-				}
-				var rr rune
-				for i := 0; i < n; i++ {
-					d, ok := hexToDigit(ps.next())
-					if !ok {
-						ps.backup()
-						ps.error(errInvalidEscapeHex)
-						break
-					}
-					rr = rr*16 + d
-				}
-				buf.WriteRune(rr)
-			case '0', '1', '2', '3', '4', '5', '6', '7': // three octal digits
-				rr := r - '0'
-				for i := 0; i < 2; i++ {
-					r := ps.next()
-					if r < '0' || r > '7' {
-						ps.backup()
-						ps.error(errInvalidEscapeOct)
-						break
-					}
-					rr = rr*8 + (r - '0')
-				}
-				buf.WriteRune(rr)
-			default:
-				if rr, ok := doubleEscape[r]; ok {
-					buf.WriteRune(rr)
-				} else {
-					ps.backup()
-					ps.error(errInvalidEscape)
-					ps.next()
-				}
-			}
+			parseEscape(ps, &buf)
 		default:
 			buf.WriteRune(r)
 		}
 	}
 }
 
+// Parses an escape sequence in a double-quoted string after the backslash, and
+// writes the rune it represents to buf.
+func parseEscape(ps *parser, buf *bytes.Buffer) {
+	switch r := ps.next(); r {
+	case 'c', '^': // control sequence
+		r := ps.next()
+		if r < 0x3F || r > 0x5F {
+			ps.backup()
+			ps.error(errInvalidEscapeControl)
+			ps.next()
+		}
+		if byte(r) == '?' { // special-case: \c? => del
+			buf.WriteByte(byte(0x7F))
+		} else {
+			buf.WriteByte(byte(r - 0x40))
+		}
+	case 'x', 'u', 'U': // two, four, or eight hex digits
+		var n int
+		switch r {
+		case 'x':
+			n = 2
+		case 'u':
+			n = 4
+		case 'U':
+			n = 8
+		}
+		var rr rune
+		for i := 0; i < n; i++ {
+			d, ok := hexToDigit(ps.next())
+			if !ok {
+				ps.backup()
+				ps.error(errInvalidEscapeHex)
+				break
+			}
+			rr = rr*16 + d
+		}
+		buf.WriteRune(rr)
+	case '0', '1', '2', '3', '4', '5', '6', '7': // three octal digits
+		rr := r - '0'
+		for i := 0; i < 2; i++ {
+			r := ps.next()
+			if r < '0' || r > '7' {
+				ps.backup()
+				ps.error(errInvalidEscapeOct)
+				break
+			}
+			rr = rr*8 + (r - '0')
+		}
+		buf.WriteRune(rr)
+	default:
+		if rr, ok := doubleEscape[r]; ok {
+			buf.WriteRune(rr)
+		} else {
+			ps.backup()
+			ps.error(errInvalidEscape)
+			ps.next()
+		}
+	}
+}
+
 // a table for the simple double-quote escape sequences.
 var doubleEscape = map[rune]rune{
 	// same as golang
 	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r',
 	't': '\t', 'v': '\v', '\\': '\\', '"': '"',
 	// additional
-	'e': '\033',
+	'e': '\033', '$': '$',
 }
 
 var doubleUnescape = map[rune]rune{}
@@ -690,6 +747,247 @@ func hexToDigit(r rune) (rune, bool) {
 	}
 }
 
+// Heredoc = RawHeredoc | InterpolatedHeredoc
+// RawHeredoc = "'''" Newline { Line } { Space } "'''"
+// InterpolatedHeredoc = '"""' Newline { Line } { Space } '"""'
+//
+// The body of a heredoc starts on the line after the opening delimiter, and
+// ends before the first line whose first non-whitespace characters are the
+// closing delimiter. The common leading whitespace of non-blank lines and the
+// line of the closing delimiter is stripped from all lines, and the newline
+// before the closing delimiter is not part of the value.
+//
+// A raw heredoc has no escape sequences. An interpolated heredoc supports the
+// same escape sequences and interpolation as double-quoted strings.
+
+const (
+	rawHeredocDelim          = "'''"
+	interpolatedHeredocDelim = `"""`
+)
+
+// Requiring the newline keeps triple quotes elsewhere parsing as quoted strings
+// like before. Code with the newline used to parse as quoted strings too, and
+// changes meaning.
+func startsHeredoc(ps *parser, delim string) bool {
+	return ps.hasPrefix(delim+"\n") || ps.hasPrefix(delim+"\r\n")
+}
+
+func (pn *Primary) heredoc(ps *parser, delim string) {
+	pn.Type = Heredoc
+	ps.pos += len(delim)
+	if ps.peek() == '\r' {
+		ps.next()
+	}
+	ps.next()
+	bodyBegin := ps.pos
+	bodyEnd, closeEnd, indent, ok := scanHeredoc(ps.src, bodyBegin, delim)
+	if !ok {
+		ps.pos = len(ps.src)
+		ps.error(errHeredocUnterminated)
+		return
+	}
+	if delim == rawHeredocDelim {
+		lines := strings.Split(ps.src[bodyBegin:bodyEnd], "\n")
+		for i, line := range lines {
+			lines[i] = dedentLine(line, indent)
+		}
+		pn.Value = strings.Join(lines, "\n")
+		ps.pos = closeEnd
+		return
+	}
+	pn.interpolatedInner(ps, bodyEnd, indent)
+	if ps.pos > bodyEnd {
+		// An output capture extends beyond the end of the body.
+		ps.error(errHeredocUnterminated)
+		return
+	}
+	ps.pos = closeEnd
+	if len(pn.Segments) > 0 {
+		addSep(pn, ps)
+	}
+}
+
+// Finds the extent of a heredoc body starting at begin. Returns the end of the
+// body, the end of the closing delimiter, the indentation to strip, and
+// whether the closing delimiter was found.
+func scanHeredoc(src string, begin int, delim string) (bodyEnd, closeEnd int, indent string, ok bool) {
+	first := true
+	for lineBegin := begin; ; {
+		lineEnd := strings.IndexByte(src[lineBegin:], '\n')
+		if lineEnd == -1 {
+			lineEnd = len(src)
+		} else {
+			lineEnd += lineBegin
+		}
+		line := src[lineBegin:lineEnd]
+		content := strings.TrimLeft(line, " \t")
+		leading := line[:len(line)-len(content)]
+		isClosing := strings.HasPrefix(content, delim)
+		if isClosing || strings.TrimRight(content, "\r") != "" {
+			if first {
+				indent = leading
+				first = false
+			} else {
+				indent = commonPrefix(indent, leading)
+			}
+		}
+		if isClosing {
+			bodyEnd = lineBegin - 1
+			if bodyEnd < begin {
+				bodyEnd = begin
+			}
+			return bodyEnd, lineBegin + len(leading) + len(delim), indent, true
+		}
+		if lineEnd == len(src) {
+			return 0, 0, "", false
+		}
+		lineBegin = lineEnd + 1
+	}
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+// Strips indent from a line. A line that doesn't start with indent can only be
+// blank, and is stripped of all leading whitespace.
+func dedentLine(line, indent string) string {
+	if strings.HasPrefix(line, indent) {
+		return line[len(indent):]
+	}
+	return strings.TrimLeft(line, " \t")
+}
+
+// Parses the body of an interpolated heredoc up to bodyEnd, stripping indent
+// from each line.
+func (pn *Primary) interpolatedInner(ps *parser, bodyEnd int, indent string) {
+	it := newInterpolator(pn, ps)
+	atLineStart := true
+	for ps.pos < bodyEnd {
+		if atLineStart {
+			atLineStart = false
+			rest := ps.src[ps.pos:bodyEnd]
+			if strings.HasPrefix(rest, indent) {
+				ps.pos += len(indent)
+			} else {
+				ps.pos += len(rest) - len(strings.TrimLeft(rest, " \t"))
+			}
+			continue
+		}
+		if it.tryInterpolation() {
+			continue
+		}
+		switch r := ps.next(); r {
+		case '\n':
+			it.buf.WriteRune(r)
+			atLineStart = true
+		case '\\':
+			parseEscape(ps, &it.buf)
+		default:
+			it.buf.WriteRune(r)
+		}
+	}
+	it.finish()
+}
+
+// Builds up the Segments of an interpolated string. If the string turns out
+// to contain no interpolation, only the Value is set.
+type interpolator struct {
+	pn  *Primary
+	ps  *parser
+	buf bytes.Buffer
+	// Beginning of the current StringSegment.
+	segBegin int
+}
+
+func newInterpolator(pn *Primary, ps *parser) *interpolator {
+	return &interpolator{pn: pn, ps: ps, segBegin: ps.pos}
+}
+
+// Parses an interpolated variable or output capture if the parser is at one,
+// and reports whether it did.
+func (it *interpolator) tryInterpolation() bool {
+	if !startsInterpolation(it.ps) {
+		return false
+	}
+	it.flushSegment()
+	it.addSegment(parseInterpolation(it.ps))
+	it.segBegin = it.ps.pos
+	return true
+}
+
+// Sets the Value of the Primary if there was no interpolation, and adds the
+// last StringSegment otherwise.
+func (it *interpolator) finish() {
+	if len(it.pn.Segments) == 0 {
+		it.pn.Value = it.buf.String()
+		return
+	}
+	it.flushSegment()
+}
+
+func (it *interpolator) flushSegment() {
+	ps := it.ps
+	if ps.pos > it.segBegin {
+		seg := &Primary{Type: StringSegment, Value: it.buf.String()}
+		seg.From, seg.To = it.segBegin, ps.pos
+		seg.sourceText = ps.src[it.segBegin:ps.pos]
+		it.addSegment(seg)
+	}
+	it.buf.Reset()
+}
+
+func (it *interpolator) addSegment(seg *Primary) {
+	if len(it.pn.Segments) == 0 {
+		// Add the opening delimiter as a Sep.
+		addChild(it.pn, NewSep(it.ps.src, it.pn.From, seg.From))
+	}
+	parsed{seg}.addTo(&it.pn.Segments, it.pn)
+}
+
+// Reports whether the parser is at a "$" that starts an interpolation: "$("
+// or "$" followed by a variable name, optionally with the "@" sigil.
+func startsInterpolation(ps *parser) bool {
+	if !ps.hasPrefix("$") {
+		return false
+	}
+	rest := ps.src[ps.pos+1:]
+	if strings.HasPrefix(rest, "(") {
+		return true
+	}
+	rest = strings.TrimPrefix(rest, "@")
+	r, size := utf8.DecodeRuneInString(rest)
+	return size > 0 && allowedInVariableName(r)
+}
+
+// Parses an interpolated variable or output capture, starting with "$".
+func parseInterpolation(ps *parser) *Primary {
+	begin := ps.pos
+	pn := &Primary{}
+	pn.From = begin
+	if ps.hasPrefix("$(") {
+		ps.next()
+		pn.outputCapture(ps)
+	} else {
+		pn.Type = Variable
+		ps.next()
+		if ps.peek() == '@' {
+			ps.next()
+		}
+		for allowedInVariableName(ps.peek()) {
+			ps.next()
+		}
+		pn.Value = ps.src[begin+1 : ps.pos]
+	}
+	pn.To = ps.pos
+	pn.sourceText = ps.src[begin:ps.pos]
+	return pn
+}
+
 func (pn *Primary) variable(ps *parser) {
 	pn.Type = Variable
 	ps.next()
@@ -244,6 +244,119 @@ var testCases = []struct {
 			"Value": "b\x1b\x1b\u548c\U0002CE23\123\n\t\\",
 		}},
 	},
+	{
+		name: "double-quoted string with interpolation",
+		code: `"a $x $@y$(b c)\$z $"`,
+		node: &Primary{},
+		want: ast{"Primary", fs{
+			"Type":  DoubleQuoted,
+			"Value": "",
+			"Segments": []ast{
+				{"Primary", fs{"Type": StringSegment, "Value": "a "}},
+				{"Primary", fs{"Type": Variable, "Value": "x"}},
+				{"Primary", fs{"Type": StringSegment, "Value": " "}},
+				{"Primary", fs{"Type": Variable, "Value": "@y"}},
+				{"Primary", fs{"Type": OutputCapture, "Chunk": "b c"}},
+				{"Primary", fs{"Type": StringSegment, "Value": "$z $"}},
+			},
+		}},
+	},
+	{
+		name: "double-quoted string with $ but no interpolation",
+		code: `"a$ \$x"`,
+		node: &Primary{},
+		want: ast{"Primary", fs{
+			"Type": DoubleQuoted, "Value": "a$ $x", "Segments": nil}},
+	},
+	{
+		name: "raw heredoc",
+		code: "'''\n  a 'b' \\n $x\n\n    c\n  '''",
+		node: &Primary{},
+		want: ast{"Primary", fs{
+			"Type":  Heredoc,
+			"Value": "a 'b' \\n $x\n\n  c",
+		}},
+	},
+	{
+		name: "raw heredoc with closing delimiter determining indentation",
+		code: "'''\n    a\n      b\n  '''",
+		node: &Primary{},
+		want: ast{"Primary", fs{"Type": Heredoc, "Value": "  a\n    b"}},
+	},
+	{
+		name: "empty heredoc",
+		code: "'''\n'''",
+		node: &Primary{},
+		want: ast{"Primary", fs{"Type": Heredoc, "Value": ""}},
+	},
+	{
+		name: "interpolated heredoc without interpolation",
+		code: "\"\"\"\n  a \"b\" \\t $ \\$x\n  \"\"\"",
+		node: &Primary{},
+		want: ast{"Primary", fs{
+			"Type":     Heredoc,
+			"Value":    "a \"b\" \t $ $x",
+			"Segments": nil,
+		}},
+	},
+	{
+		name: "interpolated heredoc with interpolation",
+		code: "\"\"\"\n  a $x\n  $(b c)$@y\n  \"\"\"",
+		node: &Primary{},
+		want: ast{"Primary", fs{
+			"Type":  Heredoc,
+			"Value": "",
+			"Segments": []ast{
+				{"Primary", fs{"Type": StringSegment, "Value": "a "}},
+				{"Primary", fs{"Type": Variable, "Value": "x"}},
+				{"Primary", fs{"Type": StringSegment, "Value": "\n"}},
+				{"Primary", fs{"Type": OutputCapture, "Chunk": "b c"}},
+				{"Primary", fs{"Type": Variable, "Value": "@y"}},
+			},
+		}},
+	},
+	// Pins down how ''' and """ parse with and without a newline. Before
+	// heredocs, the first case parsed as a single-quoted string with the value
+	// "'\na\n'".
+	{
+		name: "heredoc delimiter followed by newline",
+		code: "'''\na\n'''",
+		node: &Compound{},
+		want: ast{"Compound", fs{
+			"Indexings": []ast{
+				{"Indexing/Primary", fs{"Type": Heredoc, "Value": "a"}}},
+		}},
+	},
+	{
+		name: "triple single quotes not followed by newline",
+		code: "'''a'''",
+		node: &Compound{},
+		want: ast{"Compound", fs{
+			"Indexings": []ast{
+				{"Indexing/Primary", fs{"Type": SingleQuoted, "Value": "'a'"}}},
+		}},
+	},
+	{
+		name: "triple double quotes not followed by newline",
+		code: `"""a"""`,
+		node: &Compound{},
+		want: ast{"Compound", fs{
+			"Indexings": []ast{
+				{"Indexing/Primary", fs{"Type": DoubleQuoted, "Value": ""}},
+				{"Indexing/Primary", fs{"Type": DoubleQuoted, "Value": "a"}},
+				{"Indexing/Primary", fs{"Type": DoubleQuoted, "Value": ""}},
+			},
+		}},
+	},
+	{
+		name: "heredoc followed by indexing",
+		code: "'''\n  abc\n  '''[0]",
+		node: &Indexing{},
+		want: ast{"Indexing", fs{
+			"Head":    ast{"Primary", fs{"Type": Heredoc, "Value": "abc"}},
+			"Indices": []string{"0"},
+		}},
+	},
 	{
 		name: "wildcard",
 		code: "a * ? ** ??",
@@ -598,6 +711,13 @@ var testCases = []struct {
 		wantErrPart: "]",
 		wantErrMsg:  "unexpected rune ']'",
 	},
+	{
+		name:         "unterminated heredoc",
+		code:         "a '''\n  b\n",
+		node:         &Chunk{},
+		wantErrAtEnd: true,
+		wantErrMsg:   "heredoc not terminated",
+	},
 	{
 		name:        "unmatched }",
 		code:        "}",
@@ -9,6 +9,8 @@ import (
 
 var n = mustParse("ls $x[0]$y[1];echo done >/redir-dest")
 
+var heredoc = mustParse("echo \"\"\"\n  a $x\n  \"\"\"")
+
 var pprintASTTests = tt.Table{
 	tt.Args(n).Rets(
 		`Chunk
@@ -26,6 +28,13 @@ var pprintASTTests = tt.Table{
     Compound/Indexing/Primary ExprCtx=NormalExpr Type=Bareword LegacyLambda=false Value="done"
     Redir Mode=Write RightIsFd=false
       Compound/Indexing/Primary ExprCtx=NormalExpr Type=Bareword LegacyLambda=false Value="/redir-dest"
+`),
+	tt.Args(heredoc).Rets(
+		`Chunk/Pipeline/Form
+  Compound/Indexing/Primary ExprCtx=CmdExpr Type=Bareword LegacyLambda=false Value="echo"
+  Compound/Indexing/Primary ExprCtx=NormalExpr Type=Heredoc LegacyLambda=false Value=""
+    Primary ExprCtx=NormalExpr Type=StringSegment LegacyLambda=false Value="a "
+    Primary ExprCtx=NormalExpr Type=Variable LegacyLambda=false Value="x"
 `),
 }
 
@@ -63,6 +72,16 @@ var pprintParseTreeTests = tt.Table{
     Redir ">/redir-dest" 24-36
       Sep ">" 24-25
       Compound/Indexing/Primary "/redir-dest" 25-36
+`),
+	tt.Args(heredoc).Rets(
+		`Chunk/Pipeline/Form "echo \"\"\"\n  a $x\n  \"\"\"" 0-21
+  Compound/Indexing/Primary "echo" 0-4
+  Sep " " 4-5
+  Compound/Indexing/Primary "\"\"\"\n  a $x\n  \"\"\"" 5-21
+    Sep "\"\"\"\n" 5-9
+    Primary "  a " 9-13
+    Primary "$x" 13-15
+    Sep "\n  \"\"\"" 15-21
 `),
 }
 
@@ -42,6 +42,8 @@ type Flags struct {
 	Forked int
 
 	DB, Sock string
+
+	ImportHistory, ExportHistory, HistoryFormat string
 }
 
 func newFlagSet(f *Flags) *flag.FlagSet {
@@ -70,9 +72,13 @@ func newFlagSet(f *Flags) *flag.FlagSet {
 
 	fs.BoolVar(&f.Daemon, "daemon", false, "[internal flag] run the storage daemon instead of shell")
 
-	fs.StringVar(&f.DB, "db", "", "[internal flag] path to the database")
+	fs.StringVar(&f.DB, "db", "", "path to the database, or a URL like jsonl:///path/to/db selecting a storage backend")
 	fs.StringVar(&f.Sock, "sock", "", "[internal flag] path to the daemon socket")
 
+	fs.StringVar(&f.ImportHistory, "import-history", "", "import command history from a file (- for stdin) and quit")
+	fs.StringVar(&f.ExportHistory, "export-history", "", "export command history to a file (- for stdout) and quit")
+	fs.StringVar(&f.HistoryFormat, "history-format", "", "format for -import-history and -export-history: bash, zsh, fish or json; guessed from the file name if empty")
+
 	fs.IntVar(&DeprecationLevel, "deprecation-level", DeprecationLevel, "show warnings for all features deprecated as of version 0.X")
 
 	return fs
@@ -0,0 +1,89 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/store/histio"
+)
+
+var errHistoryNeedsDaemon = errors.New("importing or exporting history requires the daemon")
+
+// Imports and exports command history as specified by the -import-history,
+// -export-history and -history-format flags. Importing is done first when
+// both are specified.
+func transferHistory(fds [3]*os.File, f *prog.Flags, activate daemondefs.ActivateFunc) error {
+	if activate == nil {
+		return errHistoryNeedsDaemon
+	}
+	importFormat, err := historyFormat(f.HistoryFormat, f.ImportHistory)
+	if err != nil {
+		return err
+	}
+	exportFormat, err := historyFormat(f.HistoryFormat, f.ExportHistory)
+	if err != nil {
+		return err
+	}
+
+	spawnCfg, err := daemonPaths(f)
+	if err != nil {
+		return err
+	}
+	cl, err := activate(fds[2], spawnCfg)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	if f.ImportHistory != "" {
+		var r io.Reader = fds[0]
+		if f.ImportHistory != "-" {
+			file, err := os.Open(f.ImportHistory)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			r = file
+		}
+		cmds, err := histio.Read(r, importFormat)
+		if err != nil {
+			return err
+		}
+		n, err := histio.Import(cl, cmds)
+		fmt.Fprintf(fds[2], "Imported %d commands\n", n)
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.ExportHistory != "" {
+		var w io.Writer = fds[1]
+		if f.ExportHistory != "-" {
+			file, err := os.Create(f.ExportHistory)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+		return histio.Export(cl, w, exportFormat)
+	}
+	return nil
+}
+
+// Returns the history format for a file: the format specified by the flag,
+// or one guessed from the path.
+func historyFormat(flag, path string) (string, error) {
+	if flag != "" || path == "" {
+		return flag, nil
+	}
+	if format := histio.FormatFromPath(path); format != "" {
+		return format, nil
+	}
+	return "", prog.BadUsage(fmt.Sprintf(
+		"cannot guess the history format of %s; specify it with -history-format", path))
+}
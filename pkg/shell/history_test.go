@@ -0,0 +1,60 @@
+package shell
+
+import (
+	"io"
+	"testing"
+
+	"src.elv.sh/pkg/daemon/daemondefs"
+	. "src.elv.sh/pkg/prog/progtest"
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestTransferHistory(t *testing.T) {
+	testutil.InTempDir(t)
+	testutil.MustWriteFile(".bash_history", "#100\nls\n#200\nmake\n")
+	testutil.MustWriteFile("history.txt", "ls\n")
+	activate := fakeActivate(store.MustTempStore(t))
+
+	Test(t, Program{ActivateDaemon: activate},
+		ThatElvish("-import-history", ".bash_history").
+			WritesStderr("Imported 2 commands\n"),
+		ThatElvish("-import-history", "-", "-history-format", "zsh").
+			WithStdin(": 300:0;pwd\n").
+			WritesStderr("Imported 1 commands\n"),
+		ThatElvish("-export-history", "-", "-history-format", "zsh").
+			WritesStdout(": 100:0;ls\n: 200:0;make\n: 300:0;pwd\n"),
+		ThatElvish("-import-history", "history.txt").
+			ExitsWith(2).
+			WritesStderrContaining("cannot guess the history format of history.txt"),
+		ThatElvish("-import-history", "non-existent").
+			ExitsWith(2).
+			WritesStderrContaining("cannot guess"),
+		ThatElvish("-import-history", "non-existent.jsonl").
+			ExitsWith(2).
+			WritesStderrContaining("non-existent.jsonl"),
+	)
+}
+
+func TestTransferHistory_NoDaemon(t *testing.T) {
+	Test(t, Program{},
+		ThatElvish("-export-history", "-", "-history-format", "json").
+			ExitsWith(2).
+			WritesStderrContaining("requires the daemon"),
+	)
+}
+
+type fakeClient struct{ store.DBStore }
+
+// Close is a no-op, so that the store can be reused across runs.
+func (fakeClient) Close() error          { return nil }
+func (fakeClient) ResetConn() error      { return nil }
+func (fakeClient) Pid() (int, error)     { return 0, nil }
+func (fakeClient) SockPath() string      { return "" }
+func (fakeClient) Version() (int, error) { return 0, nil }
+
+func fakeActivate(s store.DBStore) daemondefs.ActivateFunc {
+	return func(io.Writer, *daemondefs.SpawnConfig) (daemondefs.Client, error) {
+		return fakeClient{s}, nil
+	}
+}
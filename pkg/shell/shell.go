@@ -29,6 +29,10 @@ type Program struct {
 }
 
 func (p Program) Run(fds [3]*os.File, f *prog.Flags, args []string) error {
+	if f.ImportHistory != "" || f.ExportHistory != "" {
+		return transferHistory(fds, f, p.ActivateDaemon)
+	}
+
 	cleanup1 := IncSHLVL()
 	defer cleanup1()
 	cleanup2 := initTTYAndSignal(fds[2])
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Backend opens a store at the given path, creating it if it doesn't exist.
+type Backend func(path string) (DBStore, error)
+
+var backends = map[string]Backend{}
+
+// DefaultBackend is the backend used for URLs without a scheme.
+const DefaultBackend = "bolt"
+
+// RegisterBackend makes a backend available under the given URL scheme. It
+// panics if a backend is already registered under the scheme.
+func RegisterBackend(scheme string, b Backend) {
+	if _, dup := backends[scheme]; dup {
+		panic("store backend already registered: " + scheme)
+	}
+	backends[scheme] = b
+}
+
+// Backends returns the URL schemes of all the registered backends, sorted.
+func Backends() []string {
+	schemes := make([]string, 0, len(backends))
+	for scheme := range backends {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// SplitURL splits a store URL in the form scheme://path into the scheme and
+// the path. A URL without "://" is just a path, and has an empty scheme.
+func SplitURL(url string) (scheme, path string) {
+	if i := strings.Index(url, "://"); i != -1 {
+		return url[:i], url[i+len("://"):]
+	}
+	return "", url
+}
+
+// NewStore opens the store specified by a URL in the form scheme://path,
+// where the scheme selects one of the registered backends. A URL without a
+// scheme is a path to a database of the default backend.
+func NewStore(url string) (DBStore, error) {
+	scheme, path := SplitURL(url)
+	if scheme == "" {
+		scheme = DefaultBackend
+	}
+	b, ok := backends[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown store backend %q, should be one of %s",
+			scheme, strings.Join(Backends(), ", "))
+	}
+	return b(path)
+}
@@ -0,0 +1,96 @@
+package store_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+var splitURLTests = []struct {
+	url, scheme, path string
+}{
+	{"db", "", "db"},
+	{"/home/u/db.bolt", "", "/home/u/db.bolt"},
+	{`C:\Users\u\db.bolt`, "", `C:\Users\u\db.bolt`},
+	{"jsonl://db.jsonl", "jsonl", "db.jsonl"},
+	{"jsonl:///home/u/db.jsonl", "jsonl", "/home/u/db.jsonl"},
+}
+
+func TestSplitURL(t *testing.T) {
+	for _, test := range splitURLTests {
+		scheme, path := store.SplitURL(test.url)
+		if scheme != test.scheme || path != test.path {
+			t.Errorf("SplitURL(%q) -> (%q, %q), want (%q, %q)",
+				test.url, scheme, path, test.scheme, test.path)
+		}
+	}
+}
+
+func TestBackends(t *testing.T) {
+	want := []string{"bolt", "jsonl", "sqlite"}
+	if got := store.Backends(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Backends() -> %v, want %v", got, want)
+	}
+}
+
+func TestNewStore_UnknownBackend(t *testing.T) {
+	_, err := store.NewStore("mysql://" + filepath.Join(t.TempDir(), "db"))
+	if err == nil {
+		t.Errorf("NewStore with unknown backend -> nil error")
+	}
+}
+
+func TestNewStore_Reopen(t *testing.T) {
+	for _, scheme := range store.Backends() {
+		t.Run(scheme, func(t *testing.T) {
+			url := scheme + "://" + filepath.Join(t.TempDir(), "db")
+			st := mustNewStore(t, url)
+			st.AddCmdWithMeta(storedefs.Cmd{Text: "echo foo", Dir: "/tmp"})
+			st.AddCmd("echo bar")
+			st.DelCmd(2)
+			st.AddDir("/tmp", 1)
+			st.SetSharedVar("foo", "bar")
+			st.Close()
+
+			st = mustNewStore(t, url)
+			defer st.Close()
+			cmds, _ := st.CmdsWithSeq(0, -1)
+			wantCmds := []storedefs.Cmd{{Text: "echo foo", Seq: 1, Dir: "/tmp"}}
+			if !reflect.DeepEqual(cmds, wantCmds) {
+				t.Errorf("got cmds %v, want %v", cmds, wantCmds)
+			}
+			if seq, _ := st.NextCmdSeq(); seq != 3 {
+				t.Errorf("got next seq %v, want 3", seq)
+			}
+			dirs, _ := st.Dirs(storedefs.NoBlacklist)
+			wantDirs := []storedefs.Dir{{Path: "/tmp", Score: store.DirScoreIncrement}}
+			if !reflect.DeepEqual(dirs, wantDirs) {
+				t.Errorf("got dirs %v, want %v", dirs, wantDirs)
+			}
+			if v, _ := st.SharedVar("foo"); v != "bar" {
+				t.Errorf("got shared var %q, want %q", v, "bar")
+			}
+		})
+	}
+}
+
+func mustNewStore(t *testing.T, url string) store.DBStore {
+	t.Helper()
+	st, err := store.NewStore(url)
+	if err != nil {
+		t.Fatalf("NewStore(%q) -> error %v", url, err)
+	}
+	return st
+}
+
+// Runs a test on a temporary store of every backend.
+func testBackends(t *testing.T, f func(*testing.T, storedefs.Store)) {
+	for _, scheme := range store.Backends() {
+		t.Run(scheme, func(t *testing.T) {
+			f(t, store.MustTempStoreWithBackend(t, scheme))
+		})
+	}
+}
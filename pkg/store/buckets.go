@@ -2,6 +2,7 @@ package store
 
 const (
 	bucketCmd       = "cmd"
+	bucketCmdMeta   = "cmd_meta"
 	bucketDir       = "dir"
 	bucketSharedVar = "shared_var"
 )
@@ -3,6 +3,7 @@ package store
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 
 	bolt "go.etcd.io/bbolt"
 	. "src.elv.sh/pkg/store/storedefs"
@@ -13,6 +14,23 @@ func init() {
 		_, err := tx.CreateBucketIfNotExists([]byte(bucketCmd))
 		return err
 	}
+	// Metadata of commands is kept in a separate bucket with the same keys, so
+	// that databases created before the metadata was recorded can be used
+	// as is; commands without an entry in this bucket have no metadata.
+	initDB["initialize command metadata table"] = func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(bucketCmdMeta))
+		return err
+	}
+}
+
+// Metadata of a command, stored as JSON in the cmd_meta bucket.
+type cmdMeta struct {
+	Dir      string  `json:",omitempty"`
+	Start    int     `json:",omitempty"`
+	Duration float64 `json:",omitempty"`
+	Status   string  `json:",omitempty"`
+	Host     string  `json:",omitempty"`
+	Session  string  `json:",omitempty"`
 }
 
 // NextCmdSeq returns the next sequence number of the command history.
@@ -28,26 +46,66 @@ func (s *dbStore) NextCmdSeq() (int, error) {
 
 // AddCmd adds a new command to the command history.
 func (s *dbStore) AddCmd(cmd string) (int, error) {
-	var (
-		seq uint64
-		err error
-	)
-	err = s.db.Update(func(tx *bolt.Tx) error {
+	return s.AddCmdWithMeta(Cmd{Text: cmd})
+}
+
+// AddCmdWithMeta adds a new command to the command history, along with its
+// metadata. The Seq field of the argument is ignored.
+func (s *dbStore) AddCmdWithMeta(cmd Cmd) (int, error) {
+	return s.AddCmds([]Cmd{cmd})
+}
+
+// AddCmds adds commands to the command history in one transaction, along with
+// their metadata. The commands get consecutive sequence numbers, and the
+// sequence number of the first one is returned. The Seq fields of the
+// arguments are ignored.
+func (s *dbStore) AddCmds(cmds []Cmd) (int, error) {
+	var first uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
-		seq, err = b.NextSequence()
-		if err != nil {
-			return err
+		first = b.Sequence() + 1
+		for _, cmd := range cmds {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			err = b.Put(marshalSeq(seq), []byte(cmd.Text))
+			if err != nil {
+				return err
+			}
+			err = putCmdMeta(tx, seq, metaOf(cmd))
+			if err != nil {
+				return err
+			}
 		}
-		return b.Put(marshalSeq(seq), []byte(cmd))
+		return nil
+	})
+	return int(first), err
+}
+
+// SetCmdResult records the duration and status of a command in the command
+// history.
+func (s *dbStore) SetCmdResult(seq int, duration float64, status string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(bucketCmd)).Get(marshalSeq(uint64(seq))) == nil {
+			return ErrNoMatchingCmd
+		}
+		meta := getCmdMeta(tx, uint64(seq))
+		meta.Duration = duration
+		meta.Status = status
+		return putCmdMeta(tx, uint64(seq), meta)
 	})
-	return int(seq), err
 }
 
 // DelCmd deletes a command history item with the given sequence number.
 func (s *dbStore) DelCmd(seq int) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketCmd))
-		return b.Delete(marshalSeq(uint64(seq)))
+		err := b.Delete(marshalSeq(uint64(seq)))
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(bucketCmdMeta)).Delete(marshalSeq(uint64(seq)))
 	})
 }
 
@@ -73,7 +131,7 @@ func (s *dbStore) IterateCmds(from, upto int, f func(Cmd)) error {
 		b := tx.Bucket([]byte(bucketCmd))
 		c := b.Cursor()
 		for k, v := c.Seek(marshalSeq(uint64(from))); k != nil && unmarshalSeq(k) < uint64(upto); k, v = c.Next() {
-			f(Cmd{Text: string(v), Seq: int(unmarshalSeq(k))})
+			f(makeCmd(tx, k, v))
 		}
 		return nil
 	})
@@ -88,6 +146,18 @@ func (s *dbStore) CmdsWithSeq(from, upto int) ([]Cmd, error) {
 	return cmds, err
 }
 
+// QueryCmds returns all commands within the specified range that match the
+// query.
+func (s *dbStore) QueryCmds(from, upto int, q CmdQuery) ([]Cmd, error) {
+	var cmds []Cmd
+	err := s.IterateCmds(from, upto, func(cmd Cmd) {
+		if q.Match(cmd) {
+			cmds = append(cmds, cmd)
+		}
+	})
+	return cmds, err
+}
+
 // NextCmd finds the first command after the given sequence number (inclusive)
 // with the given prefix.
 func (s *dbStore) NextCmd(from int, prefix string) (Cmd, error) {
@@ -98,7 +168,7 @@ func (s *dbStore) NextCmd(from int, prefix string) (Cmd, error) {
 		p := []byte(prefix)
 		for k, v := c.Seek(marshalSeq(uint64(from))); k != nil; k, v = c.Next() {
 			if bytes.HasPrefix(v, p) {
-				cmd = Cmd{Text: string(v), Seq: int(unmarshalSeq(k))}
+				cmd = makeCmd(tx, k, v)
 				return nil
 			}
 		}
@@ -129,7 +199,7 @@ func (s *dbStore) PrevCmd(upto int, prefix string) (Cmd, error) {
 
 		for ; k != nil; k, v = c.Prev() {
 			if bytes.HasPrefix(v, p) {
-				cmd = Cmd{Text: string(v), Seq: int(unmarshalSeq(k))}
+				cmd = makeCmd(tx, k, v)
 				return nil
 			}
 		}
@@ -138,6 +208,49 @@ func (s *dbStore) PrevCmd(upto int, prefix string) (Cmd, error) {
 	return cmd, err
 }
 
+// Builds a Cmd from a key and value in the cmd bucket, joined with its
+// metadata.
+func makeCmd(tx *bolt.Tx, k, v []byte) Cmd {
+	seq := unmarshalSeq(k)
+	meta := getCmdMeta(tx, seq)
+	return Cmd{
+		Text: string(v), Seq: int(seq),
+		Dir: meta.Dir, Start: meta.Start, Duration: meta.Duration,
+		Status: meta.Status, Host: meta.Host, Session: meta.Session}
+}
+
+func metaOf(cmd Cmd) cmdMeta {
+	return cmdMeta{
+		Dir: cmd.Dir, Start: cmd.Start, Duration: cmd.Duration,
+		Status: cmd.Status, Host: cmd.Host, Session: cmd.Session}
+}
+
+// Returns the metadata of the command with the given sequence number. Missing
+// or malformed metadata is treated as unknown.
+func getCmdMeta(tx *bolt.Tx, seq uint64) cmdMeta {
+	var meta cmdMeta
+	v := tx.Bucket([]byte(bucketCmdMeta)).Get(marshalSeq(seq))
+	if v != nil {
+		err := json.Unmarshal(v, &meta)
+		if err != nil {
+			logger.Printf("malformed metadata of command %d: %v", seq, err)
+		}
+	}
+	return meta
+}
+
+func putCmdMeta(tx *bolt.Tx, seq uint64, meta cmdMeta) error {
+	b := tx.Bucket([]byte(bucketCmdMeta))
+	if meta == (cmdMeta{}) {
+		return b.Delete(marshalSeq(seq))
+	}
+	v, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return b.Put(marshalSeq(seq), v)
+}
+
 func marshalSeq(seq uint64) []byte {
 	b := make([]byte, 8)
 	binary.BigEndian.PutUint64(b, seq)
@@ -1,12 +1,70 @@
 package store_test
 
 import (
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
 	"testing"
 
+	bolt "go.etcd.io/bbolt"
 	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
 	"src.elv.sh/pkg/store/storetest"
 )
 
 func TestCmd(t *testing.T) {
-	storetest.TestCmd(t, store.MustTempStore(t))
+	testBackends(t, storetest.TestCmd)
+}
+
+func TestCmdMeta(t *testing.T) {
+	testBackends(t, storetest.TestCmdMeta)
+}
+
+func TestAddCmds(t *testing.T) {
+	testBackends(t, storetest.TestAddCmds)
+}
+
+func TestCmdQuery(t *testing.T) {
+	testBackends(t, storetest.TestCmdQuery)
+}
+
+func TestCmdMeta_DatabaseWithoutMetadata(t *testing.T) {
+	// Create a database in the format used before command metadata was
+	// recorded, with just the "cmd" bucket.
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "db"), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("cmd"))
+		if err != nil {
+			return err
+		}
+		seq, _ := b.NextSequence()
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		return b.Put(key, []byte("echo old"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := store.NewStoreFromDB(db)
+	if err != nil {
+		t.Fatalf("NewStoreFromDB -> error %v", err)
+	}
+	defer st.Close()
+
+	seq, err := st.AddCmdWithMeta(storedefs.Cmd{Text: "echo new", Dir: "/tmp"})
+	if seq != 2 || err != nil {
+		t.Errorf("AddCmdWithMeta -> (%v, %v), want (2, nil)", seq, err)
+	}
+	cmds, err := st.CmdsWithSeq(0, -1)
+	wantCmds := []storedefs.Cmd{
+		{Text: "echo old", Seq: 1},
+		{Text: "echo new", Seq: 2, Dir: "/tmp"},
+	}
+	if !reflect.DeepEqual(cmds, wantCmds) || err != nil {
+		t.Errorf("CmdsWithSeq -> (%v, %v), want (%v, nil)", cmds, err, wantCmds)
+	}
 }
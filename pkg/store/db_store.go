@@ -27,6 +27,8 @@ type DBStore interface {
 type dbStore struct {
 	db *bolt.DB
 	wg sync.WaitGroup // used for registering outstanding operations on the store
+
+	varWatchers *varWatchers
 }
 
 func dbWithDefaultOptions(dbname string) (*bolt.DB, error) {
@@ -37,8 +39,12 @@ func dbWithDefaultOptions(dbname string) (*bolt.DB, error) {
 	return db, err
 }
 
-// NewStore creates a new Store from the given file.
-func NewStore(dbname string) (DBStore, error) {
+func init() {
+	RegisterBackend("bolt", newBoltStore)
+}
+
+// Creates a new Store from the given bolt database file.
+func newBoltStore(dbname string) (DBStore, error) {
 	db, err := dbWithDefaultOptions(dbname)
 	if err != nil {
 		return nil, err
@@ -53,6 +59,8 @@ func NewStoreFromDB(db *bolt.DB) (DBStore, error) {
 	st := &dbStore{
 		db: db,
 		wg: sync.WaitGroup{},
+
+		varWatchers: newVarWatchers(),
 	}
 
 	err := db.Update(func(tx *bolt.Tx) error {
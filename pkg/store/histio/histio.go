@@ -0,0 +1,391 @@
+// Package histio reads and writes command history in the formats used by other
+// shells, and imports and exports the command history of a store.
+package histio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+// Formats lists the supported formats.
+//
+//   - bash: The format of ~/.bash_history. Timestamps written when
+//     HISTTIMEFORMAT is set are supported. Commands spanning multiple lines
+//     can only be kept together when they have timestamps.
+//
+//   - zsh: The format of zsh's history file, with or without the
+//     EXTENDED_HISTORY option.
+//
+//   - fish: The YAML-like format of fish's fish_history.
+//
+//   - json: One JSON object per line, with all the fields of the command. This
+//     is the only format that keeps all the metadata.
+//
+// In all formats, the start times of commands are only written when they are
+// known.
+var Formats = []string{"bash", "zsh", "fish", "json"}
+
+// FormatFromPath guesses the format from the name of a history file. It
+// returns an empty string if the format can't be guessed.
+func FormatFromPath(path string) string {
+	name := filepath.Base(path)
+	switch {
+	case strings.Contains(name, "bash"):
+		return "bash"
+	case strings.Contains(name, "zsh") || name == ".histfile":
+		return "zsh"
+	case strings.Contains(name, "fish"):
+		return "fish"
+	case strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".jsonl"):
+		return "json"
+	}
+	return ""
+}
+
+// Read reads command history in the given format. The Seq field of the
+// returned commands is not set.
+func Read(r io.Reader, format string) ([]storedefs.Cmd, error) {
+	switch format {
+	case "bash":
+		return readBash(r)
+	case "zsh":
+		return readZsh(r)
+	case "fish":
+		return readFish(r)
+	case "json":
+		return readJSON(r)
+	}
+	return nil, badFormat(format)
+}
+
+// Write writes command history in the given format.
+func Write(w io.Writer, format string, cmds []storedefs.Cmd) error {
+	bw := bufio.NewWriter(w)
+	var write func(*bufio.Writer, storedefs.Cmd) error
+	switch format {
+	case "bash":
+		write = writeBash
+	case "zsh":
+		write = writeZsh
+	case "fish":
+		write = writeFish
+	case "json":
+		write = writeJSON
+	default:
+		return badFormat(format)
+	}
+	for _, cmd := range cmds {
+		if err := write(bw, cmd); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func badFormat(format string) error {
+	return fmt.Errorf("unsupported history format %q, should be one of %s",
+		format, strings.Join(Formats, ", "))
+}
+
+// Import adds commands to the command history of the store, and returns the
+// number of commands added.
+//
+// The commands are added in the order of their start times, with commands
+// whose start times are unknown first. Commands with a known start time are
+// skipped if they have the same text and start time as an existing command or
+// another imported command; commands whose start times are unknown are always
+// added, since they can't be told apart from repeated runs of the same
+// command.
+func Import(s storedefs.Store, cmds []storedefs.Cmd) (int, error) {
+	existing, err := s.CmdsWithSeq(0, -1)
+	if err != nil {
+		return 0, err
+	}
+	type key struct {
+		text  string
+		start int
+	}
+	seen := make(map[key]bool)
+	for _, cmd := range existing {
+		if cmd.Start != 0 {
+			seen[key{cmd.Text, cmd.Start}] = true
+		}
+	}
+
+	sorted := append([]storedefs.Cmd(nil), cmds...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	var toAdd []storedefs.Cmd
+	for _, cmd := range sorted {
+		if cmd.Start != 0 {
+			k := key{cmd.Text, cmd.Start}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		toAdd = append(toAdd, cmd)
+	}
+	if len(toAdd) == 0 {
+		return 0, nil
+	}
+	// Add all the commands at once, so that a big history doesn't need one
+	// request and one write to the database for each command.
+	if _, err := s.AddCmds(toAdd); err != nil {
+		return 0, err
+	}
+	return len(toAdd), nil
+}
+
+// Export writes the entire command history of the store in the given format.
+func Export(s storedefs.Store, w io.Writer, format string) error {
+	cmds, err := s.CmdsWithSeq(0, -1)
+	if err != nil {
+		return err
+	}
+	return Write(w, format, cmds)
+}
+
+// Bash.
+
+func readBash(r io.Reader) ([]storedefs.Cmd, error) {
+	var cmds []storedefs.Cmd
+	// All the lines after a timestamp are part of the same command; lines
+	// before the first timestamp are commands on their own.
+	timestamped := false
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if start, ok := parseBashTimestamp(line); ok {
+			timestamped = true
+			cmds = append(cmds, storedefs.Cmd{Start: start})
+			continue
+		}
+		if timestamped && len(cmds) > 0 {
+			last := &cmds[len(cmds)-1]
+			if last.Text == "" {
+				last.Text = line
+			} else {
+				last.Text += "\n" + line
+			}
+			continue
+		}
+		if line != "" {
+			cmds = append(cmds, storedefs.Cmd{Text: line})
+		}
+	}
+	return dropEmpty(cmds), scanner.Err()
+}
+
+// Parses a timestamp line, which is "#" followed by a positive number of
+// seconds since the Unix epoch.
+func parseBashTimestamp(line string) (int, bool) {
+	if len(line) < 2 || line[0] != '#' {
+		return 0, false
+	}
+	for _, r := range line[1:] {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+	start, err := strconv.Atoi(line[1:])
+	return start, err == nil && start > 0
+}
+
+func writeBash(w *bufio.Writer, cmd storedefs.Cmd) error {
+	if cmd.Start != 0 {
+		fmt.Fprintf(w, "#%d\n", cmd.Start)
+	}
+	_, err := fmt.Fprintf(w, "%s\n", cmd.Text)
+	return err
+}
+
+// Zsh.
+
+// Zsh "metafies" some bytes in the history file by writing them as this byte
+// followed by the original byte XOR 32.
+const zshMeta = 0x83
+
+func readZsh(r io.Reader) ([]storedefs.Cmd, error) {
+	var cmds []storedefs.Cmd
+	scanner := newScanner(r)
+	continued := false
+	for scanner.Scan() {
+		line := unmetafy(scanner.Text())
+		var cmd *storedefs.Cmd
+		if continued {
+			cmd = &cmds[len(cmds)-1]
+			cmd.Text += "\n"
+		} else {
+			cmds = append(cmds, storedefs.Cmd{})
+			cmd = &cmds[len(cmds)-1]
+			line = parseZshExtended(line, cmd)
+		}
+		// A trailing backslash continues the command on the next line.
+		continued = strings.HasSuffix(line, "\\")
+		cmd.Text += strings.TrimSuffix(line, "\\")
+	}
+	return dropEmpty(cmds), scanner.Err()
+}
+
+// Parses the ": start:duration;" prefix of the extended history format, and
+// returns the rest of the line.
+func parseZshExtended(line string, cmd *storedefs.Cmd) string {
+	if !strings.HasPrefix(line, ": ") {
+		return line
+	}
+	semicolon := strings.IndexByte(line, ';')
+	if semicolon == -1 {
+		return line
+	}
+	fields := strings.SplitN(line[2:semicolon], ":", 2)
+	start, err1 := strconv.Atoi(fields[0])
+	duration, err2 := 0, error(nil)
+	if len(fields) == 2 {
+		duration, err2 = strconv.Atoi(fields[1])
+	}
+	if err1 != nil || err2 != nil {
+		return line
+	}
+	cmd.Start, cmd.Duration = start, float64(duration)
+	return line[semicolon+1:]
+}
+
+func unmetafy(s string) string {
+	if strings.IndexByte(s, zshMeta) == -1 {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == zshMeta && i+1 < len(s) {
+			i++
+			sb.WriteByte(s[i] ^ 32)
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+func metafy(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		// Zsh metafies NUL and the bytes from its Meta (0x83) to its Marker
+		// (0xa2) characters.
+		if b := s[i]; b == 0 || (zshMeta <= b && b <= 0xa2) {
+			sb.WriteByte(zshMeta)
+			sb.WriteByte(b ^ 32)
+		} else {
+			sb.WriteByte(b)
+		}
+	}
+	return sb.String()
+}
+
+func writeZsh(w *bufio.Writer, cmd storedefs.Cmd) error {
+	if cmd.Start != 0 {
+		fmt.Fprintf(w, ": %d:%d;", cmd.Start, int(cmd.Duration))
+	}
+	text := metafy(strings.ReplaceAll(cmd.Text, "\n", "\\\n"))
+	_, err := fmt.Fprintf(w, "%s\n", text)
+	return err
+}
+
+// Fish.
+
+func readFish(r io.Reader) ([]storedefs.Cmd, error) {
+	var cmds []storedefs.Cmd
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if text := strings.TrimPrefix(line, "- cmd: "); text != line {
+			cmds = append(cmds, storedefs.Cmd{Text: unescapeFish(text)})
+		} else if when := strings.TrimPrefix(line, "  when: "); when != line && len(cmds) > 0 {
+			start, err := strconv.Atoi(when)
+			if err == nil {
+				cmds[len(cmds)-1].Start = start
+			}
+		}
+		// Other fields, like paths, are ignored.
+	}
+	return dropEmpty(cmds), scanner.Err()
+}
+
+var (
+	fishEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	fishUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+func unescapeFish(s string) string { return fishUnescaper.Replace(s) }
+
+func writeFish(w *bufio.Writer, cmd storedefs.Cmd) error {
+	_, err := fmt.Fprintf(w, "- cmd: %s\n", fishEscaper.Replace(cmd.Text))
+	if cmd.Start != 0 {
+		_, err = fmt.Fprintf(w, "  when: %d\n", cmd.Start)
+	}
+	return err
+}
+
+// JSON lines.
+
+// The JSON representation of a command, using the same field names as
+// store:cmds.
+type jsonCmd struct {
+	Text     string  `json:"text"`
+	Seq      int     `json:"seq,omitempty"`
+	Dir      string  `json:"dir,omitempty"`
+	Start    int     `json:"start,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	Status   string  `json:"status,omitempty"`
+	Host     string  `json:"host,omitempty"`
+	Session  string  `json:"session,omitempty"`
+}
+
+func readJSON(r io.Reader) ([]storedefs.Cmd, error) {
+	var cmds []storedefs.Cmd
+	dec := json.NewDecoder(r)
+	for {
+		var c jsonCmd
+		err := dec.Decode(&c)
+		if err == io.EOF {
+			return cmds, nil
+		} else if err != nil {
+			return cmds, err
+		}
+		cmds = append(cmds, storedefs.Cmd{
+			Text: c.Text, Dir: c.Dir, Start: c.Start, Duration: c.Duration,
+			Status: c.Status, Host: c.Host, Session: c.Session})
+	}
+}
+
+func writeJSON(w *bufio.Writer, cmd storedefs.Cmd) error {
+	c := jsonCmd(cmd)
+	// json.Encoder writes a newline after each value.
+	return json.NewEncoder(w).Encode(c)
+}
+
+// Helpers.
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	// Allow long commands.
+	scanner.Buffer(nil, 1024*1024)
+	return scanner
+}
+
+func dropEmpty(cmds []storedefs.Cmd) []storedefs.Cmd {
+	var nonEmpty []storedefs.Cmd
+	for _, cmd := range cmds {
+		if cmd.Text != "" {
+			nonEmpty = append(nonEmpty, cmd)
+		}
+	}
+	return nonEmpty
+}
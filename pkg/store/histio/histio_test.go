@@ -0,0 +1,204 @@
+package histio
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/store"
+	"src.elv.sh/pkg/store/storedefs"
+)
+
+var readTests = []struct {
+	name   string
+	format string
+	input  string
+	want   []storedefs.Cmd
+}{
+	{
+		name:   "bash without timestamps",
+		format: "bash",
+		input:  "ls\n\necho foo\n",
+		want:   []storedefs.Cmd{{Text: "ls"}, {Text: "echo foo"}},
+	},
+	{
+		name:   "bash with timestamps",
+		format: "bash",
+		input:  "#100\nls\n#200\nfor x in a b; do\necho $x\ndone\n",
+		want: []storedefs.Cmd{
+			{Text: "ls", Start: 100},
+			{Text: "for x in a b; do\necho $x\ndone", Start: 200}},
+	},
+	{
+		name:   "bash with lines before the first timestamp",
+		format: "bash",
+		input:  "pwd\n#0\n#100\nls\n",
+		want: []storedefs.Cmd{
+			{Text: "pwd"}, {Text: "#0"}, {Text: "ls", Start: 100}},
+	},
+	{
+		name:   "zsh extended",
+		format: "zsh",
+		input:  ": 100:3;make\n: 200:0;echo a\\\necho b\n",
+		want: []storedefs.Cmd{
+			{Text: "make", Start: 100, Duration: 3},
+			{Text: "echo a\necho b", Start: 200}},
+	},
+	{
+		name:   "zsh simple",
+		format: "zsh",
+		input:  "ls\n: not extended\n",
+		want:   []storedefs.Cmd{{Text: "ls"}, {Text: ": not extended"}},
+	},
+	{
+		name:   "zsh metafied",
+		format: "zsh",
+		input:  ": 100:0;echo \xc6\x83\xb2\n",
+		want:   []storedefs.Cmd{{Text: "echo \xc6\x92", Start: 100}},
+	},
+	{
+		name:   "fish",
+		format: "fish",
+		input:  "- cmd: ls\n  when: 100\n- cmd: echo a\\nb \\\\n\n  when: 200\n  paths:\n    - b\n",
+		want: []storedefs.Cmd{
+			{Text: "ls", Start: 100},
+			{Text: "echo a\nb \\n", Start: 200}},
+	},
+	{
+		name:   "json",
+		format: "json",
+		input: `{"text":"ls","seq":5,"dir":"/tmp","start":100,"duration":1.5,"status":"ok","host":"h","session":"s"}` + "\n" +
+			`{"text":"pwd"}` + "\n",
+		want: []storedefs.Cmd{
+			{Text: "ls", Dir: "/tmp", Start: 100, Duration: 1.5, Status: "ok", Host: "h", Session: "s"},
+			{Text: "pwd"}},
+	},
+}
+
+func TestRead(t *testing.T) {
+	for _, test := range readTests {
+		t.Run(test.name, func(t *testing.T) {
+			cmds, err := Read(strings.NewReader(test.input), test.format)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if !reflect.DeepEqual(cmds, test.want) {
+				t.Errorf("got %#v, want %#v", cmds, test.want)
+			}
+		})
+	}
+}
+
+func TestRead_BadFormat(t *testing.T) {
+	_, err := Read(strings.NewReader(""), "csh")
+	if err == nil {
+		t.Errorf("got nil error")
+	}
+}
+
+var writeTests = []struct {
+	format string
+	want   string
+}{
+	{"bash", "echo \xc6\x92\n#100\nls\n#200\necho a\necho b\n"},
+	{"zsh", "echo \xc6\x83\xb2\n: 100:2;ls\n: 200:0;echo a\\\necho b\n"},
+	{"fish", "- cmd: echo \xc6\x92\n- cmd: ls\n  when: 100\n- cmd: echo a\\necho b\n  when: 200\n"},
+	{"json", `{"text":"echo ƒ","seq":1}` + "\n" +
+		`{"text":"ls","seq":2,"dir":"/tmp","start":100,"duration":2,"status":"ok"}` + "\n" +
+		`{"text":"echo a\necho b","seq":3,"start":200}` + "\n"},
+}
+
+var writeCmds = []storedefs.Cmd{
+	{Text: "echo \xc6\x92", Seq: 1},
+	{Text: "ls", Seq: 2, Dir: "/tmp", Start: 100, Duration: 2, Status: "ok"},
+	{Text: "echo a\necho b", Seq: 3, Start: 200},
+}
+
+func TestWrite(t *testing.T) {
+	for _, test := range writeTests {
+		t.Run(test.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Write(&buf, test.format, writeCmds)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if buf.String() != test.want {
+				t.Errorf("got %q, want %q", buf.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestWriteThenRead(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			Write(&buf, format, writeCmds)
+			cmds, err := Read(&buf, format)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			texts := make([]string, len(cmds))
+			for i, cmd := range cmds {
+				texts[i] = cmd.Text
+			}
+			wantTexts := []string{"echo \xc6\x92", "ls", "echo a\necho b"}
+			if !reflect.DeepEqual(texts, wantTexts) {
+				t.Errorf("got texts %q, want %q", texts, wantTexts)
+			}
+		})
+	}
+}
+
+var formatFromPathTests = []struct {
+	path string
+	want string
+}{
+	{"/home/u/.bash_history", "bash"},
+	{"/home/u/.zsh_history", "zsh"},
+	{"/home/u/.histfile", "zsh"},
+	{"/home/u/.local/share/fish/fish_history", "fish"},
+	{"history.jsonl", "json"},
+	{"history.txt", ""},
+}
+
+func TestFormatFromPath(t *testing.T) {
+	for _, test := range formatFromPathTests {
+		if got := FormatFromPath(test.path); got != test.want {
+			t.Errorf("FormatFromPath(%q) -> %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	s := store.MustTempStore(t)
+	s.AddCmdWithMeta(storedefs.Cmd{Text: "existing", Start: 150})
+
+	n, err := Import(s, []storedefs.Cmd{
+		{Text: "late", Start: 300},
+		{Text: "existing", Start: 150},
+		{Text: "early", Start: 100},
+		{Text: "late", Start: 300},
+		{Text: "late", Start: 400},
+		{Text: "unknown"},
+		{Text: "other"},
+		{Text: "unknown"},
+	})
+	if err != nil {
+		t.Fatalf("Import -> error %v", err)
+	}
+	if n != 6 {
+		t.Errorf("Import -> %v, want 6", n)
+	}
+
+	var buf bytes.Buffer
+	err = Export(s, &buf, "bash")
+	if err != nil {
+		t.Fatalf("Export -> error %v", err)
+	}
+	want := "#150\nexisting\nunknown\nother\nunknown\n#100\nearly\n#300\nlate\n#400\nlate\n"
+	if buf.String() != want {
+		t.Errorf("Export wrote %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,497 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+func init() {
+	RegisterBackend("jsonl", newJSONLStore)
+}
+
+// A store backed by an append-only file with one JSON object per line, each
+// recording a change to the store. The state of the store is rebuilt by
+// replaying all the changes when the file is opened, and changes appended by
+// other processes are replayed before every operation. Unlike bolt, the file
+// is not locked, so it can be shared by multiple processes without the daemon.
+//
+// Sequence numbers of commands are not recorded, but derived from the order
+// of the records adding commands, so that concurrent appends from different
+// processes never assign the same sequence number twice.
+type jsonlStore struct {
+	mu   sync.Mutex
+	file *os.File
+	// Offset up to which the file has been replayed.
+	offset int64
+
+	// Commands, ordered by sequence number.
+	cmds    []Cmd
+	nextSeq int
+	dirs    map[string]float64
+	vars    map[string]string
+
+	varWatchers *varWatchers
+	// Whether the last cas-var record replayed has swapped the value.
+	swapped bool
+}
+
+// Operations recorded in the file.
+const (
+	opAddCmd       = "add-cmd"
+	opSetCmdResult = "set-cmd-result"
+	opDelCmd       = "del-cmd"
+	opAddDir       = "add-dir"
+	opDelDir       = "del-dir"
+	opSetVar       = "set-var"
+	opDelVar       = "del-var"
+	opCASVar       = "cas-var"
+)
+
+// A line of the file. Only the fields relevant to the operation are set.
+type jsonlRecord struct {
+	Op string `json:"op"`
+
+	Seq      int     `json:"seq,omitempty"`
+	Text     string  `json:"text,omitempty"`
+	Dir      string  `json:"dir,omitempty"`
+	Start    int     `json:"start,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	Status   string  `json:"status,omitempty"`
+	Host     string  `json:"host,omitempty"`
+	Session  string  `json:"session,omitempty"`
+
+	Path      string  `json:"path,omitempty"`
+	IncFactor float64 `json:"inc_factor,omitempty"`
+
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+	// For cas-var; nil means that the variable doesn't exist.
+	Old *string `json:"old,omitempty"`
+	New *string `json:"new,omitempty"`
+}
+
+func newJSONLStore(path string) (DBStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &jsonlStore{file: file, nextSeq: 1,
+		dirs: make(map[string]float64), vars: make(map[string]string),
+		varWatchers: newVarWatchers()}
+	err = s.replay(-1)
+	if err == nil {
+		err = s.terminateLastLine()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Terminates an incomplete last line, which can be left in the file by a
+// process that crashed while writing; otherwise the next record appended would
+// be joined with it.
+func (s *jsonlStore) terminateLastLine() error {
+	info, err := s.file.Stat()
+	if err != nil || info.Size() == s.offset {
+		return err
+	}
+	_, err = s.file.Write([]byte("\n"))
+	if err != nil {
+		return err
+	}
+	return s.replay(-1)
+}
+
+// Close closes the file.
+func (s *jsonlStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// Replays the complete lines between the offset and the given limit, or the
+// end of the file if the limit is negative. Must be called with the mutex
+// held.
+func (s *jsonlStore) replay(limit int64) error {
+	if limit < 0 {
+		info, err := s.file.Stat()
+		if err != nil {
+			return err
+		}
+		limit = info.Size()
+	}
+	if limit <= s.offset {
+		return nil
+	}
+	buf := make([]byte, limit-s.offset)
+	n, err := s.file.ReadAt(buf, s.offset)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	// An incomplete last line is still being written by another process; it
+	// will be replayed next time.
+	buf = buf[:bytes.LastIndexByte(buf[:n], '\n')+1]
+	for _, line := range bytes.SplitAfter(buf, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var rec jsonlRecord
+		err := json.Unmarshal(line, &rec)
+		if err != nil {
+			logger.Printf("malformed record at offset %d: %v", s.offset, err)
+		} else {
+			s.apply(rec)
+		}
+	}
+	s.offset += int64(len(buf))
+	return nil
+}
+
+func (s *jsonlStore) apply(rec jsonlRecord) {
+	switch rec.Op {
+	case opAddCmd:
+		s.cmds = append(s.cmds, Cmd{
+			Text: rec.Text, Seq: s.nextSeq,
+			Dir: rec.Dir, Start: rec.Start, Duration: rec.Duration,
+			Status: rec.Status, Host: rec.Host, Session: rec.Session})
+		s.nextSeq++
+	case opSetCmdResult:
+		if i, ok := s.findCmd(rec.Seq); ok {
+			s.cmds[i].Duration = rec.Duration
+			s.cmds[i].Status = rec.Status
+		}
+	case opDelCmd:
+		if i, ok := s.findCmd(rec.Seq); ok {
+			s.cmds = append(s.cmds[:i], s.cmds[i+1:]...)
+		}
+	case opAddDir:
+		for dir, score := range s.dirs {
+			s.dirs[dir] = score * DirScoreDecay
+		}
+		s.dirs[rec.Path] += DirScoreIncrement * rec.IncFactor
+	case opDelDir:
+		delete(s.dirs, rec.Path)
+	case opSetVar:
+		s.vars[rec.Name] = rec.Value
+		s.varWatchers.notify(rec.Name)
+	case opDelVar:
+		delete(s.vars, rec.Name)
+		s.varWatchers.notify(rec.Name)
+	case opCASVar:
+		v, exists := s.vars[rec.Name]
+		s.swapped = exists == (rec.Old != nil) && (!exists || v == *rec.Old)
+		if !s.swapped {
+			break
+		}
+		if rec.New == nil {
+			delete(s.vars, rec.Name)
+		} else {
+			s.vars[rec.Name] = *rec.New
+		}
+		s.varWatchers.notify(rec.Name)
+	default:
+		logger.Printf("unknown operation %q", rec.Op)
+	}
+}
+
+// Appends a record to the file, and replays the file up to the end of the
+// record. Must be called with the mutex held.
+func (s *jsonlStore) append(recs ...jsonlRecord) error {
+	var lines []byte
+	for _, rec := range recs {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	// With O_APPEND, the lines are written at the end of the file atomically,
+	// and the offset of the file is left at the end of the lines.
+	_, err := s.file.Write(lines)
+	if err != nil {
+		return err
+	}
+	end, err := s.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	return s.replay(end)
+}
+
+// Locks the mutex and replays all the changes made by other processes. If
+// there is no error, the caller must unlock the mutex.
+func (s *jsonlStore) lockAndReplay() error {
+	s.mu.Lock()
+	err := s.replay(-1)
+	if err != nil {
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Returns the index of the command with the given sequence number, and
+// whether it exists.
+func (s *jsonlStore) findCmd(seq int) (int, bool) {
+	i := sort.Search(len(s.cmds), func(i int) bool { return s.cmds[i].Seq >= seq })
+	return i, i < len(s.cmds) && s.cmds[i].Seq == seq
+}
+
+// Sequence numbers are compared as unsigned, so that a negative upper bound
+// means no bound, like in the bolt store.
+func inSeqRange(seq, from, upto int) bool {
+	return uint64(seq) >= uint64(from) && uint64(seq) < uint64(upto)
+}
+
+// NextCmdSeq returns the next sequence number of the command history.
+func (s *jsonlStore) NextCmdSeq() (int, error) {
+	if err := s.lockAndReplay(); err != nil {
+		return 0, err
+	}
+	defer s.mu.Unlock()
+	return s.nextSeq, nil
+}
+
+// AddCmd adds a new command to the command history.
+func (s *jsonlStore) AddCmd(cmd string) (int, error) {
+	return s.AddCmdWithMeta(Cmd{Text: cmd})
+}
+
+// AddCmdWithMeta adds a new command to the command history, along with its
+// metadata. The Seq field of the argument is ignored.
+func (s *jsonlStore) AddCmdWithMeta(cmd Cmd) (int, error) {
+	return s.AddCmds([]Cmd{cmd})
+}
+
+// AddCmds adds commands to the command history in one write, along with their
+// metadata. The commands get consecutive sequence numbers, and the sequence
+// number of the first one is returned. The Seq fields of the arguments are
+// ignored.
+func (s *jsonlStore) AddCmds(cmds []Cmd) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recs := make([]jsonlRecord, len(cmds))
+	for i, cmd := range cmds {
+		recs[i] = jsonlRecord{Op: opAddCmd,
+			Text: cmd.Text, Dir: cmd.Dir, Start: cmd.Start, Duration: cmd.Duration,
+			Status: cmd.Status, Host: cmd.Host, Session: cmd.Session}
+	}
+	err := s.append(recs...)
+	if err != nil {
+		return 0, err
+	}
+	// The records just appended are the last ones replayed.
+	return s.nextSeq - len(cmds), nil
+}
+
+// SetCmdResult records the duration and status of a command in the command
+// history.
+func (s *jsonlStore) SetCmdResult(seq int, duration float64, status string) error {
+	if err := s.lockAndReplay(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if _, ok := s.findCmd(seq); !ok {
+		return ErrNoMatchingCmd
+	}
+	return s.append(jsonlRecord{Op: opSetCmdResult,
+		Seq: seq, Duration: duration, Status: status})
+}
+
+// DelCmd deletes a command history item with the given sequence number.
+func (s *jsonlStore) DelCmd(seq int) error {
+	if err := s.lockAndReplay(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if _, ok := s.findCmd(seq); !ok {
+		return nil
+	}
+	return s.append(jsonlRecord{Op: opDelCmd, Seq: seq})
+}
+
+// Cmd queries the command history item with the specified sequence number.
+func (s *jsonlStore) Cmd(seq int) (string, error) {
+	if err := s.lockAndReplay(); err != nil {
+		return "", err
+	}
+	defer s.mu.Unlock()
+	i, ok := s.findCmd(seq)
+	if !ok {
+		return "", ErrNoMatchingCmd
+	}
+	return s.cmds[i].Text, nil
+}
+
+// CmdsWithSeq returns all commands within the specified range.
+func (s *jsonlStore) CmdsWithSeq(from, upto int) ([]Cmd, error) {
+	return s.QueryCmds(from, upto, CmdQuery{})
+}
+
+// QueryCmds returns all commands within the specified range that match the
+// query.
+func (s *jsonlStore) QueryCmds(from, upto int, q CmdQuery) ([]Cmd, error) {
+	if err := s.lockAndReplay(); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	var cmds []Cmd
+	for _, cmd := range s.cmds {
+		if inSeqRange(cmd.Seq, from, upto) && q.Match(cmd) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds, nil
+}
+
+// NextCmd finds the first command after the given sequence number (inclusive)
+// with the given prefix.
+func (s *jsonlStore) NextCmd(from int, prefix string) (Cmd, error) {
+	if err := s.lockAndReplay(); err != nil {
+		return Cmd{}, err
+	}
+	defer s.mu.Unlock()
+	for _, cmd := range s.cmds {
+		if inSeqRange(cmd.Seq, from, -1) && strings.HasPrefix(cmd.Text, prefix) {
+			return cmd, nil
+		}
+	}
+	return Cmd{}, ErrNoMatchingCmd
+}
+
+// PrevCmd finds the last command before the given sequence number (exclusive)
+// with the given prefix.
+func (s *jsonlStore) PrevCmd(upto int, prefix string) (Cmd, error) {
+	if err := s.lockAndReplay(); err != nil {
+		return Cmd{}, err
+	}
+	defer s.mu.Unlock()
+	for i := len(s.cmds) - 1; i >= 0; i-- {
+		cmd := s.cmds[i]
+		if inSeqRange(cmd.Seq, 0, upto) && strings.HasPrefix(cmd.Text, prefix) {
+			return cmd, nil
+		}
+	}
+	return Cmd{}, ErrNoMatchingCmd
+}
+
+// AddDir adds a directory to the directory history.
+func (s *jsonlStore) AddDir(d string, incFactor float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append(jsonlRecord{Op: opAddDir, Path: d, IncFactor: incFactor})
+}
+
+// DelDir deletes a directory record from history.
+func (s *jsonlStore) DelDir(d string) error {
+	if err := s.lockAndReplay(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if _, ok := s.dirs[d]; !ok {
+		return nil
+	}
+	return s.append(jsonlRecord{Op: opDelDir, Path: d})
+}
+
+// Dirs lists all directories in the directory history whose names are not
+// in the blacklist. The results are ordered by scores in descending order.
+func (s *jsonlStore) Dirs(blacklist map[string]struct{}) ([]Dir, error) {
+	if err := s.lockAndReplay(); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+	var dirs []Dir
+	for d, score := range s.dirs {
+		if _, ok := blacklist[d]; !ok {
+			dirs = append(dirs, Dir{Path: d, Score: score})
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].Score != dirs[j].Score {
+			return dirs[i].Score > dirs[j].Score
+		}
+		return dirs[i].Path < dirs[j].Path
+	})
+	return dirs, nil
+}
+
+// SharedVar gets the value of a shared variable.
+func (s *jsonlStore) SharedVar(n string) (string, error) {
+	if err := s.lockAndReplay(); err != nil {
+		return "", err
+	}
+	defer s.mu.Unlock()
+	v, ok := s.vars[n]
+	if !ok {
+		return "", ErrNoSharedVar
+	}
+	return v, nil
+}
+
+// SetSharedVar sets the value of a shared variable.
+func (s *jsonlStore) SetSharedVar(n, v string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.append(jsonlRecord{Op: opSetVar, Name: n, Value: v})
+}
+
+// DelSharedVar deletes a shared variable.
+func (s *jsonlStore) DelSharedVar(n string) error {
+	if err := s.lockAndReplay(); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if _, ok := s.vars[n]; !ok {
+		return nil
+	}
+	return s.append(jsonlRecord{Op: opDelVar, Name: n})
+}
+
+// CompareAndSwapSharedVar atomically sets the value of a shared variable to
+// new if its current value is old, and returns whether it did. A nil old
+// means that the variable doesn't exist, and a nil new deletes the variable.
+//
+// The comparison is recorded in the file along with the new value, and done
+// when the record is replayed, so that it is atomic even when other processes
+// append to the file concurrently.
+func (s *jsonlStore) CompareAndSwapSharedVar(n string, old, new *string) (bool, error) {
+	if err := s.lockAndReplay(); err != nil {
+		return false, err
+	}
+	defer s.mu.Unlock()
+	if v, exists := s.vars[n]; exists != (old != nil) || (exists && v != *old) {
+		// Fail early without writing a record that won't swap.
+		return false, nil
+	}
+	err := s.append(jsonlRecord{Op: opCASVar, Name: n, Old: old, New: new})
+	if err != nil {
+		return false, err
+	}
+	// The record just appended is the last one replayed.
+	return s.swapped, nil
+}
+
+// WatchSharedVar waits until the version of a shared variable is different
+// from the given one, or the timeout passes, and returns its current version.
+// See varWatchers for the meaning of versions. Changes made by other processes
+// are picked up by polling the file.
+func (s *jsonlStore) WatchSharedVar(n string, version int, timeout time.Duration) (int, error) {
+	return s.varWatchers.wait(n, version, timeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		err := s.replay(-1)
+		if err != nil {
+			logger.Printf("failed to read changes: %v", err)
+		}
+	}), nil
+}
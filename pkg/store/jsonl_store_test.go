@@ -0,0 +1,110 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestJSONLStore_SharedBetweenStores(t *testing.T) {
+	url := "jsonl://" + filepath.Join(t.TempDir(), "db")
+	st1 := mustNewStore(t, url)
+	defer st1.Close()
+	st2 := mustNewStore(t, url)
+	defer st2.Close()
+
+	seq1, _ := st1.AddCmd("echo 1")
+	seq2, _ := st2.AddCmd("echo 2")
+	seq3, _ := st1.AddCmd("echo 3")
+	if seq1 != 1 || seq2 != 2 || seq3 != 3 {
+		t.Errorf("got seqs %v %v %v, want 1 2 3", seq1, seq2, seq3)
+	}
+	st2.SetSharedVar("foo", "bar")
+
+	cmds, _ := st1.CmdsWithSeq(0, -1)
+	wantCmds := []storedefs.Cmd{
+		{Text: "echo 1", Seq: 1}, {Text: "echo 2", Seq: 2}, {Text: "echo 3", Seq: 3}}
+	if !reflect.DeepEqual(cmds, wantCmds) {
+		t.Errorf("got cmds %v, want %v", cmds, wantCmds)
+	}
+	if v, _ := st1.SharedVar("foo"); v != "bar" {
+		t.Errorf("got shared var %q, want %q", v, "bar")
+	}
+}
+
+func TestJSONLStore_SkipsMalformedAndTruncatedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	err := os.WriteFile(path, []byte(
+		`{"op":"add-cmd","text":"echo 1"}`+"\n"+
+			"not json\n"+
+			`{"op":"add-cmd","text":"echo 2"}`+"\n"+
+			`{"op":"add-cmd","te`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := mustNewStore(t, "jsonl://"+path)
+	defer st.Close()
+
+	cmds, _ := st.CmdsWithSeq(0, -1)
+	wantCmds := []storedefs.Cmd{{Text: "echo 1", Seq: 1}, {Text: "echo 2", Seq: 2}}
+	if !reflect.DeepEqual(cmds, wantCmds) {
+		t.Errorf("got cmds %v, want %v", cmds, wantCmds)
+	}
+
+	// The incomplete line is left by a crashed process, and must not swallow
+	// new records.
+	seq, err := st.AddCmd("echo 3")
+	if seq != 3 || err != nil {
+		t.Errorf("AddCmd -> (%v, %v), want (3, nil)", seq, err)
+	}
+	st.Close()
+	st = mustNewStore(t, "jsonl://"+path)
+	cmd, err := st.Cmd(3)
+	if cmd != "echo 3" || err != nil {
+		t.Errorf("Cmd(3) -> (%q, %v), want (%q, nil)", cmd, err, "echo 3")
+	}
+}
+
+func TestJSONLStore_CASRecordsAreConditional(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	// Records written by processes that raced to create and update the
+	// variable; only the first ones with a matching old value take effect.
+	err := os.WriteFile(path, []byte(
+		`{"op":"cas-var","name":"x","new":"a"}`+"\n"+
+			`{"op":"cas-var","name":"x","new":"b"}`+"\n"+
+			`{"op":"cas-var","name":"x","old":"b","new":"c"}`+"\n"+
+			`{"op":"cas-var","name":"x","old":"a","new":"d"}`+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := mustNewStore(t, "jsonl://"+path)
+	defer st.Close()
+
+	v, err := st.SharedVar("x")
+	if v != "d" || err != nil {
+		t.Errorf("SharedVar -> (%q, %v), want (%q, nil)", v, err, "d")
+	}
+}
+
+func TestJSONLStore_WatchSeesChangesByOtherStores(t *testing.T) {
+	url := "jsonl://" + filepath.Join(t.TempDir(), "db")
+	st1 := mustNewStore(t, url)
+	defer st1.Close()
+	st2 := mustNewStore(t, url)
+	defer st2.Close()
+
+	version, _ := st2.WatchSharedVar("x", -1, 0)
+	go func() {
+		time.Sleep(testutil.Scaled(10 * time.Millisecond))
+		st1.SetSharedVar("x", "a")
+	}()
+	v, err := st2.WatchSharedVar("x", version, testutil.Scaled(time.Second))
+	if v == version || err != nil {
+		t.Errorf("WatchSharedVar -> (%v, %v), want new version and nil", v, err)
+	}
+}
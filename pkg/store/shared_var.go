@@ -1,14 +1,13 @@
 package store
 
 import (
-	"errors"
+	"sync"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
+	. "src.elv.sh/pkg/store/storedefs"
 )
 
-// ErrNoSharedVar is returned by Store.SharedVar when there is no such variable.
-var ErrNoSharedVar = errors.New("no such shared variable")
-
 func init() {
 	initDB["initialize shared variable table"] = func(tx *bolt.Tx) error {
 		_, err := tx.CreateBucketIfNotExists([]byte(bucketSharedVar))
@@ -33,16 +32,117 @@ func (s *dbStore) SharedVar(n string) (string, error) {
 
 // SetSharedVar sets the value of a shared variable.
 func (s *dbStore) SetSharedVar(n, v string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketSharedVar))
 		return b.Put([]byte(n), []byte(v))
 	})
+	if err == nil {
+		s.varWatchers.notify(n)
+	}
+	return err
 }
 
 // DelSharedVar deletes a shared variable.
 func (s *dbStore) DelSharedVar(n string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	deleted := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketSharedVar))
+		if b.Get([]byte(n)) == nil {
+			return nil
+		}
+		deleted = true
 		return b.Delete([]byte(n))
 	})
+	if deleted && err == nil {
+		s.varWatchers.notify(n)
+	}
+	return err
+}
+
+// CompareAndSwapSharedVar atomically sets the value of a shared variable to
+// new if its current value is old, and returns whether it did. A nil old
+// means that the variable doesn't exist, and a nil new deletes the variable.
+func (s *dbStore) CompareAndSwapSharedVar(n string, old, new *string) (bool, error) {
+	swapped := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketSharedVar))
+		v := b.Get([]byte(n))
+		if (v == nil) != (old == nil) || (v != nil && string(v) != *old) {
+			return nil
+		}
+		swapped = true
+		if new == nil {
+			return b.Delete([]byte(n))
+		}
+		return b.Put([]byte(n), []byte(*new))
+	})
+	if err != nil {
+		return false, err
+	}
+	if swapped {
+		s.varWatchers.notify(n)
+	}
+	return swapped, nil
+}
+
+// WatchSharedVar waits until the version of a shared variable is different
+// from the given one, or the timeout passes, and returns its current version.
+// See varWatchers for the meaning of versions.
+func (s *dbStore) WatchSharedVar(n string, version int, timeout time.Duration) (int, error) {
+	return s.varWatchers.wait(n, version, timeout, nil), nil
+}
+
+// How often a store polls for changes made by other processes while waiting
+// for a shared variable to change.
+const varPollInterval = 100 * time.Millisecond
+
+// Keeps track of the versions of shared variables, and wakes up goroutines
+// waiting for them to change.
+//
+// Versions only live in memory: they all start from 0 when the store is
+// opened, and the version of a variable is incremented every time it is set
+// or deleted.
+type varWatchers struct {
+	mu       sync.Mutex
+	versions map[string]int
+	// Closed and replaced every time a variable changes.
+	changed chan struct{}
+}
+
+func newVarWatchers() *varWatchers {
+	return &varWatchers{versions: make(map[string]int), changed: make(chan struct{})}
+}
+
+func (w *varWatchers) notify(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.versions[name]++
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// Waits until the version of the variable is different from the given one, or
+// the timeout passes, and returns the current version. If poll is not nil, it
+// is called periodically to pick up changes made by other processes.
+func (w *varWatchers) wait(name string, version int, timeout time.Duration, poll func()) int {
+	deadline := time.After(timeout)
+	for {
+		w.mu.Lock()
+		current, changed := w.versions[name], w.changed
+		w.mu.Unlock()
+		if current != version {
+			return current
+		}
+		var pollCh <-chan time.Time
+		if poll != nil {
+			pollCh = time.After(varPollInterval)
+		}
+		select {
+		case <-changed:
+		case <-pollCh:
+			poll()
+		case <-deadline:
+			return current
+		}
+	}
 }
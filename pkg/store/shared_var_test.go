@@ -3,10 +3,17 @@ package store_test
 import (
 	"testing"
 
-	"src.elv.sh/pkg/store"
 	"src.elv.sh/pkg/store/storetest"
 )
 
 func TestSharedVar(t *testing.T) {
-	storetest.TestSharedVar(t, store.MustTempStore(t))
+	testBackends(t, storetest.TestSharedVar)
+}
+
+func TestSharedVarCAS(t *testing.T) {
+	testBackends(t, storetest.TestSharedVarCAS)
+}
+
+func TestSharedVarWatch(t *testing.T) {
+	testBackends(t, storetest.TestSharedVarWatch)
 }
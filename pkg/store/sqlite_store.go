@@ -0,0 +1,440 @@
+package store
+
+import (
+	"database/sql"
+	"net/url"
+	"sync"
+	"time"
+
+	// Registers the "sqlite" driver for database/sql.
+	_ "modernc.org/sqlite"
+	. "src.elv.sh/pkg/store/storedefs"
+)
+
+func init() {
+	RegisterBackend("sqlite", newSQLiteStore)
+}
+
+// A store backed by an SQLite database. Like the jsonl store, the database can
+// be shared by multiple processes without the daemon; SQLite takes care of
+// locking, and changes to shared variables made by other processes are picked
+// up by polling.
+type sqliteStore struct {
+	db *sql.DB
+
+	varWatchers *varWatchers
+	// Values of shared variables last seen, used to find out which variables
+	// have changed.
+	varsMu sync.Mutex
+	vars   map[string]string
+}
+
+var sqliteSchema = []string{
+	// AUTOINCREMENT keeps sequence numbers of deleted commands from being
+	// reused, like the sequence of a bolt bucket.
+	`CREATE TABLE IF NOT EXISTS cmd (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		text TEXT NOT NULL,
+		dir TEXT NOT NULL DEFAULT '',
+		start INTEGER NOT NULL DEFAULT 0,
+		duration REAL NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT '',
+		host TEXT NOT NULL DEFAULT '',
+		session TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS dir (
+		path TEXT PRIMARY KEY,
+		score REAL NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS shared_var (
+		name TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+}
+
+func newSQLiteStore(path string) (DBStore, error) {
+	// The path is escaped since it is put in a URI, where the query makes every
+	// connection wait for locks held by other processes instead of failing
+	// immediately.
+	uri := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", uri)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqliteStore{db: db, varWatchers: newVarWatchers()}
+	err = s.update(func(tx *sql.Tx) error {
+		for _, stmt := range sqliteSchema {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		s.vars, err = s.allSharedVars()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// Runs f in a transaction, which is committed if f returns nil and rolled
+// back otherwise.
+func (s *sqliteStore) update(f func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = f(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// The columns of the cmd table, in the order scanned by scanCmd.
+const sqliteCmdColumns = "seq, text, dir, start, duration, status, host, session"
+
+type sqliteRow interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCmd(row sqliteRow) (Cmd, error) {
+	var cmd Cmd
+	err := row.Scan(&cmd.Seq, &cmd.Text, &cmd.Dir, &cmd.Start, &cmd.Duration,
+		&cmd.Status, &cmd.Host, &cmd.Session)
+	if err == sql.ErrNoRows {
+		return Cmd{}, ErrNoMatchingCmd
+	}
+	return cmd, err
+}
+
+// NextCmdSeq returns the next sequence number of the command history.
+func (s *sqliteStore) NextCmdSeq() (int, error) {
+	var seq int
+	err := s.db.QueryRow(
+		`SELECT seq FROM sqlite_sequence WHERE name = 'cmd'`).Scan(&seq)
+	if err == sql.ErrNoRows {
+		// No command has ever been added.
+		return 1, nil
+	}
+	return seq + 1, err
+}
+
+// AddCmd adds a new command to the command history.
+func (s *sqliteStore) AddCmd(cmd string) (int, error) {
+	return s.AddCmdWithMeta(Cmd{Text: cmd})
+}
+
+// AddCmdWithMeta adds a new command to the command history, along with its
+// metadata. The Seq field of the argument is ignored.
+func (s *sqliteStore) AddCmdWithMeta(cmd Cmd) (int, error) {
+	return s.AddCmds([]Cmd{cmd})
+}
+
+// AddCmds adds commands to the command history in one transaction, along with
+// their metadata. The commands get consecutive sequence numbers, and the
+// sequence number of the first one is returned. The Seq fields of the
+// arguments are ignored.
+func (s *sqliteStore) AddCmds(cmds []Cmd) (int, error) {
+	var first int
+	err := s.update(func(tx *sql.Tx) error {
+		if len(cmds) == 0 {
+			return tx.QueryRow(`SELECT COALESCE(
+				(SELECT seq FROM sqlite_sequence WHERE name = 'cmd'), 0) + 1`).
+				Scan(&first)
+		}
+		stmt, err := tx.Prepare(`INSERT INTO cmd
+			(text, dir, start, duration, status, host, session)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for i, cmd := range cmds {
+			res, err := stmt.Exec(cmd.Text, cmd.Dir, cmd.Start, cmd.Duration,
+				cmd.Status, cmd.Host, cmd.Session)
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				seq, err := res.LastInsertId()
+				if err != nil {
+					return err
+				}
+				first = int(seq)
+			}
+		}
+		return nil
+	})
+	return first, err
+}
+
+// SetCmdResult records the duration and status of a command in the command
+// history.
+func (s *sqliteStore) SetCmdResult(seq int, duration float64, status string) error {
+	res, err := s.db.Exec(`UPDATE cmd SET duration = ?, status = ? WHERE seq = ?`,
+		duration, status, seq)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoMatchingCmd
+	}
+	return nil
+}
+
+// DelCmd deletes a command history item with the given sequence number.
+func (s *sqliteStore) DelCmd(seq int) error {
+	_, err := s.db.Exec(`DELETE FROM cmd WHERE seq = ?`, seq)
+	return err
+}
+
+// Cmd queries the command history item with the specified sequence number.
+func (s *sqliteStore) Cmd(seq int) (string, error) {
+	var text string
+	err := s.db.QueryRow(`SELECT text FROM cmd WHERE seq = ?`, seq).Scan(&text)
+	if err == sql.ErrNoRows {
+		return "", ErrNoMatchingCmd
+	}
+	return text, err
+}
+
+// Converts an upper bound of sequence numbers to one usable in SQL, where a
+// negative bound means no bound, like in the bolt store.
+func sqliteUpto(upto int) int64 {
+	if upto < 0 {
+		return 1<<63 - 1
+	}
+	return int64(upto)
+}
+
+// CmdsWithSeq returns all commands within the specified range.
+func (s *sqliteStore) CmdsWithSeq(from, upto int) ([]Cmd, error) {
+	return s.QueryCmds(from, upto, CmdQuery{})
+}
+
+// QueryCmds returns all commands within the specified range that match the
+// query.
+func (s *sqliteStore) QueryCmds(from, upto int, q CmdQuery) ([]Cmd, error) {
+	rows, err := s.db.Query(`SELECT `+sqliteCmdColumns+` FROM cmd
+		WHERE seq >= ? AND seq < ? ORDER BY seq`, from, sqliteUpto(upto))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cmds []Cmd
+	for rows.Next() {
+		cmd, err := scanCmd(rows)
+		if err != nil {
+			return nil, err
+		}
+		// Queries are matched in Go rather than in SQL, so that they have
+		// exactly the same semantics as in the other backends.
+		if q.Match(cmd) {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds, rows.Err()
+}
+
+// A condition that the text of a command starts with a prefix, which is
+// compared byte by byte. It takes the prefix as two arguments.
+const sqliteHasPrefix = `substr(CAST(text AS BLOB), 1, length(CAST(? AS BLOB))) = CAST(? AS BLOB)`
+
+// NextCmd finds the first command after the given sequence number (inclusive)
+// with the given prefix.
+func (s *sqliteStore) NextCmd(from int, prefix string) (Cmd, error) {
+	return scanCmd(s.db.QueryRow(`SELECT `+sqliteCmdColumns+` FROM cmd
+		WHERE seq >= ? AND `+sqliteHasPrefix+`
+		ORDER BY seq LIMIT 1`, from, prefix, prefix))
+}
+
+// PrevCmd finds the last command before the given sequence number (exclusive)
+// with the given prefix.
+func (s *sqliteStore) PrevCmd(upto int, prefix string) (Cmd, error) {
+	return scanCmd(s.db.QueryRow(`SELECT `+sqliteCmdColumns+` FROM cmd
+		WHERE seq < ? AND `+sqliteHasPrefix+`
+		ORDER BY seq DESC LIMIT 1`, sqliteUpto(upto), prefix, prefix))
+}
+
+// AddDir adds a directory to the directory history.
+func (s *sqliteStore) AddDir(d string, incFactor float64) error {
+	return s.update(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE dir SET score = score * ?`, DirScoreDecay)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO dir (path, score) VALUES (?, ?)
+			ON CONFLICT (path) DO UPDATE SET score = score + excluded.score`,
+			d, DirScoreIncrement*incFactor)
+		return err
+	})
+}
+
+// DelDir deletes a directory record from history.
+func (s *sqliteStore) DelDir(d string) error {
+	_, err := s.db.Exec(`DELETE FROM dir WHERE path = ?`, d)
+	return err
+}
+
+// Dirs lists all directories in the directory history whose names are not
+// in the blacklist. The results are ordered by scores in descending order.
+func (s *sqliteStore) Dirs(blacklist map[string]struct{}) ([]Dir, error) {
+	rows, err := s.db.Query(`SELECT path, score FROM dir
+		ORDER BY score DESC, path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dirs []Dir
+	for rows.Next() {
+		var d Dir
+		if err := rows.Scan(&d.Path, &d.Score); err != nil {
+			return nil, err
+		}
+		if _, ok := blacklist[d.Path]; !ok {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs, rows.Err()
+}
+
+// SharedVar gets the value of a shared variable.
+func (s *sqliteStore) SharedVar(n string) (string, error) {
+	var v string
+	err := s.db.QueryRow(`SELECT value FROM shared_var WHERE name = ?`, n).Scan(&v)
+	if err == sql.ErrNoRows {
+		return "", ErrNoSharedVar
+	}
+	return v, err
+}
+
+// SetSharedVar sets the value of a shared variable.
+func (s *sqliteStore) SetSharedVar(n, v string) error {
+	_, err := s.db.Exec(`INSERT INTO shared_var (name, value) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET value = excluded.value`, n, v)
+	if err == nil {
+		s.varChanged(n, &v)
+	}
+	return err
+}
+
+// DelSharedVar deletes a shared variable.
+func (s *sqliteStore) DelSharedVar(n string) error {
+	_, err := s.db.Exec(`DELETE FROM shared_var WHERE name = ?`, n)
+	if err == nil {
+		s.varChanged(n, nil)
+	}
+	return err
+}
+
+// CompareAndSwapSharedVar atomically sets the value of a shared variable to
+// new if its current value is old, and returns whether it did. A nil old
+// means that the variable doesn't exist, and a nil new deletes the variable.
+func (s *sqliteStore) CompareAndSwapSharedVar(n string, old, new *string) (bool, error) {
+	var res sql.Result
+	var err error
+	switch {
+	case old == nil && new == nil:
+		// Succeeds if the variable doesn't exist, without changing anything.
+		_, err = s.SharedVar(n)
+		if err == ErrNoSharedVar {
+			return true, nil
+		}
+		return false, err
+	case old == nil:
+		res, err = s.db.Exec(`INSERT INTO shared_var (name, value) VALUES (?, ?)
+			ON CONFLICT (name) DO NOTHING`, n, *new)
+	case new == nil:
+		res, err = s.db.Exec(`DELETE FROM shared_var
+			WHERE name = ? AND value = ?`, n, *old)
+	default:
+		res, err = s.db.Exec(`UPDATE shared_var SET value = ?
+			WHERE name = ? AND value = ?`, *new, n, *old)
+	}
+	if err != nil {
+		return false, err
+	}
+	// Each statement changes at most one row, and only does so if the current
+	// value matches old.
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	s.varChanged(n, new)
+	return true, nil
+}
+
+// WatchSharedVar waits until the version of a shared variable is different
+// from the given one, or the timeout passes, and returns its current version.
+// See varWatchers for the meaning of versions. Changes made by other processes
+// are picked up by polling the database.
+func (s *sqliteStore) WatchSharedVar(n string, version int, timeout time.Duration) (int, error) {
+	return s.varWatchers.wait(n, version, timeout, func() {
+		vars, err := s.allSharedVars()
+		if err != nil {
+			logger.Printf("failed to read changes: %v", err)
+			return
+		}
+		var deleted []string
+		s.varsMu.Lock()
+		for name := range s.vars {
+			if _, ok := vars[name]; !ok {
+				deleted = append(deleted, name)
+			}
+		}
+		s.varsMu.Unlock()
+		for _, name := range deleted {
+			s.varChanged(name, nil)
+		}
+		for name, value := range vars {
+			value := value
+			s.varChanged(name, &value)
+		}
+	}), nil
+}
+
+// Records the value of a shared variable, nil if it is deleted, and notifies
+// watchers if it is different from the value last seen.
+func (s *sqliteStore) varChanged(n string, v *string) {
+	s.varsMu.Lock()
+	old, existed := s.vars[n]
+	if v == nil {
+		delete(s.vars, n)
+	} else {
+		s.vars[n] = *v
+	}
+	s.varsMu.Unlock()
+	if existed != (v != nil) || (existed && old != *v) {
+		s.varWatchers.notify(n)
+	}
+}
+
+func (s *sqliteStore) allSharedVars() (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT name, value FROM shared_var`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vars := make(map[string]string)
+	for rows.Next() {
+		var n, v string
+		if err := rows.Scan(&n, &v); err != nil {
+			return nil, err
+		}
+		vars[n] = v
+	}
+	return vars, rows.Err()
+}
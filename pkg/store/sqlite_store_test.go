@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/testutil"
+)
+
+func TestSQLiteStore_SharedBetweenStores(t *testing.T) {
+	url := "sqlite://" + filepath.Join(t.TempDir(), "db")
+	st1 := mustNewStore(t, url)
+	defer st1.Close()
+	st2 := mustNewStore(t, url)
+	defer st2.Close()
+
+	seq1, _ := st1.AddCmd("echo 1")
+	seq2, _ := st2.AddCmd("echo 2")
+	seq3, _ := st1.AddCmd("echo 3")
+	if seq1 != 1 || seq2 != 2 || seq3 != 3 {
+		t.Errorf("got seqs %v %v %v, want 1 2 3", seq1, seq2, seq3)
+	}
+	st2.SetSharedVar("foo", "bar")
+
+	cmds, _ := st1.CmdsWithSeq(0, -1)
+	wantCmds := []storedefs.Cmd{
+		{Text: "echo 1", Seq: 1}, {Text: "echo 2", Seq: 2}, {Text: "echo 3", Seq: 3}}
+	if !reflect.DeepEqual(cmds, wantCmds) {
+		t.Errorf("got cmds %v, want %v", cmds, wantCmds)
+	}
+	if v, _ := st1.SharedVar("foo"); v != "bar" {
+		t.Errorf("got shared var %q, want %q", v, "bar")
+	}
+}
+
+func TestSQLiteStore_PathWithSpecialCharacters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db #1 %41")
+	st := mustNewStore(t, "sqlite://"+path)
+	defer st.Close()
+	st.AddCmd("echo")
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("database not created at %q: %v", path, err)
+	}
+}
+
+func TestSQLiteStore_PrefixIsCaseSensitive(t *testing.T) {
+	st := mustNewStore(t, "sqlite://"+filepath.Join(t.TempDir(), "db"))
+	defer st.Close()
+	st.AddCmd("Echo foo")
+	st.AddCmd("echo_bar")
+
+	cmd, err := st.NextCmd(0, "echo")
+	if cmd.Text != "echo_bar" || err != nil {
+		t.Errorf("NextCmd -> (%v, %v), want echo_bar", cmd, err)
+	}
+	// "%" and "_" have no special meaning.
+	_, err = st.PrevCmd(-1, "echo%")
+	if err != storedefs.ErrNoMatchingCmd {
+		t.Errorf("PrevCmd -> error %v, want %v", err, storedefs.ErrNoMatchingCmd)
+	}
+}
+
+func TestSQLiteStore_WatchSeesChangesByOtherStores(t *testing.T) {
+	url := "sqlite://" + filepath.Join(t.TempDir(), "db")
+	st1 := mustNewStore(t, url)
+	defer st1.Close()
+	st2 := mustNewStore(t, url)
+	defer st2.Close()
+
+	version, _ := st2.WatchSharedVar("x", -1, 0)
+	go func() {
+		time.Sleep(testutil.Scaled(10 * time.Millisecond))
+		st1.SetSharedVar("x", "a")
+	}()
+	v, err := st2.WatchSharedVar("x", version, testutil.Scaled(time.Second))
+	if v == version || err != nil {
+		t.Errorf("WatchSharedVar -> (%v, %v), want new version and nil", v, err)
+	}
+}
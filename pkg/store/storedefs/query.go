@@ -0,0 +1,152 @@
+package storedefs
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CmdQuery specifies conditions on the metadata of command history entries.
+// Fields with zero values impose no conditions.
+type CmdQuery struct {
+	// Only match commands run in this directory or its subdirectories. Must be
+	// an absolute path.
+	Dir string
+	// Only match commands with this status. Besides the values of Cmd.Status,
+	// "exit" and "signal" match any exit and signal status respectively, and
+	// "failed" matches any known status other than StatusOK.
+	Status string
+	// Only match commands started at or after this time, in seconds since the
+	// Unix epoch.
+	Since int
+	// Only match commands started before this time, in seconds since the Unix
+	// epoch.
+	Before int
+	// Only match commands run in this session.
+	Session string
+}
+
+// CmdQueryKeys are the keys accepted by CmdQuery.Set, in the order of the
+// fields they set.
+var CmdQueryKeys = []string{"cwd", "status", "since", "before", "session"}
+
+// Set parses the value of a condition specified by a key in CmdQueryKeys and
+// sets the corresponding field of the query. Relative paths and times are
+// resolved against the working directory and the given time.
+func (q *CmdQuery) Set(key, value string, now time.Time) error {
+	switch key {
+	case "cwd":
+		dir, err := filepath.Abs(value)
+		if err != nil {
+			return err
+		}
+		q.Dir = dir
+	case "status":
+		if code, err := strconv.Atoi(value); err == nil {
+			value = ExitStatus(code)
+		}
+		q.Status = value
+	case "since", "before":
+		t, err := ParseTime(value, now)
+		if err != nil {
+			return err
+		}
+		if key == "since" {
+			q.Since = t
+		} else {
+			q.Before = t
+		}
+	case "session":
+		q.Session = value
+	default:
+		return fmt.Errorf("unknown query key %s", key)
+	}
+	return nil
+}
+
+// Match returns whether the command satisfies all the conditions of the query.
+func (q CmdQuery) Match(cmd Cmd) bool {
+	return matchDir(q.Dir, cmd.Dir) &&
+		matchStatus(q.Status, cmd.Status) &&
+		(q.Since == 0 || (cmd.Start != 0 && cmd.Start >= q.Since)) &&
+		(q.Before == 0 || (cmd.Start != 0 && cmd.Start < q.Before)) &&
+		(q.Session == "" || cmd.Session == q.Session)
+}
+
+func matchDir(pattern, dir string) bool {
+	if pattern == "" || dir == pattern {
+		return true
+	}
+	if !strings.HasSuffix(pattern, string(filepath.Separator)) {
+		pattern += string(filepath.Separator)
+	}
+	return strings.HasPrefix(dir, pattern)
+}
+
+func matchStatus(pattern, status string) bool {
+	switch pattern {
+	case "":
+		return true
+	case "failed":
+		return status != "" && status != StatusOK
+	case "exit", "signal":
+		return strings.HasPrefix(status, pattern+" ")
+	default:
+		return status == pattern
+	}
+}
+
+var relativeTimePattern = regexp.MustCompile(`^(\d+)([smhdw])$`)
+
+var relativeTimeUnits = map[string]time.Duration{
+	"s": time.Second, "m": time.Minute, "h": time.Hour,
+	"d": 24 * time.Hour, "w": 7 * 24 * time.Hour,
+}
+
+var timeLayouts = []string{
+	"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339,
+}
+
+// ParseTime parses a time used in a CmdQuery, and returns it in seconds since
+// the Unix epoch. The following forms are supported:
+//
+//   - An integer, which is already in seconds since the Unix epoch.
+//
+//   - "now", "today" or "yesterday"; the latter two mean the start of the day.
+//
+//   - A number followed by one of the units s, m, h, d and w, meaning that
+//     long before now. For example, "3d" means 3 days ago.
+//
+//   - A date like 2006-01-02, optionally followed by a time like T15:04 or
+//     T15:04:05, in local time.
+//
+//   - An RFC 3339 time like 2006-01-02T15:04:05+08:00.
+func ParseTime(s string, now time.Time) (int, error) {
+	if t, err := strconv.Atoi(s); err == nil {
+		return t, nil
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch s {
+	case "now":
+		return int(now.Unix()), nil
+	case "today":
+		return int(today.Unix()), nil
+	case "yesterday":
+		return int(today.AddDate(0, 0, -1).Unix()), nil
+	}
+	if m := relativeTimePattern.FindStringSubmatch(s); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			d := time.Duration(n) * relativeTimeUnits[m[2]]
+			return int(now.Add(-d).Unix()), nil
+		}
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return int(t.Unix()), nil
+		}
+	}
+	return 0, fmt.Errorf("bad time: %q", s)
+}
@@ -0,0 +1,53 @@
+package storedefs
+
+import (
+	"testing"
+	"time"
+
+	"src.elv.sh/pkg/tt"
+)
+
+var now = time.Date(2021, 10, 15, 12, 30, 0, 0, time.UTC)
+
+func TestParseTime(t *testing.T) {
+	parseTime := func(s string) (int, bool) {
+		t, err := ParseTime(s, now)
+		return t, err == nil
+	}
+	tt.Test(t, tt.Fn("ParseTime", parseTime), tt.Table{
+		tt.Args("1634300000").Rets(1634300000, true),
+		tt.Args("now").Rets(unix(2021, 10, 15, 12, 30, 0), true),
+		tt.Args("today").Rets(unix(2021, 10, 15, 0, 0, 0), true),
+		tt.Args("yesterday").Rets(unix(2021, 10, 14, 0, 0, 0), true),
+		tt.Args("30m").Rets(unix(2021, 10, 15, 12, 0, 0), true),
+		tt.Args("2d").Rets(unix(2021, 10, 13, 12, 30, 0), true),
+		tt.Args("1w").Rets(unix(2021, 10, 8, 12, 30, 0), true),
+		tt.Args("2021-10-01").Rets(unix(2021, 10, 1, 0, 0, 0), true),
+		tt.Args("2021-10-01T08:15").Rets(unix(2021, 10, 1, 8, 15, 0), true),
+		tt.Args("2021-10-01T08:15:30").Rets(unix(2021, 10, 1, 8, 15, 30), true),
+		tt.Args("2021-10-01T08:15:30+01:00").Rets(unix(2021, 10, 1, 7, 15, 30), true),
+		tt.Args("3y").Rets(0, false),
+		tt.Args("bad").Rets(0, false),
+	})
+}
+
+func TestCmdQuery_Set(t *testing.T) {
+	set := func(key, value string) (CmdQuery, bool) {
+		var q CmdQuery
+		err := q.Set(key, value, now)
+		return q, err == nil
+	}
+	tt.Test(t, tt.Fn("Set", set), tt.Table{
+		tt.Args("status", "failed").Rets(CmdQuery{Status: "failed"}, true),
+		tt.Args("status", "1").Rets(CmdQuery{Status: "exit 1"}, true),
+		tt.Args("since", "today").Rets(CmdQuery{Since: unix(2021, 10, 15, 0, 0, 0)}, true),
+		tt.Args("before", "1").Rets(CmdQuery{Before: 1}, true),
+		tt.Args("session", "s").Rets(CmdQuery{Session: "s"}, true),
+		tt.Args("since", "bad").Rets(CmdQuery{}, false),
+		tt.Args("bad", "x").Rets(CmdQuery{}, false),
+	})
+}
+
+func unix(y int, m time.Month, d, h, min, s int) int {
+	return int(time.Date(y, m, d, h, min, s, 0, time.UTC).Unix())
+}
@@ -4,7 +4,11 @@
 // does not need to depend on the concrete implementation.
 package storedefs
 
-import "errors"
+import (
+	"errors"
+	"strconv"
+	"time"
+)
 
 // NoBlacklist is an empty blacklist, to be used in GetDirs.
 var NoBlacklist = map[string]struct{}{}
@@ -13,13 +17,20 @@ var NoBlacklist = map[string]struct{}{}
 // completes with no result.
 var ErrNoMatchingCmd = errors.New("no matching command line")
 
+// ErrNoSharedVar is returned by Store.SharedVar when there is no such variable.
+var ErrNoSharedVar = errors.New("no such shared variable")
+
 // Store is an interface satisfied by the storage service.
 type Store interface {
 	NextCmdSeq() (int, error)
 	AddCmd(text string) (int, error)
+	AddCmdWithMeta(cmd Cmd) (int, error)
+	AddCmds(cmds []Cmd) (int, error)
+	SetCmdResult(seq int, duration float64, status string) error
 	DelCmd(seq int) error
 	Cmd(seq int) (string, error)
 	CmdsWithSeq(from, upto int) ([]Cmd, error)
+	QueryCmds(from, upto int, q CmdQuery) ([]Cmd, error)
 	NextCmd(from int, prefix string) (Cmd, error)
 	PrevCmd(upto int, prefix string) (Cmd, error)
 
@@ -30,6 +41,8 @@ type Store interface {
 	SharedVar(name string) (string, error)
 	SetSharedVar(name, value string) error
 	DelSharedVar(name string) error
+	CompareAndSwapSharedVar(name string, old, new *string) (bool, error)
+	WatchSharedVar(name string, version int, timeout time.Duration) (int, error)
 }
 
 // Dir is an entry in the directory history.
@@ -41,9 +54,47 @@ type Dir struct {
 func (Dir) IsStructMap() {}
 
 // Cmd is an entry in the command history.
+//
+// All fields other than Text and Seq are metadata; their zero values mean that
+// the metadata is unknown, which is the case for commands added before the
+// metadata was recorded, or whose execution has not finished yet.
 type Cmd struct {
 	Text string
 	Seq  int
+	// Working directory when the command was run.
+	Dir string
+	// Time when the command was run, in seconds since the Unix epoch.
+	Start int
+	// How long the command ran, in seconds.
+	Duration float64
+	// Outcome of the command; see the Status* constants and ExitStatus.
+	Status string
+	// Hostname of the machine the command was run on.
+	Host string
+	// ID of the interactive session the command was run in.
+	Session string
 }
 
 func (Cmd) IsStructMap() {}
+
+// Values of Cmd.Status other than those returned by ExitStatus.
+const (
+	// The command finished without an exception.
+	StatusOK = "ok"
+	// The command threw an exception from the fail command.
+	StatusFail = "fail"
+	// The command threw any other exception.
+	StatusError = "error"
+)
+
+// ExitStatus returns the value of Cmd.Status for a command terminated by an
+// external command exiting with the given non-zero exit code.
+func ExitStatus(code int) string {
+	return "exit " + strconv.Itoa(code)
+}
+
+// SignalStatus returns the value of Cmd.Status for a command terminated by an
+// external command killed by the named signal.
+func SignalStatus(name string) string {
+	return "signal " + name
+}
@@ -1,6 +1,7 @@
 package storetest
 
 import (
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -109,3 +110,146 @@ func TestCmd(t *testing.T, store storedefs.Store) {
 func equalCmds(a, b []storedefs.Cmd) bool {
 	return (len(a) == 0 && len(b) == 0) || reflect.DeepEqual(a, b)
 }
+
+// TestCmdMeta tests the command metadata functionality of a Store.
+func TestCmdMeta(t *testing.T, store storedefs.Store) {
+	cmd := storedefs.Cmd{Text: "make test", Dir: "/src", Start: 1634300000,
+		Host: "box", Session: "1-1634290000"}
+	seq, err := store.AddCmdWithMeta(cmd)
+	if err != nil {
+		t.Errorf("store.AddCmdWithMeta(%v) => error %v", cmd, err)
+	}
+	cmd.Seq = seq
+	testCmdsEqual(t, store, seq, cmd)
+
+	err = store.SetCmdResult(seq, 1.5, storedefs.ExitStatus(2))
+	if err != nil {
+		t.Errorf("store.SetCmdResult(%v, ...) => error %v", seq, err)
+	}
+	cmd.Duration, cmd.Status = 1.5, "exit 2"
+	testCmdsEqual(t, store, seq, cmd)
+
+	// Commands added with AddCmd have no metadata until the result is set.
+	seq2, _ := store.AddCmd("echo")
+	testCmdsEqual(t, store, seq2, storedefs.Cmd{Text: "echo", Seq: seq2})
+	store.SetCmdResult(seq2, 0.5, storedefs.StatusOK)
+	testCmdsEqual(t, store, seq2,
+		storedefs.Cmd{Text: "echo", Seq: seq2, Duration: 0.5, Status: "ok"})
+
+	err = store.SetCmdResult(seq2+1, 1, storedefs.StatusOK)
+	if !matchErr(err, storedefs.ErrNoMatchingCmd) {
+		t.Errorf("store.SetCmdResult(%v, ...) => error %v, want %v",
+			seq2+1, err, storedefs.ErrNoMatchingCmd)
+	}
+
+	// Deleting a command also deletes its metadata.
+	store.DelCmd(seq)
+	cmds, err := store.CmdsWithSeq(seq, seq+1)
+	if len(cmds) != 0 || err != nil {
+		t.Errorf("store.CmdsWithSeq(%v, %v) => (%v, %v), want (nil, nil)",
+			seq, seq+1, cmds, err)
+	}
+}
+
+// TestAddCmds tests adding multiple commands at once to a Store.
+func TestAddCmds(t *testing.T, store storedefs.Store) {
+	cmds := []storedefs.Cmd{
+		{Text: "make", Dir: "/src", Start: 100, Status: "ok"},
+		{Text: "ls", Start: 200},
+		{Text: "echo"},
+	}
+	wantSeq, _ := store.NextCmdSeq()
+	seq, err := store.AddCmds(cmds)
+	if seq != wantSeq || err != nil {
+		t.Errorf("store.AddCmds(%v) => (%v, %v), want (%v, nil)",
+			cmds, seq, err, wantSeq)
+	}
+	for i := range cmds {
+		cmds[i].Seq = seq + i
+	}
+	got, err := store.CmdsWithSeq(seq, -1)
+	if !equalCmds(got, cmds) || err != nil {
+		t.Errorf("store.CmdsWithSeq(%v, -1) => (%v, %v), want (%v, nil)",
+			seq, got, err, cmds)
+	}
+
+	// Adding no commands is a no-op that returns the next sequence number.
+	wantSeq, _ = store.NextCmdSeq()
+	seq, err = store.AddCmds(nil)
+	if seq != wantSeq || err != nil {
+		t.Errorf("store.AddCmds(nil) => (%v, %v), want (%v, nil)",
+			seq, err, wantSeq)
+	}
+	if nextSeq, _ := store.NextCmdSeq(); nextSeq != wantSeq {
+		t.Errorf("store.NextCmdSeq() => %v after store.AddCmds(nil), want %v",
+			nextSeq, wantSeq)
+	}
+}
+
+// Tests that CmdsWithSeq, NextCmd and PrevCmd all return the wanted command,
+// including its metadata.
+func testCmdsEqual(t *testing.T, store storedefs.Store, seq int, want storedefs.Cmd) {
+	t.Helper()
+	cmds, err := store.CmdsWithSeq(seq, seq+1)
+	if !equalCmds(cmds, []storedefs.Cmd{want}) || err != nil {
+		t.Errorf("store.CmdsWithSeq(%v, %v) => (%v, %v), want (%v, nil)",
+			seq, seq+1, cmds, err, []storedefs.Cmd{want})
+	}
+	cmd, err := store.NextCmd(seq, want.Text)
+	if cmd != want || err != nil {
+		t.Errorf("store.NextCmd(%v, %q) => (%v, %v), want (%v, nil)",
+			seq, want.Text, cmd, err, want)
+	}
+	cmd, err = store.PrevCmd(seq+1, want.Text)
+	if cmd != want || err != nil {
+		t.Errorf("store.PrevCmd(%v, %q) => (%v, %v), want (%v, nil)",
+			seq+1, want.Text, cmd, err, want)
+	}
+}
+
+// TestCmdQuery tests the command query functionality of a Store.
+func TestCmdQuery(t *testing.T, store storedefs.Store) {
+	src, srcA := filepath.FromSlash("/src"), filepath.FromSlash("/src/a")
+	cmds := []storedefs.Cmd{
+		{Text: "make", Dir: src, Status: "exit 2", Start: 100, Session: "s1"},
+		{Text: "ls", Dir: srcA, Status: "ok", Start: 200, Session: "s1"},
+		{Text: "ls", Dir: filepath.FromSlash("/srcb"), Status: "fail", Start: 300, Session: "s2"},
+		{Text: "echo"},
+	}
+	startSeq, _ := store.NextCmdSeq()
+	for i := range cmds {
+		cmds[i].Seq, _ = store.AddCmdWithMeta(cmds[i])
+	}
+	endSeq, _ := store.NextCmdSeq()
+
+	queries := []struct {
+		q    storedefs.CmdQuery
+		want []storedefs.Cmd
+	}{
+		{storedefs.CmdQuery{}, cmds},
+		{storedefs.CmdQuery{Dir: src}, cmds[:2]},
+		{storedefs.CmdQuery{Dir: srcA}, cmds[1:2]},
+		{storedefs.CmdQuery{Status: "failed"}, []storedefs.Cmd{cmds[0], cmds[2]}},
+		{storedefs.CmdQuery{Status: "exit"}, cmds[:1]},
+		{storedefs.CmdQuery{Status: "ok"}, cmds[1:2]},
+		{storedefs.CmdQuery{Since: 200}, cmds[1:3]},
+		{storedefs.CmdQuery{Before: 300}, cmds[:2]},
+		{storedefs.CmdQuery{Since: 150, Before: 250}, cmds[1:2]},
+		{storedefs.CmdQuery{Session: "s2"}, cmds[2:3]},
+		{storedefs.CmdQuery{Dir: src, Status: "ok"}, cmds[1:2]},
+		{storedefs.CmdQuery{Session: "s3"}, nil},
+	}
+	for _, test := range queries {
+		got, err := store.QueryCmds(startSeq, endSeq, test.q)
+		if !equalCmds(got, test.want) || err != nil {
+			t.Errorf("store.QueryCmds(%v, %v, %v) => (%v, %v), want (%v, nil)",
+				startSeq, endSeq, test.q, got, err, test.want)
+		}
+	}
+
+	got, err := store.QueryCmds(startSeq+1, endSeq, storedefs.CmdQuery{Dir: src})
+	if !equalCmds(got, cmds[1:2]) || err != nil {
+		t.Errorf("store.QueryCmds(%v, %v, ...) => (%v, %v), want (%v, nil)",
+			startSeq+1, endSeq, got, err, cmds[1:2])
+	}
+}
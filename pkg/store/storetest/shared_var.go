@@ -1,10 +1,12 @@
 package storetest
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
-	"src.elv.sh/pkg/store"
 	"src.elv.sh/pkg/store/storedefs"
+	"src.elv.sh/pkg/testutil"
 )
 
 // TestSharedVar tests the shared variable functionality of a Store.
@@ -15,7 +17,7 @@ func TestSharedVar(t *testing.T, tStore storedefs.Store) {
 
 	// Getting an nonexistent variable should return ErrNoSharedVar.
 	_, err := tStore.SharedVar(varname)
-	if !matchErr(err, store.ErrNoSharedVar) {
+	if !matchErr(err, storedefs.ErrNoSharedVar) {
 		t.Error("want ErrNoSharedVar, got", err)
 	}
 
@@ -45,7 +47,108 @@ func TestSharedVar(t *testing.T, tStore storedefs.Store) {
 		t.Error("want no error, got", err)
 	}
 	_, err = tStore.SharedVar(varname)
-	if !matchErr(err, store.ErrNoSharedVar) {
+	if !matchErr(err, storedefs.ErrNoSharedVar) {
 		t.Error("want ErrNoSharedVar, got", err)
 	}
 }
+
+// TestSharedVarCAS tests the compare-and-swap operation on shared variables of
+// a Store.
+func TestSharedVarCAS(t *testing.T, tStore storedefs.Store) {
+	name := "foo"
+	empty, value1, value2 := "", "lorem ipsum", "o mores, o tempora"
+
+	cas := func(old, new *string, wantSwapped bool) {
+		t.Helper()
+		swapped, err := tStore.CompareAndSwapSharedVar(name, old, new)
+		if swapped != wantSwapped || err != nil {
+			t.Errorf("CompareAndSwapSharedVar(%v, %v) -> (%v, %v), want (%v, nil)",
+				show(old), show(new), swapped, err, wantSwapped)
+		}
+	}
+	wantValue := func(want *string) {
+		t.Helper()
+		v, err := tStore.SharedVar(name)
+		if want == nil && !matchErr(err, storedefs.ErrNoSharedVar) {
+			t.Errorf("want ErrNoSharedVar, got %q and %v", v, err)
+		} else if want != nil && (v != *want || err != nil) {
+			t.Errorf("want %q and no error, got %q and %v", *want, v, err)
+		}
+	}
+
+	// Creating a variable only succeeds when it doesn't exist.
+	cas(&value1, &value2, false)
+	wantValue(nil)
+	cas(nil, &empty, true)
+	wantValue(&empty)
+	cas(nil, &value1, false)
+	wantValue(&empty)
+
+	// Setting a variable only succeeds when the old value matches; the empty
+	// value is distinct from a missing variable.
+	cas(&value1, &value2, false)
+	cas(&empty, &value1, true)
+	wantValue(&value1)
+
+	// Deleting a variable only succeeds when the old value matches.
+	cas(&value2, nil, false)
+	wantValue(&value1)
+	cas(&value1, nil, true)
+	wantValue(nil)
+}
+
+// TestSharedVarWatch tests watching changes to shared variables of a Store.
+func TestSharedVarWatch(t *testing.T, tStore storedefs.Store) {
+	name := "foo"
+
+	// Watching with a version different from the current one returns at once.
+	version, err := tStore.WatchSharedVar(name, -1, time.Hour)
+	if err != nil {
+		t.Fatalf("WatchSharedVar -> error %v", err)
+	}
+
+	// Watching with the current version times out without changes.
+	v, err := tStore.WatchSharedVar(name, version, time.Millisecond)
+	if v != version || err != nil {
+		t.Errorf("WatchSharedVar without changes -> (%v, %v), want (%v, nil)",
+			v, err, version)
+	}
+
+	// Watching with the current version returns after a change.
+	value1, value2 := "lorem", "ipsum"
+	for _, change := range []func() error{
+		func() error { return tStore.SetSharedVar(name, value1) },
+		func() error { _, err := tStore.CompareAndSwapSharedVar(name, &value1, &value2); return err },
+		func() error { return tStore.DelSharedVar(name) },
+	} {
+		errCh := make(chan error, 1)
+		go func() {
+			time.Sleep(testutil.Scaled(10 * time.Millisecond))
+			errCh <- change()
+		}()
+		v, err := tStore.WatchSharedVar(name, version, time.Hour)
+		if v == version || err != nil {
+			t.Errorf("WatchSharedVar after change -> (%v, %v), want new version and nil", v, err)
+		}
+		version = v
+		if err := <-errCh; err != nil {
+			t.Errorf("changing shared var -> error %v", err)
+		}
+	}
+
+	// Operations that don't change the variable don't change the version.
+	tStore.DelSharedVar(name)
+	tStore.CompareAndSwapSharedVar(name, &value1, &value2)
+	v, err = tStore.WatchSharedVar(name, version, time.Millisecond)
+	if v != version || err != nil {
+		t.Errorf("WatchSharedVar after no-op changes -> (%v, %v), want (%v, nil)",
+			v, err, version)
+	}
+}
+
+func show(p *string) string {
+	if p == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%q", *p)
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -37,3 +38,24 @@ func MustTempStore(c testutil.Cleanuper) DBStore {
 	})
 	return st
 }
+
+// MustTempStoreWithBackend is like MustTempStore, but uses the backend
+// registered under the given URL scheme.
+func MustTempStoreWithBackend(c testutil.Cleanuper, scheme string) DBStore {
+	dir, err := os.MkdirTemp("", "elvish.test")
+	if err != nil {
+		panic(fmt.Sprintf("create temp dir: %v", err))
+	}
+	st, err := NewStore(scheme + "://" + filepath.Join(dir, "db"))
+	if err != nil {
+		panic(fmt.Sprintf("create Store instance: %v", err))
+	}
+	c.Cleanup(func() {
+		st.Close()
+		err = os.RemoveAll(dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to remove temp dir:", err)
+		}
+	})
+	return st
+}
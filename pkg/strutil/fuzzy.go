@@ -0,0 +1,237 @@
+package strutil
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scores used by FuzzyMatch. The scheme is modelled after the one used by fzf,
+// which is in turn a variant of the Smith-Waterman algorithm.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	// Bonus for a match after whitespace or at the start of the text.
+	bonusBoundaryWhite = scoreMatch/2 + 2
+	// Bonus for a match after a delimiter like "/" or ":".
+	bonusBoundaryDelimiter = scoreMatch/2 + 1
+	// Bonus for a match after any other non-word character, and for matching
+	// non-word characters themselves.
+	bonusNonWord = scoreMatch / 2
+	// Bonus for a match at a camelCase or letter-to-digit boundary.
+	bonusCamel123 = bonusNonWord - 1
+	// Minimal bonus for a match that immediately follows another match. A run
+	// of consecutive matches also gets at least the bonus of its first match.
+	bonusConsecutive = -(scoreGapStart + scoreGapExtension)
+	// The bonus of the first rune of the pattern is multiplied by this.
+	bonusFirstCharMultiplier = 2
+)
+
+type charClass int
+
+const (
+	classWhite charClass = iota
+	classDelimiter
+	classNonWord
+	classLower
+	classUpper
+	classLetter
+	classNumber
+)
+
+func classOf(r rune) charClass {
+	switch {
+	case unicode.IsSpace(r):
+		return classWhite
+	case strings.ContainsRune("/,:;|", r):
+		return classDelimiter
+	case unicode.IsLower(r):
+		return classLower
+	case unicode.IsUpper(r):
+		return classUpper
+	case unicode.IsLetter(r):
+		return classLetter
+	case unicode.IsNumber(r):
+		return classNumber
+	default:
+		return classNonWord
+	}
+}
+
+func bonusFor(prev, cur charClass) int {
+	if cur >= classLower {
+		switch {
+		case prev == classWhite:
+			return bonusBoundaryWhite
+		case prev == classDelimiter:
+			return bonusBoundaryDelimiter
+		case prev == classNonWord:
+			return bonusNonWord
+		case prev == classLower && cur == classUpper,
+			prev != classNumber && cur == classNumber:
+			return bonusCamel123
+		}
+		return 0
+	}
+	if cur == classWhite {
+		return bonusBoundaryWhite
+	}
+	return bonusNonWord
+}
+
+// Used as the score of impossible alignments; small enough to never win, but
+// large enough to not overflow when penalties are added.
+const scoreImpossible = -1 << 30
+
+// FuzzyMatch determines whether text contains all the runes in pattern in the
+// same order, and scores the best such alignment. Higher scores indicate
+// better matches: matches at word boundaries and consecutive matches are
+// rewarded, while gaps between matches are penalized.
+//
+// If there is a match, FuzzyMatch also returns the byte indices of the runes in
+// text that are matched. If ignoreCase is true, runes are compared after being
+// converted to lower case.
+//
+// FuzzyMatch doesn't allocate when text doesn't match. Otherwise it uses memory
+// proportional to the length of text, plus the number of pairs of runes in text
+// and pattern that are equal.
+func FuzzyMatch(text, pattern string, ignoreCase bool) (score int, positions []int, ok bool) {
+	if pattern == "" {
+		return 0, nil, true
+	}
+	if !hasSubseqFold(text, pattern, ignoreCase) {
+		return 0, nil, false
+	}
+
+	var (
+		runes   []rune
+		indices []int
+		bonuses []int
+	)
+	prevClass := classWhite
+	for i, r := range text {
+		class := classOf(r)
+		if ignoreCase {
+			r = unicode.ToLower(r)
+		}
+		runes = append(runes, r)
+		indices = append(indices, i)
+		bonuses = append(bonuses, bonusFor(prevClass, class))
+		prevClass = class
+	}
+	pat := []rune(pattern)
+	if ignoreCase {
+		for j, r := range pat {
+			pat[j] = unicode.ToLower(r)
+		}
+	}
+	n, m := len(runes), len(pat)
+
+	// The rows h and carried are for pat[j], and prevH and prevCarried for
+	// pat[j-1]. h[i] is the best score of matching pat[:j+1] against
+	// runes[:i+1], with pat[j] matched to runes[i], and carried[i] is the
+	// bonus carried by the run of consecutive matches ending at runes[i].
+	//
+	// For backtracking, the position pat[j-1] is matched to in each of those
+	// alignments is recorded in matches; the matches for pat[j] are
+	// matches[rowStarts[j]:rowStarts[j+1]], sorted by position.
+	h, prevH := make([]int, n), make([]int, n)
+	carried, prevCarried := make([]int, n), make([]int, n)
+	var matches []fuzzyMatchCell
+	rowStarts := make([]int, m+1)
+	for i := range h {
+		h[i] = scoreImpossible
+	}
+	for i, r := range runes {
+		if r == pat[0] {
+			h[i] = scoreMatch + bonuses[i]*bonusFirstCharMultiplier
+			carried[i] = bonuses[i]
+			matches = append(matches, fuzzyMatchCell{i, -1})
+		}
+	}
+	for j := 1; j < m; j++ {
+		rowStarts[j] = len(matches)
+		h, prevH = prevH, h
+		carried, prevCarried = prevCarried, carried
+		for i := range h {
+			h[i] = scoreImpossible
+		}
+		// Best score of an alignment of pat[:j] that leaves a gap before the
+		// current position, and where it ends.
+		gapScore, gapFrom := scoreImpossible, -1
+		for i := 1; i < n; i++ {
+			if i >= 2 {
+				if extended := gapScore + scoreGapExtension; extended >= prevH[i-2]+scoreGapStart {
+					gapScore = extended
+				} else {
+					gapScore, gapFrom = prevH[i-2]+scoreGapStart, i-2
+				}
+			}
+			if runes[i] != pat[j] {
+				continue
+			}
+			consecutiveBonus := maxInt(bonuses[i], prevCarried[i-1], bonusConsecutive)
+			best, bestFrom, bonus := prevH[i-1]+consecutiveBonus, i-1, consecutiveBonus
+			if gapScore+bonuses[i] > best {
+				best, bestFrom, bonus = gapScore+bonuses[i], gapFrom, bonuses[i]
+			}
+			if best > scoreImpossible/2 {
+				h[i], carried[i] = best+scoreMatch, bonus
+				matches = append(matches, fuzzyMatchCell{i, bestFrom})
+			}
+		}
+	}
+	rowStarts[m] = len(matches)
+
+	end := -1
+	for i := 0; i < n; i++ {
+		if h[i] > scoreImpossible/2 && (end == -1 || h[i] > h[end]) {
+			end = i
+		}
+	}
+	if end == -1 {
+		return 0, nil, false
+	}
+	positions = make([]int, m)
+	for j, i := m-1, end; j >= 0; j-- {
+		positions[j] = indices[i]
+		row := matches[rowStarts[j]:rowStarts[j+1]]
+		k := sort.Search(len(row), func(k int) bool { return row[k].pos >= i })
+		i = row[k].from
+	}
+	return h[end], positions, true
+}
+
+// A rune in text that a rune in pattern is matched to in the best alignment
+// ending there, and the position the previous rune in pattern is matched to.
+type fuzzyMatchCell struct{ pos, from int }
+
+// Like HasSubseq, but optionally compares runes after converting them to lower
+// case, without allocating.
+func hasSubseqFold(s, t string, ignoreCase bool) bool {
+	for _, r := range s {
+		if t == "" {
+			return true
+		}
+		first, size := utf8.DecodeRuneInString(t)
+		if ignoreCase {
+			r, first = unicode.ToLower(r), unicode.ToLower(first)
+		}
+		if r == first {
+			t = t[size:]
+		}
+	}
+	return t == ""
+}
+
+func maxInt(a int, rest ...int) int {
+	for _, b := range rest {
+		if b > a {
+			a = b
+		}
+	}
+	return a
+}
@@ -0,0 +1,72 @@
+package strutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+var fuzzyMatchTests = []struct {
+	text, pattern string
+	ignoreCase    bool
+	wantOK        bool
+	wantPositions []int
+}{
+	{"", "", false, true, nil},
+	{"foo", "", false, true, nil},
+	{"foo", "foo", false, true, []int{0, 1, 2}},
+	{"foobar", "fb", false, true, []int{0, 3}},
+	{"foo bar", "ob", false, true, []int{2, 4}},
+	{"foo", "of", false, false, nil},
+	{"Foo", "f", false, false, nil},
+	{"Foo", "f", true, true, []int{0}},
+	{"FOO", "Fo", true, true, []int{0, 1}},
+	// Prefers matches at word boundaries.
+	{"xbar bar", "bar", false, true, []int{5, 6, 7}},
+	{"a/b/file", "f", false, true, []int{4}},
+	// Prefers consecutive matches.
+	{"b-a-r bar", "bar", false, true, []int{6, 7, 8}},
+	// Byte indices of multi-byte runes.
+	{"你好世界", "好界", false, true, []int{3, 9}},
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	for _, test := range fuzzyMatchTests {
+		_, positions, ok := FuzzyMatch(test.text, test.pattern, test.ignoreCase)
+		if ok != test.wantOK || !reflect.DeepEqual(positions, test.wantPositions) {
+			t.Errorf("FuzzyMatch(%q, %q, %v) -> %v, %v, want %v, %v",
+				test.text, test.pattern, test.ignoreCase,
+				positions, ok, test.wantPositions, test.wantOK)
+		}
+	}
+}
+
+var fuzzyRankTests = []struct {
+	pattern, better, worse string
+}{
+	{"fb", "foo-bar", "fxxbxx"},
+	{"fb", "foo/bar", "foobar"},
+	{"bar", "bar", "b-a-r"},
+	{"ls", "ls -l", "false"},
+	{"gc", "git commit", "logic"},
+	{"fb", "FooBar", "Foobar"},
+}
+
+func TestFuzzyMatch_Ranking(t *testing.T) {
+	for _, test := range fuzzyRankTests {
+		better, _, ok1 := FuzzyMatch(test.better, test.pattern, true)
+		worse, _, ok2 := FuzzyMatch(test.worse, test.pattern, true)
+		if !ok1 || !ok2 || better <= worse {
+			t.Errorf("want %q to score higher than %q for %q, got %v, %v",
+				test.better, test.worse, test.pattern, better, worse)
+		}
+	}
+}
+
+func TestFuzzyMatch_NoAllocationWithoutMatch(t *testing.T) {
+	allocs := testing.AllocsPerRun(10, func() {
+		FuzzyMatch("some/long/path/to/a/File.go", "Fx", true)
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations, want 0", allocs)
+	}
+}
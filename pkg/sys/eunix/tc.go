@@ -11,3 +11,8 @@ import (
 func Tcsetpgrp(fd int, pid int) error {
 	return unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, pid)
 }
+
+// Tcgetpgrp gets the terminal foreground process group.
+func Tcgetpgrp(fd int) (int, error) {
+	return unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+}
@@ -16,11 +16,13 @@ func notifySignals() chan os.Signal {
 	// Calling signal.Notify will reset the signal ignore status, so we need to
 	// call signal.Ignore every time we call signal.Notify.
 	//
-	// TODO: Remove this if, and when, job control is implemented. This
-	// handles the case of running an external command from an interactive
-	// prompt.
+	// This handles the case of running an external command from an
+	// interactive prompt. See https://b.elv.sh/988.
 	//
-	// See https://b.elv.sh/988.
-	signal.Ignore(syscall.SIGTTIN, syscall.SIGTTOU, syscall.SIGTSTP)
+	// SIGTSTP is caught rather than ignored, so that it doesn't stop Elvish,
+	// but still has its default disposition in child processes; this allows
+	// foreground external commands to be stopped with Ctrl-Z and put in the
+	// job table.
+	signal.Ignore(syscall.SIGTTIN, syscall.SIGTTOU)
 	return sigCh
 }
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"src.elv.sh/pkg/cli/term"
+	"src.elv.sh/pkg/diag"
+	"src.elv.sh/pkg/edit"
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/eval/vals"
+	"src.elv.sh/pkg/parse"
+	"src.elv.sh/pkg/ui"
+)
+
+// Event is a single item in the response of the /execute endpoint. The
+// response is a stream of events encoded as JSON, one per line.
+type Event struct {
+	// One of "value", "bytes" and "exception".
+	Type string `json:"type"`
+	// For "value" and "bytes" events, the port the output was written to: 1
+	// for stdout and 2 for stderr.
+	Port int `json:"port,omitempty"`
+	// For "value" events, the representation of the value; for "bytes"
+	// events, the bytes written.
+	Text string `json:"text,omitempty"`
+	// For "exception" events, the error rendered as HTML.
+	HTML string `json:"html,omitempty"`
+}
+
+// Size of the buffer used to read byte outputs. Byte outputs are streamed in
+// chunks of at most this size.
+const bytesChunkSize = 4096
+
+// Width used when rendering exceptions. Lines are not expected to be wrapped
+// by the backend, so this is just a large number.
+const exceptionRenderWidth = 1 << 16
+
+func (h handler) serveExecute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.checkExecuteRequest(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	code, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "cannot read request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	ew := newEventWriter(w)
+
+	ports := []*eval.Port{eval.DummyInputPort, nil, nil}
+	var dones []func()
+	waitOutputs := func() {
+		for _, done := range dones {
+			done()
+		}
+	}
+	for i := 1; i <= 2; i++ {
+		port, done, err := outputPort(ew, i)
+		if err != nil {
+			waitOutputs()
+			ew.write(Event{Type: "exception", HTML: errorToHTML(err)})
+			return
+		}
+		ports[i] = port
+		dones = append(dones, done)
+	}
+
+	err = h.ev.Eval(
+		parse.Source{Name: "[web]", Code: string(code)},
+		eval.EvalCfg{
+			Ports: ports,
+			Interrupt: func() (<-chan struct{}, func()) {
+				return r.Context().Done(), func() {}
+			}})
+	// Wait for all outputs to be relayed before writing the exception.
+	waitOutputs()
+	if err != nil {
+		ew.write(Event{Type: "exception", HTML: errorToHTML(err)})
+	}
+}
+
+// Returns a port whose outputs are relayed to the eventWriter.
+func outputPort(ew *eventWriter, i int) (*eval.Port, func(), error) {
+	return eval.PipePort(
+		func(ch <-chan interface{}) {
+			for v := range ch {
+				ew.write(Event{Type: "value", Port: i, Text: vals.Repr(v, vals.NoPretty)})
+			}
+		},
+		func(r *os.File) {
+			buf := make([]byte, bytesChunkSize)
+			for {
+				n, err := r.Read(buf)
+				if n > 0 {
+					ew.write(Event{Type: "bytes", Port: i, Text: string(buf[:n])})
+				}
+				if err != nil {
+					if err != io.EOF {
+						logger.Println("error on reading:", err)
+					}
+					return
+				}
+			}
+		})
+}
+
+// Renders an error to HTML, using the same styling classes as the ttyshot
+// feature of the editor.
+func errorToHTML(err error) string {
+	var text string
+	if shower, ok := err.(diag.Shower); ok {
+		text = shower.Show("")
+	} else {
+		text = err.Error()
+	}
+	buf := term.NewBufferBuilder(exceptionRenderWidth).
+		WriteStyled(ui.ParseSGREscapedText(text)).Buffer()
+	return edit.BufToHTML(buf)
+}
+
+// Serializes writing of events from multiple goroutines, flushing after each
+// event.
+type eventWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	flusher http.Flusher
+}
+
+func newEventWriter(w http.ResponseWriter) *eventWriter {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	flusher, _ := w.(http.Flusher)
+	return &eventWriter{encoder: encoder, flusher: flusher}
+}
+
+func (ew *eventWriter) write(e Event) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	err := ew.encoder.Encode(e)
+	if err != nil {
+		logger.Println("cannot write event:", err)
+		return
+	}
+	if ew.flusher != nil {
+		ew.flusher.Flush()
+	}
+}
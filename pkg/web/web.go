@@ -0,0 +1,148 @@
+// Package web is the entry point for the backend of the web interface of
+// Elvish.
+//
+// The web interface serves a single HTML page, from which code can be
+// submitted to be evaluated in an Evaler shared by all clients. Outputs of the
+// code are streamed back to the client as they are produced.
+package web
+
+import (
+	"bytes"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"src.elv.sh/pkg/eval"
+	"src.elv.sh/pkg/logutil"
+	"src.elv.sh/pkg/prog"
+	"src.elv.sh/pkg/shell"
+)
+
+var logger = logutil.GetLogger("[web] ")
+
+//go:embed web.html
+var mainPageHTML []byte
+
+// Program is the web subprogram.
+var Program prog.Program = program{}
+
+type program struct{}
+
+func (program) Run(fds [3]*os.File, f *prog.Flags, args []string) error {
+	if !f.Web {
+		return prog.ErrNotSuitable
+	}
+	if len(args) > 0 {
+		return prog.BadUsage("arguments are not allowed with -web")
+	}
+	if f.CodeInArg {
+		return prog.BadUsage("-c cannot be used together with -web")
+	}
+
+	ev := shell.MakeEvaler(fds[2])
+	addr := fmt.Sprintf("localhost:%d", f.Port)
+	logger.Println("going to listen", addr)
+	fmt.Fprintf(fds[2], "Serving web interface on http://%s\n", addr)
+	return http.ListenAndServe(addr, NewHandler(ev))
+}
+
+// Placeholder in web.html that is replaced with the token of the handler.
+var tokenPlaceholder = []byte("{{TOKEN}}")
+
+// Name of the header in which requests to /execute must carry the token. Being
+// a custom header, it also forces browsers to send a CORS preflight request for
+// cross-origin requests, which the server never approves.
+const tokenHeader = "X-Elvish-Token"
+
+// NewHandler returns an http.Handler that serves the web interface, evaluating
+// code in the given Evaler.
+//
+// Since the code can do anything the user can, the handler only accepts
+// requests addressed to a loopback host, and requests to /execute must
+// additionally come from the same origin and carry a token embedded in the main
+// page. This stops other web pages open in the browser from running code,
+// either directly or via DNS rebinding.
+func NewHandler(ev *eval.Evaler) http.Handler {
+	token := newToken()
+	h := handler{ev, token,
+		bytes.ReplaceAll(mainPageHTML, tokenPlaceholder, []byte(token))}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", h.serveMainPage)
+	mux.HandleFunc("/execute", h.serveExecute)
+	return checkHost(mux)
+}
+
+type handler struct {
+	ev       *eval.Evaler
+	token    string
+	mainPage []byte
+}
+
+func newToken() string {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Rejects requests whose Host header doesn't name a loopback host and the port
+// the request was received on.
+func checkHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isServedHost(r) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isServedHost(r *http.Request) bool {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return false
+	}
+	localAddr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return false
+	}
+	_, localPort, err := net.SplitHostPort(localAddr.String())
+	if err != nil || port != localPort {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Checks that a request to /execute comes from the main page: the Origin
+// header, if present, must name the host being served, and the token header
+// must match.
+func (h handler) checkExecuteRequest(r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if origin != "http://"+r.Host {
+			return false
+		}
+	}
+	return r.Header.Get(tokenHeader) == h.token
+}
+
+func (h handler) serveMainPage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := w.Write(h.mainPage)
+	if err != nil {
+		logger.Println("cannot write response:", err)
+	}
+}
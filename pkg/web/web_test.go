@@ -0,0 +1,234 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"src.elv.sh/pkg/eval"
+	. "src.elv.sh/pkg/prog/progtest"
+)
+
+func TestProgram_BadUsage(t *testing.T) {
+	Test(t, Program,
+		ThatElvish("-web", "a").
+			ExitsWith(2).
+			WritesStderrContaining("arguments are not allowed with -web"),
+		ThatElvish("-web", "-c").
+			ExitsWith(2).
+			WritesStderrContaining("-c cannot be used together with -web"),
+		ThatElvish().
+			ExitsWith(2).
+			WritesStderr("internal error: no suitable subprogram\n"),
+	)
+}
+
+func TestHandler_MainPage(t *testing.T) {
+	server := httptest.NewServer(NewHandler(eval.NewEvaler()))
+	defer server.Close()
+
+	body, resp := get(t, server.URL+"/")
+	if !strings.Contains(body, "<textarea id=\"code\"") {
+		t.Errorf("main page doesn't contain code input")
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("got Content-Type %q, want text/html", ct)
+	}
+
+	_, resp = get(t, server.URL+"/bad")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got status %v for bad path, want 404", resp.StatusCode)
+	}
+}
+
+var executeTests = []struct {
+	name string
+	code string
+	want string
+}{
+	{"value output", "put foo", `{"type":"value","port":1,"text":"foo"}` + "\n"},
+	{"byte output", "echo foo", `{"type":"bytes","port":1,"text":"foo\n"}` + "\n"},
+	{"stderr", "echo foo >&2", `{"type":"bytes","port":2,"text":"foo\n"}` + "\n"},
+	{"exception", "fail foo",
+		`{"type":"exception","html":"Exception: <span class=\"sgr-1 sgr-31\">foo</span>\n` +
+			`[web], line 1: <span class=\"sgr-1 sgr-4\">fail foo</span>\n"}` + "\n"},
+}
+
+func TestHandler_Execute(t *testing.T) {
+	server := httptest.NewServer(NewHandler(eval.NewEvaler()))
+	defer server.Close()
+
+	for _, test := range executeTests {
+		t.Run(test.name, func(t *testing.T) {
+			body := post(t, server.URL, test.code)
+			if body != test.want {
+				t.Errorf("got response %s, want %s", body, test.want)
+			}
+		})
+	}
+}
+
+func TestHandler_Execute_SharesEvaler(t *testing.T) {
+	server := httptest.NewServer(NewHandler(eval.NewEvaler()))
+	defer server.Close()
+
+	post(t, server.URL, "var x = foo")
+	body := post(t, server.URL, "put $x")
+	want := `{"type":"value","port":1,"text":"foo"}` + "\n"
+	if body != want {
+		t.Errorf("got response %s, want %s", body, want)
+	}
+}
+
+func TestHandler_Execute_RejectsGet(t *testing.T) {
+	server := httptest.NewServer(NewHandler(eval.NewEvaler()))
+	defer server.Close()
+
+	_, resp := get(t, server.URL+"/execute")
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("got status %v, want 405", resp.StatusCode)
+	}
+}
+
+func TestHandler_Execute_RejectsForbiddenRequests(t *testing.T) {
+	server := httptest.NewServer(NewHandler(eval.NewEvaler()))
+	defer server.Close()
+	token := getToken(t, server.URL)
+	port := server.URL[strings.LastIndexByte(server.URL, ':')+1:]
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		host    string
+	}{
+		{name: "no token"},
+		{name: "wrong token",
+			headers: map[string]string{tokenHeader: "bad"}},
+		{name: "cross-origin",
+			headers: map[string]string{tokenHeader: token, "Origin": "http://evil.com"}},
+		{name: "other port as origin",
+			headers: map[string]string{tokenHeader: token, "Origin": "http://localhost:1"}},
+		{name: "wrong host",
+			headers: map[string]string{tokenHeader: token}, host: "evil.com:" + port},
+		{name: "wrong port in host",
+			headers: map[string]string{tokenHeader: token}, host: "localhost:1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := newExecuteRequest(t, server.URL, "var x = bad")
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			if test.host != "" {
+				req.Host = test.host
+			}
+			_, resp := do(t, req)
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("got status %v, want 403", resp.StatusCode)
+			}
+		})
+	}
+
+	// Make sure that none of the requests above were executed.
+	body := post(t, server.URL, "put $x")
+	if !strings.Contains(body, "exception") {
+		t.Errorf("got response %s, want exception", body)
+	}
+}
+
+func TestHandler_Execute_AcceptsSameOrigin(t *testing.T) {
+	server := httptest.NewServer(NewHandler(eval.NewEvaler()))
+	defer server.Close()
+	port := server.URL[strings.LastIndexByte(server.URL, ':')+1:]
+
+	req := newExecuteRequest(t, server.URL, "put foo")
+	req.Host = "localhost:" + port
+	req.Header.Set("Origin", "http://localhost:"+port)
+	req.Header.Set(tokenHeader, getToken(t, server.URL))
+	body, resp := do(t, req)
+	want := `{"type":"value","port":1,"text":"foo"}` + "\n"
+	if resp.StatusCode != http.StatusOK || body != want {
+		t.Errorf("got status %v and response %s, want 200 and %s",
+			resp.StatusCode, body, want)
+	}
+}
+
+func TestHandler_MainPage_RejectsWrongHost(t *testing.T) {
+	server := httptest.NewServer(NewHandler(eval.NewEvaler()))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "evil.com"
+	_, resp := do(t, req)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("got status %v, want 403", resp.StatusCode)
+	}
+}
+
+var tokenPattern = regexp.MustCompile(`<meta name="elvish-token" content="([0-9a-f]+)">`)
+
+// Extracts the token from the main page.
+func getToken(t *testing.T, url string) string {
+	t.Helper()
+	body, _ := get(t, url+"/")
+	m := tokenPattern.FindStringSubmatch(body)
+	if m == nil {
+		t.Fatal("main page doesn't contain token")
+	}
+	return m[1]
+}
+
+func get(t *testing.T, url string) (string, *http.Response) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	return readAll(t, resp.Body), resp
+}
+
+// Posts code to the /execute endpoint of the server, the same way the main page
+// does.
+func post(t *testing.T, url, code string) string {
+	t.Helper()
+	req := newExecuteRequest(t, url, code)
+	req.Header.Set(tokenHeader, getToken(t, url))
+	body, _ := do(t, req)
+	return body
+}
+
+func newExecuteRequest(t *testing.T, url, code string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url+"/execute", strings.NewReader(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	return req
+}
+
+func do(t *testing.T, req *http.Request) (string, *http.Response) {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	return readAll(t, resp.Body), resp
+}
+
+func readAll(t *testing.T, r io.Reader) string {
+	t.Helper()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}